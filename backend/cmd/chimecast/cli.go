@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/config"
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/repositories"
+	"github.com/legendary-acp/chimecast/internal/service"
+	"github.com/legendary-acp/chimecast/internal/session"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// cli is what the management commands share. They go through the same
+// services as the admin API, straight against the configured database.
+// Running servers notice changes to accounts on the next request (see
+// AuthService.CheckSession); sockets they hold are only reached when the
+// instances share a Redis bus.
+type cli struct {
+	ctx            context.Context // carries AuditActorCLI as the acting user
+	bus            bus.Bus
+	auth           *service.AuthService
+	rooms          *service.RoomService
+	admin          *service.AdminService
+	webhooks       *service.WebhookService
+	roomRepository *repositories.RoomRepository // room housekeeping the services don't do
+	closers        []func() error
+}
+
+func openCLI(cfg *config.Config) *cli {
+	database, err := db.Open(cfg.DBDriver, cfg.DatabaseURL)
+	if err != nil {
+		die(fmt.Errorf("unable to open database: %w", err))
+	}
+	c := &cli{
+		ctx:     context.WithValue(context.Background(), "userID", models.AuditActorCLI),
+		closers: []func() error{database.Close},
+	}
+
+	authRepository := repositories.NewAuthRepository(database)
+	roomRepository := repositories.NewRoomRepository(database)
+	c.roomRepository = roomRepository
+
+	audit, closeAudit, err := newAuditService(cfg, repositories.NewAuditRepository(database))
+	if err != nil {
+		die(fmt.Errorf("unable to open audit log file: %w", err))
+	}
+	c.closers = append(c.closers, closeAudit)
+
+	c.bus, err = newBus(cfg)
+	if err != nil {
+		die(fmt.Errorf("unable to initiate room bus: %w", err))
+	}
+	c.closers = append(c.closers, c.bus.Close)
+
+	c.auth = &service.AuthService{
+		AuthRepository: authRepository,
+		SessionManager: session.NewSessionManager(cfg.SessionTTL),
+		Audit:          audit,
+	}
+	c.rooms, err = service.NewRoomService(roomRepository, authRepository, repositories.NewAttendanceRepository(database), c.bus, cfg.MessageRateLimits)
+	if err != nil {
+		die(err)
+	}
+	c.rooms.Audit = audit
+	c.webhooks = service.NewWebhookService(repositories.NewWebhookRepository(database), roomRepository, service.WebhookSettings{
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		RetryBase:    cfg.WebhookRetryBase,
+		Timeout:      cfg.WebhookTimeout,
+		AllowPrivate: cfg.WebhookAllowPrivate,
+	})
+	c.rooms.Webhooks = c.webhooks
+	c.admin = service.NewAdminService(c.auth, c.rooms)
+	return c
+}
+
+// close queues deliveries for any webhook events raised, for a server to
+// send, and releases the database and bus
+func (c *cli) close() {
+	c.webhooks.Flush()
+	for i := len(c.closers) - 1; i >= 0; i-- {
+		if err := c.closers[i](); err != nil {
+			fmt.Fprintln(os.Stderr, "chimecast:", err)
+		}
+	}
+}
+
+// user looks up an account that hasn't been erased by its username
+func (c *cli) user(username string) *models.User {
+	user, err := c.auth.AuthRepository.GetUserByUsername(username)
+	if err == nil && user.DeletedAt != nil {
+		err = utils.ErrUserNotFound
+	}
+	if errors.Is(err, utils.ErrUserNotFound) {
+		die(fmt.Errorf("no user named %q", username))
+	}
+	if err != nil {
+		die(err)
+	}
+	return user
+}
+
+// parseArgs parses a command's flags, which may come before or after its
+// arguments, and returns the arguments. Anything but exactly want arguments
+// prints the command's usage and exits.
+func parseArgs(fs *flag.FlagSet, args []string, want int) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != want {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return positional
+}
+
+// newFlagSet returns the flags of a command, whose usage line is usage
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chimecast %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// unknownSubcommand reports a subcommand that doesn't exist and exits
+func unknownSubcommand(command string, args []string, subcommands string) {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "chimecast: unknown command %q\n", command+" "+args[0])
+	}
+	fmt.Fprintf(os.Stderr, "Usage: chimecast %s %s\n", command, subcommands)
+	os.Exit(2)
+}
+
+// die reports a failed command and exits
+func die(err error) {
+	fmt.Fprintln(os.Stderr, "chimecast:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+
+	"github.com/legendary-acp/chimecast/internal/config"
+	"github.com/legendary-acp/chimecast/internal/db"
+)
+
+const dbSubcommands = "backup"
+
+// migrate brings the schema up to date, which opening the database does
+func migrate(cfg *config.Config) {
+	database, err := db.Open(cfg.DBDriver, cfg.DatabaseURL)
+	if err != nil {
+		die(fmt.Errorf("unable to migrate database: %w", err))
+	}
+	if err := database.Close(); err != nil {
+		die(err)
+	}
+	fmt.Println("Database schema is up to date")
+}
+
+func dbCommand(cfg *config.Config, args []string) {
+	if len(args) == 0 || args[0] != "backup" {
+		unknownSubcommand("db", args, dbSubcommands)
+	}
+	backup(cfg, args[1:])
+}
+
+// backup writes a consistent copy of the database while the server keeps
+// running: a SQLite file made with VACUUM INTO, or a pg_dump custom-format
+// archive for PostgreSQL, which needs pg_dump on the PATH
+func backup(cfg *config.Config, args []string) {
+	fs := newFlagSet("db backup", "<path>")
+	path := parseArgs(fs, args, 1)[0]
+
+	if _, err := os.Stat(path); err == nil {
+		die(fmt.Errorf("%s already exists", path))
+	}
+
+	switch cfg.DBDriver {
+	case db.Postgres:
+		dbname, password, err := pgDumpConnection(cfg.DatabaseURL)
+		if err != nil {
+			die(err)
+		}
+		cmd := exec.Command("pg_dump", "--format=custom", "--file="+path, "--dbname="+dbname)
+		// The password goes in the environment, where other users can't read
+		// it from the process list
+		cmd.Env = os.Environ()
+		if password != "" {
+			cmd.Env = append(cmd.Env, "PGPASSWORD="+password)
+		}
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			die(fmt.Errorf("pg_dump failed: %w", err))
+		}
+	default:
+		database, err := db.Open(cfg.DBDriver, cfg.DatabaseURL)
+		if err != nil {
+			die(fmt.Errorf("unable to open database: %w", err))
+		}
+		defer database.Close()
+		if _, err := database.Exec("VACUUM INTO ?", path); err != nil {
+			die(fmt.Errorf("backup failed: %w", err))
+		}
+	}
+	fmt.Printf("Database backed up to %s\n", path)
+}
+
+// pgDumpConnection splits the password out of a PostgreSQL connection URL,
+// leaving the rest (host, database, sslmode and so on) for --dbname
+func pgDumpConnection(databaseURL string) (string, string, error) {
+	u, err := url.Parse(databaseURL)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return "", "", errors.New("backups need CHIMECAST_DATABASE_URL to be a postgres:// URL")
+	}
+
+	var password string
+	if u.User != nil {
+		password, _ = u.User.Password()
+		u.User = url.User(u.User.Username())
+	}
+	query := u.Query()
+	if query.Has("password") {
+		password = query.Get("password")
+		query.Del("password")
+		u.RawQuery = query.Encode()
+	}
+	return u.String(), password, nil
+}
@@ -1,54 +1,329 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/legendary-acp/chimecast/internal/api"
+	"github.com/legendary-acp/chimecast/internal/api/handler"
+	"github.com/legendary-acp/chimecast/internal/avatar"
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/config"
 	"github.com/legendary-acp/chimecast/internal/constants"
 	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/logging"
+	"github.com/legendary-acp/chimecast/internal/mailer"
+	"github.com/legendary-acp/chimecast/internal/metrics"
 	"github.com/legendary-acp/chimecast/internal/middleware"
+	"github.com/legendary-acp/chimecast/internal/origin"
 	"github.com/legendary-acp/chimecast/internal/repositories"
 	"github.com/legendary-acp/chimecast/internal/service"
 	"github.com/legendary-acp/chimecast/internal/session"
 )
 
+// version is the release this binary was built from, set at build time with
+// -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+// sessionSweepInterval is how often expired sessions are dropped from memory
+const sessionSweepInterval = time.Minute
+
+const usageText = `Usage: chimecast [command] [arguments]
+
+Commands:
+  serve                                  run the server (the default)
+  migrate                                bring the database schema up to date
+  user create                            create an account
+  user disable|enable <username>         disable or re-enable an account
+  user reset-password <username>         set a new password
+  user promote-admin <username>          grant (or with -revoke, remove) the admin role
+  room list                              list rooms
+  room end <roomID>                      end a room
+  room purge-inactive                    delete rooms that have long been unused
+  session revoke <username>              sign a user out everywhere
+  db backup <path>                       write a copy of the database to path
+  version                                print build information
+
+Every command but version reads the same environment as the server and works
+directly against its database. Run "chimecast <command> -h" for a command's
+options.
+`
+
 func main() {
-	db, err := db.CreateDB()
-	if err != nil {
-		log.Fatalln("Unable to Initiate DB")
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usageText) }
+	flag.Parse()
+
+	command, args := "serve", flag.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	if command == "version" {
+		printVersion(os.Stdout)
 		return
 	}
 
-	sessionManager := session.NewSessionManager()
+	cfg := config.Load()
+
+	if _, err := logging.Setup(os.Stderr, cfg.LogLevel, cfg.LogFormat); err != nil {
+		log.Fatalln("Unable to initiate logging:", err)
+	}
+
+	switch command {
+	case "serve":
+		serve(cfg)
+	case "migrate":
+		migrate(cfg)
+	case "user":
+		userCommand(cfg, args)
+	case "room":
+		roomCommand(cfg, args)
+	case "session":
+		sessionCommand(cfg, args)
+	case "db":
+		dbCommand(cfg, args)
+	default:
+		fmt.Fprintf(os.Stderr, "chimecast: unknown command %q\n\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// serve runs the server until it is interrupted
+func serve(cfg *config.Config) {
+	database, err := db.Open(cfg.DBDriver, cfg.DatabaseURL)
+	if err != nil {
+		fatal("Unable to initiate DB", err)
+	}
+
+	sessionManager := session.NewSessionManager(cfg.SessionTTL)
+
+	authRepository := repositories.NewAuthRepository(database)
+	roomRepository := repositories.NewRoomRepository(database)
+	loginAttemptRepository := repositories.NewLoginAttemptRepository(database)
+	tokenRepository := repositories.NewTokenRepository(database)
+	apiTokenRepository := repositories.NewAPITokenRepository(database)
+	attendanceRepository := repositories.NewAttendanceRepository(database)
+	auditRepository := repositories.NewAuditRepository(database)
+	webhookRepository := repositories.NewWebhookRepository(database)
+
+	mail, err := newMailer(cfg)
+	if err != nil {
+		fatal("Unable to initiate mailer", err)
+	}
+
+	auditService, closeAudit, err := newAuditService(cfg, auditRepository)
+	if err != nil {
+		fatal("Unable to open audit log file", err)
+	}
+	defer closeAudit()
+
+	authService := service.NewAuthService(authRepository, loginAttemptRepository, tokenRepository, apiTokenRepository, sessionManager, mail, service.LoginLimits{
+		Window:           cfg.LoginWindow,
+		PerIP:            cfg.LoginIPLimit,
+		PerUsername:      cfg.LoginUserLimit,
+		LockoutThreshold: cfg.LockoutThreshold,
+		LockoutBase:      cfg.LockoutBase,
+		LockoutMax:       cfg.LockoutMax,
+	}, cfg.PublicURL)
+	authService.RequireVerifiedEmail = cfg.RequireVerifiedEmail
+	authService.RequireMFA = cfg.RequireMFA
+	authService.Audit = auditService
+	if cfg.OIDCIssuer != "" {
+		authService.OIDC = service.NewOIDCProvider(service.OIDCConfig{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+			LinkByEmail:  cfg.OIDCLinkByEmail,
+		})
+	}
+	authService.Avatars, err = avatar.NewDirStore(cfg.AvatarDir)
+	if err != nil {
+		fatal("Unable to open avatar directory", err)
+	}
+	roomBus, err := newBus(cfg)
+	if err != nil {
+		fatal("Unable to initiate room bus", err)
+	}
+
+	roomService, err := service.NewRoomService(roomRepository, authRepository, attendanceRepository, roomBus, cfg.MessageRateLimits)
+	if err != nil {
+		fatal("Unable to initiate room service", err)
+	}
+	roomService.Audit = auditService
+
+	webhookService := service.NewWebhookService(webhookRepository, roomRepository, service.WebhookSettings{
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		RetryBase:    cfg.WebhookRetryBase,
+		Timeout:      cfg.WebhookTimeout,
+		AllowPrivate: cfg.WebhookAllowPrivate,
+	})
+	roomService.Webhooks = webhookService
+	webhookCtx, stopWebhooks := context.WithCancel(context.Background())
+	webhooksDone := make(chan struct{})
+	go func() {
+		webhookService.Run(webhookCtx)
+		close(webhooksDone)
+	}()
+
+	if err := authService.GrantAdmins(context.Background(), cfg.AdminUsers); err != nil {
+		fatal("Unable to grant admin roles", err)
+	}
 
-	authRepository := repositories.NewAuthRepository(db)
-	roomRepository := repositories.NewRoomRepository(db)
+	sessionManager.OnRevoke(roomService.CloseSessions)
+	sweepCtx, stopSweep := context.WithCancel(context.Background())
+	defer stopSweep()
+	go sessionManager.Sweep(sweepCtx, sessionSweepInterval)
+	authService.OnAPITokensRevoked = roomService.CloseAPITokens
+	metrics.RegisterRoomStats(roomService.Stats)
+	metrics.RegisterSessionCount(sessionManager.ActiveSessions)
 
-	authService := service.NewAuthService(authRepository, sessionManager)
-	roomService := service.NewRoomService(roomRepository)
+	origins := origin.NewAllowlist(cfg.AllowedOrigins)
+	privacyService := service.NewPrivacyService(authService, roomService, webhookService)
+	adminService := service.NewAdminService(authService, roomService)
 
-	router := api.NewRouter(authService, roomService, sessionManager)
+	router := api.NewRouter(authService, roomService, privacyService, auditService, adminService, webhookService, sessionManager, database.DB, api.RateLimits{
+		Auth:       cfg.AuthRateLimit,
+		API:        cfg.APIRateLimit,
+		CreateRoom: cfg.CreateRoomRateLimit,
+	}, handler.CookieSettings{
+		Secure:   cfg.CookieSecure,
+		SameSite: cfg.CookieSameSite,
+		Domain:   cfg.CookieDomain,
+	}, origins)
 
-	handlerWithCors := middleware.CorsMiddleware(router)
+	// CORS wraps the whole router so preflights never reach route matching
+	// or AuthMiddleware
+	httpHandler := middleware.RequestLogger(middleware.CorsMiddleware(middleware.CORSOptions{
+		Origins:        origins,
+		MaxAge:         cfg.CORSMaxAge,
+		ExposedHeaders: cfg.CORSExposedHeaders,
+	})(router))
+	if cfg.TrustProxy {
+		httpHandler = middleware.ProxiedClientIP(httpHandler)
+	}
 	server := &http.Server{
-		Addr:    ":" + constants.PORT,
-		Handler: handlerWithCors,
+		Addr:     ":" + constants.PORT,
+		Handler:  httpHandler,
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
 	}
 
 	go func() {
-		log.Println("Server started on :", constants.PORT)
+		slog.Info("Server started", "port", constants.PORT, "version", version)
 		err := server.ListenAndServe()
 		if err != nil && err != http.ErrServerClosed {
-			log.Fatal("Failed to start Server:", err)
+			fatal("Failed to start server", err)
 		}
 	}()
 
+	// Metrics reveal room and session counts, so they get a listener of their
+	// own that can be kept off the public network
+	var metricsServer *http.Server
+	if cfg.MetricsAddr != "off" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", metrics.Handler())
+		metricsServer = &http.Server{
+			Addr:     cfg.MetricsAddr,
+			Handler:  metricsMux,
+			ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+		}
+		go func() {
+			slog.Info("Metrics listening", "addr", cfg.MetricsAddr)
+			err := metricsServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				fatal("Failed to start metrics listener", err)
+			}
+		}()
+	}
+
 	// Wait for an interrupt signal to gracefully shut down the server
 	interruptChan := make(chan os.Signal, 1)
 	signal.Notify(interruptChan, os.Interrupt, syscall.SIGTERM)
 	<-interruptChan
+	start := time.Now()
+
+	// Stop taking joins and ask peers to reconnect elsewhere before the
+	// listener goes away
+	slog.Info("Draining connections", "drain_period", cfg.DrainPeriod)
+	roomService.Drain(cfg.ReconnectAfter)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.DrainPeriod)
+	roomService.WaitForDrain(drainCtx)
+	cancelDrain()
+	roomService.CloseAll()
+	// Queues deliveries for the last departures; they are sent after restart
+	stopWebhooks()
+	<-webhooksDone
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down server", "error", err)
+	}
+	if metricsServer != nil {
+		metricsServer.Close()
+	}
+
+	if err := roomBus.Close(); err != nil {
+		slog.Error("Error closing room bus", "error", err)
+	}
+	if err := database.Close(); err != nil {
+		slog.Error("Error closing DB", "error", err)
+	}
+	slog.Info("Server stopped", "elapsed", time.Since(start))
+}
+
+// newAuditService records to the database and, when configured, appends to
+// the audit log file. The returned func closes the file.
+func newAuditService(cfg *config.Config, auditRepository service.AuditRepository) (*service.AuditService, func() error, error) {
+	auditService := service.NewAuditService(auditRepository)
+	if cfg.AuditLogFile == "" {
+		return auditService, func() error { return nil }, nil
+	}
+	auditFile, err := os.OpenFile(cfg.AuditLogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	auditService.Sink = auditFile
+	return auditService, auditFile.Close, nil
+}
+
+// newBus picks the room event bus configured for this instance
+func newBus(cfg *config.Config) (bus.Bus, error) {
+	switch cfg.Bus {
+	case config.BusMemory:
+		return bus.NewMemoryBus(), nil
+	case config.BusRedis:
+		return bus.NewRedisBus(cfg.RedisURL)
+	default:
+		return nil, fmt.Errorf("unknown bus %q", cfg.Bus)
+	}
+}
+
+// newMailer picks how this instance sends email
+func newMailer(cfg *config.Config) (mailer.Mailer, error) {
+	switch cfg.Mailer {
+	case config.MailerLog:
+		return mailer.NewLogMailer(), nil
+	case config.MailerFile:
+		return mailer.NewFileMailer(cfg.MailDir)
+	case config.MailerSMTP:
+		return mailer.NewSMTPMailer(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", cfg.Mailer)
+	}
+}
+
+// fatal logs an unrecoverable startup error and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/config"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+const roomSubcommands = "list|end|purge-inactive"
+
+func roomCommand(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		unknownSubcommand("room", args, roomSubcommands)
+	}
+
+	var run func(*cli, []string)
+	switch args[0] {
+	case "list":
+		run = listRooms
+	case "end":
+		run = endRoom
+	case "purge-inactive":
+		run = purgeInactiveRooms
+	default:
+		unknownSubcommand("room", args, roomSubcommands)
+	}
+
+	c := openCLI(cfg)
+	defer c.close()
+	run(c, args[1:])
+}
+
+// listRooms prints rooms, active ones first, then newest first
+func listRooms(c *cli, args []string) {
+	fs := newFlagSet("room list", "[flags]")
+	all := fs.Bool("all", false, "include rooms that have ended")
+	host := fs.String("host", "", "only rooms hosted by this username")
+	search := fs.String("q", "", "only rooms with these words in their name")
+	parseArgs(fs, args, 0)
+
+	query := models.RoomQuery{Search: *search, Limit: 500}
+	if !*all {
+		query.Status = models.RoomStatusActive
+	}
+	if *host != "" {
+		query.HostID = c.user(*host).ID
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tHOST\tSTATUS\tVISIBILITY\tCREATED")
+	hosts := make(map[string]string)
+	for {
+		rooms, err := c.roomRepository.ListRooms(query)
+		if err != nil {
+			die(err)
+		}
+		for _, room := range rooms {
+			if _, ok := hosts[room.HostID]; !ok {
+				hosts[room.HostID] = c.username(room.HostID)
+			}
+			status := "active"
+			if room.Status != models.RoomStatusActive {
+				status = "ended"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", room.ID, room.Name, hosts[room.HostID], status, room.Visibility, room.CreatedAt.Format(time.RFC3339))
+		}
+		if len(rooms) < query.Limit {
+			break
+		}
+		last := rooms[len(rooms)-1]
+		query.After = &models.RoomCursor{Status: last.Status, CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	w.Flush()
+}
+
+// username names a room's host for display
+func (c *cli) username(userID string) string {
+	user, err := c.auth.AuthRepository.GetUserByID(userID)
+	if errors.Is(err, utils.ErrUserNotFound) || (err == nil && user.DeletedAt != nil) {
+		return "(deleted)"
+	}
+	if err != nil {
+		die(err)
+	}
+	return user.Username
+}
+
+// endRoom ends a room as the admin API does
+func endRoom(c *cli, args []string) {
+	fs := newFlagSet("room end", "<roomID>")
+	roomID := parseArgs(fs, args, 1)[0]
+
+	if err := c.admin.EndRoom(c.ctx, roomID); err != nil {
+		die(err)
+	}
+	fmt.Printf("Room %s ended\n", roomID)
+}
+
+// purgeInactiveRooms deletes ended rooms that haven't been used for a while,
+// with their meeting history and webhooks
+func purgeInactiveRooms(c *cli, args []string) {
+	fs := newFlagSet("room purge-inactive", "[flags]")
+	olderThan := fs.Duration("older-than", 90*24*time.Hour, "purge rooms with no meeting in this long")
+	dryRun := fs.Bool("dry-run", false, "list the rooms that would be purged without deleting them")
+	parseArgs(fs, args, 0)
+	if *olderThan <= 0 {
+		die(errors.New("-older-than must be positive"))
+	}
+
+	rooms, err := c.roomRepository.InactiveRooms(time.Now().Add(-*olderThan))
+	if err != nil {
+		die(err)
+	}
+	for _, room := range rooms {
+		fmt.Printf("%s\t%s\n", room.ID, room.Name)
+	}
+	if *dryRun || len(rooms) == 0 {
+		fmt.Printf("%d room(s) to purge\n", len(rooms))
+		return
+	}
+
+	roomIDs := make([]string, len(rooms))
+	for i, room := range rooms {
+		roomIDs[i] = room.ID
+	}
+	if err := c.roomRepository.PurgeRooms(roomIDs); err != nil {
+		die(err)
+	}
+	for _, room := range rooms {
+		c.rooms.Audit.Record(c.ctx, models.AuditEvent{
+			Action:     models.AuditRoomPurged,
+			TargetType: models.AuditTargetRoom,
+			TargetID:   room.ID,
+			RoomID:     room.ID,
+			Details:    map[string]string{"name": room.Name},
+		})
+	}
+	fmt.Printf("%d room(s) purged\n", len(rooms))
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/config"
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+const sessionSubcommands = "revoke"
+
+func sessionCommand(cfg *config.Config, args []string) {
+	if len(args) == 0 || args[0] != "revoke" {
+		unknownSubcommand("session", args, sessionSubcommands)
+	}
+
+	c := openCLI(cfg)
+	defer c.close()
+	revokeSessions(c, args[1:])
+}
+
+// revokeSessions signs a user out of every session started so far. Servers
+// refuse those sessions from their next request; API tokens keep working.
+func revokeSessions(c *cli, args []string) {
+	fs := newFlagSet("session revoke", "<username>")
+	username := parseArgs(fs, args, 1)[0]
+
+	user := c.user(username)
+	if err := c.auth.AuthRepository.RevokeSessions(user.ID, time.Now()); err != nil {
+		die(err)
+	}
+	c.auth.Audit.Record(c.ctx, models.AuditEvent{
+		Action:     models.AuditUserSessionsRevoked,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID,
+		Details:    map[string]string{"username": user.Username},
+	})
+	fmt.Printf("Sessions of %s revoked\n", user.Username)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/config"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const userSubcommands = "create|disable|enable|reset-password|promote-admin"
+
+func userCommand(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		unknownSubcommand("user", args, userSubcommands)
+	}
+
+	var run func(*cli, []string)
+	switch args[0] {
+	case "create":
+		run = createUser
+	case "disable":
+		run = func(c *cli, args []string) { setUserDisabled(c, args, true) }
+	case "enable":
+		run = func(c *cli, args []string) { setUserDisabled(c, args, false) }
+	case "reset-password":
+		run = resetPassword
+	case "promote-admin":
+		run = promoteAdmin
+	default:
+		unknownSubcommand("user", args, userSubcommands)
+	}
+
+	c := openCLI(cfg)
+	defer c.close()
+	run(c, args[1:])
+}
+
+// createUser adds an account with a password, which is generated and printed
+// unless one is given on standard input
+func createUser(c *cli, args []string) {
+	fs := newFlagSet("user create", "-username <name> -email <address> [flags]")
+	username := fs.String("username", "", "login name (required)")
+	email := fs.String("email", "", "email address (required)")
+	name := fs.String("name", "", "display name; defaults to the username")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from the first line of standard input")
+	verified := fs.Bool("verified", false, "treat the email address as already confirmed")
+	admin := fs.Bool("admin", false, "grant the admin role")
+	parseArgs(fs, args, 0)
+
+	request := models.RegisterRequest{
+		UserName: *username,
+		Email:    *email,
+		Name:     *name,
+	}
+	if request.Name == "" {
+		request.Name = request.UserName
+	}
+	generated := !*passwordStdin
+	if generated {
+		request.Password = newPassword()
+	} else {
+		request.Password = readPassword()
+	}
+	if err := utils.ValidateUserRegistrationRequest(&request); err != nil {
+		die(err)
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		die(err)
+	}
+	user := models.User{
+		ID:             utils.CreateNewUUID(),
+		Name:           request.Name,
+		Username:       request.UserName,
+		Email:          request.Email,
+		HashedPassword: string(hashedPassword),
+		EmailVerified:  *verified,
+		CreatedAt:      time.Now(),
+	}
+	if err := c.auth.AuthRepository.RegisterUser(user); err != nil {
+		if errors.Is(err, utils.ErrUserAlreadyExists) {
+			die(fmt.Errorf("a user named %q or with that email already exists", user.Username))
+		}
+		die(err)
+	}
+	c.auth.Audit.Record(c.ctx, models.AuditEvent{
+		Action:     models.AuditUserCreated,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID,
+		Details:    map[string]string{"username": user.Username},
+	})
+	if *admin {
+		if err := c.auth.SetAdmin(c.ctx, user.ID, true); err != nil {
+			die(err)
+		}
+	}
+
+	fmt.Printf("Created user %s (%s)\n", user.Username, user.ID)
+	if generated {
+		fmt.Printf("Password: %s\n", request.Password)
+	}
+}
+
+// setUserDisabled disables or re-enables an account, as the admin API does
+func setUserDisabled(c *cli, args []string, disabled bool) {
+	verb := "enable"
+	if disabled {
+		verb = "disable"
+	}
+	fs := newFlagSet("user "+verb, "<username>")
+	username := parseArgs(fs, args, 1)[0]
+
+	user := c.user(username)
+	if err := c.admin.SetUserDisabled(c.ctx, user.ID, disabled); err != nil {
+		die(err)
+	}
+	fmt.Printf("User %s %sd\n", user.Username, verb)
+}
+
+// resetPassword sets a new password, signs the user out everywhere and
+// revokes their API tokens
+func resetPassword(c *cli, args []string) {
+	fs := newFlagSet("user reset-password", "[-password-stdin] <username>")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from the first line of standard input instead of generating one")
+	username := parseArgs(fs, args, 1)[0]
+
+	user := c.user(username)
+	var password string
+	if *passwordStdin {
+		password = readPassword()
+	} else {
+		password = newPassword()
+	}
+	if password == "" {
+		die(errors.New("password cannot be empty"))
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		die(err)
+	}
+	if err := c.auth.AuthRepository.UpdatePassword(user.ID, string(hashedPassword)); err != nil {
+		die(err)
+	}
+	if err := c.auth.AuthRepository.RevokeSessions(user.ID, time.Now()); err != nil {
+		die(err)
+	}
+	if _, err := c.auth.APITokenRepository.DeleteUserAPITokens(user.ID); err != nil {
+		die(err)
+	}
+	c.auth.Audit.Record(c.ctx, models.AuditEvent{
+		Action:     models.AuditUserPasswordReset,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID,
+		Details:    map[string]string{"username": user.Username},
+	})
+
+	fmt.Printf("Password of %s reset; their sessions were signed out and API tokens revoked\n", user.Username)
+	if !*passwordStdin {
+		fmt.Printf("Password: %s\n", password)
+	}
+}
+
+// promoteAdmin grants or revokes the admin role
+func promoteAdmin(c *cli, args []string) {
+	fs := newFlagSet("user promote-admin", "[-revoke] <username>")
+	revoke := fs.Bool("revoke", false, "remove the admin role instead")
+	username := parseArgs(fs, args, 1)[0]
+
+	user := c.user(username)
+	if err := c.auth.SetAdmin(c.ctx, user.ID, !*revoke); err != nil {
+		die(err)
+	}
+	if *revoke {
+		fmt.Printf("User %s is not an admin\n", user.Username)
+	} else {
+		fmt.Printf("User %s is an admin\n", user.Username)
+	}
+}
+
+// readPassword reads a password from the first line of standard input
+func readPassword() string {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		die(fmt.Errorf("unable to read password: %w", err))
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+// newPassword returns a random password for the user to change after signing
+// in
+func newPassword() string {
+	token, err := utils.NewToken()
+	if err != nil {
+		die(err)
+	}
+	return token[:20]
+}
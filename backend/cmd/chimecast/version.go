@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// printVersion writes the release and, when the binary was built from a git
+// checkout, the commit it was built from
+func printVersion(w io.Writer) {
+	fmt.Fprintf(w, "chimecast %s\n", version)
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		settings := make(map[string]string)
+		for _, setting := range info.Settings {
+			settings[setting.Key] = setting.Value
+		}
+		if revision := settings["vcs.revision"]; revision != "" {
+			if settings["vcs.modified"] == "true" {
+				revision += " (modified)"
+			}
+			fmt.Fprintf(w, "commit:  %s\n", revision)
+		}
+		if built := settings["vcs.time"]; built != "" {
+			fmt.Fprintf(w, "date:    %s\n", built)
+		}
+	}
+	fmt.Fprintf(w, "go:      %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/service"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func NewAdminHandler(auditService *service.AuditService, adminService *service.AdminService) *AdminHandler {
+	return &AdminHandler{
+		AuditService: auditService,
+		AdminService: adminService,
+	}
+}
+
+// ListAuditEvents pages through the audit log, newest first. Filters are
+// query parameters: action, actor, target, room, ip, since and until
+// (RFC 3339), plus before and limit for paging.
+func (h *AdminHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := models.AuditQuery{
+		Action:   params.Get("action"),
+		ActorID:  params.Get("actor"),
+		TargetID: params.Get("target"),
+		RoomID:   params.Get("room"),
+		IP:       params.Get("ip"),
+	}
+
+	var err error
+	if query.Since, err = parseTimeParam(params.Get("since")); err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "since must be an RFC 3339 time")
+		return
+	}
+	if query.Until, err = parseTimeParam(params.Get("until")); err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "until must be an RFC 3339 time")
+		return
+	}
+	if before := params.Get("before"); before != "" {
+		if query.Before, err = strconv.ParseInt(before, 10, 64); err != nil || query.Before <= 0 {
+			utils.SendJSONError(w, http.StatusBadRequest, "before must be a cursor from a previous page")
+			return
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit <= 0 {
+			utils.SendJSONError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+
+	page, err := h.AuditService.ListEvents(query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing audit events", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Failed to list audit events")
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, page)
+}
+
+// parseTimeParam reads an optional RFC 3339 query parameter
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// ListUsers pages through accounts ordered by username. q searches part of
+// the username, name or email; after and limit page.
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := models.UserQuery{
+		Search: params.Get("q"),
+		After:  params.Get("after"),
+	}
+	if limit := params.Get("limit"); limit != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit <= 0 {
+			utils.SendJSONError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+
+	page, err := h.AdminService.ListUsers(query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing users", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Failed to list users")
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, page)
+}
+
+// GetUser returns one account
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.AdminService.GetUser(mux.Vars(r)["userID"])
+	if err != nil {
+		sendAdminError(w, r, err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, user)
+}
+
+// DisableUser disables an account and signs it out everywhere
+func (h *AdminHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, true)
+}
+
+// EnableUser lets a disabled account log in again
+func (h *AdminHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, false)
+}
+
+func (h *AdminHandler) setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	userID := mux.Vars(r)["userID"]
+	if err := h.AdminService.SetUserDisabled(r.Context(), userID, disabled); err != nil {
+		sendAdminError(w, r, err)
+		return
+	}
+
+	user, err := h.AdminService.GetUser(userID)
+	if err != nil {
+		sendAdminError(w, r, err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, user)
+}
+
+// ListLiveRooms lists rooms with someone connected, busiest first
+func (h *AdminHandler) ListLiveRooms(w http.ResponseWriter, r *http.Request) {
+	rooms, err := h.AdminService.ListLiveRooms()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing live rooms", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Failed to list rooms")
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, rooms)
+}
+
+// EndRoom ends any room, disconnecting everyone in it
+func (h *AdminHandler) EndRoom(w http.ResponseWriter, r *http.Request) {
+	if err := h.AdminService.EndRoom(r.Context(), mux.Vars(r)["roomID"]); err != nil {
+		sendAdminError(w, r, err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Room ended",
+	})
+}
+
+// Announce sends a message to everyone connected to the server
+func (h *AdminHandler) Announce(w http.ResponseWriter, r *http.Request) {
+	var request models.AnnouncementRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if err := h.AdminService.Announce(r.Context(), request.Message); err != nil {
+		sendAdminError(w, r, err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusAccepted, map[string]string{
+		"message": "Announcement sent",
+	})
+}
+
+func sendAdminError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, utils.ErrUserNotFound), errors.Is(err, utils.ErrRoomNotFound):
+		utils.SendJSONError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, utils.ErrRoomEnded):
+		utils.SendJSONError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, utils.ErrDisableSelf), errors.Is(err, utils.ErrInvalidAnnouncement):
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+	default:
+		slog.ErrorContext(r.Context(), "Error in admin request", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
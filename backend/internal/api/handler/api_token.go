@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// CreateAPIToken issues a personal API token. The token is in the response
+// and is not shown again.
+func (a *AuthHandler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	var request models.CreateAPITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	raw, token, err := a.AuthService.CreateAPIToken(userID, &request)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error creating API token", "error", err)
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, map[string]interface{}{
+		"token":    raw,
+		"apiToken": token,
+	})
+}
+
+// ListAPITokens returns the signed-in user's API tokens without their secrets
+func (a *AuthHandler) ListAPITokens(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	tokens, err := a.AuthService.ListAPITokens(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing API tokens", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, tokens)
+}
+
+// RevokeAPIToken deletes one of the signed-in user's API tokens
+func (a *AuthHandler) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	tokenID := mux.Vars(r)["tokenID"]
+
+	err := a.AuthService.RevokeAPIToken(userID, tokenID)
+	if errors.Is(err, utils.ErrAPITokenNotFound) {
+		utils.SendJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking API token", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "API token revoked"})
+}
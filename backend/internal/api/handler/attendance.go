@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/legendary-acp/chimecast/internal/service"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// ListMyMeetings lists the meetings the signed-in user attended
+func (h *RoomHandler) ListMyMeetings(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	meetings, err := h.RoomService.ListMyMeetings(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing meetings", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Failed to list meetings")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, meetings)
+}
+
+// ListRoomMeetings lists every meeting held in a room, for its host
+func (h *RoomHandler) ListRoomMeetings(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["roomID"]
+	userID := r.Context().Value("userID").(string)
+
+	meetings, err := h.RoomService.ListRoomMeetings(roomID, userID)
+	if err != nil {
+		sendAttendanceError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, meetings)
+}
+
+// GetAttendanceReport returns who attended a meeting and for how long, as
+// JSON or, with ?format=csv, as a spreadsheet download
+func (h *RoomHandler) GetAttendanceReport(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["roomID"]
+	meetingID := mux.Vars(r)["meetingID"]
+	userID := r.Context().Value("userID").(string)
+
+	report, err := h.RoomService.GetAttendanceReport(roomID, meetingID, userID)
+	if err != nil {
+		sendAttendanceError(w, err)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		utils.WriteJSONResponse(w, http.StatusOK, report)
+	case "csv":
+		filename := "attendance-" + report.Meeting.StartedAt.UTC().Format("2006-01-02-1504") + ".csv"
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.WriteHeader(http.StatusOK)
+		if err := service.WriteAttendanceCSV(w, report); err != nil {
+			slog.ErrorContext(r.Context(), "Error writing attendance report", "error", err)
+		}
+	default:
+		utils.SendJSONError(w, http.StatusBadRequest, "format must be json or csv")
+	}
+}
+
+// KickParticipant removes an admitted participant from the room
+func (h *RoomHandler) KickParticipant(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["roomID"]
+	participantID := mux.Vars(r)["userID"]
+	hostID := r.Context().Value("userID").(string)
+
+	err := h.RoomService.KickParticipant(r.Context(), roomID, participantID, hostID)
+	if err != nil {
+		sendAttendanceError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Participant removed successfully",
+	})
+}
+
+func sendAttendanceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, utils.ErrNotRoomHost):
+		utils.SendJSONError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, utils.ErrMeetingNotFound):
+		utils.SendJSONError(w, http.StatusNotFound, err.Error())
+	default:
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+	}
+}
@@ -3,17 +3,23 @@ package handler
 import (
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/legendary-acp/chimecast/internal/middleware"
 	"github.com/legendary-acp/chimecast/internal/models"
 	"github.com/legendary-acp/chimecast/internal/service"
+	"github.com/legendary-acp/chimecast/internal/session"
 	"github.com/legendary-acp/chimecast/internal/utils"
 )
 
-func NewAuthHandler(authService *service.AuthService) *AuthHandler {
+func NewAuthHandler(authService *service.AuthService, cookies CookieSettings) *AuthHandler {
 	return &AuthHandler{
 		AuthService: authService,
+		Cookies:     cookies,
 	}
 }
 
@@ -24,7 +30,7 @@ func (a *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	err := json.NewDecoder(r.Body).Decode(&userRegisterRequest)
 	if err != nil {
 		// Log the error for debugging purposes
-		log.Printf("Error decoding request body: %v", err)
+		slog.WarnContext(r.Context(), "Error decoding request body", "error", err)
 
 		// Respond with a generic error message
 		response := map[string]string{
@@ -37,7 +43,7 @@ func (a *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	err = utils.ValidateUserRegistrationRequest(&userRegisterRequest)
 	if err != nil {
 		// Log the error for debugging purposes
-		log.Printf("Error decoding request body: %v", err)
+		slog.WarnContext(r.Context(), "Invalid registration request", "error", err)
 
 		// Respond with a generic error message
 		response := map[string]string{
@@ -49,10 +55,10 @@ func (a *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Step 2: Register the user
-	sessionID, err := a.AuthService.RegisterUser(&userRegisterRequest)
+	sessionID, err := a.AuthService.RegisterUser(&userRegisterRequest, clientOf(r))
 	if err != nil {
 		// Log the actual error
-		log.Printf("Error registering user: %v", err)
+		slog.ErrorContext(r.Context(), "Error registering user", "error", err)
 
 		// Send a generic error message to the client
 		response := map[string]string{
@@ -70,16 +76,11 @@ func (a *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Step 3: Success response
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    *sessionID,
-		HttpOnly: true,
-		Path:     "/",
-	})
+	a.setSessionCookie(w, *sessionID)
 
 	response := map[string]string{
-		"message": "User registered successfully",
+		"message":   "User registered successfully",
+		"csrfToken": a.csrfToken(*sessionID),
 	}
 	utils.WriteJSONResponse(w, http.StatusOK, response)
 }
@@ -93,24 +94,119 @@ func (a *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Login and get session ID
-	sessionID, err := a.AuthService.Login(&loginRequest)
+	result, err := a.AuthService.Login(&loginRequest, clientOf(r))
+	if writeThrottled(w, err) || writeDisabled(w, err) {
+		return
+	}
 	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusUnauthorized, map[string]string{"message": "Invalid Credentials"})
 		return
 	}
 
-	// Set session ID as a cookie
+	// The password was right but a second factor is due
+	if result.MFAToken != "" {
+		utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+			"message":     "Two-factor authentication required",
+			"mfaRequired": true,
+			"mfaToken":    result.MFAToken,
+		})
+		return
+	}
+
+	a.writeLoginSuccess(w, result)
+}
+
+// LoginMFA is the second step of a login for users with two-factor
+// authentication
+func (a *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var request models.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.MFAToken == "" || request.Code == "" {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	result, err := a.AuthService.CompleteMFALogin(&request, clientOf(r))
+	if writeThrottled(w, err) || writeDisabled(w, err) {
+		return
+	}
+	if errors.Is(err, utils.ErrInvalidToken) {
+		utils.WriteJSONResponse(w, http.StatusUnauthorized, map[string]string{"message": "Login expired, please start again"})
+		return
+	}
+	if err != nil {
+		if !errors.Is(err, utils.ErrInvalidMFACode) {
+			slog.ErrorContext(r.Context(), "Error completing two-factor login", "error", err)
+		}
+		utils.WriteJSONResponse(w, http.StatusUnauthorized, map[string]string{"message": "Invalid two-factor code"})
+		return
+	}
+
+	a.writeLoginSuccess(w, result)
+}
+
+// writeLoginSuccess sets the session cookie for a completed login
+func (a *AuthHandler) writeLoginSuccess(w http.ResponseWriter, result *service.LoginResult) {
+	a.setSessionCookie(w, result.SessionID)
+
+	response := map[string]interface{}{
+		"message":   "Login successful",
+		"csrfToken": a.csrfToken(result.SessionID),
+	}
+	if result.MFAEnrollmentRequired {
+		response["mfaEnrollmentRequired"] = true
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, response)
+}
+
+// writeThrottled answers with 429 and Retry-After if err is a throttled login
+func writeThrottled(w http.ResponseWriter, err error) bool {
+	var throttled *service.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	utils.WriteJSONResponse(w, http.StatusTooManyRequests, map[string]string{"message": "Too many login attempts, try again later"})
+	return true
+}
+
+// writeDisabled answers a login to a disabled account, reporting whether it
+// did. Only reached once the credentials have been checked.
+func writeDisabled(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, utils.ErrAccountDisabled) {
+		return false
+	}
+	utils.WriteJSONResponse(w, http.StatusForbidden, map[string]string{"message": "This account has been disabled"})
+	return true
+}
+
+// clientOf describes the device a request came from, for the session list
+func clientOf(r *http.Request) session.Client {
+	return session.Client{IP: utils.ClientIP(r), UserAgent: r.UserAgent()}
+}
+
+func (a *AuthHandler) setSessionCookie(w http.ResponseWriter, sessionID string) {
+	ttl := a.AuthService.SessionManager.TTL()
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_id",
 		Value:    sessionID,
 		HttpOnly: true,
 		Path:     "/",
+		Domain:   a.Cookies.Domain,
+		Secure:   a.Cookies.Secure,
+		SameSite: a.Cookies.SameSite,
+		Expires:  time.Now().Add(ttl),
+		MaxAge:   int(ttl.Seconds()),
 	})
+}
 
-	response := map[string]string{
-		"message": "Login successful",
+// csrfToken returns the token the client must send back in X-CSRF-Token on
+// state-changing requests made with the session
+func (a *AuthHandler) csrfToken(sessionID string) string {
+	token, err := a.AuthService.CSRFToken(sessionID)
+	if err != nil {
+		slog.Error("Error reading CSRF token for new session", "error", err)
 	}
-	utils.WriteJSONResponse(w, http.StatusOK, response)
+	return token
 }
 
 func (a *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
@@ -121,6 +217,12 @@ func (a *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A live session may only be ended by its own client
+	if token, err := a.AuthService.CSRFToken(cookie.Value); err == nil && !middleware.ValidCSRFToken(r, token) {
+		utils.SendJSONError(w, http.StatusForbidden, "missing or invalid CSRF token")
+		return
+	}
+
 	// Delete session
 	a.AuthService.Logout(cookie.Value)
 
@@ -130,6 +232,7 @@ func (a *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		Value:  "",
 		MaxAge: -1,
 		Path:   "/",
+		Domain: a.Cookies.Domain,
 	})
 
 	response := map[string]string{
@@ -150,7 +253,7 @@ func (a *AuthHandler) ValidateAuth(w http.ResponseWriter, r *http.Request) {
 
 	// Validate session ID
 	sessionID := cookie.Value
-	err = a.AuthService.ValidateAuth(sessionID)
+	session, err := a.AuthService.SessionManager.GetSession(sessionID)
 	if err != nil {
 		utils.WriteJSONResponse(w, http.StatusUnauthorized, map[string]string{
 			"message": "Invalid session",
@@ -158,10 +261,114 @@ func (a *AuthHandler) ValidateAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The account may have been disabled, erased or signed out from the
+	// command line since the session started
+	if err := a.AuthService.CheckSession(session.UserID, session.CreatedAt); err != nil {
+		if !errors.Is(err, utils.ErrAccountDisabled) && !errors.Is(err, utils.ErrSessionRevoked) && !errors.Is(err, utils.ErrUserNotFound) {
+			slog.ErrorContext(r.Context(), "Error checking session", "error", err)
+			utils.SendJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		a.AuthService.SessionManager.DeleteSession(sessionID)
+		utils.WriteJSONResponse(w, http.StatusUnauthorized, map[string]string{
+			"message": "Invalid session",
+		})
+		return
+	}
+
 	// Return success response
 	response := map[string]interface{}{
 		"message":         "Session valid",
 		"isAuthenticated": true,
+		"csrfToken":       session.CSRFToken,
 	}
 	utils.WriteJSONResponse(w, http.StatusOK, response)
 }
+
+func (a *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var request models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	err := a.AuthService.VerifyEmail(request.Token)
+	if errors.Is(err, utils.ErrInvalidToken) {
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error verifying email", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Email verified",
+	})
+}
+
+func (a *AuthHandler) ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	var request models.EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Email == "" {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if err := a.AuthService.ResendVerificationEmail(request.Email); err != nil {
+		slog.ErrorContext(r.Context(), "Error resending verification email", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+
+	// Same answer whether or not the address is registered
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "If that address needs verifying, a new link is on its way",
+	})
+}
+
+func (a *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var request models.EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Email == "" {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if err := a.AuthService.ForgotPassword(request.Email); err != nil {
+		slog.ErrorContext(r.Context(), "Error starting password reset", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Failed to send reset email")
+		return
+	}
+
+	// Same answer whether or not the address is registered
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "If that address has an account, a reset link is on its way",
+	})
+}
+
+func (a *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var request models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	err := a.AuthService.ResetPassword(&request)
+	if errors.Is(err, utils.ErrInvalidToken) {
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil && err.Error() == "password cannot be empty" {
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting password", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Password reset, please log in again",
+	})
+}
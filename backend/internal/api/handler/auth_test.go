@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/mailer"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/repositories"
+	"github.com/legendary-acp/chimecast/internal/service"
+	"github.com/legendary-acp/chimecast/internal/session"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+func TestValidateAuth(t *testing.T) {
+	database, err := db.Open(db.SQLite, filepath.Join(t.TempDir(), "chimecast.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	users := repositories.NewAuthRepository(database)
+	sessions := session.NewSessionManager(time.Hour)
+	authService := service.NewAuthService(users, repositories.NewLoginAttemptRepository(database), repositories.NewTokenRepository(database),
+		repositories.NewAPITokenRepository(database), sessions, mailer.NewLogMailer(), service.LoginLimits{}, "http://localhost:5173")
+	h := NewAuthHandler(authService, CookieSettings{})
+
+	// Each user is signed in once, then their account is changed under the
+	// session the way the admin CLI would. mallory never had an account.
+	now := time.Now()
+	changes := map[string]func(userID string) error{
+		"alice":   func(string) error { return nil },
+		"bob":     func(userID string) error { return users.SetDisabled(userID, &now) },
+		"carol":   func(userID string) error { return users.RevokeSessions(userID, now.Add(time.Second)) },
+		"dave":    func(userID string) error { return users.AnonymizeUser(models.User{ID: userID}, now) },
+		"mallory": nil,
+	}
+	cookies := make(map[string]string)
+	for username, change := range changes {
+		userID := "id-" + username
+		if change != nil {
+			if err := users.RegisterUser(models.User{ID: userID, Username: username, Email: username + "@example.com", CreatedAt: time.Now()}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		cookie, err := sessions.CreateSession(username, userID, session.Client{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if change != nil {
+			if err := change(userID); err != nil {
+				t.Fatal(err)
+			}
+		}
+		cookies[username] = cookie
+	}
+
+	tests := []struct {
+		name       string
+		cookie     string
+		wantStatus int
+	}{
+		{name: "no cookie", wantStatus: http.StatusUnauthorized},
+		{name: "unknown session", cookie: "stale", wantStatus: http.StatusUnauthorized},
+		{name: "valid session", cookie: cookies["alice"], wantStatus: http.StatusOK},
+		{name: "disabled account", cookie: cookies["bob"], wantStatus: http.StatusUnauthorized},
+		{name: "sessions revoked", cookie: cookies["carol"], wantStatus: http.StatusUnauthorized},
+		{name: "deleted account", cookie: cookies["dave"], wantStatus: http.StatusUnauthorized},
+		{name: "user gone from the database", cookie: cookies["mallory"], wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/auth/v1/validate", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "session_id", Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			h.ValidateAuth(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if csrf, _ := body["csrfToken"].(string); (csrf != "") != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("csrfToken = %q with status %d", csrf, w.Code)
+			}
+
+			// Refused sessions are ended rather than asked about again
+			if tt.cookie != "" && tt.wantStatus != http.StatusOK {
+				if _, err := sessions.GetSession(tt.cookie); err == nil {
+					t.Error("refused session is still live")
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"database/sql"
 	"net/http"
 
 	"github.com/gorilla/websocket"
@@ -9,12 +10,39 @@ import (
 
 type AuthHandler struct {
 	AuthService *service.AuthService
+	Cookies     CookieSettings
+}
+
+// CookieSettings are the attributes the session cookie is set with
+type CookieSettings struct {
+	Secure   bool
+	SameSite http.SameSite
+	Domain   string
+}
+
+// PrivacyHandler serves a user's data export and account deletion
+type PrivacyHandler struct {
+	PrivacyService *service.PrivacyService
+	Cookies        CookieSettings
 }
 
 type RoomHandler struct {
 	RoomService *service.RoomService
+	Upgrader    websocket.Upgrader
+}
+
+type HealthHandler struct {
+	DB          *sql.DB
+	RoomService *service.RoomService
+}
+
+// AdminHandler serves the admin API
+type AdminHandler struct {
+	AuditService *service.AuditService
+	AdminService *service.AdminService
 }
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+// WebhookHandler serves a user's webhooks and their delivery logs
+type WebhookHandler struct {
+	WebhookService *service.WebhookService
 }
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/service"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+const readinessTimeout = 2 * time.Second
+
+func NewHealthHandler(db *sql.DB, roomService *service.RoomService) *HealthHandler {
+	return &HealthHandler{
+		DB:          db,
+		RoomService: roomService,
+	}
+}
+
+// Healthz reports that the process is up and serving HTTP
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"status": "ok",
+	})
+}
+
+// Readyz reports whether this instance should receive traffic: the database
+// must be reachable and the instance must not be draining
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	if h.RoomService.IsDraining() {
+		utils.WriteJSONResponse(w, http.StatusServiceUnavailable, map[string]string{
+			"status": "draining",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	if err := h.DB.PingContext(ctx); err != nil {
+		slog.WarnContext(r.Context(), "Readiness check failed", "error", err)
+		utils.WriteJSONResponse(w, http.StatusServiceUnavailable, map[string]string{
+			"status":   "unavailable",
+			"database": "unreachable",
+		})
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"status":   "ok",
+		"database": "ok",
+	})
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// BeginMFAEnrollment returns a new authenticator secret and its provisioning
+// URI for the signed-in user
+func (a *AuthHandler) BeginMFAEnrollment(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	enrollment, err := a.AuthService.BeginMFAEnrollment(userID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error starting two-factor enrolment", "error", err)
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, enrollment)
+}
+
+// ConfirmMFAEnrollment enables two-factor authentication and returns the
+// recovery codes, which are not shown again
+func (a *AuthHandler) ConfirmMFAEnrollment(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	var request models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Code == "" {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	codes, err := a.AuthService.ConfirmMFAEnrollment(userID, request.Code)
+	if err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"message":       "Two-factor authentication enabled",
+		"recoveryCodes": codes,
+	})
+}
+
+func (a *AuthHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	var request models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Code == "" {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if err := a.AuthService.DisableMFA(userID, request.Code); err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+func (a *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	var request models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Code == "" {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	codes, err := a.AuthService.RegenerateRecoveryCodes(userID, request.Code)
+	if err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"recoveryCodes": codes,
+	})
+}
+
+// writeMFAError maps failures of the enrolment endpoints to responses
+func writeMFAError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, utils.ErrInvalidMFACode) {
+		utils.SendJSONError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	slog.WarnContext(r.Context(), "Two-factor request failed", "error", err)
+	utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/service"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func NewPrivacyHandler(privacyService *service.PrivacyService, cookies CookieSettings) *PrivacyHandler {
+	return &PrivacyHandler{
+		PrivacyService: privacyService,
+		Cookies:        cookies,
+	}
+}
+
+// ExportData downloads everything stored about the signed-in user as a ZIP
+func (p *PrivacyHandler) ExportData(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	data, err := p.PrivacyService.Export(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error exporting user data", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Failed to export data")
+		return
+	}
+
+	filename := "chimecast-export-" + time.Now().UTC().Format("2006-01-02") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// DeleteAccount erases the signed-in user and signs them out everywhere
+func (p *PrivacyHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	var request models.DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	err := p.PrivacyService.DeleteAccount(r.Context(), userID, &request)
+	if errors.Is(err, utils.ErrIncorrectPassword) || errors.Is(err, utils.ErrConfirmationMismatch) {
+		utils.SendJSONError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting account", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   "session_id",
+		Value:  "",
+		MaxAge: -1,
+		Path:   "/",
+		Domain: p.Cookies.Domain,
+	})
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Account deleted"})
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/legendary-acp/chimecast/internal/avatar"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// GetProfile returns the signed-in user's profile
+func (a *AuthHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	profile, err := a.AuthService.GetProfile(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading profile", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, profile)
+}
+
+// UpdateProfile changes the signed-in user's name, email or preferred
+// language
+func (a *AuthHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	var request models.UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	profile, err := a.AuthService.UpdateProfile(userID, &request)
+	if errors.Is(err, utils.ErrUserAlreadyExists) {
+		utils.SendJSONError(w, http.StatusConflict, "email is already in use")
+		return
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error updating profile", "error", err)
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, profile)
+}
+
+// ChangePassword sets a new password and signs the user out everywhere
+// else. The response carries a new session cookie and CSRF token.
+func (a *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	var request models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	sessionID, err := a.AuthService.ChangePassword(userID, &request, clientOf(r))
+	if errors.Is(err, utils.ErrIncorrectPassword) {
+		utils.SendJSONError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error changing password", "error", err)
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a.setSessionCookie(w, sessionID)
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message":   "Password changed",
+		"csrfToken": a.csrfToken(sessionID),
+	})
+}
+
+// UploadAvatar takes a picture in the multipart field "avatar" and makes it
+// the signed-in user's avatar
+func (a *AuthHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	// Leave room for the multipart envelope around the file
+	r.Body = http.MaxBytesReader(w, r.Body, avatar.MaxUploadBytes+64<<10)
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "expected an image in the \"avatar\" field of at most 5 MB")
+		return
+	}
+	defer file.Close()
+
+	profile, err := a.AuthService.SetAvatar(userID, file)
+	if errors.Is(err, utils.ErrInvalidImage) {
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving avatar", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, profile)
+}
+
+// DeleteAvatar removes the signed-in user's avatar
+func (a *AuthHandler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	if err := a.AuthService.RemoveAvatar(userID); err != nil {
+		slog.ErrorContext(r.Context(), "Error removing avatar", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Avatar removed"})
+}
@@ -2,28 +2,95 @@ package handler
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/origin"
 	"github.com/legendary-acp/chimecast/internal/service"
 	"github.com/legendary-acp/chimecast/internal/utils"
 )
 
-func NewRoomHandler(roomService *service.RoomService) *RoomHandler {
+func NewRoomHandler(roomService *service.RoomService, origins *origin.Allowlist) *RoomHandler {
 	return &RoomHandler{
 		RoomService: roomService,
+		Upgrader: websocket.Upgrader{
+			CheckOrigin:  origins.CheckOrigin,
+			Subprotocols: service.Subprotocols(),
+		},
 	}
 }
 
-func (h *RoomHandler) GetAllRooms(w http.ResponseWriter, r *http.Request) {
-	rooms, err := h.RoomService.GetAllRooms()
+// ListRooms pages through rooms, active first, then newest first. status
+// (active or ended), host, mine, q (words in the name) and since/until
+// filter; after and limit page.
+func (h *RoomHandler) ListRooms(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	params := r.URL.Query()
+	query := models.RoomQuery{
+		HostID:   params.Get("host"),
+		Search:   params.Get("q"),
+		ViewerID: userID,
+	}
+
+	switch params.Get("status") {
+	case "":
+	case "active":
+		query.Status = models.RoomStatusActive
+	case "ended":
+		query.Status = models.RoomStatusInactive
+	default:
+		utils.SendJSONError(w, http.StatusBadRequest, "status must be active or ended")
+		return
+	}
+	if mine := params.Get("mine"); mine != "" {
+		isMine, err := strconv.ParseBool(mine)
+		if err != nil {
+			utils.SendJSONError(w, http.StatusBadRequest, "mine must be true or false")
+			return
+		}
+		if isMine {
+			if query.HostID != "" && query.HostID != userID {
+				utils.SendJSONError(w, http.StatusBadRequest, "host and mine can't both be set")
+				return
+			}
+			query.HostID = userID
+		}
+	}
+
+	var err error
+	if query.Since, err = parseTimeParam(params.Get("since")); err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "since must be an RFC 3339 time")
+		return
+	}
+	if query.Until, err = parseTimeParam(params.Get("until")); err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "until must be an RFC 3339 time")
+		return
+	}
+	if after := params.Get("after"); after != "" {
+		if query.After, err = service.ParseRoomCursor(after); err != nil {
+			utils.SendJSONError(w, http.StatusBadRequest, "after must be a cursor from a previous page")
+			return
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit <= 0 {
+			utils.SendJSONError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+
+	page, err := h.RoomService.ListRooms(query)
 	if err != nil {
-		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		slog.ErrorContext(r.Context(), "Error listing rooms", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Failed to list rooms")
 		return
 	}
-	utils.WriteJSONResponse(w, http.StatusOK, rooms)
+	utils.WriteJSONResponse(w, http.StatusOK, page)
 }
 
 func (h *RoomHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
@@ -36,9 +103,10 @@ func (h *RoomHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	roomID, err := h.RoomService.CreateRoom(createRoomRequest, userID) // Pass hostID
+	roomID, err := h.RoomService.CreateRoom(r.Context(), createRoomRequest, userID) // Pass hostID
 	if err != nil {
-		if err.Error() == "name can't be empty" {
+		if err.Error() == "name can't be empty" || errors.Is(err, utils.ErrInvalidScreenSharePolicy) ||
+			errors.Is(err, utils.ErrInvalidVisibility) {
 			utils.SendJSONError(w, http.StatusBadRequest, err.Error())
 		} else {
 			utils.SendJSONError(w, http.StatusInternalServerError, err.Error())
@@ -58,8 +126,20 @@ func (h *RoomHandler) JoinRoom(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(string)
 
 	status, err := h.RoomService.JoinRoom(roomID, userID)
+	if errors.Is(err, utils.ErrServerDraining) {
+		utils.SendJSONError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if errors.Is(err, utils.ErrRoomEnded) {
+		utils.SendJSONError(w, http.StatusGone, err.Error())
+		return
+	}
+	if errors.Is(err, utils.ErrRoomNotFound) {
+		utils.SendJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
 	if err != nil {
-		log.Printf("Error joining room %s: %v", roomID, err)
+		slog.WarnContext(r.Context(), "Error joining room", "error", err)
 		utils.SendJSONError(w, http.StatusBadRequest, "Could not join the room: "+err.Error())
 		return
 	}
@@ -76,31 +156,52 @@ func (h *RoomHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	roomID := mux.Vars(r)["roomID"]
 	userID := r.Context().Value("userID").(string)
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	// New sockets belong on an instance that isn't going away
+	if h.RoomService.IsDraining() {
+		utils.SendJSONError(w, http.StatusServiceUnavailable, utils.ErrServerDraining.Error())
+		return
+	}
+	if err := h.RoomService.CanAccessRoom(roomID, userID); err != nil {
+		if errors.Is(err, utils.ErrRoomEnded) {
+			utils.SendJSONError(w, http.StatusGone, err.Error())
+			return
+		}
+		sendRoomLookupError(w, err)
+		return
+	}
+
+	upgraded, err := h.Upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("Failed to upgrade to WebSocket: %v", err)
+		slog.WarnContext(r.Context(), "Failed to upgrade to WebSocket", "error", err)
 		return
 	}
+	conn := service.NewSocket(upgraded)
 	defer conn.Close()
 
+	protocolVersion, err := service.NegotiateProtocol(conn, websocket.Subprotocols(r))
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error negotiating signaling protocol", "error", err)
+		return
+	}
+
 	// Check if user is admitted to the room
 	isAdmitted, err := h.RoomService.IsUserAdmitted(roomID, userID)
 	if err != nil {
-		log.Printf("Error checking user admission: %v", err)
+		slog.ErrorContext(r.Context(), "Error checking user admission", "error", err)
 		return
 	}
 
 	if !isAdmitted {
 		// If not admitted, put in waiting room queue
-		if err := h.RoomService.HandleWaitingRoom(roomID, userID, conn); err != nil {
-			log.Printf("Error handling waiting room: %v", err)
+		if err := h.RoomService.HandleWaitingRoom(r.Context(), roomID, userID, protocolVersion, conn); err != nil {
+			slog.DebugContext(r.Context(), "Waiting room connection closed", "error", err)
 		}
 		return
 	}
 
 	// Handle admitted user's WebSocket connection
-	if err := h.RoomService.HandleWebSocket(roomID, userID, conn); err != nil {
-		log.Printf("Error handling WebSocket: %v", err)
+	if err := h.RoomService.HandleWebSocket(r.Context(), roomID, userID, protocolVersion, conn); err != nil {
+		slog.DebugContext(r.Context(), "WebSocket connection closed", "error", err)
 	}
 }
 
@@ -110,7 +211,7 @@ func (h *RoomHandler) GetParticipants(w http.ResponseWriter, r *http.Request) {
 
 	participants, err := h.RoomService.GetParticipants(roomID, userID)
 	if err != nil {
-		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		sendRoomLookupError(w, err)
 		return
 	}
 
@@ -122,7 +223,7 @@ func (h *RoomHandler) AdmitParticipant(w http.ResponseWriter, r *http.Request) {
 	participantID := mux.Vars(r)["userID"]
 	hostID := r.Context().Value("userID").(string)
 
-	err := h.RoomService.AdmitParticipant(roomID, participantID, hostID)
+	err := h.RoomService.AdmitParticipant(r.Context(), roomID, participantID, hostID)
 	if err != nil {
 		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
 		return
@@ -153,7 +254,7 @@ func (h *RoomHandler) DenyParticipant(w http.ResponseWriter, r *http.Request) {
 	participantID := mux.Vars(r)["userID"]
 	hostID := r.Context().Value("userID").(string)
 
-	err := h.RoomService.DenyParticipant(roomID, participantID, hostID)
+	err := h.RoomService.DenyParticipant(r.Context(), roomID, participantID, hostID)
 	if err != nil {
 		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
 		return
@@ -170,9 +271,47 @@ func (h *RoomHandler) GetRoomStatus(w http.ResponseWriter, r *http.Request) {
 
 	status, err := h.RoomService.GetRoomStatus(roomID, userID)
 	if err != nil {
-		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		sendRoomLookupError(w, err)
 		return
 	}
 
 	utils.WriteJSONResponse(w, http.StatusOK, status)
 }
+
+func (h *RoomHandler) UpdateScreenSharePolicy(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["roomID"]
+	hostID := r.Context().Value("userID").(string)
+
+	var request models.UpdateScreenSharePolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	err := h.RoomService.UpdateScreenSharePolicy(r.Context(), roomID, request.Policy, hostID)
+	if err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Screen share policy updated successfully",
+		"policy":  request.Policy,
+	})
+}
+
+func (h *RoomHandler) StopScreenShare(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["roomID"]
+	participantID := mux.Vars(r)["userID"]
+	requesterID := r.Context().Value("userID").(string)
+
+	err := h.RoomService.StopScreenShare(roomID, participantID, requesterID)
+	if err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Screen share stopped successfully",
+	})
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/legendary-acp/chimecast/internal/session"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+type sessionResponse struct {
+	session.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions returns the signed-in user's sessions, marking the one making
+// the request
+func (a *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	currentID := r.Context().Value("sessionID").(string)
+
+	sessions := a.AuthService.ListSessions(userID)
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		response = append(response, sessionResponse{Session: s, Current: s.ID == currentID})
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, response)
+}
+
+// RevokeSession signs out one of the signed-in user's sessions
+func (a *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	sessionID := mux.Vars(r)["sessionID"]
+
+	err := a.AuthService.RevokeSession(userID, sessionID)
+	if errors.Is(err, session.ErrSessionNotFound) {
+		utils.SendJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions signs out every session of the signed-in user except
+// the one making the request
+func (a *AuthHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	currentID := r.Context().Value("sessionID").(string)
+
+	revoked := a.AuthService.RevokeOtherSessions(userID, currentID)
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"message": "Other sessions revoked",
+		"revoked": revoked,
+	})
+}
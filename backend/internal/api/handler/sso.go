@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/legendary-acp/chimecast/internal/service"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+const (
+	oidcStateCookie = "oidc_auth"
+	oidcCookiePath  = "/api/auth/v1/oidc"
+	oidcStateMaxAge = 600 // seconds the user has to finish at the provider
+)
+
+// OIDCLogin sends the browser to the identity provider
+func (a *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if !a.AuthService.SSOEnabled() {
+		utils.SendJSONError(w, http.StatusNotFound, "single sign-on is not configured")
+		return
+	}
+
+	authURL, authState, err := a.AuthService.BeginOIDCLogin(r.Context(), safeReturnTo(r.URL.Query().Get("returnTo")))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting single sign-on", "error", err)
+		utils.SendJSONError(w, http.StatusBadGateway, "identity provider unavailable")
+		return
+	}
+
+	data, err := json.Marshal(authState)
+	if err != nil {
+		utils.SendJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	// SameSite=Lax so the cookie comes back on the provider's top-level
+	// redirect to the callback
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(data),
+		Path:     oidcCookiePath,
+		MaxAge:   oidcStateMaxAge,
+		HttpOnly: true,
+		Secure:   a.Cookies.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback finishes single sign-on and sends the browser back to the app
+func (a *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if !a.AuthService.SSOEnabled() {
+		utils.SendJSONError(w, http.StatusNotFound, "single sign-on is not configured")
+		return
+	}
+
+	// The state cookie is single-use whatever happens next
+	http.SetCookie(w, &http.Cookie{
+		Name:   oidcStateCookie,
+		Value:  "",
+		Path:   oidcCookiePath,
+		MaxAge: -1,
+	})
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		slog.WarnContext(r.Context(), "Identity provider returned an error", "error", providerErr, "description", query.Get("error_description"))
+		a.redirectToLogin(w, r, "sso_failed")
+		return
+	}
+
+	authState, err := readOIDCState(r)
+	if err != nil || subtle.ConstantTimeCompare([]byte(authState.State), []byte(query.Get("state"))) != 1 {
+		slog.WarnContext(r.Context(), "Single sign-on state mismatch", "error", err)
+		a.redirectToLogin(w, r, "sso_failed")
+		return
+	}
+
+	result, err := a.AuthService.CompleteOIDCLogin(r.Context(), authState, query.Get("code"), clientOf(r))
+	if errors.Is(err, utils.ErrUserAlreadyExists) {
+		a.redirectToLogin(w, r, "account_exists")
+		return
+	}
+	if errors.Is(err, utils.ErrAccountDisabled) {
+		a.redirectToLogin(w, r, "account_disabled")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error completing single sign-on", "error", err)
+		a.redirectToLogin(w, r, "sso_failed")
+		return
+	}
+
+	a.setSessionCookie(w, result.SessionID)
+	http.Redirect(w, r, a.AuthService.PublicURL+authState.ReturnTo, http.StatusFound)
+}
+
+// redirectToLogin sends the browser to the app's login page with an error
+// code it can show
+func (a *AuthHandler) redirectToLogin(w http.ResponseWriter, r *http.Request, code string) {
+	http.Redirect(w, r, a.AuthService.PublicURL+"/login?error="+url.QueryEscape(code), http.StatusFound)
+}
+
+func readOIDCState(r *http.Request) (*service.OIDCAuthState, error) {
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return nil, err
+	}
+	var authState service.OIDCAuthState
+	if err := json.Unmarshal(data, &authState); err != nil {
+		return nil, err
+	}
+	return &authState, nil
+}
+
+// safeReturnTo only allows paths within the app, so the login can't be used
+// as an open redirect
+func safeReturnTo(returnTo string) string {
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.Contains(returnTo, "\\") {
+		return "/"
+	}
+	return returnTo
+}
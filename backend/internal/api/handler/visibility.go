@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// UpdateVisibility makes a room private, unlisted or public. Host only.
+func (h *RoomHandler) UpdateVisibility(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["roomID"]
+	hostID := r.Context().Value("userID").(string)
+
+	var request models.UpdateRoomVisibilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.RoomService.UpdateVisibility(r.Context(), roomID, request.Visibility, hostID); err != nil {
+		sendInvitationError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message":    "Room visibility updated successfully",
+		"visibility": request.Visibility,
+	})
+}
+
+// ListInvitations lists who may see and join a private room. Host only.
+func (h *RoomHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["roomID"]
+	hostID := r.Context().Value("userID").(string)
+
+	invitations, err := h.RoomService.ListInvitations(roomID, hostID)
+	if err != nil {
+		sendInvitationError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, invitations)
+}
+
+// Invite invites a user, by username, to a room. Host only.
+func (h *RoomHandler) Invite(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["roomID"]
+	hostID := r.Context().Value("userID").(string)
+
+	var request models.InviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Username == "" {
+		utils.SendJSONError(w, http.StatusBadRequest, "username is required")
+		return
+	}
+
+	invitation, err := h.RoomService.Invite(r.Context(), roomID, hostID, request.Username)
+	if err != nil {
+		sendInvitationError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, invitation)
+}
+
+// Uninvite withdraws a user's invitation to a room. Host only.
+func (h *RoomHandler) Uninvite(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["roomID"]
+	userID := mux.Vars(r)["userID"]
+	hostID := r.Context().Value("userID").(string)
+
+	if err := h.RoomService.Uninvite(r.Context(), roomID, hostID, userID); err != nil {
+		sendInvitationError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Invitation withdrawn successfully",
+	})
+}
+
+// sendRoomLookupError reports rooms the user may not see as not found
+func sendRoomLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, utils.ErrRoomNotFound) {
+		utils.SendJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+}
+
+func sendInvitationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, utils.ErrNotRoomHost):
+		utils.SendJSONError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, utils.ErrRoomNotFound), errors.Is(err, utils.ErrUserNotFound), errors.Is(err, utils.ErrInvitationNotFound):
+		utils.SendJSONError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, utils.ErrInvalidVisibility):
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.SendJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/service"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		WebhookService: webhookService,
+	}
+}
+
+// CreateWebhook registers an endpoint for room events. The signing secret is
+// in the response and cannot be retrieved again.
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	var request models.CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	secret, webhook, err := h.WebhookService.CreateWebhook(userID, &request)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error creating webhook", "error", err)
+		sendWebhookError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, map[string]interface{}{
+		"secret":  secret,
+		"webhook": webhook,
+	})
+}
+
+// ListWebhooks returns the signed-in user's webhooks without their secrets
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+
+	webhooks, err := h.WebhookService.ListWebhooks(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing webhooks", "error", err)
+		utils.SendJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, webhooks)
+}
+
+// DeleteWebhook removes one of the signed-in user's webhooks
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	webhookID := mux.Vars(r)["webhookID"]
+
+	if err := h.WebhookService.DeleteWebhook(userID, webhookID); err != nil {
+		sendWebhookError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Webhook deleted",
+	})
+}
+
+// ListDeliveries returns a webhook's recent deliveries, newest first
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	webhookID := mux.Vars(r)["webhookID"]
+
+	deliveries, err := h.WebhookService.ListDeliveries(userID, webhookID)
+	if err != nil {
+		sendWebhookError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, deliveries)
+}
+
+// Redeliver sends an earlier delivery again
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	webhookID := mux.Vars(r)["webhookID"]
+	deliveryID := mux.Vars(r)["deliveryID"]
+
+	delivery, err := h.WebhookService.Redeliver(userID, webhookID, deliveryID)
+	if err != nil {
+		sendWebhookError(w, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusAccepted, delivery)
+}
+
+func sendWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, utils.ErrNotRoomHost):
+		utils.SendJSONError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, utils.ErrWebhookNotFound), errors.Is(err, utils.ErrDeliveryNotFound):
+		utils.SendJSONError(w, http.StatusNotFound, err.Error())
+	default:
+		utils.SendJSONError(w, http.StatusBadRequest, err.Error())
+	}
+}
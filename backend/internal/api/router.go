@@ -1,45 +1,172 @@
 package api
 
 import (
+	"database/sql"
+	"net/http"
+
 	"github.com/gorilla/mux"
 	"github.com/legendary-acp/chimecast/internal/api/handler"
+	"github.com/legendary-acp/chimecast/internal/avatar"
+	"github.com/legendary-acp/chimecast/internal/metrics"
 	"github.com/legendary-acp/chimecast/internal/middleware"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/origin"
+	"github.com/legendary-acp/chimecast/internal/ratelimit"
 	"github.com/legendary-acp/chimecast/internal/service"
 	"github.com/legendary-acp/chimecast/internal/session"
 )
 
+// RateLimits are the request budgets for each group of routes
+type RateLimits struct {
+	Auth       ratelimit.Rate // per client IP, across /api/auth/v1
+	API        ratelimit.Rate // per user, across /api/room/v1
+	CreateRoom ratelimit.Rate // per user, for POST /api/room/v1/ on top of API
+}
+
 func NewRouter(
 	authService *service.AuthService,
 	roomService *service.RoomService,
+	privacyService *service.PrivacyService,
+	auditService *service.AuditService,
+	adminService *service.AdminService,
+	webhookService *service.WebhookService,
 	sessionManager *session.SessionManager,
+	db *sql.DB,
+	rateLimits RateLimits,
+	cookies handler.CookieSettings,
+	origins *origin.Allowlist,
 ) *mux.Router {
 	router := mux.NewRouter()
-	authHandler := handler.NewAuthHandler(authService)
-	roomHandler := handler.NewRoomHandler(roomService)
+	router.Use(metrics.Middleware)
+	authHandler := handler.NewAuthHandler(authService, cookies)
+	roomHandler := handler.NewRoomHandler(roomService, origins)
+	privacyHandler := handler.NewPrivacyHandler(privacyService, cookies)
+	healthHandler := handler.NewHealthHandler(db, roomService)
+	adminHandler := handler.NewAdminHandler(auditService, adminService)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+
+	// Health checks for load balancers. Metrics are served on their own
+	// listener so they aren't public.
+	router.HandleFunc("/healthz", healthHandler.Healthz).Methods("GET")
+	router.HandleFunc("/readyz", healthHandler.Readyz).Methods("GET")
+
+	// Profile pictures; names are unguessable and change on every upload
+	router.PathPrefix(avatar.URLPrefix).Handler(avatar.Handler(authService.Avatars)).Methods("GET", "HEAD")
 
 	// Auth routes remain the same
 	authAPIsV1 := router.PathPrefix("/api/auth/v1").Subrouter()
+	authAPIsV1.Use(middleware.RateLimit(ratelimit.NewLimiter(rateLimits.Auth)))
 	authAPIsV1.HandleFunc("/register", authHandler.Register).Methods("POST")
 	authAPIsV1.HandleFunc("/login", authHandler.Login).Methods("POST")
+	authAPIsV1.HandleFunc("/login/mfa", authHandler.LoginMFA).Methods("POST")
+	authAPIsV1.HandleFunc("/oidc/login", authHandler.OIDCLogin).Methods("GET")
+	authAPIsV1.HandleFunc("/oidc/callback", authHandler.OIDCCallback).Methods("GET")
 	authAPIsV1.HandleFunc("/logout", authHandler.Logout).Methods("POST")
 	authAPIsV1.HandleFunc("/validate", authHandler.ValidateAuth).Methods("GET")
+	authAPIsV1.HandleFunc("/verify-email", authHandler.VerifyEmail).Methods("POST")
+	authAPIsV1.HandleFunc("/verify-email/resend", authHandler.ResendVerificationEmail).Methods("POST")
+	authAPIsV1.HandleFunc("/password/forgot", authHandler.ForgotPassword).Methods("POST")
+	authAPIsV1.HandleFunc("/password/reset", authHandler.ResetPassword).Methods("POST")
+
+	// Two-factor enrolment for the signed-in user
+	mfaAPIsV1 := authAPIsV1.PathPrefix("/mfa").Subrouter()
+	mfaAPIsV1.Use(middleware.AuthMiddleware(sessionManager, authService), middleware.RequireSession)
+	mfaAPIsV1.HandleFunc("/enroll", authHandler.BeginMFAEnrollment).Methods("POST")
+	mfaAPIsV1.HandleFunc("/confirm", authHandler.ConfirmMFAEnrollment).Methods("POST")
+	mfaAPIsV1.HandleFunc("/disable", authHandler.DisableMFA).Methods("POST")
+	mfaAPIsV1.HandleFunc("/recovery-codes", authHandler.RegenerateRecoveryCodes).Methods("POST")
+
+	// The signed-in user's own profile
+	profileAPIsV1 := authAPIsV1.PathPrefix("/me").Subrouter()
+	profileAPIsV1.Use(middleware.AuthMiddleware(sessionManager, authService), middleware.RequireSession)
+	profileAPIsV1.HandleFunc("", authHandler.GetProfile).Methods("GET")
+	profileAPIsV1.HandleFunc("", authHandler.UpdateProfile).Methods("PATCH")
+	profileAPIsV1.HandleFunc("", privacyHandler.DeleteAccount).Methods("DELETE")
+	profileAPIsV1.HandleFunc("/export", privacyHandler.ExportData).Methods("GET")
+	profileAPIsV1.HandleFunc("/password", authHandler.ChangePassword).Methods("POST")
+	profileAPIsV1.HandleFunc("/avatar", authHandler.UploadAvatar).Methods("PUT")
+	profileAPIsV1.HandleFunc("/avatar", authHandler.DeleteAvatar).Methods("DELETE")
+
+	// Personal API tokens; managing them needs a real session, not a token
+	tokenAPIsV1 := authAPIsV1.PathPrefix("/tokens").Subrouter()
+	tokenAPIsV1.Use(middleware.AuthMiddleware(sessionManager, authService), middleware.RequireSession)
+	tokenAPIsV1.HandleFunc("", authHandler.ListAPITokens).Methods("GET")
+	tokenAPIsV1.HandleFunc("", authHandler.CreateAPIToken).Methods("POST")
+	tokenAPIsV1.HandleFunc("/{tokenID}", authHandler.RevokeAPIToken).Methods("DELETE")
+
+	// The signed-in user's sessions across devices
+	sessionAPIsV1 := authAPIsV1.PathPrefix("/sessions").Subrouter()
+	sessionAPIsV1.Use(middleware.AuthMiddleware(sessionManager, authService), middleware.RequireSession)
+	sessionAPIsV1.HandleFunc("", authHandler.ListSessions).Methods("GET")
+	sessionAPIsV1.HandleFunc("", authHandler.RevokeOtherSessions).Methods("DELETE")
+	sessionAPIsV1.HandleFunc("/{sessionID}", authHandler.RevokeSession).Methods("DELETE")
 
 	// Room routes with additional endpoints
 	roomAPIsV1 := router.PathPrefix("/api/room/v1").Subrouter()
-	roomAPIsV1.Use(middleware.AuthMiddleware(sessionManager))
+	roomAPIsV1.Use(middleware.AuthMiddleware(sessionManager, authService))
+	roomAPIsV1.Use(middleware.RequireScopes(models.ScopeRoomsRead, models.ScopeRoomsWrite))
+	if authService.RequireMFA {
+		roomAPIsV1.Use(middleware.RequireMFA(authService.IsMFAEnrolled))
+	}
+	roomAPIsV1.Use(middleware.RoomContext)
+	roomAPIsV1.Use(middleware.RateLimit(ratelimit.NewLimiter(rateLimits.API)))
+	createRoom := middleware.RateLimit(ratelimit.NewLimiter(rateLimits.CreateRoom))(http.HandlerFunc(roomHandler.CreateRoom))
+	if authService.RequireVerifiedEmail {
+		createRoom = middleware.RequireVerifiedEmail(authService.IsEmailVerified)(createRoom)
+	}
 
 	// Existing endpoints
-	roomAPIsV1.HandleFunc("/", roomHandler.GetAllRooms).Methods("GET")
-	roomAPIsV1.HandleFunc("/", roomHandler.CreateRoom).Methods("POST")
+	roomAPIsV1.HandleFunc("/", roomHandler.ListRooms).Methods("GET")
+	roomAPIsV1.Handle("/", createRoom).Methods("POST")
 	roomAPIsV1.HandleFunc("/{roomID}/join", roomHandler.JoinRoom).Methods("POST")
 	roomAPIsV1.HandleFunc("/{roomID}/ws", roomHandler.HandleWebSocket).Methods("GET")
 
+	// Webhooks for the signed-in user's rooms
+	roomAPIsV1.HandleFunc("/webhooks", webhookHandler.ListWebhooks).Methods("GET")
+	roomAPIsV1.HandleFunc("/webhooks", webhookHandler.CreateWebhook).Methods("POST")
+	roomAPIsV1.HandleFunc("/webhooks/{webhookID}", webhookHandler.DeleteWebhook).Methods("DELETE")
+	roomAPIsV1.HandleFunc("/webhooks/{webhookID}/deliveries", webhookHandler.ListDeliveries).Methods("GET")
+	roomAPIsV1.HandleFunc("/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", webhookHandler.Redeliver).Methods("POST")
+
 	// New endpoints needed for waiting room functionality
 	roomAPIsV1.HandleFunc("/{roomID}/participants", roomHandler.GetParticipants).Methods("GET")
 	roomAPIsV1.HandleFunc("/{roomID}/admit/{userID}", roomHandler.AdmitParticipant).Methods("POST")
 	roomAPIsV1.HandleFunc("/{roomID}/deny/{userID}", roomHandler.DenyParticipant).Methods("POST")
+	roomAPIsV1.HandleFunc("/{roomID}/kick/{userID}", roomHandler.KickParticipant).Methods("POST")
 	roomAPIsV1.HandleFunc("/{roomID}/leave", roomHandler.LeaveRoom).Methods("POST")
 	roomAPIsV1.HandleFunc("/{roomID}/status", roomHandler.GetRoomStatus).Methods("GET")
 
+	// Screen sharing coordination
+	roomAPIsV1.HandleFunc("/{roomID}/screenshare/policy", roomHandler.UpdateScreenSharePolicy).Methods("PUT")
+	roomAPIsV1.HandleFunc("/{roomID}/screenshare/stop/{userID}", roomHandler.StopScreenShare).Methods("POST")
+
+	// Visibility and invitations to private rooms
+	roomAPIsV1.HandleFunc("/{roomID}/visibility", roomHandler.UpdateVisibility).Methods("PUT")
+	roomAPIsV1.HandleFunc("/{roomID}/invitations", roomHandler.ListInvitations).Methods("GET")
+	roomAPIsV1.HandleFunc("/{roomID}/invitations", roomHandler.Invite).Methods("POST")
+	roomAPIsV1.HandleFunc("/{roomID}/invitations/{userID}", roomHandler.Uninvite).Methods("DELETE")
+
+	// Attendance
+	roomAPIsV1.HandleFunc("/meetings", roomHandler.ListMyMeetings).Methods("GET")
+	roomAPIsV1.HandleFunc("/{roomID}/meetings", roomHandler.ListRoomMeetings).Methods("GET")
+	roomAPIsV1.HandleFunc("/{roomID}/meetings/{meetingID}/attendance", roomHandler.GetAttendanceReport).Methods("GET")
+
+	// Administration; needs a signed-in session of a user with the admin role
+	adminAPIsV1 := router.PathPrefix("/api/admin/v1").Subrouter()
+	adminAPIsV1.Use(middleware.AuthMiddleware(sessionManager, authService), middleware.RequireSession)
+	if authService.RequireMFA {
+		adminAPIsV1.Use(middleware.RequireMFA(authService.IsMFAEnrolled))
+	}
+	adminAPIsV1.Use(middleware.RequireAdmin(authService.IsAdmin))
+	adminAPIsV1.Use(middleware.RateLimit(ratelimit.NewLimiter(rateLimits.API)))
+	adminAPIsV1.HandleFunc("/audit", adminHandler.ListAuditEvents).Methods("GET")
+	adminAPIsV1.HandleFunc("/users", adminHandler.ListUsers).Methods("GET")
+	adminAPIsV1.HandleFunc("/users/{userID}", adminHandler.GetUser).Methods("GET")
+	adminAPIsV1.HandleFunc("/users/{userID}/disable", adminHandler.DisableUser).Methods("POST")
+	adminAPIsV1.HandleFunc("/users/{userID}/enable", adminHandler.EnableUser).Methods("POST")
+	adminAPIsV1.HandleFunc("/rooms", adminHandler.ListLiveRooms).Methods("GET")
+	adminAPIsV1.HandleFunc("/rooms/{roomID}/end", adminHandler.EndRoom).Methods("POST")
+	adminAPIsV1.HandleFunc("/announcements", adminHandler.Announce).Methods("POST")
+
 	return router
 }
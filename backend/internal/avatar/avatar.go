@@ -0,0 +1,102 @@
+// Package avatar turns uploaded pictures into square profile images and
+// stores them.
+package avatar
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif" // decoders for image.Decode
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"regexp"
+
+	"github.com/legendary-acp/chimecast/internal/utils"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// Size is the width and height avatars are stored at
+	Size = 256
+
+	// MaxUploadBytes limits the uploaded file
+	MaxUploadBytes = 5 << 20
+
+	// maxPixels guards against small files that decode to huge images
+	maxPixels = 40_000_000
+
+	// URLPrefix is where avatars are served from
+	URLPrefix = "/avatars/"
+)
+
+// namePattern matches the file names NewName produces, so only those can be
+// served or deleted
+var namePattern = regexp.MustCompile(`^[0-9a-f-]+-[0-9a-f]{16}\.png$`)
+
+// Process decodes a JPEG, PNG, GIF or WebP picture, crops it to a centred
+// square and scales it to Size, returning it as PNG
+func Process(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxUploadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxUploadBytes {
+		return nil, fmt.Errorf("%w: larger than %d MB", utils.ErrInvalidImage, MaxUploadBytes>>20)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrInvalidImage, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d is too large", utils.ErrInvalidImage, config.Width, config.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrInvalidImage, err)
+	}
+
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	dst := image.NewRGBA(image.Rect(0, 0, Size, Size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, dst); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// NewName returns a fresh file name for a user's avatar. Names change on
+// every upload so browsers and proxies can cache them forever.
+func NewName(userID string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return userID + "-" + hex.EncodeToString(b) + ".png", nil
+}
+
+// ValidName reports whether name could have come from NewName
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// URL is the path an avatar is served at, or "" for users without one
+func URL(name string) string {
+	if name == "" {
+		return ""
+	}
+	return URLPrefix + name
+}
@@ -0,0 +1,92 @@
+package avatar
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Store keeps processed avatars
+type Store interface {
+	Save(name string, data []byte) error
+	Delete(name string) error
+	Open(name string) (io.ReadSeekCloser, time.Time, error)
+}
+
+// DirStore keeps avatars as files in a directory
+type DirStore struct {
+	Dir string
+}
+
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirStore{Dir: dir}, nil
+}
+
+// Save writes through a temporary file so readers never see half an image
+func (d *DirStore) Save(name string, data []byte) error {
+	tmp, err := os.CreateTemp(d.Dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(d.Dir, name))
+}
+
+func (d *DirStore) Delete(name string) error {
+	err := os.Remove(filepath.Join(d.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (d *DirStore) Open(name string) (io.ReadSeekCloser, time.Time, error) {
+	f, err := os.Open(filepath.Join(d.Dir, name))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, time.Time{}, err
+	}
+	return f, info.ModTime(), nil
+}
+
+// Handler serves avatars from store under URLPrefix. Names are checked
+// against the pattern NewName produces, so nothing else in the store can be
+// read.
+func Handler(store Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Base(r.URL.Path)
+		if !ValidName(name) {
+			http.NotFound(w, r)
+			return
+		}
+		f, modTime, err := store.Open(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, name, modTime, f)
+	})
+}
@@ -0,0 +1,79 @@
+package bus
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrMemberNotFound is returned by UpdateMember for participants who aren't
+// present in the room, so a stale update can't bring them back
+var ErrMemberNotFound = errors.New("member not found")
+
+// Event kinds fanned out between nodes
+const (
+	EventBroadcast  = "broadcast"  // write Message to admitted participants
+	EventAdmit      = "admit"      // move Target out of the waiting room
+	EventDeny       = "deny"       // turn Target away from the waiting room
+	EventDisconnect = "disconnect" // close Target's socket
+
+	// Server-wide events, with no RoomID
+	EventAnnounce       = "announce"         // write Message to every socket, admitted or waiting
+	EventDisconnectUser = "disconnect-user"  // close all of Target's sockets, in any room
+	EventCloseAPITokens = "close-api-tokens" // close sockets opened with any of Tokens
+)
+
+// Event is a room-scoped or server-wide message delivered to every node,
+// including the one that published it. Each node acts only on the sockets it
+// holds.
+type Event struct {
+	Kind        string          `json:"kind"`
+	RoomID      string          `json:"roomId"`
+	Target      string          `json:"target,omitempty"`      // only this user
+	Exclude     string          `json:"exclude,omitempty"`     // everyone but this user
+	MessageType string          `json:"messageType,omitempty"` // type of Message, for metrics
+	Reason      string          `json:"reason,omitempty"`      // why Target is disconnected, for attendance
+	Tokens      []string        `json:"tokens,omitempty"`      // API token IDs, for EventCloseAPITokens
+	Message     json.RawMessage `json:"message,omitempty"`
+}
+
+// Member is a participant's cluster-wide presence in a room
+type Member struct {
+	UserID        string    `json:"userId"`
+	ConnectionID  string    `json:"connectionId"` // the socket this entry belongs to
+	Username      string    `json:"username"`
+	Name          string    `json:"name"`
+	AvatarURL     string    `json:"avatarUrl"`
+	Status        string    `json:"status"` // "waiting" or "admitted"
+	JoinedAt      time.Time `json:"joinedAt"`
+	ScreenSharing bool      `json:"screenSharing"`
+}
+
+// MemberUpdate computes a participant's new presence from the room's current
+// members, which it must not modify
+type MemberUpdate func(member Member, members map[string]Member) (Member, error)
+
+// Bus carries room events and presence between ChimeCast instances so that
+// participants connected to different nodes can share a room
+type Bus interface {
+	// Publish fans an event out to every subscribed node
+	Publish(event Event) error
+	// Subscribe registers a handler for events from all rooms
+	Subscribe(handler func(Event)) error
+	// SetMember adds or replaces a participant's presence in a room
+	SetMember(roomID string, member Member) error
+	// UpdateMember atomically rewrites a participant's presence. update sees
+	// the member and everyone in the room, and returns the new entry or an
+	// error to leave the room as it was. It may run more than once under
+	// contention and must not call the bus.
+	UpdateMember(roomID, userID string, update MemberUpdate) error
+	// RemoveMember drops a participant's presence from a room if it still
+	// belongs to connectionID, so a closing socket can't remove the entry of
+	// one that replaced it
+	RemoveMember(roomID, userID, connectionID string) error
+	// Members returns everyone present in a room, keyed by user ID
+	Members(roomID string) (map[string]Member, error)
+	// Rooms returns the IDs of the rooms anyone is present in
+	Rooms() ([]string, error)
+	Close() error
+}
@@ -0,0 +1,226 @@
+package bus
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testPresence checks the presence operations every Bus must provide. a and
+// b may be the same bus, or two nodes sharing one backend.
+func testPresence(t *testing.T, a, b Bus) {
+	t.Run("set, read and remove", func(t *testing.T) {
+		joined := time.Now().UTC().Truncate(time.Second)
+		alice := Member{UserID: "alice", Username: "alice", Status: "admitted", JoinedAt: joined}
+		if err := a.SetMember("presence", alice); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.SetMember("presence", Member{UserID: "bob", Status: "waiting"}); err != nil {
+			t.Fatal(err)
+		}
+
+		members, err := b.Members("presence")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(members) != 2 || members["alice"] != alice || members["bob"].Status != "waiting" {
+			t.Fatalf("Members() = %+v", members)
+		}
+
+		// Setting again replaces the entry
+		if err := b.SetMember("presence", Member{UserID: "bob", Status: "admitted"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.RemoveMember("presence", "alice", ""); err != nil {
+			t.Fatal(err)
+		}
+		members, err = a.Members("presence")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(members) != 1 || members["bob"].Status != "admitted" {
+			t.Fatalf("Members() after update and removal = %+v", members)
+		}
+	})
+
+	t.Run("remove only the connection's own entry", func(t *testing.T) {
+		tests := []struct {
+			name          string
+			set           []Member
+			connectionID  string
+			wantRemaining string
+		}{
+			{name: "current connection", set: []Member{{UserID: "erin", ConnectionID: "c1"}}, connectionID: "c1"},
+			{name: "replaced by a newer connection", set: []Member{{UserID: "erin", ConnectionID: "c1"}, {UserID: "erin", ConnectionID: "c2"}}, connectionID: "c1", wantRemaining: "c2"},
+			{name: "already gone", connectionID: "c1"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// The newer connection may have landed on the other node
+				for i, member := range tt.set {
+					node := a
+					if i%2 == 1 {
+						node = b
+					}
+					if err := node.SetMember("reconnect", member); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := a.RemoveMember("reconnect", "erin", tt.connectionID); err != nil {
+					t.Fatal(err)
+				}
+				members, err := b.Members("reconnect")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := members["erin"].ConnectionID; got != tt.wantRemaining {
+					t.Fatalf("remaining connection = %q, want %q", got, tt.wantRemaining)
+				}
+				b.RemoveMember("reconnect", "erin", tt.wantRemaining)
+			})
+		}
+	})
+
+	t.Run("empty room", func(t *testing.T) {
+		members, err := a.Members("nobody-here")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(members) != 0 {
+			t.Fatalf("Members() = %+v, want none", members)
+		}
+	})
+
+	t.Run("rooms", func(t *testing.T) {
+		if err := a.SetMember("listed", Member{UserID: "dave", Status: "waiting"}); err != nil {
+			t.Fatal(err)
+		}
+		rooms, err := b.Rooms()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Contains(rooms, "listed") || slices.Contains(rooms, "nobody-here") {
+			t.Fatalf("Rooms() = %v, want listed and not nobody-here", rooms)
+		}
+
+		// The room goes with its last member
+		if err := b.RemoveMember("listed", "dave", ""); err != nil {
+			t.Fatal(err)
+		}
+		rooms, err = a.Rooms()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slices.Contains(rooms, "listed") {
+			t.Fatalf("Rooms() = %v after the last member left, want listed gone", rooms)
+		}
+	})
+
+	t.Run("update refuses absent members", func(t *testing.T) {
+		err := a.UpdateMember("update", "ghost", func(m Member, _ map[string]Member) (Member, error) {
+			t.Error("update called for an absent member")
+			return m, nil
+		})
+		if !errors.Is(err, ErrMemberNotFound) {
+			t.Fatalf("UpdateMember() error = %v, want ErrMemberNotFound", err)
+		}
+		members, _ := a.Members("update")
+		if _, exists := members["ghost"]; exists {
+			t.Fatal("UpdateMember() created an absent member")
+		}
+	})
+
+	t.Run("update errors leave the room alone", func(t *testing.T) {
+		if err := a.SetMember("update", Member{UserID: "carol", Status: "admitted"}); err != nil {
+			t.Fatal(err)
+		}
+		refused := errors.New("refused")
+		err := b.UpdateMember("update", "carol", func(m Member, _ map[string]Member) (Member, error) {
+			m.ScreenSharing = true
+			return m, refused
+		})
+		if !errors.Is(err, refused) {
+			t.Fatalf("UpdateMember() error = %v, want the update's error", err)
+		}
+		members, _ := a.Members("update")
+		if members["carol"].ScreenSharing {
+			t.Fatal("refused update was written")
+		}
+	})
+
+	t.Run("concurrent updates see each other", func(t *testing.T) {
+		const users = 10
+		for i := 0; i < users; i++ {
+			if err := a.SetMember("exclusive", Member{UserID: fmt.Sprintf("u%d", i), Status: "admitted"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Everyone tries to become the only sharer, half through each node
+		var wg sync.WaitGroup
+		var granted atomic.Int32
+		for i := 0; i < users; i++ {
+			node := a
+			if i%2 == 1 {
+				node = b
+			}
+			wg.Add(1)
+			go func(node Bus, userID string) {
+				defer wg.Done()
+				err := node.UpdateMember("exclusive", userID, func(m Member, members map[string]Member) (Member, error) {
+					for _, other := range members {
+						if other.ScreenSharing {
+							return m, errors.New("taken")
+						}
+					}
+					m.ScreenSharing = true
+					return m, nil
+				})
+				if err == nil {
+					granted.Add(1)
+				}
+			}(node, fmt.Sprintf("u%d", i))
+		}
+		wg.Wait()
+
+		members, err := a.Members("exclusive")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sharing := 0
+		for _, m := range members {
+			if m.ScreenSharing {
+				sharing++
+			}
+		}
+		if granted.Load() != 1 || sharing != 1 {
+			t.Fatalf("%d updates granted and %d members sharing, want one", granted.Load(), sharing)
+		}
+	})
+}
+
+func TestMemoryBusPresence(t *testing.T) {
+	bus := NewMemoryBus()
+	testPresence(t, bus, bus)
+}
+
+func TestMemoryBusPublish(t *testing.T) {
+	bus := NewMemoryBus()
+	var got []Event
+	for i := 0; i < 2; i++ {
+		if err := bus.Subscribe(func(event Event) { got = append(got, event) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := bus.Publish(Event{Kind: EventBroadcast, RoomID: "room"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].RoomID != "room" || got[1].Kind != EventBroadcast {
+		t.Fatalf("handlers received %+v, want the event twice", got)
+	}
+}
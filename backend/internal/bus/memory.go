@@ -0,0 +1,103 @@
+package bus
+
+import "sync"
+
+// MemoryBus is a Bus for a single instance. Events are delivered
+// synchronously on the publishing goroutine.
+type MemoryBus struct {
+	mu       sync.RWMutex
+	handlers []func(Event)
+	members  map[string]map[string]Member // roomID -> userID -> Member
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		members: make(map[string]map[string]Member),
+	}
+}
+
+func (b *MemoryBus) Publish(event Event) error {
+	b.mu.RLock()
+	handlers := make([]func(Event), len(b.handlers))
+	copy(handlers, b.handlers)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+	return nil
+}
+
+func (b *MemoryBus) Subscribe(handler func(Event)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+	return nil
+}
+
+func (b *MemoryBus) SetMember(roomID string, member Member) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.members[roomID] == nil {
+		b.members[roomID] = make(map[string]Member)
+	}
+	b.members[roomID][member.UserID] = member
+	return nil
+}
+
+func (b *MemoryBus) UpdateMember(roomID, userID string, update MemberUpdate) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	member, exists := b.members[roomID][userID]
+	if !exists {
+		return ErrMemberNotFound
+	}
+	updated, err := update(member, b.members[roomID])
+	if err != nil {
+		return err
+	}
+	b.members[roomID][userID] = updated
+	return nil
+}
+
+func (b *MemoryBus) RemoveMember(roomID, userID, connectionID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if member, exists := b.members[roomID][userID]; !exists || member.ConnectionID != connectionID {
+		return nil
+	}
+	delete(b.members[roomID], userID)
+	if len(b.members[roomID]) == 0 {
+		delete(b.members, roomID)
+	}
+	return nil
+}
+
+func (b *MemoryBus) Members(roomID string) (map[string]Member, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	members := make(map[string]Member, len(b.members[roomID]))
+	for userID, member := range b.members[roomID] {
+		members[userID] = member
+	}
+	return members, nil
+}
+
+func (b *MemoryBus) Rooms() ([]string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	roomIDs := make([]string, 0, len(b.members))
+	for roomID := range b.members {
+		roomIDs = append(roomIDs, roomID)
+	}
+	return roomIDs, nil
+}
+
+func (b *MemoryBus) Close() error {
+	return nil
+}
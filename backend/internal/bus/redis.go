@@ -0,0 +1,313 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisChannelPrefix  = "chimecast:room:"
+	redisPresencePrefix = "chimecast:presence:"
+	redisTimeout        = 5 * time.Second
+
+	// updateAttempts bounds how often UpdateMember and RemoveMember retry
+	// after another node changed the room's presence under them
+	updateAttempts = 10
+
+	// presenceTTL bounds how long a room's presence outlives a node that died
+	// without cleaning up after itself
+	presenceTTL = 24 * time.Hour
+)
+
+// RedisBus is a Bus shared by every instance pointed at the same Redis
+// server. Events travel over pub/sub and presence is kept in one hash per
+// room.
+type RedisBus struct {
+	client *redis.Client
+
+	mu       sync.Mutex
+	pubsub   *redis.PubSub
+	handlers []func(Event)
+	owned    map[string]map[string]string // roomID -> userID -> connection ID written by this node
+}
+
+// NewRedisBus connects to the server at url, e.g. "redis://localhost:6379/0"
+func NewRedisBus(url string) (*RedisBus, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &RedisBus{
+		client: client,
+		owned:  make(map[string]map[string]string),
+	}, nil
+}
+
+func (b *RedisBus) Publish(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := b.client.Publish(ctx, redisChannelPrefix+event.RoomID, data).Err(); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+func (b *RedisBus) Subscribe(handler func(Event)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers = append(b.handlers, handler)
+	if b.pubsub != nil {
+		return nil
+	}
+
+	// One pattern subscription per node carries every room
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	pubsub := b.client.PSubscribe(ctx, redisChannelPrefix+"*")
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	b.pubsub = pubsub
+
+	go b.dispatch(pubsub.Channel())
+	return nil
+}
+
+// dispatch hands every received event to the registered handlers
+func (b *RedisBus) dispatch(messages <-chan *redis.Message) {
+	for message := range messages {
+		var event Event
+		if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+			slog.Warn("Dropping malformed bus event", "channel", message.Channel, "error", err)
+			continue
+		}
+		if event.RoomID == "" {
+			event.RoomID = strings.TrimPrefix(message.Channel, redisChannelPrefix)
+		}
+
+		b.mu.Lock()
+		handlers := make([]func(Event), len(b.handlers))
+		copy(handlers, b.handlers)
+		b.mu.Unlock()
+
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+}
+
+func (b *RedisBus) SetMember(roomID string, member Member) error {
+	data, err := json.Marshal(member)
+	if err != nil {
+		return fmt.Errorf("failed to encode member: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	key := redisPresencePrefix + roomID
+	pipe := b.client.TxPipeline()
+	pipe.HSet(ctx, key, member.UserID, data)
+	pipe.Expire(ctx, key, presenceTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to set presence: %w", err)
+	}
+
+	b.mu.Lock()
+	if b.owned[roomID] == nil {
+		b.owned[roomID] = make(map[string]string)
+	}
+	b.owned[roomID][member.UserID] = member.ConnectionID
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *RedisBus) RemoveMember(roomID, userID, connectionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := b.removeMember(ctx, roomID, userID, connectionID); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	if b.owned[roomID][userID] == connectionID {
+		delete(b.owned[roomID], userID)
+		if len(b.owned[roomID]) == 0 {
+			delete(b.owned, roomID)
+		}
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// removeMember reads the member under WATCH and deletes it in a MULTI if it
+// still belongs to connectionID, retrying if another node changed the room
+// in between
+func (b *RedisBus) removeMember(ctx context.Context, roomID, userID, connectionID string) error {
+	key := redisPresencePrefix + roomID
+
+	remove := func(tx *redis.Tx) error {
+		data, err := tx.HGet(ctx, key, userID).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read presence: %w", err)
+		}
+		// A malformed entry belongs to no one and goes too
+		var member Member
+		if err := json.Unmarshal([]byte(data), &member); err == nil && member.ConnectionID != connectionID {
+			return nil
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HDel(ctx, key, userID)
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < updateAttempts; attempt++ {
+		err := b.client.Watch(ctx, remove, key)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, redis.TxFailedErr) {
+			return fmt.Errorf("failed to remove presence: %w", err)
+		}
+	}
+	return fmt.Errorf("failed to remove presence: room %s kept changing", roomID)
+}
+
+func (b *RedisBus) Members(roomID string) (map[string]Member, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	entries, err := b.client.HGetAll(ctx, redisPresencePrefix+roomID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presence: %w", err)
+	}
+	return decodeMembers(roomID, entries), nil
+}
+
+// Rooms scans for presence hashes. Redis deletes a hash with its last field,
+// so every one found has someone in it.
+func (b *RedisBus) Rooms() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	var roomIDs []string
+	iter := b.client.Scan(ctx, 0, redisPresencePrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		roomIDs = append(roomIDs, strings.TrimPrefix(iter.Val(), redisPresencePrefix))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list rooms: %w", err)
+	}
+	return roomIDs, nil
+}
+
+// UpdateMember reads the room's presence under WATCH and writes the member
+// back in a MULTI, retrying if another node changed the room in between
+func (b *RedisBus) UpdateMember(roomID, userID string, update MemberUpdate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	key := redisPresencePrefix + roomID
+
+	apply := func(tx *redis.Tx) error {
+		entries, err := tx.HGetAll(ctx, key).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read presence: %w", err)
+		}
+		members := decodeMembers(roomID, entries)
+		member, exists := members[userID]
+		if !exists {
+			return ErrMemberNotFound
+		}
+
+		updated, err := update(member, members)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(updated)
+		if err != nil {
+			return fmt.Errorf("failed to encode member: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, userID, data)
+			pipe.Expire(ctx, key, presenceTTL)
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < updateAttempts; attempt++ {
+		err := b.client.Watch(ctx, apply, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("failed to update presence: room %s kept changing", roomID)
+}
+
+// decodeMembers parses a room's presence hash, skipping malformed entries
+func decodeMembers(roomID string, entries map[string]string) map[string]Member {
+	members := make(map[string]Member, len(entries))
+	for userID, data := range entries {
+		var member Member
+		if err := json.Unmarshal([]byte(data), &member); err != nil {
+			slog.Warn("Skipping malformed presence", "user_id", userID, "room_id", roomID, "error", err)
+			continue
+		}
+		members[userID] = member
+	}
+	return members
+}
+
+// Close withdraws this node's presence entries, leaving any that another
+// node has since replaced, and disconnects from Redis
+func (b *RedisBus) Close() error {
+	b.mu.Lock()
+	owned := b.owned
+	b.owned = make(map[string]map[string]string)
+	pubsub := b.pubsub
+	b.pubsub = nil
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	for roomID, users := range owned {
+		for userID, connectionID := range users {
+			if err := b.removeMember(ctx, roomID, userID, connectionID); err != nil {
+				slog.Warn("Failed to withdraw presence", "room_id", roomID, "user_id", userID, "error", err)
+			}
+		}
+	}
+
+	if pubsub != nil {
+		pubsub.Close()
+	}
+	return b.client.Close()
+}
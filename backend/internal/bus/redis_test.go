@@ -0,0 +1,155 @@
+package bus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestRedisBus connects a node to a miniredis server
+func newTestRedisBus(t *testing.T, server *miniredis.Miniredis) *RedisBus {
+	t.Helper()
+	bus, err := NewRedisBus("redis://" + server.Addr())
+	if err != nil {
+		t.Fatalf("NewRedisBus() error = %v", err)
+	}
+	t.Cleanup(func() { bus.Close() })
+	return bus
+}
+
+// collect subscribes to a bus and returns the channel its events arrive on
+func collect(t *testing.T, bus Bus) <-chan Event {
+	t.Helper()
+	events := make(chan Event, 16)
+	if err := bus.Subscribe(func(event Event) { events <- event }); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	return events
+}
+
+func receive(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event received")
+		return Event{}
+	}
+}
+
+func TestRedisBusPresence(t *testing.T) {
+	server := miniredis.RunT(t)
+	testPresence(t, newTestRedisBus(t, server), newTestRedisBus(t, server))
+
+	if ttl := server.TTL(redisPresencePrefix + "presence"); ttl <= 0 || ttl > presenceTTL {
+		t.Errorf("presence TTL = %v, want up to %v", ttl, presenceTTL)
+	}
+}
+
+func TestRedisBusSkipsMalformedPresence(t *testing.T) {
+	server := miniredis.RunT(t)
+	bus := newTestRedisBus(t, server)
+	if err := bus.SetMember("room", Member{UserID: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	server.HSet(redisPresencePrefix+"room", "mallory", "{not json")
+
+	members, err := bus.Members("room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members["alice"].UserID != "alice" {
+		t.Fatalf("Members() = %+v, want only alice", members)
+	}
+}
+
+func TestRedisBusFanOut(t *testing.T) {
+	server := miniredis.RunT(t)
+	a, b := newTestRedisBus(t, server), newTestRedisBus(t, server)
+	fromA, fromB := collect(t, a), collect(t, b)
+
+	tests := []struct {
+		name      string
+		publisher *RedisBus
+		event     Event
+	}{
+		{name: "room event from a", publisher: a, event: Event{Kind: EventBroadcast, RoomID: "room1", Exclude: "alice", Message: []byte(`{"type":"join"}`)}},
+		{name: "room event from b", publisher: b, event: Event{Kind: EventAdmit, RoomID: "room2", Target: "bob"}},
+		{name: "server-wide event", publisher: a, event: Event{Kind: EventDisconnectUser, Target: "carol"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.publisher.Publish(tt.event); err != nil {
+				t.Fatal(err)
+			}
+			// Every node hears every event, including the publisher
+			for node, events := range map[string]<-chan Event{"a": fromA, "b": fromB} {
+				got := receive(t, events)
+				if got.Kind != tt.event.Kind || got.RoomID != tt.event.RoomID || got.Target != tt.event.Target ||
+					got.Exclude != tt.event.Exclude || string(got.Message) != string(tt.event.Message) {
+					t.Errorf("node %s received %+v, want %+v", node, got, tt.event)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisBusClose(t *testing.T) {
+	server := miniredis.RunT(t)
+	a, b := newTestRedisBus(t, server), newTestRedisBus(t, server)
+	fromB := collect(t, b)
+	collect(t, a)
+
+	for _, member := range []Member{{UserID: "alice"}, {UserID: "carol"}, {UserID: "dave", ConnectionID: "c1"}} {
+		if err := a.SetMember("room", member); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// bob is only on b, and dave has since reconnected to it
+	for _, member := range []Member{{UserID: "bob"}, {UserID: "dave", ConnectionID: "c2"}} {
+		if err := b.SetMember("room", member); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.RemoveMember("room", "carol", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// The closed node's participants are gone; the other node's remain
+	members, err := b.Members("room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 || members["bob"].UserID != "bob" || members["dave"].ConnectionID != "c2" {
+		t.Fatalf("Members() after close = %+v, want bob and dave's newer connection", members)
+	}
+
+	// The closed node can't publish, and the others keep talking
+	if err := a.Publish(Event{Kind: EventBroadcast, RoomID: "room"}); err == nil {
+		t.Error("Publish() on a closed bus succeeded")
+	}
+	if err := b.Publish(Event{Kind: EventBroadcast, RoomID: "room"}); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, fromB)
+}
+
+func TestNewRedisBusUnreachable(t *testing.T) {
+	server := miniredis.RunT(t)
+	addr := server.Addr()
+	server.Close()
+
+	if _, err := NewRedisBus("redis://" + addr); err == nil {
+		t.Error("NewRedisBus() connected to a stopped server")
+	}
+	if _, err := NewRedisBus("not a url"); err == nil {
+		t.Error("NewRedisBus() accepted an invalid URL")
+	}
+}
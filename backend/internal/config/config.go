@@ -0,0 +1,264 @@
+package config
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/ratelimit"
+)
+
+// Bus backends
+const (
+	BusMemory = "memory"
+	BusRedis  = "redis"
+)
+
+// Mailers
+const (
+	MailerLog  = "log"
+	MailerFile = "file"
+	MailerSMTP = "smtp"
+)
+
+// Config holds settings read from the environment at startup
+type Config struct {
+	DBDriver    string // CHIMECAST_DB_DRIVER: "sqlite" (default) or "postgres"
+	DatabaseURL string // CHIMECAST_DATABASE_URL: SQLite file or PostgreSQL connection URL
+
+	Bus       string // CHIMECAST_BUS: "memory" (default) or "redis"
+	RedisURL  string // CHIMECAST_REDIS_URL
+	LogLevel  string // CHIMECAST_LOG_LEVEL: "debug", "info" (default), "warn" or "error"
+	LogFormat string // CHIMECAST_LOG_FORMAT: "text" (default) or "json"
+
+	DrainPeriod     time.Duration // CHIMECAST_DRAIN_PERIOD: how long to wait for peers to leave on shutdown
+	ReconnectAfter  time.Duration // CHIMECAST_RECONNECT_AFTER: delay suggested to peers before reconnecting
+	ShutdownTimeout time.Duration // CHIMECAST_SHUTDOWN_TIMEOUT: limit for finishing in-flight HTTP requests
+
+	TrustProxy  bool   // CHIMECAST_TRUST_PROXY: take client IPs from X-Forwarded-For / X-Real-IP
+	MetricsAddr string // CHIMECAST_METRICS_ADDR: private listener for /metrics; "off" disables it
+
+	AllowedOrigins     []string      // CHIMECAST_ALLOWED_ORIGINS: comma-separated browser origins for CORS and WebSockets; https://*.example.com allows subdomains on any port
+	CORSMaxAge         time.Duration // CHIMECAST_CORS_MAX_AGE: how long browsers may cache preflight responses
+	CORSExposedHeaders []string      // CHIMECAST_CORS_EXPOSED_HEADERS: comma-separated response headers readable by the web app
+	SessionTTL         time.Duration // CHIMECAST_SESSION_TTL: how long a login lasts
+	CookieSecure       bool          // CHIMECAST_COOKIE_SECURE: send the session cookie over HTTPS only
+	CookieSameSite     http.SameSite // CHIMECAST_COOKIE_SAMESITE: "lax" (default), "strict" or "none"
+	CookieDomain       string        // CHIMECAST_COOKIE_DOMAIN: empty for a host-only cookie
+
+	LoginWindow      time.Duration // CHIMECAST_LOGIN_WINDOW: sliding window for login limits
+	LoginIPLimit     int           // CHIMECAST_LOGIN_IP_LIMIT: attempts per IP per window, 0 disables
+	LoginUserLimit   int           // CHIMECAST_LOGIN_USER_LIMIT: attempts per username per window, 0 disables
+	LockoutThreshold int           // CHIMECAST_LOCKOUT_THRESHOLD: consecutive failures before lockout, 0 disables
+	LockoutBase      time.Duration // CHIMECAST_LOCKOUT_BASE: first lockout, doubled per further failure
+	LockoutMax       time.Duration // CHIMECAST_LOCKOUT_MAX: longest lockout
+
+	AuthRateLimit       ratelimit.Rate            // CHIMECAST_RATE_LIMIT_AUTH: requests per client IP to /api/auth/v1
+	APIRateLimit        ratelimit.Rate            // CHIMECAST_RATE_LIMIT_API: requests per user to /api/room/v1
+	CreateRoomRateLimit ratelimit.Rate            // CHIMECAST_RATE_LIMIT_CREATE_ROOM: rooms created per user
+	MessageRateLimits   map[string]ratelimit.Rate // CHIMECAST_WS_RATE_LIMITS: WebSocket messages per connection, by type
+
+	PublicURL            string // CHIMECAST_PUBLIC_URL: web app address used in emailed links
+	Mailer               string // CHIMECAST_MAILER: "log" (default), "file" or "smtp"
+	MailDir              string // CHIMECAST_MAIL_DIR: where the file mailer writes messages
+	MailFrom             string // CHIMECAST_MAIL_FROM
+	SMTPAddr             string // CHIMECAST_SMTP_ADDR: host:port of the SMTP relay
+	SMTPUsername         string // CHIMECAST_SMTP_USERNAME
+	SMTPPassword         string // CHIMECAST_SMTP_PASSWORD
+	RequireVerifiedEmail bool   // CHIMECAST_REQUIRE_VERIFIED_EMAIL: block unverified users from creating rooms
+	RequireMFA           bool   // CHIMECAST_REQUIRE_MFA: users must enrol two-factor authentication to use rooms
+	AvatarDir            string // CHIMECAST_AVATAR_DIR: where uploaded profile pictures are stored
+
+	OIDCIssuer       string   // CHIMECAST_OIDC_ISSUER: OpenID Connect issuer URL; empty disables single sign-on
+	OIDCClientID     string   // CHIMECAST_OIDC_CLIENT_ID
+	OIDCClientSecret string   // CHIMECAST_OIDC_CLIENT_SECRET
+	OIDCRedirectURL  string   // CHIMECAST_OIDC_REDIRECT_URL: this server's callback URL
+	OIDCScopes       []string // CHIMECAST_OIDC_SCOPES: space-separated
+	OIDCLinkByEmail  bool     // CHIMECAST_OIDC_LINK_BY_EMAIL: link first SSO logins to local accounts with the same verified email
+
+	AdminUsers   []string // CHIMECAST_ADMIN_USERS: comma-separated usernames granted the admin role on startup
+	AuditLogFile string   // CHIMECAST_AUDIT_LOG_FILE: also append audit events to this file as JSON lines; empty for none
+
+	WebhookMaxAttempts  int           // CHIMECAST_WEBHOOK_MAX_ATTEMPTS: attempts before a delivery is given up on
+	WebhookRetryBase    time.Duration // CHIMECAST_WEBHOOK_RETRY_BASE: first retry delay; doubles with each further one
+	WebhookTimeout      time.Duration // CHIMECAST_WEBHOOK_TIMEOUT: per delivery attempt
+	WebhookAllowPrivate bool          // CHIMECAST_WEBHOOK_ALLOW_PRIVATE: let webhooks reach loopback and private addresses
+}
+
+func Load() *Config {
+	publicURL := getEnv("CHIMECAST_PUBLIC_URL", "http://localhost:5173")
+	dbDriver := getEnv("CHIMECAST_DB_DRIVER", "sqlite")
+	databaseURL := "./chimecast.db"
+	if dbDriver == "postgres" {
+		databaseURL = "postgres://localhost:5432/chimecast?sslmode=disable"
+	}
+
+	cfg := &Config{
+		DBDriver:    dbDriver,
+		DatabaseURL: getEnv("CHIMECAST_DATABASE_URL", databaseURL),
+
+		Bus:       getEnv("CHIMECAST_BUS", BusMemory),
+		RedisURL:  getEnv("CHIMECAST_REDIS_URL", "redis://localhost:6379/0"),
+		LogLevel:  getEnv("CHIMECAST_LOG_LEVEL", "info"),
+		LogFormat: getEnv("CHIMECAST_LOG_FORMAT", "text"),
+
+		DrainPeriod:     getDuration("CHIMECAST_DRAIN_PERIOD", 10*time.Second),
+		ReconnectAfter:  getDuration("CHIMECAST_RECONNECT_AFTER", time.Second),
+		ShutdownTimeout: getDuration("CHIMECAST_SHUTDOWN_TIMEOUT", 5*time.Second),
+
+		TrustProxy:  getBool("CHIMECAST_TRUST_PROXY", false),
+		MetricsAddr: getEnv("CHIMECAST_METRICS_ADDR", "localhost:9090"),
+
+		AllowedOrigins:     getList("CHIMECAST_ALLOWED_ORIGINS", publicURL),
+		CORSMaxAge:         getDuration("CHIMECAST_CORS_MAX_AGE", 10*time.Minute),
+		CORSExposedHeaders: getList("CHIMECAST_CORS_EXPOSED_HEADERS", "X-Request-ID,Retry-After"),
+		SessionTTL:         getDuration("CHIMECAST_SESSION_TTL", 24*time.Hour),
+		CookieSecure:       getBool("CHIMECAST_COOKIE_SECURE", strings.HasPrefix(publicURL, "https://")),
+		CookieSameSite:     getSameSite("CHIMECAST_COOKIE_SAMESITE", http.SameSiteLaxMode),
+		CookieDomain:       getEnv("CHIMECAST_COOKIE_DOMAIN", ""),
+
+		LoginWindow:      getDuration("CHIMECAST_LOGIN_WINDOW", 15*time.Minute),
+		LoginIPLimit:     getInt("CHIMECAST_LOGIN_IP_LIMIT", 20),
+		LoginUserLimit:   getInt("CHIMECAST_LOGIN_USER_LIMIT", 10),
+		LockoutThreshold: getInt("CHIMECAST_LOCKOUT_THRESHOLD", 5),
+		LockoutBase:      getDuration("CHIMECAST_LOCKOUT_BASE", 30*time.Second),
+		LockoutMax:       getDuration("CHIMECAST_LOCKOUT_MAX", time.Hour),
+
+		AuthRateLimit:       getRate("CHIMECAST_RATE_LIMIT_AUTH", "30/1m"),
+		APIRateLimit:        getRate("CHIMECAST_RATE_LIMIT_API", "600/1m"),
+		CreateRoomRateLimit: getRate("CHIMECAST_RATE_LIMIT_CREATE_ROOM", "10/1m"),
+		MessageRateLimits:   getRates("CHIMECAST_WS_RATE_LIMITS", "ice-candidate=100/10s,offer=20/10s,answer=20/10s,*=50/10s"),
+
+		PublicURL:            publicURL,
+		Mailer:               getEnv("CHIMECAST_MAILER", MailerLog),
+		MailDir:              getEnv("CHIMECAST_MAIL_DIR", "./mail"),
+		MailFrom:             getEnv("CHIMECAST_MAIL_FROM", "ChimeCast <no-reply@localhost>"),
+		SMTPAddr:             getEnv("CHIMECAST_SMTP_ADDR", "localhost:25"),
+		SMTPUsername:         getEnv("CHIMECAST_SMTP_USERNAME", ""),
+		SMTPPassword:         getEnv("CHIMECAST_SMTP_PASSWORD", ""),
+		RequireVerifiedEmail: getBool("CHIMECAST_REQUIRE_VERIFIED_EMAIL", false),
+		RequireMFA:           getBool("CHIMECAST_REQUIRE_MFA", false),
+		AvatarDir:            getEnv("CHIMECAST_AVATAR_DIR", "./avatars"),
+
+		OIDCIssuer:       getEnv("CHIMECAST_OIDC_ISSUER", ""),
+		OIDCClientID:     getEnv("CHIMECAST_OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("CHIMECAST_OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getEnv("CHIMECAST_OIDC_REDIRECT_URL", "http://localhost:8081/api/auth/v1/oidc/callback"),
+		OIDCScopes:       strings.Fields(getEnv("CHIMECAST_OIDC_SCOPES", "openid profile email")),
+		OIDCLinkByEmail:  getBool("CHIMECAST_OIDC_LINK_BY_EMAIL", true),
+
+		AdminUsers:   getList("CHIMECAST_ADMIN_USERS", ""),
+		AuditLogFile: getEnv("CHIMECAST_AUDIT_LOG_FILE", ""),
+
+		WebhookMaxAttempts:  getInt("CHIMECAST_WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookRetryBase:    getDuration("CHIMECAST_WEBHOOK_RETRY_BASE", 30*time.Second),
+		WebhookTimeout:      getDuration("CHIMECAST_WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookAllowPrivate: getBool("CHIMECAST_WEBHOOK_ALLOW_PRIVATE", false),
+	}
+
+	// Browsers drop SameSite=None cookies that aren't Secure
+	if cfg.CookieSameSite == http.SameSiteNoneMode && !cfg.CookieSecure {
+		slog.Warn("CHIMECAST_COOKIE_SAMESITE=none requires secure cookies; enabling CHIMECAST_COOKIE_SECURE")
+		cfg.CookieSecure = true
+	}
+	return cfg
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Ignoring invalid duration", "key", key, "value", value, "error", err)
+		return fallback
+	}
+	return duration
+}
+
+func getInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("Ignoring invalid number", "key", key, "value", value, "error", err)
+		return fallback
+	}
+	return number
+}
+
+func getBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("Ignoring invalid boolean", "key", key, "value", value, "error", err)
+		return fallback
+	}
+	return flag
+}
+
+// getList splits a comma-separated value, dropping empty entries
+func getList(key, fallback string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, fallback), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getSameSite(key string, fallback http.SameSite) http.SameSite {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	switch strings.ToLower(value) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	slog.Warn("Ignoring invalid SameSite mode", "key", key, "value", value)
+	return fallback
+}
+
+func getRate(key, fallback string) ratelimit.Rate {
+	rate, err := ratelimit.ParseRate(getEnv(key, fallback))
+	if err != nil {
+		slog.Warn("Ignoring invalid rate limit", "key", key, "error", err)
+		rate, _ = ratelimit.ParseRate(fallback)
+	}
+	return rate
+}
+
+func getRates(key, fallback string) map[string]ratelimit.Rate {
+	rates, err := ratelimit.ParseRates(getEnv(key, fallback))
+	if err != nil {
+		slog.Warn("Ignoring invalid rate limits", "key", key, "error", err)
+		rates, _ = ratelimit.ParseRates(fallback)
+	}
+	return rates
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" driver
+	"github.com/mattn/go-sqlite3"
+)
+
+// Database drivers
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+)
+
+// DB is a database handle for one of the supported drivers. Repositories
+// write their queries with ? placeholders; Exec, Query, QueryRow, Prepare and
+// Begin rewrite them into the driver's own style.
+type DB struct {
+	*sql.DB
+	Driver string
+
+	// RoomSearch is set when SQLite has a full-text index of room names,
+	// which needs a build with FTS5 (go build -tags sqlite_fts5)
+	RoomSearch bool
+}
+
+// Tx is a transaction started with DB.Begin
+type Tx struct {
+	*sql.Tx
+	driver string
+}
+
+// Open connects to the database and brings its schema up to date
+func Open(driver, dsn string) (*DB, error) {
+	var (
+		conn *sql.DB
+		err  error
+	)
+	switch driver {
+	case SQLite:
+		conn, err = sql.Open("sqlite3", dsn)
+	case Postgres:
+		conn, err = sql.Open("pgx", dsn)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	roomSearch := false
+	if driver == Postgres {
+		err = createPostgresTables(conn)
+	} else if err = createTables(conn); err == nil {
+		roomSearch, err = createRoomSearchIndex(conn)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &DB{DB: conn, Driver: driver, RoomSearch: roomSearch}, nil
+}
+
+func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.DB.Exec(rebind(d.Driver, query), args...)
+}
+
+func (d *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.DB.Query(rebind(d.Driver, query), args...)
+}
+
+func (d *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.DB.QueryRow(rebind(d.Driver, query), args...)
+}
+
+func (d *DB) Prepare(query string) (*sql.Stmt, error) {
+	return d.DB.Prepare(rebind(d.Driver, query))
+}
+
+func (d *DB) Begin() (*Tx, error) {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, driver: d.Driver}, nil
+}
+
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(rebind(t.driver, query), args...)
+}
+
+func (t *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(rebind(t.driver, query), args...)
+}
+
+func (t *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(rebind(t.driver, query), args...)
+}
+
+// rebind turns ? placeholders into $1, $2, ... for PostgreSQL. Question
+// marks inside quoted strings and identifiers are left alone.
+func rebind(driver, query string) string {
+	if driver != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote rune
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// IsUniqueViolation reports whether err is a failed UNIQUE or primary key
+// constraint
+func IsUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrConstraint
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505" // unique_violation
+	}
+	return false
+}
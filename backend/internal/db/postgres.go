@@ -0,0 +1,176 @@
+package db
+
+import (
+	"database/sql"
+	"log/slog"
+)
+
+// postgresSchema mirrors the SQLite tables. Identifiers are unquoted so they
+// fold to lower case and match the repositories' unquoted queries; flags are
+// real booleans rather than SQLite's 0/1 integers.
+var postgresSchema = []struct{ table, sql string }{
+	{"users", `CREATE TABLE IF NOT EXISTS users (
+        Username TEXT PRIMARY KEY,
+        ID TEXT NOT NULL UNIQUE,
+        Email TEXT UNIQUE,
+        Name TEXT NOT NULL DEFAULT '',
+        HashedPassword TEXT NOT NULL DEFAULT '',
+        CreatedAt TIMESTAMPTZ NOT NULL,
+        EmailVerified BOOLEAN NOT NULL DEFAULT FALSE,
+        TOTPSecret TEXT NOT NULL DEFAULT '',
+        MFAEnabled BOOLEAN NOT NULL DEFAULT FALSE,
+        TOTPLastStep BIGINT NOT NULL DEFAULT 0,
+        AvatarName TEXT NOT NULL DEFAULT '',
+        PreferredLanguage TEXT NOT NULL DEFAULT '',
+        DeletedAt TIMESTAMPTZ
+    )`},
+	// Columns added after PostgreSQL support was released
+	{"users", `ALTER TABLE users ADD COLUMN IF NOT EXISTS IsAdmin BOOLEAN NOT NULL DEFAULT FALSE`},
+	{"users", `ALTER TABLE users ADD COLUMN IF NOT EXISTS DisabledAt TIMESTAMPTZ`},
+	{"users", `ALTER TABLE users ADD COLUMN IF NOT EXISTS SessionsRevokedAt TIMESTAMPTZ`},
+	{"rooms", `CREATE TABLE IF NOT EXISTS rooms (
+        ID TEXT PRIMARY KEY,
+        Name TEXT NOT NULL DEFAULT '',
+        HostID TEXT NOT NULL REFERENCES users (ID),
+        CreatedAt TIMESTAMPTZ NOT NULL,
+        Status INTEGER NOT NULL DEFAULT 0,
+        ScreenSharePolicy TEXT NOT NULL DEFAULT 'anyone'
+    )`},
+	{"rooms", `ALTER TABLE rooms ADD COLUMN IF NOT EXISTS Visibility TEXT NOT NULL DEFAULT 'public'`},
+	{"rooms", `CREATE INDEX IF NOT EXISTS rooms_listing ON rooms (Status, CreatedAt DESC, ID DESC)`},
+	{"rooms", `CREATE INDEX IF NOT EXISTS rooms_host ON rooms (HostID, Status, CreatedAt DESC, ID DESC)`},
+	// Room name search; pg_trgm is a trusted extension, so the database owner
+	// can create it
+	{"rooms", `CREATE EXTENSION IF NOT EXISTS pg_trgm`},
+	{"rooms", `CREATE INDEX IF NOT EXISTS rooms_name_trgm ON rooms USING GIN (Name gin_trgm_ops)`},
+	{"room_invitations", `CREATE TABLE IF NOT EXISTS room_invitations (
+        RoomID TEXT NOT NULL REFERENCES rooms (ID),
+        UserID TEXT NOT NULL REFERENCES users (ID),
+        InvitedAt TIMESTAMPTZ NOT NULL,
+        PRIMARY KEY (RoomID, UserID)
+    )`},
+	{"room_invitations", `CREATE INDEX IF NOT EXISTS room_invitations_user ON room_invitations (UserID)`},
+	{"login_attempts", `CREATE TABLE IF NOT EXISTS login_attempts (
+        ID BIGSERIAL PRIMARY KEY,
+        Username TEXT NOT NULL,
+        IP TEXT NOT NULL,
+        Result TEXT NOT NULL,
+        AttemptedAt TIMESTAMPTZ NOT NULL
+    )`},
+	{"login_attempts", `CREATE INDEX IF NOT EXISTS login_attempts_username ON login_attempts (Username, AttemptedAt)`},
+	{"login_attempts", `CREATE INDEX IF NOT EXISTS login_attempts_ip ON login_attempts (IP, AttemptedAt)`},
+	{"user_tokens", `CREATE TABLE IF NOT EXISTS user_tokens (
+        TokenHash TEXT PRIMARY KEY,
+        UserID TEXT NOT NULL REFERENCES users (ID),
+        Purpose TEXT NOT NULL,
+        CreatedAt TIMESTAMPTZ NOT NULL,
+        ExpiresAt TIMESTAMPTZ NOT NULL,
+        UsedAt TIMESTAMPTZ
+    )`},
+	{"user_tokens", `CREATE INDEX IF NOT EXISTS user_tokens_user ON user_tokens (UserID, Purpose)`},
+	{"mfa_recovery_codes", `CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+        CodeHash TEXT PRIMARY KEY,
+        UserID TEXT NOT NULL REFERENCES users (ID),
+        UsedAt TIMESTAMPTZ
+    )`},
+	{"mfa_recovery_codes", `CREATE INDEX IF NOT EXISTS mfa_recovery_codes_user ON mfa_recovery_codes (UserID)`},
+	{"user_identities", `CREATE TABLE IF NOT EXISTS user_identities (
+        Issuer TEXT NOT NULL,
+        Subject TEXT NOT NULL,
+        UserID TEXT NOT NULL REFERENCES users (ID),
+        Email TEXT,
+        CreatedAt TIMESTAMPTZ NOT NULL,
+        PRIMARY KEY (Issuer, Subject)
+    )`},
+	{"api_tokens", `CREATE TABLE IF NOT EXISTS api_tokens (
+        ID TEXT PRIMARY KEY,
+        UserID TEXT NOT NULL REFERENCES users (ID),
+        Name TEXT NOT NULL,
+        TokenHash TEXT NOT NULL UNIQUE,
+        Scopes TEXT NOT NULL,
+        CreatedAt TIMESTAMPTZ NOT NULL,
+        ExpiresAt TIMESTAMPTZ NOT NULL,
+        LastUsedAt TIMESTAMPTZ
+    )`},
+	{"api_tokens", `CREATE INDEX IF NOT EXISTS api_tokens_user ON api_tokens (UserID)`},
+	{"meetings", `CREATE TABLE IF NOT EXISTS meetings (
+        ID TEXT PRIMARY KEY,
+        RoomID TEXT NOT NULL REFERENCES rooms (ID),
+        StartedAt TIMESTAMPTZ NOT NULL,
+        EndedAt TIMESTAMPTZ
+    )`},
+	{"meetings", `CREATE UNIQUE INDEX IF NOT EXISTS meetings_in_progress ON meetings (RoomID) WHERE EndedAt IS NULL`},
+	{"meetings", `CREATE INDEX IF NOT EXISTS meetings_room ON meetings (RoomID, StartedAt)`},
+	{"attendance", `CREATE TABLE IF NOT EXISTS attendance (
+        ID TEXT PRIMARY KEY,
+        MeetingID TEXT NOT NULL REFERENCES meetings (ID),
+        RoomID TEXT NOT NULL,
+        UserID TEXT NOT NULL REFERENCES users (ID),
+        JoinedAt TIMESTAMPTZ NOT NULL,
+        LeftAt TIMESTAMPTZ,
+        JoinReason TEXT NOT NULL,
+        LeaveReason TEXT NOT NULL DEFAULT ''
+    )`},
+	{"attendance", `CREATE INDEX IF NOT EXISTS attendance_meeting ON attendance (MeetingID)`},
+	{"attendance", `CREATE INDEX IF NOT EXISTS attendance_user ON attendance (UserID, JoinedAt)`},
+	{"attendance", `CREATE INDEX IF NOT EXISTS attendance_room_user ON attendance (RoomID, UserID)`},
+	{"audit_events", `CREATE TABLE IF NOT EXISTS audit_events (
+        ID BIGSERIAL PRIMARY KEY,
+        Action TEXT NOT NULL,
+        ActorID TEXT NOT NULL,
+        TargetType TEXT NOT NULL,
+        TargetID TEXT NOT NULL,
+        RoomID TEXT NOT NULL,
+        IP TEXT NOT NULL,
+        Details TEXT NOT NULL,
+        CreatedAt TIMESTAMPTZ NOT NULL
+    )`},
+	{"audit_events", `CREATE INDEX IF NOT EXISTS audit_events_action ON audit_events (Action, ID)`},
+	{"audit_events", `CREATE INDEX IF NOT EXISTS audit_events_actor ON audit_events (ActorID, ID)`},
+	{"audit_events", `CREATE INDEX IF NOT EXISTS audit_events_target ON audit_events (TargetID, ID)`},
+	{"audit_events", `CREATE INDEX IF NOT EXISTS audit_events_created ON audit_events (CreatedAt)`},
+	{"audit_events", `CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger LANGUAGE plpgsql AS $$
+    BEGIN
+        RAISE EXCEPTION 'audit_events is append-only';
+    END
+    $$`},
+	{"audit_events", `CREATE OR REPLACE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_events
+        FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only()`},
+	{"webhooks", `CREATE TABLE IF NOT EXISTS webhooks (
+        ID TEXT PRIMARY KEY,
+        UserID TEXT NOT NULL REFERENCES users (ID),
+        RoomID TEXT NOT NULL,
+        URL TEXT NOT NULL,
+        Secret TEXT NOT NULL,
+        Events TEXT NOT NULL,
+        CreatedAt TIMESTAMPTZ NOT NULL
+    )`},
+	{"webhooks", `CREATE INDEX IF NOT EXISTS webhooks_user ON webhooks (UserID)`},
+	{"webhooks", `CREATE INDEX IF NOT EXISTS webhooks_room ON webhooks (RoomID)`},
+	{"webhook_deliveries", `CREATE TABLE IF NOT EXISTS webhook_deliveries (
+        ID TEXT PRIMARY KEY,
+        WebhookID TEXT NOT NULL REFERENCES webhooks (ID),
+        EventID TEXT NOT NULL,
+        Event TEXT NOT NULL,
+        Payload TEXT NOT NULL,
+        Status TEXT NOT NULL,
+        Attempts INTEGER NOT NULL DEFAULT 0,
+        ResponseStatus INTEGER NOT NULL DEFAULT 0,
+        LastError TEXT NOT NULL DEFAULT '',
+        CreatedAt TIMESTAMPTZ NOT NULL,
+        NextAttemptAt TIMESTAMPTZ,
+        DeliveredAt TIMESTAMPTZ
+    )`},
+	{"webhook_deliveries", `CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries (WebhookID, CreatedAt)`},
+	{"webhook_deliveries", `CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (NextAttemptAt) WHERE Status = 'pending'`},
+}
+
+func createPostgresTables(db *sql.DB) error {
+	for _, statement := range postgresSchema {
+		if _, err := db.Exec(statement.sql); err != nil {
+			slog.Error("Error creating table", "table", statement.table, "error", err)
+			return err
+		}
+	}
+	return nil
+}
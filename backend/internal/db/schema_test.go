@@ -0,0 +1,204 @@
+package db
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// schema is a database's tables, each with its columns, and its index names,
+// all lower case
+type schema struct {
+	tables  map[string][]string
+	indexes []string
+}
+
+// Objects only one backend has, each standing in for something the other
+// does its own way
+var (
+	// Full-text search of room names; PostgreSQL uses rooms_name_trgm
+	sqliteOnly = regexp.MustCompile(`^rooms_fts`)
+	// Trigram index for room name search; SQLite uses rooms_fts
+	postgresOnly = []string{"rooms_name_trgm"}
+)
+
+var (
+	createTable  = regexp.MustCompile(`(?is)^CREATE TABLE IF NOT EXISTS (\w+) \((.*)\)$`)
+	addColumn    = regexp.MustCompile(`(?i)^ALTER TABLE (\w+) ADD COLUMN IF NOT EXISTS (\w+)`)
+	createIndex  = regexp.MustCompile(`(?i)^CREATE (?:UNIQUE )?INDEX IF NOT EXISTS (\w+)`)
+	tableElement = regexp.MustCompile(`(?i)^(PRIMARY|FOREIGN|UNIQUE|CONSTRAINT|CHECK)\b`)
+)
+
+// postgresTables reads the schema out of the PostgreSQL statements
+func postgresTables(t *testing.T) schema {
+	t.Helper()
+	s := schema{tables: make(map[string][]string)}
+	for _, statement := range postgresSchema {
+		sql := strings.TrimSpace(statement.sql)
+		if m := createTable.FindStringSubmatch(sql); m != nil {
+			table := strings.ToLower(m[1])
+			for _, element := range splitTopLevel(m[2]) {
+				if element == "" || tableElement.MatchString(element) {
+					continue
+				}
+				s.tables[table] = append(s.tables[table], strings.ToLower(strings.Fields(element)[0]))
+			}
+		} else if m := addColumn.FindStringSubmatch(sql); m != nil {
+			table := strings.ToLower(m[1])
+			s.tables[table] = append(s.tables[table], strings.ToLower(m[2]))
+		} else if m := createIndex.FindStringSubmatch(sql); m != nil {
+			if name := strings.ToLower(m[1]); !slices.Contains(postgresOnly, name) {
+				s.indexes = append(s.indexes, name)
+			}
+		}
+	}
+	return s
+}
+
+// splitTopLevel splits a table body on the commas that aren't inside
+// parentheses
+func splitTopLevel(body string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range body {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(body[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(body[start:]))
+}
+
+// sqliteTables reads the schema SQLite ends up with after Open
+func sqliteTables(t *testing.T) schema {
+	t.Helper()
+	database, err := Open(SQLite, filepath.Join(t.TempDir(), "chimecast.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	names := func(query string) []string {
+		rows, err := database.Query(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var names []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				t.Fatal(err)
+			}
+			if name = strings.ToLower(name); !sqliteOnly.MatchString(name) {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	s := schema{
+		tables: make(map[string][]string),
+		// Indexes SQLite makes itself for keys have no SQL
+		indexes: names(`SELECT name FROM sqlite_master WHERE type = 'index' AND sql IS NOT NULL`),
+	}
+	for _, table := range names(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`) {
+		s.tables[table] = names(`SELECT name FROM pragma_table_info('` + table + `')`)
+	}
+	return s
+}
+
+// TestSchemasMatch keeps the two schemas, which are written separately, from
+// drifting apart: every table, column and index must exist in both
+func TestSchemasMatch(t *testing.T) {
+	postgres := postgresTables(t)
+	sqlite := sqliteTables(t)
+
+	tables := make(map[string]bool)
+	for table := range postgres.tables {
+		tables[table] = true
+	}
+	for table := range sqlite.tables {
+		tables[table] = true
+	}
+	for table := range tables {
+		t.Run(table, func(t *testing.T) {
+			pg, ok := postgres.tables[table]
+			if !ok {
+				t.Fatal("missing from the PostgreSQL schema")
+			}
+			lite, ok := sqlite.tables[table]
+			if !ok {
+				t.Fatal("missing from the SQLite schema")
+			}
+			if missing := difference(lite, pg); len(missing) > 0 {
+				t.Errorf("columns missing from PostgreSQL: %v", missing)
+			}
+			if missing := difference(pg, lite); len(missing) > 0 {
+				t.Errorf("columns missing from SQLite: %v", missing)
+			}
+		})
+	}
+
+	t.Run("indexes", func(t *testing.T) {
+		if missing := difference(sqlite.indexes, postgres.indexes); len(missing) > 0 {
+			t.Errorf("indexes missing from PostgreSQL: %v", missing)
+		}
+		if missing := difference(postgres.indexes, sqlite.indexes); len(missing) > 0 {
+			t.Errorf("indexes missing from SQLite: %v", missing)
+		}
+	})
+}
+
+// difference returns the names in a that aren't in b, sorted
+func difference(a, b []string) []string {
+	var missing []string
+	for _, name := range a {
+		if !slices.Contains(b, name) {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver string
+		query  string
+		want   string
+	}{
+		{name: "sqlite is unchanged", driver: SQLite, query: "SELECT * FROM users WHERE ID = ?", want: "SELECT * FROM users WHERE ID = ?"},
+		{name: "placeholders are numbered", driver: Postgres, query: "UPDATE users SET Name = ? WHERE ID = ?", want: "UPDATE users SET Name = $1 WHERE ID = $2"},
+		{name: "no placeholders", driver: Postgres, query: "SELECT 1", want: "SELECT 1"},
+		{name: "question mark in a string", driver: Postgres, query: "SELECT '?' WHERE ID = ?", want: "SELECT '?' WHERE ID = $1"},
+		{name: "question mark in an identifier", driver: Postgres, query: `SELECT "a?" FROM t WHERE ID = ?`, want: `SELECT "a?" FROM t WHERE ID = $1`},
+		{name: "escape character in a string", driver: Postgres, query: `SELECT 1 WHERE Name LIKE ? ESCAPE '\' AND ID = ?`, want: `SELECT 1 WHERE Name LIKE $1 ESCAPE '\' AND ID = $2`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rebind(tt.driver, tt.query); got != tt.want {
+				t.Errorf("rebind() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,20 +2,11 @@ package db
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
+	"log/slog"
+	"strings"
 )
 
-func CreateDB() (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", "./chimecast.db")
-	if err != nil {
-		return nil, err
-	}
-	if err = createTables(db); err != nil {
-		return nil, err
-	}
-	return db, nil
-}
-
 func createTables(db *sql.DB) error {
 	// Create user table first since rooms will reference it
 	err := createUserTable(db)
@@ -26,6 +17,42 @@ func createTables(db *sql.DB) error {
 	if err != nil {
 		return err
 	}
+	err = createRoomInvitationTable(db)
+	if err != nil {
+		return err
+	}
+	err = createLoginAttemptTable(db)
+	if err != nil {
+		return err
+	}
+	err = createUserTokenTable(db)
+	if err != nil {
+		return err
+	}
+	err = createRecoveryCodeTable(db)
+	if err != nil {
+		return err
+	}
+	err = createUserIdentityTable(db)
+	if err != nil {
+		return err
+	}
+	err = createAPITokenTable(db)
+	if err != nil {
+		return err
+	}
+	err = createAttendanceTables(db)
+	if err != nil {
+		return err
+	}
+	err = createAuditEventTable(db)
+	if err != nil {
+		return err
+	}
+	err = createWebhookTables(db)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -41,9 +68,28 @@ func createUserTable(db *sql.DB) error {
 
 	_, err := db.Exec(createUserTableSQL)
 	if err != nil {
-		log.Printf("Error creating User table: %s", err)
+		slog.Error("Error creating User table", "error", err)
 		return err
 	}
+
+	// Columns added after the table was first released
+	columns := []struct{ name, definition string }{
+		{"EmailVerified", `INTEGER NOT NULL DEFAULT 0`},
+		{"TOTPSecret", `TEXT NOT NULL DEFAULT ''`},        // Base32 secret; set on enrolment, kept once confirmed
+		{"MFAEnabled", `INTEGER NOT NULL DEFAULT 0`},      // Whether logins need a second factor
+		{"TOTPLastStep", `INTEGER NOT NULL DEFAULT 0`},    // Last time step accepted, to stop code replay
+		{"AvatarName", `TEXT NOT NULL DEFAULT ''`},        // File name in the avatar store; empty for none
+		{"PreferredLanguage", `TEXT NOT NULL DEFAULT ''`}, // BCP 47 tag, e.g. "en" or "pt-BR"
+		{"DeletedAt", `DATETIME`},                         // Set when the account was erased and anonymized
+		{"IsAdmin", `INTEGER NOT NULL DEFAULT 0`},         // Whether the user may use the admin API
+		{"DisabledAt", `DATETIME`},                        // Set while an admin has disabled the account
+		{"SessionsRevokedAt", `DATETIME`},                 // Sessions started before this are refused
+	}
+	for _, column := range columns {
+		if err := addColumnIfMissing(db, "users", column.name, column.definition); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -54,12 +100,338 @@ func createRoomTable(db *sql.DB) error {
         "HostID" TEXT NOT NULL,        -- ID of the user who created the room
         "CreatedAt" DATETIME,          -- Time of creating room
         "Status" INTEGER,              -- Room status: 0 for inactive, 1 for active
+        "ScreenSharePolicy" TEXT NOT NULL DEFAULT 'anyone', -- Who may share their screen
+        "Visibility" TEXT NOT NULL DEFAULT 'public', -- Who may see and join it: private, unlisted or public
         FOREIGN KEY ("HostID") REFERENCES users("ID")
     );`
 
 	_, err := db.Exec(createRoomTableSQL)
 	if err != nil {
-		log.Printf("Error creating Rooms table: %s", err)
+		slog.Error("Error creating Rooms table", "error", err)
+		return err
+	}
+
+	// Columns added after the table was first released
+	if err := addColumnIfMissing(db, "rooms", "ScreenSharePolicy", `TEXT NOT NULL DEFAULT 'anyone'`); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "rooms", "Visibility", `TEXT NOT NULL DEFAULT 'public'`); err != nil {
+		return err
+	}
+
+	// The room list's order, overall and by host
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS rooms_listing ON rooms ("Status", "CreatedAt" DESC, "ID" DESC);
+    CREATE INDEX IF NOT EXISTS rooms_host ON rooms ("HostID", "Status", "CreatedAt" DESC, "ID" DESC);`)
+	if err != nil {
+		slog.Error("Error creating Rooms indexes", "error", err)
+		return err
+	}
+	return nil
+}
+
+// createRoomSearchIndex keeps a full-text index of room names when SQLite
+// was built with FTS5, and reports whether it does. Without FTS5 the triggers
+// that maintain the index are dropped so writes to rooms keep working, and
+// the index is rebuilt when a build with FTS5 next opens the database.
+func createRoomSearchIndex(db *sql.DB) (bool, error) {
+	var available bool
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&available); err != nil {
+		return false, err
+	}
+	if !available {
+		_, err := db.Exec(`DROP TRIGGER IF EXISTS rooms_fts_insert;
+    DROP TRIGGER IF EXISTS rooms_fts_update;
+    DROP TRIGGER IF EXISTS rooms_fts_delete;`)
+		if err != nil {
+			slog.Error("Error dropping Room search triggers", "error", err)
+			return false, err
+		}
+		slog.Info("SQLite was built without FTS5; room search falls back to LIKE")
+		return false, nil
+	}
+
+	var maintained bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'trigger' AND name = 'rooms_fts_insert')`).Scan(&maintained)
+	if err != nil {
+		return false, err
+	}
+
+	createRoomSearchIndexSQL := `CREATE VIRTUAL TABLE IF NOT EXISTS rooms_fts USING fts5 (RoomID UNINDEXED, Name);
+    CREATE TRIGGER IF NOT EXISTS rooms_fts_insert AFTER INSERT ON rooms BEGIN
+        INSERT INTO rooms_fts (RoomID, Name) VALUES (new.ID, new.Name);
+    END;
+    CREATE TRIGGER IF NOT EXISTS rooms_fts_update AFTER UPDATE OF Name ON rooms BEGIN
+        UPDATE rooms_fts SET Name = new.Name WHERE RoomID = old.ID;
+    END;
+    CREATE TRIGGER IF NOT EXISTS rooms_fts_delete AFTER DELETE ON rooms BEGIN
+        DELETE FROM rooms_fts WHERE RoomID = old.ID;
+    END;`
+	if !maintained {
+		// New, or missed writes while FTS5 was unavailable
+		createRoomSearchIndexSQL += `
+    DELETE FROM rooms_fts;
+    INSERT INTO rooms_fts (RoomID, Name) SELECT ID, Name FROM rooms;`
+	}
+	if _, err := db.Exec(createRoomSearchIndexSQL); err != nil {
+		slog.Error("Error creating Room search index", "error", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func createRoomInvitationTable(db *sql.DB) error {
+	createRoomInvitationTableSQL := `CREATE TABLE IF NOT EXISTS room_invitations (
+        "RoomID" TEXT NOT NULL,
+        "UserID" TEXT NOT NULL,        -- Invitee
+        "InvitedAt" DATETIME NOT NULL,
+        PRIMARY KEY ("RoomID", "UserID"),
+        FOREIGN KEY ("RoomID") REFERENCES rooms("ID"),
+        FOREIGN KEY ("UserID") REFERENCES users("ID")
+    );
+    CREATE INDEX IF NOT EXISTS room_invitations_user ON room_invitations ("UserID");`
+
+	_, err := db.Exec(createRoomInvitationTableSQL)
+	if err != nil {
+		slog.Error("Error creating Room invitation table", "error", err)
+		return err
+	}
+	return nil
+}
+
+func createLoginAttemptTable(db *sql.DB) error {
+	createLoginAttemptTableSQL := `CREATE TABLE IF NOT EXISTS login_attempts (
+        "ID" INTEGER PRIMARY KEY AUTOINCREMENT,
+        "Username" TEXT NOT NULL,      -- Username as submitted, whether or not it exists
+        "IP" TEXT NOT NULL,            -- Client address the attempt came from
+        "Result" TEXT NOT NULL,        -- success, failure or blocked
+        "AttemptedAt" DATETIME NOT NULL -- Time of the attempt, in UTC
+    );
+    CREATE INDEX IF NOT EXISTS login_attempts_username ON login_attempts ("Username", "AttemptedAt");
+    CREATE INDEX IF NOT EXISTS login_attempts_ip ON login_attempts ("IP", "AttemptedAt");`
+
+	_, err := db.Exec(createLoginAttemptTableSQL)
+	if err != nil {
+		slog.Error("Error creating LoginAttempts table", "error", err)
+		return err
+	}
+	return nil
+}
+
+func createUserTokenTable(db *sql.DB) error {
+	createUserTokenTableSQL := `CREATE TABLE IF NOT EXISTS user_tokens (
+        "TokenHash" TEXT PRIMARY KEY,  -- SHA-256 of the token; the token itself is only ever emailed
+        "UserID" TEXT NOT NULL,        -- User the token acts for
+        "Purpose" TEXT NOT NULL,       -- verify-email or reset-password
+        "CreatedAt" DATETIME NOT NULL,
+        "ExpiresAt" DATETIME NOT NULL,
+        "UsedAt" DATETIME,             -- Set when redeemed; tokens are single-use
+        FOREIGN KEY ("UserID") REFERENCES users("ID")
+    );
+    CREATE INDEX IF NOT EXISTS user_tokens_user ON user_tokens ("UserID", "Purpose");`
+
+	_, err := db.Exec(createUserTokenTableSQL)
+	if err != nil {
+		slog.Error("Error creating UserTokens table", "error", err)
+		return err
+	}
+	return nil
+}
+
+func createRecoveryCodeTable(db *sql.DB) error {
+	createRecoveryCodeTableSQL := `CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+        "CodeHash" TEXT PRIMARY KEY,   -- SHA-256 of the recovery code
+        "UserID" TEXT NOT NULL,        -- User the code unlocks
+        "UsedAt" DATETIME,             -- Set when redeemed; codes are single-use
+        FOREIGN KEY ("UserID") REFERENCES users("ID")
+    );
+    CREATE INDEX IF NOT EXISTS mfa_recovery_codes_user ON mfa_recovery_codes ("UserID");`
+
+	_, err := db.Exec(createRecoveryCodeTableSQL)
+	if err != nil {
+		slog.Error("Error creating MFARecoveryCodes table", "error", err)
+		return err
+	}
+	return nil
+}
+
+func createUserIdentityTable(db *sql.DB) error {
+	createUserIdentityTableSQL := `CREATE TABLE IF NOT EXISTS user_identities (
+        "Issuer" TEXT NOT NULL,        -- OpenID Connect issuer URL
+        "Subject" TEXT NOT NULL,       -- Stable user ID at that issuer
+        "UserID" TEXT NOT NULL,        -- Local user the identity signs in as
+        "Email" TEXT,                  -- Email the issuer reported when linked
+        "CreatedAt" DATETIME NOT NULL,
+        PRIMARY KEY ("Issuer", "Subject"),
+        FOREIGN KEY ("UserID") REFERENCES users("ID")
+    );`
+
+	_, err := db.Exec(createUserIdentityTableSQL)
+	if err != nil {
+		slog.Error("Error creating UserIdentities table", "error", err)
+		return err
+	}
+	return nil
+}
+
+func createAPITokenTable(db *sql.DB) error {
+	createAPITokenTableSQL := `CREATE TABLE IF NOT EXISTS api_tokens (
+        "ID" TEXT PRIMARY KEY,         -- Unique ID for the token, used to list and revoke it
+        "UserID" TEXT NOT NULL,        -- User the token acts for
+        "Name" TEXT NOT NULL,          -- Label chosen by the user
+        "TokenHash" TEXT NOT NULL UNIQUE, -- SHA-256 of the token; the token itself is shown once
+        "Scopes" TEXT NOT NULL,        -- Space-separated scopes
+        "CreatedAt" DATETIME NOT NULL,
+        "ExpiresAt" DATETIME NOT NULL,
+        "LastUsedAt" DATETIME,         -- Updated at most once a minute
+        FOREIGN KEY ("UserID") REFERENCES users("ID")
+    );
+    CREATE INDEX IF NOT EXISTS api_tokens_user ON api_tokens ("UserID");`
+
+	_, err := db.Exec(createAPITokenTableSQL)
+	if err != nil {
+		slog.Error("Error creating APITokens table", "error", err)
+		return err
+	}
+	return nil
+}
+
+func createAttendanceTables(db *sql.DB) error {
+	createAttendanceTablesSQL := `CREATE TABLE IF NOT EXISTS meetings (
+        "ID" TEXT PRIMARY KEY,         -- Unique ID for the meeting
+        "RoomID" TEXT NOT NULL,        -- Room the meeting took place in
+        "StartedAt" DATETIME NOT NULL, -- When the first participant arrived
+        "EndedAt" DATETIME,            -- When the last one left; NULL while in progress
+        FOREIGN KEY ("RoomID") REFERENCES rooms("ID")
+    );
+    CREATE UNIQUE INDEX IF NOT EXISTS meetings_in_progress ON meetings ("RoomID") WHERE "EndedAt" IS NULL;
+    CREATE INDEX IF NOT EXISTS meetings_room ON meetings ("RoomID", "StartedAt");
+    CREATE TABLE IF NOT EXISTS attendance (
+        "ID" TEXT PRIMARY KEY,         -- One per admitted connection
+        "MeetingID" TEXT NOT NULL,
+        "RoomID" TEXT NOT NULL,
+        "UserID" TEXT NOT NULL,
+        "JoinedAt" DATETIME NOT NULL,
+        "LeftAt" DATETIME,             -- NULL while the connection is open
+        "JoinReason" TEXT NOT NULL,    -- join, admit or reconnect
+        "LeaveReason" TEXT NOT NULL DEFAULT '', -- leave, kick, disconnect, reconnect or ended
+        FOREIGN KEY ("MeetingID") REFERENCES meetings("ID"),
+        FOREIGN KEY ("UserID") REFERENCES users("ID")
+    );
+    CREATE INDEX IF NOT EXISTS attendance_meeting ON attendance ("MeetingID");
+    CREATE INDEX IF NOT EXISTS attendance_user ON attendance ("UserID", "JoinedAt");
+    CREATE INDEX IF NOT EXISTS attendance_room_user ON attendance ("RoomID", "UserID");`
+
+	_, err := db.Exec(createAttendanceTablesSQL)
+	if err != nil {
+		slog.Error("Error creating Attendance tables", "error", err)
+		return err
+	}
+	return nil
+}
+
+func createAuditEventTable(db *sql.DB) error {
+	createAuditEventTableSQL := `CREATE TABLE IF NOT EXISTS audit_events (
+        "ID" INTEGER PRIMARY KEY AUTOINCREMENT, -- Increases with every event; used as the page cursor
+        "Action" TEXT NOT NULL,        -- What happened, e.g. user.login or participant.kicked
+        "ActorID" TEXT NOT NULL,       -- User who did it; empty when nobody was signed in
+        "TargetType" TEXT NOT NULL,    -- Kind of thing acted on: user or room
+        "TargetID" TEXT NOT NULL,
+        "RoomID" TEXT NOT NULL,        -- Room the event happened in; empty for none
+        "IP" TEXT NOT NULL,            -- Client address of the request; empty for server-initiated events
+        "Details" TEXT NOT NULL,       -- JSON object with action-specific fields
+        "CreatedAt" DATETIME NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS audit_events_action ON audit_events ("Action", "ID");
+    CREATE INDEX IF NOT EXISTS audit_events_actor ON audit_events ("ActorID", "ID");
+    CREATE INDEX IF NOT EXISTS audit_events_target ON audit_events ("TargetID", "ID");
+    CREATE INDEX IF NOT EXISTS audit_events_created ON audit_events ("CreatedAt");
+    CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+    BEGIN
+        SELECT RAISE(ABORT, 'audit_events is append-only');
+    END;
+    CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+    BEGIN
+        SELECT RAISE(ABORT, 'audit_events is append-only');
+    END;`
+
+	_, err := db.Exec(createAuditEventTableSQL)
+	if err != nil {
+		slog.Error("Error creating Audit event table", "error", err)
+		return err
+	}
+	return nil
+}
+
+func createWebhookTables(db *sql.DB) error {
+	createWebhookTablesSQL := `CREATE TABLE IF NOT EXISTS webhooks (
+        "ID" TEXT PRIMARY KEY,         -- Unique ID for the webhook
+        "UserID" TEXT NOT NULL,        -- Owner
+        "RoomID" TEXT NOT NULL,        -- Room it listens to; empty for every room the owner hosts
+        "URL" TEXT NOT NULL,           -- Where events are POSTed
+        "Secret" TEXT NOT NULL,        -- HMAC key for signing deliveries
+        "Events" TEXT NOT NULL,        -- Space-separated event types
+        "CreatedAt" DATETIME NOT NULL,
+        FOREIGN KEY ("UserID") REFERENCES users("ID")
+    );
+    CREATE INDEX IF NOT EXISTS webhooks_user ON webhooks ("UserID");
+    CREATE INDEX IF NOT EXISTS webhooks_room ON webhooks ("RoomID");
+    CREATE TABLE IF NOT EXISTS webhook_deliveries (
+        "ID" TEXT PRIMARY KEY,         -- Unique ID for the delivery
+        "WebhookID" TEXT NOT NULL,
+        "EventID" TEXT NOT NULL,       -- Shared by redeliveries of the same event
+        "Event" TEXT NOT NULL,         -- Event type
+        "Payload" TEXT NOT NULL,       -- Body sent on every attempt
+        "Status" TEXT NOT NULL,        -- pending, succeeded or failed
+        "Attempts" INTEGER NOT NULL DEFAULT 0,
+        "ResponseStatus" INTEGER NOT NULL DEFAULT 0, -- HTTP status of the last attempt; 0 if none came back
+        "LastError" TEXT NOT NULL DEFAULT '',
+        "CreatedAt" DATETIME NOT NULL,
+        "NextAttemptAt" DATETIME,      -- When a pending delivery is due; NULL once finished
+        "DeliveredAt" DATETIME,        -- When it succeeded
+        FOREIGN KEY ("WebhookID") REFERENCES webhooks("ID")
+    );
+    CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries ("WebhookID", "CreatedAt");
+    CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries ("NextAttemptAt") WHERE "Status" = 'pending';`
+
+	_, err := db.Exec(createWebhookTablesSQL)
+	if err != nil {
+		slog.Error("Error creating Webhook tables", "error", err)
+		return err
+	}
+	return nil
+}
+
+// addColumnIfMissing brings tables created by older releases up to date
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
+			return err
+		}
+		if strings.EqualFold(name, column) {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN "%s" %s`, table, column, definition))
+	if err != nil {
+		slog.Error("Error adding column", "table", table, "column", column, "error", err)
 		return err
 	}
 	return nil
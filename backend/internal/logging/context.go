@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	userIDKey
+	roomIDKey
+	fieldsKey
+)
+
+// requestFields lets IDs learned deep in the handler chain (e.g. the user
+// resolved by the auth middleware) reach log lines written further out, such
+// as the access log
+type requestFields struct {
+	mu     sync.Mutex
+	userID string
+	roomID string
+}
+
+// WithRequestID tags a context with the ID of the HTTP request or WebSocket
+// connection it belongs to
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey, id)
+	return context.WithValue(ctx, fieldsKey, &requestFields{})
+}
+
+// WithUserID tags a context with the authenticated user
+func WithUserID(ctx context.Context, id string) context.Context {
+	if fields, ok := ctx.Value(fieldsKey).(*requestFields); ok {
+		fields.mu.Lock()
+		fields.userID = id
+		fields.mu.Unlock()
+	}
+	return context.WithValue(ctx, userIDKey, id)
+}
+
+// WithRoomID tags a context with the room being acted on
+func WithRoomID(ctx context.Context, id string) context.Context {
+	if fields, ok := ctx.Value(fieldsKey).(*requestFields); ok {
+		fields.mu.Lock()
+		fields.roomID = id
+		fields.mu.Unlock()
+	}
+	return context.WithValue(ctx, roomIDKey, id)
+}
+
+// RequestID returns the request ID carried by ctx, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// ids returns the correlation IDs visible from ctx
+func ids(ctx context.Context) (requestID, userID, roomID string) {
+	requestID, _ = ctx.Value(requestIDKey).(string)
+	userID, _ = ctx.Value(userIDKey).(string)
+	roomID, _ = ctx.Value(roomIDKey).(string)
+
+	if fields, ok := ctx.Value(fieldsKey).(*requestFields); ok {
+		fields.mu.Lock()
+		if userID == "" {
+			userID = fields.userID
+		}
+		if roomID == "" {
+			roomID = fields.roomID
+		}
+		fields.mu.Unlock()
+	}
+	return requestID, userID, roomID
+}
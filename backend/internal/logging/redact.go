@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// Redacted replaces the value of any attribute that may hold a secret
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are matched case-insensitively against attribute keys, as
+// substrings so that e.g. "hashed_password" and "api_token" are caught too
+var sensitiveKeys = []string{
+	"password",
+	"secret",
+	"token",
+	"session",
+	"cookie",
+	"authorization",
+	"otp",
+	"recovery_code",
+}
+
+// redact is the slog ReplaceAttr hook enforcing the redaction policy. Types
+// that know which of their fields are safe should implement slog.LogValuer
+// (see models.User) rather than rely on this.
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if IsSensitive(attr.Key) {
+		return slog.String(attr.Key, Redacted)
+	}
+	return attr
+}
+
+// IsSensitive reports whether an attribute key names a secret
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
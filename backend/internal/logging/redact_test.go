@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		key          string
+		wantRedacted bool
+	}{
+		{key: "password", wantRedacted: true},
+		{key: "hashed_password", wantRedacted: true},
+		{key: "api_token", wantRedacted: true},
+		{key: "tokenID", wantRedacted: true},
+		{key: "Session", wantRedacted: true},
+		{key: "totp_secret", wantRedacted: true},
+		{key: "Authorization", wantRedacted: true},
+		{key: "recovery_code", wantRedacted: true},
+		{key: "credential_id"},
+		{key: "user_id"},
+		{key: "room_id"},
+		{key: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: redact}))
+			logger.Info("message", tt.key, "value")
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatal(err)
+			}
+			want := "value"
+			if tt.wantRedacted {
+				want = Redacted
+			}
+			if record[tt.key] != want {
+				t.Errorf("%s = %v, want %q", tt.key, record[tt.key], want)
+			}
+		})
+	}
+}
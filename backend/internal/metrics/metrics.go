@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "chimecast"
+
+// Labels used on WebSocketMessages
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+// Labels used on Logins
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+	LoginBlocked = "blocked"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route template and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	WebSocketMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "messages_total",
+		Help:      "WebSocket messages by direction and message type.",
+	}, []string{"direction", "type"})
+
+	BroadcastWriteFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "broadcast_write_failures_total",
+		Help:      "Failed writes while broadcasting to a room.",
+	})
+
+	Logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "logins_total",
+		Help:      "Login attempts by result.",
+	}, []string{"result"})
+)
+
+// RoomStats is a point-in-time count of the rooms held by this instance
+type RoomStats struct {
+	ActiveRooms int
+	Admitted    int
+	Waiting     int
+}
+
+// RegisterRoomStats exposes room gauges read from stats on every scrape
+func RegisterRoomStats(stats func() RoomStats) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "rooms",
+		Name:      "active",
+		Help:      "Rooms with at least one connected participant on this instance.",
+	}, func() float64 { return float64(stats().ActiveRooms) })
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "rooms",
+		Name:      "admitted_participants",
+		Help:      "Admitted participants connected to this instance.",
+	}, func() float64 { return float64(stats().Admitted) })
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "rooms",
+		Name:      "waiting_participants",
+		Help:      "Participants in a waiting room on this instance.",
+	}, func() float64 { return float64(stats().Waiting) })
+}
+
+// RegisterSessionCount exposes the number of live sessions
+func RegisterSessionCount(count func() int) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "sessions",
+		Name:      "active",
+		Help:      "Unexpired sessions held by the session manager.",
+	}, func() float64 { return float64(count()) })
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/legendary-acp/chimecast/internal/middleware"
+)
+
+// Middleware records HTTP request counts and latencies for mux routes,
+// labelled by route template so IDs in the path don't explode cardinality
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		start := time.Now()
+		recorder := middleware.NewStatusRecorder(w)
+		next.ServeHTTP(recorder, r)
+
+		HTTPRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.Status)).Inc()
+		HTTPDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
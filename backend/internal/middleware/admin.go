@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// RequireAdmin lets only users with the admin role through. It must run after
+// AuthMiddleware.
+func RequireAdmin(isAdmin func(userID string) (bool, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := r.Context().Value("userID").(string)
+
+			admin, err := isAdmin(userID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error checking admin role", "error", err)
+				utils.SendJSONError(w, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+			if !admin {
+				utils.SendJSONError(w, http.StatusForbidden, "admin role required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,15 +2,64 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/legendary-acp/chimecast/internal/logging"
+	"github.com/legendary-acp/chimecast/internal/models"
 	"github.com/legendary-acp/chimecast/internal/session"
+	"github.com/legendary-acp/chimecast/internal/utils"
 )
 
-// AuthMiddleware checks if the user is authenticated
-func AuthMiddleware(sessionManager *session.SessionManager) func(http.Handler) http.Handler {
+// CSRFHeader carries the session's CSRF token on state-changing requests
+const CSRFHeader = "X-CSRF-Token"
+
+// Authenticator resolves personal API tokens to their records and checks
+// sessions against the account they belong to
+type Authenticator interface {
+	AuthenticateAPIToken(token string) (*models.APIToken, error)
+	// CheckSession fails if a session started at createdAt may no longer be
+	// used, e.g. because the account was disabled or its sessions revoked
+	// from outside the server
+	CheckSession(userID string, createdAt time.Time) error
+}
+
+// AuthMiddleware checks if the user is authenticated, either with a session
+// cookie or with an API token sent as "Authorization: Bearer <token>". Token
+// requests carry the token in the context under "apiToken". Cookies are sent
+// by the browser on its own, so state-changing cookie requests must also
+// carry the session's CSRF token in CSRFHeader.
+func AuthMiddleware(sessionManager *session.SessionManager, auth Authenticator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if header := r.Header.Get("Authorization"); header != "" {
+				scheme, raw, ok := strings.Cut(header, " ")
+				if !ok || !strings.EqualFold(scheme, "Bearer") || raw == "" {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_request"`)
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+				token, err := auth.AuthenticateAPIToken(strings.TrimSpace(raw))
+				if err != nil {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+
+				ctx := context.WithValue(r.Context(), "userID", token.UserID)
+				ctx = context.WithValue(ctx, "userName", token.Username)
+				ctx = context.WithValue(ctx, "apiToken", token)
+				ctx = context.WithValue(ctx, "clientIP", utils.ClientIP(r))
+				ctx = logging.WithUserID(ctx, token.UserID)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			cookie, err := r.Cookie("session_id")
 			if err != nil || cookie.Value == "" {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -22,10 +71,28 @@ func AuthMiddleware(sessionManager *session.SessionManager) func(http.Handler) h
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
+			if err := auth.CheckSession(session.UserID, session.CreatedAt); err != nil {
+				if !errors.Is(err, utils.ErrAccountDisabled) && !errors.Is(err, utils.ErrSessionRevoked) && !errors.Is(err, utils.ErrUserNotFound) {
+					slog.ErrorContext(r.Context(), "Error checking session", "error", err)
+					utils.SendJSONError(w, http.StatusInternalServerError, "Internal server error")
+					return
+				}
+				// Also closes any WebSocket the session opened
+				sessionManager.DeleteSession(cookie.Value)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if !ValidCSRFToken(r, session.CSRFToken) {
+				utils.SendJSONError(w, http.StatusForbidden, "missing or invalid CSRF token")
+				return
+			}
 
-			// Add both userID and username to context
+			// Add userID, username and the session's public ID to context
 			ctx := context.WithValue(r.Context(), "userID", session.UserID)
 			ctx = context.WithValue(ctx, "userName", session.UserName)
+			ctx = context.WithValue(ctx, "sessionID", session.ID)
+			ctx = context.WithValue(ctx, "clientIP", utils.ClientIP(r))
+			ctx = logging.WithUserID(ctx, session.UserID)
 
 			// Create new request with the updated context
 			r = r.WithContext(ctx)
@@ -34,3 +101,49 @@ func AuthMiddleware(sessionManager *session.SessionManager) func(http.Handler) h
 		})
 	}
 }
+
+// RequireScopes checks API token requests against the token's scopes: reads
+// need readScope, everything else (including WebSocket upgrades) needs
+// writeScope. Session requests are not restricted. It must run after
+// AuthMiddleware.
+func RequireScopes(readScope, writeScope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token, ok := r.Context().Value("apiToken").(*models.APIToken); ok {
+				scope := writeScope
+				if (r.Method == http.MethodGet || r.Method == http.MethodHead) && !websocket.IsWebSocketUpgrade(r) {
+					scope = readScope
+				}
+				if !token.HasScope(scope) {
+					w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+					utils.SendJSONError(w, http.StatusForbidden, "API token lacks the "+scope+" scope")
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession refuses API token requests, for endpoints that manage the
+// account itself. It must run after AuthMiddleware.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value("apiToken").(*models.APIToken); ok {
+			utils.SendJSONError(w, http.StatusForbidden, "this endpoint requires a signed-in session")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ValidCSRFToken reports whether a request may go ahead: safe methods always
+// can, anything else must carry the expected token in CSRFHeader
+func ValidCSRFToken(r *http.Request, expected string) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	got := r.Header.Get(CSRFHeader)
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(expected)) == 1
+}
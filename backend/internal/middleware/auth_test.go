@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/session"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// testAuthenticator knows a fixed set of API tokens and accounts
+type testAuthenticator struct {
+	tokens   map[string]*models.APIToken
+	disabled map[string]bool // user IDs
+}
+
+func (a *testAuthenticator) AuthenticateAPIToken(raw string) (*models.APIToken, error) {
+	token, ok := a.tokens[raw]
+	if !ok {
+		return nil, utils.ErrInvalidToken
+	}
+	return token, nil
+}
+
+func (a *testAuthenticator) CheckSession(userID string, createdAt time.Time) error {
+	if a.disabled[userID] {
+		return utils.ErrAccountDisabled
+	}
+	return nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	sessions := session.NewSessionManager(time.Hour)
+	auth := &testAuthenticator{
+		tokens: map[string]*models.APIToken{
+			"reader": {ID: "t1", UserID: "alice", Scopes: []string{models.ScopeRoomsRead}},
+			"writer": {ID: "t2", UserID: "alice", Scopes: []string{models.ScopeRoomsRead, models.ScopeRoomsWrite}},
+		},
+		disabled: map[string]bool{"mallory": true},
+	}
+	cookie, err := sessions.CreateSession("alice", "alice", session.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := sessions.GetSession(cookie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabledCookie, err := sessions.CreateSession("mallory", "mallory", session.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotUserID string
+	handler := AuthMiddleware(sessions, auth)(RequireScopes(models.ScopeRoomsRead, models.ScopeRoomsWrite)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotUserID, _ = r.Context().Value("userID").(string)
+		}),
+	))
+
+	tests := []struct {
+		name       string
+		method     string
+		cookie     string
+		csrf       string
+		bearer     string
+		websocket  bool
+		wantStatus int
+	}{
+		{name: "no credentials", method: http.MethodGet, wantStatus: http.StatusUnauthorized},
+		{name: "unknown cookie", method: http.MethodGet, cookie: "stale", wantStatus: http.StatusUnauthorized},
+		{name: "cookie read needs no CSRF token", method: http.MethodGet, cookie: cookie, wantStatus: http.StatusOK},
+		{name: "cookie write without CSRF token", method: http.MethodPost, cookie: cookie, wantStatus: http.StatusForbidden},
+		{name: "cookie write with the wrong CSRF token", method: http.MethodPost, cookie: cookie, csrf: "forged", wantStatus: http.StatusForbidden},
+		{name: "cookie delete with the wrong CSRF token", method: http.MethodDelete, cookie: cookie, csrf: "forged", wantStatus: http.StatusForbidden},
+		{name: "cookie write with the CSRF token", method: http.MethodPost, cookie: cookie, csrf: current.CSRFToken, wantStatus: http.StatusOK},
+		{name: "cookie of a disabled account", method: http.MethodGet, cookie: disabledCookie, wantStatus: http.StatusUnauthorized},
+		{name: "bearer write skips CSRF", method: http.MethodPost, bearer: "Bearer writer", wantStatus: http.StatusOK},
+		{name: "bearer read with the read scope", method: http.MethodGet, bearer: "Bearer reader", wantStatus: http.StatusOK},
+		{name: "bearer write with only the read scope", method: http.MethodPost, bearer: "Bearer reader", wantStatus: http.StatusForbidden},
+		{name: "bearer socket with only the read scope", method: http.MethodGet, bearer: "Bearer reader", websocket: true, wantStatus: http.StatusForbidden},
+		{name: "bearer socket with the write scope", method: http.MethodGet, bearer: "Bearer writer", websocket: true, wantStatus: http.StatusOK},
+		{name: "unknown bearer token", method: http.MethodGet, bearer: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "other scheme", method: http.MethodGet, bearer: "Basic writer", wantStatus: http.StatusUnauthorized},
+		{name: "bearer wins over the cookie", method: http.MethodPost, cookie: cookie, bearer: "Bearer reader", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID = ""
+			r := httptest.NewRequest(tt.method, "/api/room/v1/", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "session_id", Value: tt.cookie})
+			}
+			if tt.csrf != "" {
+				r.Header.Set(CSRFHeader, tt.csrf)
+			}
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", tt.bearer)
+			}
+			if tt.websocket {
+				r.Header.Set("Connection", "Upgrade")
+				r.Header.Set("Upgrade", "websocket")
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if reached := gotUserID != ""; reached != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("handler reached = %v with status %d", reached, w.Code)
+			}
+		})
+	}
+
+	// A refused session is ended for good
+	if _, err := sessions.GetSession(disabledCookie); err == nil {
+		t.Error("disabled account's session survived")
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      *models.APIToken
+		wantStatus int
+	}{
+		{name: "session", wantStatus: http.StatusOK},
+		{name: "API token", token: &models.APIToken{Scopes: models.APIScopes}, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/auth/v1/tokens", nil)
+			if tt.token != nil {
+				r = r.WithContext(context.WithValue(r.Context(), "apiToken", tt.token))
+			}
+			w := httptest.NewRecorder()
+			RequireSession(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
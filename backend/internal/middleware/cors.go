@@ -1,25 +1,60 @@
 package middleware
 
-import "net/http"
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-func CorsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Specify the exact origin for local development
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
+	"github.com/legendary-acp/chimecast/internal/origin"
+)
 
-		// Allow credentials (important for cookies)
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
+// CORSOptions configures CorsMiddleware
+type CORSOptions struct {
+	Origins        *origin.Allowlist
+	MaxAge         time.Duration // how long browsers may cache a preflight; 0 leaves it to the browser
+	ExposedHeaders []string      // response headers scripts on the allowed origins may read
+}
+
+// CorsMiddleware lets the allowed origins make credentialed requests. The
+// request's origin is echoed back, so responses vary by Origin. It must wrap
+// the router, ahead of AuthMiddleware, so preflights are answered before
+// any route or authentication check.
+func CorsMiddleware(options CORSOptions) func(http.Handler) http.Handler {
+	exposed := strings.Join(options.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(options.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+			requestOrigin := r.Header.Get("Origin")
+			allowed := requestOrigin != "" && options.Origins.Allowed(requestOrigin)
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Origin", requestOrigin)
 
-		// Rest of the headers
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+				// Allow credentials (important for cookies)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+				if exposed != "" {
+					w.Header().Set("Access-Control-Expose-Headers", exposed)
+				}
+			}
 
-		// Handle preflight
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
+			// Handle preflight
+			if preflight {
+				if allowed {
+					w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+					w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+CSRFHeader)
+					if options.MaxAge > 0 {
+						w.Header().Set("Access-Control-Max-Age", maxAge)
+					}
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
 
-		next.ServeHTTP(w, r)
-	})
+			next.ServeHTTP(w, r)
+		})
+	}
 }
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// RequireMFA lets only users with two-factor authentication enrolled through.
+// It must run after AuthMiddleware.
+func RequireMFA(isEnrolled func(userID string) (bool, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := r.Context().Value("userID").(string)
+
+			enrolled, err := isEnrolled(userID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error checking two-factor enrolment", "error", err)
+				utils.SendJSONError(w, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+			if !enrolled {
+				utils.SendJSONError(w, http.StatusForbidden, "two-factor authentication enrolment required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
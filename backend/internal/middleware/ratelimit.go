@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/legendary-acp/chimecast/internal/ratelimit"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// RateLimit throttles requests with one token bucket per caller: the
+// authenticated user when there is one, otherwise the client IP. Routes that
+// share a limiter share its budget.
+func RateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "ip:" + utils.ClientIP(r)
+			if userID, ok := r.Context().Value("userID").(string); ok && userID != "" {
+				key = "user:" + userID
+			}
+
+			if allowed, retryAfter := limiter.Allow(key); !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				utils.SendJSONError(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/legendary-acp/chimecast/internal/logging"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits which client-supplied IDs are trusted, so log lines
+// can't be forged through the header
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestLogger gives every request an ID, echoes it back in X-Request-ID and
+// logs the request once it completes. WebSocket upgrades keep the ID for the
+// lifetime of the connection.
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = utils.CreateNewUUID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := logging.WithRequestID(r.Context(), requestID)
+		r = r.WithContext(ctx)
+
+		start := time.Now()
+		recorder := NewStatusRecorder(w)
+		next.ServeHTTP(recorder, r)
+
+		slog.InfoContext(ctx, "http request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.Status,
+			"duration", time.Since(start),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+// RoomContext tags the request context with the {roomID} route variable so
+// that everything logged while handling it carries the room
+func RoomContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if roomID := mux.Vars(r)["roomID"]; roomID != "" {
+			r = r.WithContext(logging.WithRoomID(r.Context(), roomID))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ProxiedClientIP replaces the request's remote address with the client
+// address reported by a reverse proxy in front of the server. Only the last
+// X-Forwarded-For entry is used since that is the one our proxy appended;
+// earlier entries come from the client and can be forged.
+func ProxiedClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded := r.Header.Get("X-Forwarded-For")
+		if i := strings.LastIndex(forwarded, ","); i >= 0 {
+			forwarded = forwarded[i+1:]
+		}
+		if ip := net.ParseIP(strings.TrimSpace(forwarded)); ip != nil {
+			r.RemoteAddr = ip.String()
+		} else if ip := net.ParseIP(r.Header.Get("X-Real-IP")); ip != nil {
+			r.RemoteAddr = ip.String()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// RequireVerifiedEmail lets only users who have confirmed their email address
+// through. It must run after AuthMiddleware.
+func RequireVerifiedEmail(isVerified func(userID string) (bool, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := r.Context().Value("userID").(string)
+
+			verified, err := isVerified(userID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error checking email verification", "error", err)
+				utils.SendJSONError(w, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+			if !verified {
+				utils.SendJSONError(w, http.StatusForbidden, "email address not verified")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// AdminUser is an account as the admin API shows it
+type AdminUser struct {
+	ID            string     `json:"id"`
+	Username      string     `json:"username"`
+	Name          string     `json:"name"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"emailVerified"`
+	MFAEnabled    bool       `json:"mfaEnabled"`
+	IsAdmin       bool       `json:"isAdmin"`
+	Disabled      bool       `json:"disabled"`
+	DisabledAt    *time.Time `json:"disabledAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// UserQuery searches and pages the account list. An empty Search matches
+// everyone.
+type UserQuery struct {
+	Search string
+	After  string // only usernames after this one, from the previous page
+	Limit  int
+}
+
+// UserPage is one page of accounts, ordered by username
+type UserPage struct {
+	Users      []AdminUser `json:"users"`
+	NextCursor string      `json:"nextCursor,omitempty"` // pass as ?after= for the next page; empty on the last
+}
+
+// LiveRoom is a room with someone connected to it
+type LiveRoom struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	HostID       string    `json:"hostId"`
+	Participants int       `json:"participantCount"`
+	WaitingCount int       `json:"waitingCount"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Why a participant's time in a meeting started
+const (
+	AttendanceJoin      = "join"      // connected already admitted, such as the host
+	AttendanceAdmit     = "admit"     // let in from the waiting room
+	AttendanceReconnect = "reconnect" // took over from an earlier connection
+)
+
+// Why a participant's time in a meeting ended
+const (
+	AttendanceLeave      = "leave"      // left on their own
+	AttendanceKick       = "kick"       // removed by the host
+	AttendanceDisconnect = "disconnect" // socket dropped
+	AttendanceEnded      = "ended"      // the room was ended
+	AttendanceDisabled   = "disabled"   // an administrator disabled their account
+	// AttendanceReconnect also ends an interval, when a new connection replaces it
+)
+
+// Meeting is one continuous use of a room, from the first participant
+// arriving to the last one leaving
+type Meeting struct {
+	ID              string     `json:"id"`
+	RoomID          string     `json:"roomId"`
+	RoomName        string     `json:"roomName"`
+	HostID          string     `json:"hostId"`
+	StartedAt       time.Time  `json:"startedAt"`
+	EndedAt         *time.Time `json:"endedAt"` // nil while in progress
+	DurationSeconds int64      `json:"durationSeconds"`
+}
+
+// AttendanceInterval is one stretch of a participant's time in a meeting,
+// from being admitted or connecting to leaving or dropping
+type AttendanceInterval struct {
+	ID          string     `json:"-"`
+	MeetingID   string     `json:"meetingId"`
+	RoomID      string     `json:"roomId"`
+	UserID      string     `json:"-"`
+	Username    string     `json:"-"`
+	Name        string     `json:"-"`
+	JoinedAt    time.Time  `json:"joinedAt"`
+	LeftAt      *time.Time `json:"leftAt"` // nil while still in the room
+	JoinReason  string     `json:"joinReason"`
+	LeaveReason string     `json:"leaveReason,omitempty"`
+}
+
+// MeetingAttendance is a meeting as one participant attended it
+type MeetingAttendance struct {
+	Meeting
+	JoinedAt          time.Time  `json:"joinedAt"` // first arrival
+	LeftAt            *time.Time `json:"leftAt"`   // last departure; nil while still in the room
+	TimeInRoomSeconds int64      `json:"timeInRoomSeconds"`
+}
+
+// ParticipantAttendance totals one participant's time in a meeting
+type ParticipantAttendance struct {
+	UserID            string               `json:"userId"`
+	Username          string               `json:"username"`
+	Name              string               `json:"name"`
+	JoinedAt          time.Time            `json:"joinedAt"`
+	LeftAt            *time.Time           `json:"leftAt"`
+	TimeInRoomSeconds int64                `json:"timeInRoomSeconds"`
+	Sessions          []AttendanceInterval `json:"sessions"`
+}
+
+// AttendanceReport is who attended a meeting and for how long
+type AttendanceReport struct {
+	Meeting      Meeting                 `json:"meeting"`
+	Participants []ParticipantAttendance `json:"participants"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Audit event actions
+const (
+	AuditLogin                = "user.login"
+	AuditLoginFailed          = "user.login_failed"
+	AuditRoomCreated          = "room.created"
+	AuditRoomEnded            = "room.ended"
+	AuditRoomHostChanged      = "room.host_changed"
+	AuditRoomSettingsChanged  = "room.settings_changed"
+	AuditRoomPurged           = "room.purged"
+	AuditParticipantAdmitted  = "participant.admitted"
+	AuditParticipantDenied    = "participant.denied"
+	AuditParticipantKicked    = "participant.kicked"
+	AuditParticipantInvited   = "participant.invited"
+	AuditParticipantUninvited = "participant.uninvited"
+	AuditUserCreated          = "user.created"
+	AuditUserAdminRoleChanged = "user.admin_role_changed"
+	AuditUserDisabled         = "user.disabled"
+	AuditUserEnabled          = "user.enabled"
+	AuditUserPasswordReset    = "user.password_reset"
+	AuditUserSessionsRevoked  = "user.sessions_revoked"
+	AuditAnnouncement         = "server.announcement"
+)
+
+// AuditActorCLI is the actor of changes made with the chimecast command line
+const AuditActorCLI = "cli"
+
+// What an audit event acted on
+const (
+	AuditTargetUser     = "user"
+	AuditTargetRoom     = "room"
+	AuditTargetUsername = "username" // a name submitted at login, which may not belong to anyone
+	AuditTargetServer   = "server"   // the whole instance
+)
+
+// AuditEvent is one entry in the append-only audit log
+type AuditEvent struct {
+	ID         int64             `json:"id"`
+	Action     string            `json:"action"`
+	ActorID    string            `json:"actorId"` // empty when nobody was signed in, e.g. a failed login
+	TargetType string            `json:"targetType"`
+	TargetID   string            `json:"targetId"`
+	RoomID     string            `json:"roomId,omitempty"`
+	IP         string            `json:"ip"` // empty for events the server started itself
+	Details    map[string]string `json:"details,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+}
+
+// AuditQuery filters and pages the audit log. Empty fields match anything.
+type AuditQuery struct {
+	Action   string
+	ActorID  string
+	TargetID string
+	RoomID   string
+	IP       string
+	Since    time.Time
+	Until    time.Time
+	Before   int64 // only events older than this ID, from the previous page
+	Limit    int
+}
+
+// AuditPage is one page of audit events, newest first
+type AuditPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"nextCursor,omitempty"` // pass as ?before= for the next page; empty on the last
+}
@@ -12,6 +12,83 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfaToken"`
+	Code     string `json:"code"` // authenticator code or recovery code
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// UpdateProfileRequest changes only the fields that are present
+type UpdateProfileRequest struct {
+	Name              *string `json:"name"`
+	Email             *string `json:"email"`
+	PreferredLanguage *string `json:"preferredLanguage"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// DeleteAccountRequest confirms an erasure with the password, or with the
+// username for accounts that have no password
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Confirm  string `json:"confirm"`
+}
+
+type CreateAPITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"` // defaults to 30
+}
+
+// CreateWebhookRequest registers an endpoint for the caller's rooms, or for
+// one room they host when RoomID is set
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	RoomID string   `json:"roomId"`
+}
+
+// AnnouncementRequest is a message an administrator sends to everyone
+// connected
+type AnnouncementRequest struct {
+	Message string `json:"message"`
+}
+
 type CreateRoomRequest struct {
-	Name string `json:"name"`
+	Name              string `json:"name"`
+	ScreenSharePolicy string `json:"screenSharePolicy"`
+	Visibility        string `json:"visibility"`
+}
+
+type UpdateScreenSharePolicyRequest struct {
+	Policy string `json:"policy"`
+}
+
+// UpdateRoomVisibilityRequest changes who may see and join a room
+type UpdateRoomVisibilityRequest struct {
+	Visibility string `json:"visibility"`
+}
+
+// InviteRequest invites a user, by username, to a private room
+type InviteRequest struct {
+	Username string `json:"username"`
 }
@@ -3,19 +3,64 @@ package models
 import "time"
 
 type Room struct {
-	ID        string    `json:"id"`
+	ID                string    `json:"id"`
+	Name              string    `json:"name"`
+	HostID            string    `json:"hostId"`
+	CreatedAt         time.Time `json:"createdAt"`
+	Status            int       `json:"status"`
+	ScreenSharePolicy string    `json:"screenSharePolicy"`
+	Visibility        string    `json:"visibility"`
+}
+
+// RoomInvitation lets a user into a private room
+type RoomInvitation struct {
+	RoomID    string    `json:"roomId"`
+	UserID    string    `json:"userId"`
+	Username  string    `json:"username"`
 	Name      string    `json:"name"`
-	HostID    string    `json:"hostId"`
-	CreatedAt time.Time `json:"createdAt"`
-	Status    int       `json:"status"`
+	InvitedAt time.Time `json:"invitedAt"`
+}
+
+// RoomListing is a room in the room list, with who is in it right now
+type RoomListing struct {
+	Room
+	Participants int `json:"participantCount"`
+	WaitingCount int `json:"waitingCount"`
+}
+
+// RoomQuery filters and pages the room list. Empty fields match anything.
+type RoomQuery struct {
+	Status   int // RoomStatusActive or RoomStatusInactive
+	HostID   string
+	Search   string    // words in the room name
+	Since    time.Time // created at or after
+	Until    time.Time // created before
+	ViewerID string    // only rooms listed for this user: public ones, their own, and private ones they're invited to
+	After    *RoomCursor
+	Limit    int
+}
+
+// RoomCursor is the last room of a page of the room list. Rooms are listed
+// active first, then newest first.
+type RoomCursor struct {
+	Status    int
+	CreatedAt time.Time
+	ID        string
+}
+
+// RoomPage is one page of the room list
+type RoomPage struct {
+	Rooms      []RoomListing `json:"rooms"`
+	NextCursor string        `json:"nextCursor,omitempty"` // pass as ?after= for the next page; empty on the last
 }
 
 type Participant struct {
-	UserID   string    `json:"userId"`
-	Username string    `json:"username"`
-	Name     string    `json:"name"`
-	JoinedAt time.Time `json:"joinedAt"`
-	Status   string    `json:"status"` // "waiting", "admitted", "denied"
+	UserID    string    `json:"userId"`
+	Username  string    `json:"username"`
+	Name      string    `json:"name"`
+	AvatarURL string    `json:"avatarUrl"`
+	JoinedAt  time.Time `json:"joinedAt"`
+	Status    string    `json:"status"` // "waiting", "admitted", "denied"
 }
 
 type Participants struct {
@@ -24,19 +69,34 @@ type Participants struct {
 }
 
 type RoomStatus struct {
-	RoomID       string    `json:"roomId"`
-	Name         string    `json:"name"`
-	HostID       string    `json:"hostId"`
-	IsActive     bool      `json:"isActive"`
-	Participants int       `json:"participantCount"`
-	WaitingCount int       `json:"waitingCount"`
-	CreatedAt    time.Time `json:"createdAt"`
+	RoomID            string    `json:"roomId"`
+	Name              string    `json:"name"`
+	HostID            string    `json:"hostId"`
+	IsActive          bool      `json:"isActive"`
+	Participants      int       `json:"participantCount"`
+	WaitingCount      int       `json:"waitingCount"`
+	CreatedAt         time.Time `json:"createdAt"`
+	ScreenSharePolicy string    `json:"screenSharePolicy"`
+	ScreenSharers     []string  `json:"screenSharers"`
+	Visibility        string    `json:"visibility"`
+}
+
+// RoomState is sent to a participant right after their WebSocket is admitted
+// so late joiners can catch up with what is already happening in the room.
+type RoomState struct {
+	RoomID            string   `json:"roomId"`
+	HostID            string   `json:"hostId"`
+	Participants      []string `json:"participants"`
+	ScreenSharePolicy string   `json:"screenSharePolicy"`
+	ScreenSharers     []string `json:"screenSharers"`
 }
 
 // WebSocket message types
 type WebSocketMessage struct {
 	Type    string      `json:"type"`
-	Payload interface{} `json:"payload"`
+	ID      string      `json:"id,omitempty"`   // echoes the client's correlation ID on ack and error frames
+	From    string      `json:"from,omitempty"` // sender of a relayed signaling message
+	Payload interface{} `json:"payload,omitempty"`
 }
 
 // Constants for room status
@@ -52,6 +112,34 @@ const (
 	ParticipantStatusDenied   = "denied"
 )
 
+// ServerRestartingPayload tells clients to reconnect, after the given delay,
+// to another instance
+type ServerRestartingPayload struct {
+	Reason           string `json:"reason"`
+	ReconnectAfterMs int64  `json:"reconnectAfterMs"`
+}
+
+// AnnouncementPayload is a server-wide message from an administrator, sent
+// to every connected participant
+type AnnouncementPayload struct {
+	Message string    `json:"message"`
+	SentAt  time.Time `json:"sentAt"`
+}
+
+// Constants for screen share policies
+const (
+	ScreenSharePolicyAnyone   = "anyone"    // any number of participants may share at once
+	ScreenSharePolicyHostOnly = "host-only" // only the host may share
+	ScreenSharePolicySingle   = "single"    // anyone may share, but one at a time
+)
+
+// Constants for room visibility
+const (
+	RoomVisibilityPrivate  = "private"  // only the host and invitees may see or join it
+	RoomVisibilityUnlisted = "unlisted" // anyone with the link may join, but it isn't listed
+	RoomVisibilityPublic   = "public"   // listed for every signed-in user
+)
+
 // Constants for WebSocket message types
 const (
 	WSMessageTypeJoin              = "join"
@@ -62,4 +150,34 @@ const (
 	WSMessageTypeAdmitted          = "admitted"
 	WSMessageTypeDenied            = "denied"
 	WSMessageTypeParticipantUpdate = "participant-update"
+	WSMessageTypeRoomState         = "room-state"
+	WSMessageTypeScreenShareStart  = "screenshare-start"
+	WSMessageTypeScreenShareStop   = "screenshare-stop"
+	WSMessageTypeWelcome           = "welcome"
+	WSMessageTypeAck               = "ack"
+	WSMessageTypeError             = "error"
+	WSMessageTypeServerRestarting  = "server-restarting"
+	WSMessageTypeRoomEnded         = "room-ended"
+	WSMessageTypeHostChanged       = "host-changed"
+	WSMessageTypeKicked            = "kicked"
+	WSMessageTypeAnnouncement      = "announcement"
 )
+
+// IsValidScreenSharePolicy reports whether policy is one of the known policies
+func IsValidScreenSharePolicy(policy string) bool {
+	switch policy {
+	case ScreenSharePolicyAnyone, ScreenSharePolicyHostOnly, ScreenSharePolicySingle:
+		return true
+	}
+	return false
+}
+
+// IsValidRoomVisibility reports whether visibility is one of the known
+// settings
+func IsValidRoomVisibility(visibility string) bool {
+	switch visibility {
+	case RoomVisibilityPrivate, RoomVisibilityUnlisted, RoomVisibilityPublic:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Signaling protocol versions. Clients pick one through the WebSocket
+// subprotocol header, e.g. "Sec-WebSocket-Protocol: chimecast.v1".
+const (
+	ProtocolVersion1      = 1
+	CurrentProtocol       = ProtocolVersion1
+	SubprotocolNamePrefix = "chimecast.v"
+)
+
+// InboundMessage is a frame as received from a client, before its payload
+// has been decoded against the message registry
+type InboundMessage struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// SignalPayload is implemented by every inbound payload type
+type SignalPayload interface {
+	Validate() error
+}
+
+// SessionDescriptionPayload carries an SDP offer or answer
+type SessionDescriptionPayload struct {
+	Target string `json:"target,omitempty"` // deliver to one peer instead of the whole room
+	Type   string `json:"type"`
+	SDP    string `json:"sdp"`
+}
+
+func (p *SessionDescriptionPayload) Validate() error {
+	if p.Type != WSMessageTypeOffer && p.Type != WSMessageTypeAnswer {
+		return errors.New("type must be offer or answer")
+	}
+	if p.SDP == "" {
+		return errors.New("sdp cannot be empty")
+	}
+	return nil
+}
+
+// IceCandidatePayload mirrors RTCIceCandidateInit. An empty candidate string
+// signals end-of-candidates.
+type IceCandidatePayload struct {
+	Target           string  `json:"target,omitempty"`
+	Candidate        string  `json:"candidate"`
+	SDPMid           *string `json:"sdpMid,omitempty"`
+	SDPMLineIndex    *int    `json:"sdpMLineIndex,omitempty"`
+	UsernameFragment string  `json:"usernameFragment,omitempty"`
+}
+
+func (p *IceCandidatePayload) Validate() error {
+	if p.SDPMid == nil && p.SDPMLineIndex == nil {
+		return errors.New("sdpMid or sdpMLineIndex is required")
+	}
+	if p.SDPMLineIndex != nil && *p.SDPMLineIndex < 0 {
+		return errors.New("sdpMLineIndex cannot be negative")
+	}
+	return nil
+}
+
+// ScreenSharePayload names whose share a screenshare-stop refers to. It is
+// empty for screenshare-start and for participants stopping their own share.
+type ScreenSharePayload struct {
+	UserID string `json:"userId,omitempty"`
+}
+
+func (p *ScreenSharePayload) Validate() error {
+	return nil
+}
+
+// EmptyPayload is used by messages that carry no data
+type EmptyPayload struct{}
+
+func (p *EmptyPayload) Validate() error {
+	return nil
+}
+
+// WelcomePayload is the first frame the server sends on a new socket
+type WelcomePayload struct {
+	ProtocolVersion   int   `json:"protocolVersion"`
+	SupportedVersions []int `json:"supportedVersions"`
+}
+
+// ErrorPayload is the body of every error frame
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Machine-readable codes sent in error frames
+const (
+	ErrCodeMalformedMessage   = "malformed_message"
+	ErrCodeUnknownType        = "unknown_type"
+	ErrCodeInvalidPayload     = "invalid_payload"
+	ErrCodeUnsupportedVersion = "unsupported_version"
+	ErrCodeNotAdmitted        = "not_admitted"
+	ErrCodeUnknownTarget      = "unknown_target"
+	ErrCodeScreenShareDenied  = "screenshare_denied"
+	ErrCodeForbidden          = "forbidden"
+	ErrCodeRateLimited        = "rate_limited"
+	ErrCodeInternal           = "internal_error"
+)
@@ -1,12 +1,119 @@
 package models
 
-import "time"
+import (
+	"log/slog"
+	"slices"
+	"time"
+)
 
 type User struct {
-	ID             string    `json:"-"`
-	Username       string    `json:"Username"`
-	Name           string    `json:"Name"`
-	Email          string    `json:"Email"`
-	HashedPassword string    `json:"HashedPassword"`
-	CreatedAt      time.Time `json:"CreatedAt"`
+	ID                string     `json:"-"`
+	Username          string     `json:"Username"`
+	Name              string     `json:"Name"`
+	Email             string     `json:"Email"`
+	HashedPassword    string     `json:"HashedPassword"`
+	CreatedAt         time.Time  `json:"CreatedAt"`
+	EmailVerified     bool       `json:"EmailVerified"`
+	MFAEnabled        bool       `json:"MFAEnabled"`
+	TOTPSecret        string     `json:"-"`
+	TOTPLastStep      int64      `json:"-"`
+	AvatarName        string     `json:"-"`
+	PreferredLanguage string     `json:"PreferredLanguage"`
+	DeletedAt         *time.Time `json:"-"` // set once the account has been erased
+	IsAdmin           bool       `json:"-"` // may use the admin API
+	DisabledAt        *time.Time `json:"-"` // set while an admin has disabled the account
+	SessionsRevokedAt *time.Time `json:"-"` // sessions started before this are refused, for revoking them from outside the server
+}
+
+// Profile is what a user sees and edits about their own account
+type Profile struct {
+	ID                string    `json:"id"`
+	Username          string    `json:"username"`
+	Name              string    `json:"name"`
+	Email             string    `json:"email"`
+	EmailVerified     bool      `json:"emailVerified"`
+	MFAEnabled        bool      `json:"mfaEnabled"`
+	HasPassword       bool      `json:"hasPassword"` // false for accounts created through single sign-on
+	PreferredLanguage string    `json:"preferredLanguage"`
+	AvatarURL         string    `json:"avatarUrl"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// LogValue keeps credentials and contact details out of logs
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", u.ID),
+		slog.String("username", u.Username),
+	)
+}
+
+// Outcomes recorded for a login attempt
+const (
+	LoginResultSuccess    = "success"
+	LoginResultFailure    = "failure"
+	LoginResultBlocked    = "blocked"     // refused by rate limiting before the password was checked
+	LoginResultMFAPending = "mfa-pending" // password accepted, waiting for the second factor
+)
+
+// LoginAttempt is one row of the login audit trail
+type LoginAttempt struct {
+	Username    string    `json:"username"`
+	IP          string    `json:"ip"`
+	Result      string    `json:"result"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+// What a user token may be redeemed for
+const (
+	TokenPurposeVerifyEmail   = "verify-email"
+	TokenPurposeResetPassword = "reset-password"
+	TokenPurposeMFAPending    = "mfa-pending" // password checked, second factor still due
+)
+
+// UserToken is a single-use, expiring token emailed to a user. Only its hash
+// is stored.
+type UserToken struct {
+	TokenHash string
+	UserID    string
+	Purpose   string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// UserIdentity links a local user to an account at an external OpenID
+// Connect provider
+type UserIdentity struct {
+	Issuer    string
+	Subject   string
+	UserID    string
+	Email     string
+	CreatedAt time.Time
+}
+
+// Scopes an API token can be granted
+const (
+	ScopeRoomsRead  = "rooms:read"  // GET requests under /api/room/v1
+	ScopeRoomsWrite = "rooms:write" // everything else under /api/room/v1, including WebSockets
+)
+
+// APIScopes lists every scope a token may be granted
+var APIScopes = []string{ScopeRoomsRead, ScopeRoomsWrite}
+
+// APIToken is a long-lived credential a user creates for bots and
+// integrations. Only its hash is stored; the token itself is shown once.
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Username   string     `json:"-"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+// HasScope reports whether the token was granted scope
+func (t *APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
 }
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Room events webhooks can subscribe to
+const (
+	EventRoomCreated       = "room.created"
+	EventRoomEnded         = "room.ended"
+	EventParticipantJoined = "participant.joined"
+	EventParticipantLeft   = "participant.left"
+)
+
+// WebhookEvents lists every event a webhook may subscribe to
+var WebhookEvents = []string{EventRoomCreated, EventRoomEnded, EventParticipantJoined, EventParticipantLeft}
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "pending"   // waiting for its next attempt
+	DeliverySucceeded = "succeeded" // the endpoint answered 2xx
+	DeliveryFailed    = "failed"    // every attempt failed
+)
+
+// RoomEvent is something that happened in a room, as sent to webhooks
+type RoomEvent struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"event"`
+	RoomID    string            `json:"roomId"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Data      map[string]string `json:"data,omitempty"`
+}
+
+// Webhook is an endpoint that receives room events. One with a RoomID hears
+// that room; one without hears every room its owner hosts.
+type Webhook struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"-"`
+	RoomID    string    `json:"roomId,omitempty"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"` // signs deliveries; shown once, on creation
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Subscribes reports whether the webhook wants an event type
+func (w *Webhook) Subscribes(event string) bool {
+	return slices.Contains(w.Events, event)
+}
+
+// WebhookDelivery is one event sent, or to be sent, to one webhook
+type WebhookDelivery struct {
+	ID             string     `json:"id"`
+	WebhookID      string     `json:"webhookId"`
+	EventID        string     `json:"eventId"`
+	Event          string     `json:"event"`
+	Payload        string     `json:"payload"` // the exact body sent
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"responseStatus,omitempty"` // from the last attempt
+	LastError      string     `json:"lastError,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"` // nil once succeeded or failed
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+}
@@ -0,0 +1,81 @@
+package origin
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	allowlist := NewAllowlist([]string{
+		"https://app.example.org/",
+		"http://localhost:5173",
+		"https://*.example.com",
+		"https://*.internal.test:8443",
+	})
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		// Exact entries
+		{origin: "https://app.example.org", want: true},
+		{origin: "HTTPS://App.Example.org/", want: true},
+		{origin: "http://localhost:5173", want: true},
+		{origin: "http://localhost:5174", want: false},
+		{origin: "http://app.example.org", want: false},
+
+		// Wildcard on any port
+		{origin: "https://a.example.com", want: true},
+		{origin: "https://a.b.example.com", want: true},
+		{origin: "https://a.example.com:8443", want: true},
+		{origin: "https://a.example.com:443", want: true},
+		{origin: "https://example.com", want: false},
+		{origin: "https://.example.com", want: false},
+		{origin: "http://a.example.com", want: false},
+		{origin: "https://a.example.com.evil.test", want: false},
+		{origin: "https://evilexample.com", want: false},
+		{origin: "https://evil.test@a.example.com", want: false},
+		{origin: "https://a.example.com/path", want: false},
+		{origin: "https://a.example.com:port", want: false},
+
+		// Wildcard on a given port
+		{origin: "https://a.internal.test:8443", want: true},
+		{origin: "https://a.internal.test", want: false},
+		{origin: "https://a.internal.test:9443", want: false},
+
+		{origin: "", want: false},
+		{origin: "null", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			if got := allowlist.Allowed(tt.origin); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	allowlist := NewAllowlist([]string{"https://*.example.com"})
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{name: "no origin header", origin: "", want: true},
+		{name: "allowed origin", origin: "https://a.example.com:8443", want: true},
+		{name: "other origin", origin: "https://evil.test", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := allowlist.CheckOrigin(r); got != tt.want {
+				t.Errorf("CheckOrigin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
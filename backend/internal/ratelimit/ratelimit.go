@@ -0,0 +1,148 @@
+// Package ratelimit implements token buckets for throttling HTTP requests and
+// WebSocket messages. Buckets live in memory, so limits apply per instance.
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate allows Count events per Per, with bursts of up to Count. A zero Rate
+// allows everything.
+type Rate struct {
+	Count int
+	Per   time.Duration
+}
+
+// ParseRate reads a rate written as "<count>/<duration>", e.g. "10/1m".
+// An empty string or "0" disables limiting.
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Rate{}, nil
+	}
+
+	count, per, found := strings.Cut(s, "/")
+	if !found {
+		return Rate{}, fmt.Errorf("invalid rate %q, expected <count>/<duration>", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || n < 0 {
+		return Rate{}, fmt.Errorf("invalid count in rate %q", s)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(per))
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("invalid duration in rate %q", s)
+	}
+	return Rate{Count: n, Per: d}, nil
+}
+
+func (r Rate) Unlimited() bool {
+	return r.Count == 0 || r.Per == 0
+}
+
+// ParseRates reads comma-separated "<key>=<rate>" pairs, e.g.
+// "offer=20/10s,*=50/10s"
+func ParseRates(s string) (map[string]Rate, error) {
+	rates := make(map[string]Rate)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, found := strings.Cut(pair, "=")
+		if !found || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid rate %q, expected <key>=<count>/<duration>", pair)
+		}
+		rate, err := ParseRate(value)
+		if err != nil {
+			return nil, err
+		}
+		rates[strings.TrimSpace(key)] = rate
+	}
+	return rates, nil
+}
+
+// Bucket is a single token bucket. It is not safe for concurrent use.
+type Bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a bucket that starts full
+func NewBucket(rate Rate, now time.Time) *Bucket {
+	return &Bucket{tokens: float64(rate.Count), last: now}
+}
+
+// Take spends one token if there is one. Otherwise it reports how long until
+// the next token arrives.
+func (b *Bucket) Take(rate Rate, now time.Time) (bool, time.Duration) {
+	if rate.Unlimited() {
+		return true, 0
+	}
+
+	perToken := rate.Per / time.Duration(rate.Count)
+	b.tokens = min(float64(rate.Count), b.tokens+float64(now.Sub(b.last))/float64(perToken))
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) * float64(perToken))
+}
+
+// full reports whether the bucket would be back at capacity by now, i.e. it
+// holds no state worth keeping
+func (b *Bucket) full(rate Rate, now time.Time) bool {
+	return now.Sub(b.last) >= rate.Per
+}
+
+// Limiter holds one bucket per key, e.g. per user or per client IP
+type Limiter struct {
+	rate Rate
+	now  func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*Bucket
+	lastSweep time.Time
+}
+
+func NewLimiter(rate Rate) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		now:     time.Now,
+		buckets: make(map[string]*Bucket),
+	}
+}
+
+// Allow spends a token from key's bucket, reporting how long to wait when
+// there is none
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.rate.Unlimited() {
+		return true, 0
+	}
+
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Forget keys that have been idle long enough to refill completely
+	if now.Sub(l.lastSweep) >= l.rate.Per {
+		for k, bucket := range l.buckets {
+			if bucket.full(l.rate, now) {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = NewBucket(l.rate, now)
+		l.buckets[key] = bucket
+	}
+	return bucket.Take(l.rate, now)
+}
@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rate
+		wantErr bool
+	}{
+		{in: "10/1m", want: Rate{Count: 10, Per: time.Minute}},
+		{in: " 5 / 10s ", want: Rate{Count: 5, Per: 10 * time.Second}},
+		{in: "", want: Rate{}},
+		{in: "0", want: Rate{}},
+		{in: "10", wantErr: true},
+		{in: "ten/1m", wantErr: true},
+		{in: "-1/1m", wantErr: true},
+		{in: "10/soon", wantErr: true},
+		{in: "10/0s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRate(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBucketTake spends a 2/1s bucket, which refills a token every 500ms, one
+// step after another
+func TestBucketTake(t *testing.T) {
+	rate := Rate{Count: 2, Per: time.Second}
+	bucket := NewBucket(rate, testTime)
+
+	steps := []struct {
+		name      string
+		at        time.Duration
+		wantOK    bool
+		wantRetry time.Duration
+	}{
+		{name: "starts full", at: 0, wantOK: true},
+		{name: "burst up to the count", at: 0, wantOK: true},
+		{name: "empty", at: 0, wantRetry: 500 * time.Millisecond},
+		{name: "half a token", at: 250 * time.Millisecond, wantRetry: 250 * time.Millisecond},
+		{name: "refilled a token", at: 500 * time.Millisecond, wantOK: true},
+		{name: "empty again", at: 500 * time.Millisecond, wantRetry: 500 * time.Millisecond},
+		{name: "idle refills to the count", at: time.Hour, wantOK: true},
+		{name: "but no further", at: time.Hour, wantOK: true},
+		{name: "so the burst ends", at: time.Hour, wantRetry: 500 * time.Millisecond},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			ok, retry := bucket.Take(rate, testTime.Add(step.at))
+			if ok != step.wantOK || retry != step.wantRetry {
+				t.Errorf("Take() = %v, %v, want %v, %v", ok, retry, step.wantOK, step.wantRetry)
+			}
+		})
+	}
+}
+
+func TestBucketUnlimited(t *testing.T) {
+	bucket := NewBucket(Rate{}, testTime)
+	for i := 0; i < 100; i++ {
+		if ok, _ := bucket.Take(Rate{}, testTime); !ok {
+			t.Fatalf("Take() #%d refused with no rate", i)
+		}
+	}
+}
+
+// TestLimiterEviction checks that the sweep forgets keys whose buckets have
+// refilled, and only those
+func TestLimiterEviction(t *testing.T) {
+	now := testTime
+	limiter := NewLimiter(Rate{Count: 1, Per: time.Second})
+	limiter.now = func() time.Time { return now }
+
+	steps := []struct {
+		name     string
+		at       time.Duration
+		key      string
+		wantOK   bool
+		wantKeys []string
+	}{
+		{name: "first key", at: 0, key: "alice", wantOK: true, wantKeys: []string{"alice"}},
+		{name: "keys are separate", at: 500 * time.Millisecond, key: "bob", wantOK: true, wantKeys: []string{"alice", "bob"}},
+		{name: "spent bucket is kept", at: 700 * time.Millisecond, key: "bob", wantKeys: []string{"alice", "bob"}},
+		{name: "sweep drops full buckets", at: time.Second, key: "carol", wantOK: true, wantKeys: []string{"bob", "carol"}},
+		{name: "no sweep before the next period", at: 1900 * time.Millisecond, key: "dave", wantOK: true, wantKeys: []string{"bob", "carol", "dave"}},
+		{name: "evicted key starts full", at: 2 * time.Second, key: "alice", wantOK: true, wantKeys: []string{"alice", "dave"}},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			now = testTime.Add(step.at)
+			if ok, _ := limiter.Allow(step.key); ok != step.wantOK {
+				t.Errorf("Allow(%q) = %v, want %v", step.key, ok, step.wantOK)
+			}
+
+			var keys []string
+			for key := range limiter.buckets {
+				keys = append(keys, key)
+			}
+			slices.Sort(keys)
+			if !slices.Equal(keys, step.wantKeys) {
+				t.Errorf("buckets = %v, want %v", keys, step.wantKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func NewAPITokenRepository(database *db.DB) *APITokenRepository {
+	return &APITokenRepository{
+		DB: database,
+	}
+}
+
+const apiTokenColumns = "t.ID, t.UserID, u.Username, t.Name, t.TokenHash, t.Scopes, t.CreatedAt, t.ExpiresAt, t.LastUsedAt"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIToken(row rowScanner, token *models.APIToken) error {
+	var scopes string
+	var lastUsedAt sql.NullTime
+	err := row.Scan(&token.ID, &token.UserID, &token.Username, &token.Name, &token.TokenHash,
+		&scopes, &token.CreatedAt, &token.ExpiresAt, &lastUsedAt)
+	if err != nil {
+		return err
+	}
+	token.Scopes = strings.Fields(scopes)
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return nil
+}
+
+func (a *APITokenRepository) CreateAPIToken(token models.APIToken) error {
+	_, err := a.DB.Exec("INSERT INTO api_tokens (ID, UserID, Name, TokenHash, Scopes, CreatedAt, ExpiresAt) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		token.ID, token.UserID, token.Name, token.TokenHash, strings.Join(token.Scopes, " "), token.CreatedAt.UTC(), token.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to create API token: %w", err)
+	}
+	return nil
+}
+
+// ListAPITokens returns the user's tokens, newest first, including expired
+// ones so they can be cleaned up
+func (a *APITokenRepository) ListAPITokens(userID string) ([]models.APIToken, error) {
+	rows, err := a.DB.Query("SELECT "+apiTokenColumns+" FROM api_tokens t JOIN users u ON u.ID = t.UserID WHERE t.UserID = ? ORDER BY t.CreatedAt DESC", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		var token models.APIToken
+		if err := scanAPIToken(rows, &token); err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// GetAPITokenByHash returns the unexpired token with the given hash
+func (a *APITokenRepository) GetAPITokenByHash(tokenHash string, now time.Time) (*models.APIToken, error) {
+	var token models.APIToken
+	row := a.DB.QueryRow("SELECT "+apiTokenColumns+" FROM api_tokens t JOIN users u ON u.ID = t.UserID WHERE t.TokenHash = ? AND t.ExpiresAt > ?", tokenHash, now.UTC())
+	if err := scanAPIToken(row, &token); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to query API token: %w", err)
+	}
+	return &token, nil
+}
+
+// TouchAPIToken records that a token was used, writing at most once per
+// interval so busy bots don't turn every request into a write
+func (a *APITokenRepository) TouchAPIToken(tokenID string, now time.Time, interval time.Duration) error {
+	_, err := a.DB.Exec("UPDATE api_tokens SET LastUsedAt = ? WHERE ID = ? AND (LastUsedAt IS NULL OR LastUsedAt < ?)",
+		now.UTC(), tokenID, now.Add(-interval).UTC())
+	if err != nil {
+		return fmt.Errorf("failed to update API token: %w", err)
+	}
+	return nil
+}
+
+// DeleteAPIToken revokes one of the user's tokens
+func (a *APITokenRepository) DeleteAPIToken(userID, tokenID string) error {
+	result, err := a.DB.Exec("DELETE FROM api_tokens WHERE ID = ? AND UserID = ?", tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete API token: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return utils.ErrAPITokenNotFound
+	}
+	return nil
+}
+
+// DeleteUserAPITokens revokes all of the user's tokens and returns their IDs
+func (a *APITokenRepository) DeleteUserAPITokens(userID string) ([]string, error) {
+	rows, err := a.DB.Query("DELETE FROM api_tokens WHERE UserID = ? RETURNING ID", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokenIDs := []string{}
+	for rows.Next() {
+		var tokenID string
+		if err := rows.Scan(&tokenID); err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		tokenIDs = append(tokenIDs, tokenID)
+	}
+	return tokenIDs, rows.Err()
+}
@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func NewAttendanceRepository(database *db.DB) *AttendanceRepository {
+	return &AttendanceRepository{
+		DB: database,
+	}
+}
+
+// OpenAttendance starts an interval in the room's meeting in progress,
+// starting a meeting with newMeetingID if there is none. An interval the
+// user still has open in the room is closed as a reconnect, and the new one
+// is recorded as a reconnect too. interval.MeetingID and JoinReason are
+// updated to match what was stored.
+func (a *AttendanceRepository) OpenAttendance(interval *models.AttendanceInterval, newMeetingID string) error {
+	tx, err := a.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	joinedAt := interval.JoinedAt.UTC()
+	result, err := tx.Exec("UPDATE attendance SET LeftAt = ?, LeaveReason = ? WHERE RoomID = ? AND UserID = ? AND LeftAt IS NULL",
+		joinedAt, models.AttendanceReconnect, interval.RoomID, interval.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to close earlier attendance: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		interval.JoinReason = models.AttendanceReconnect
+	}
+
+	// Another node may start the meeting at the same moment; the partial
+	// unique index lets only one of them win
+	_, err = tx.Exec("INSERT INTO meetings (ID, RoomID, StartedAt) VALUES (?, ?, ?) ON CONFLICT (RoomID) WHERE EndedAt IS NULL DO NOTHING",
+		newMeetingID, interval.RoomID, joinedAt)
+	if err != nil {
+		return fmt.Errorf("failed to start meeting: %w", err)
+	}
+	err = tx.QueryRow("SELECT ID FROM meetings WHERE RoomID = ? AND EndedAt IS NULL", interval.RoomID).Scan(&interval.MeetingID)
+	if err != nil {
+		return fmt.Errorf("failed to find meeting: %w", err)
+	}
+
+	_, err = tx.Exec("INSERT INTO attendance (ID, MeetingID, RoomID, UserID, JoinedAt, JoinReason) VALUES (?, ?, ?, ?, ?, ?)",
+		interval.ID, interval.MeetingID, interval.RoomID, interval.UserID, joinedAt, interval.JoinReason)
+	if err != nil {
+		return fmt.Errorf("failed to record attendance: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// CloseAttendance ends an open interval, and its meeting if nobody else is
+// left in it. It reports whether the interval was still open; closing one
+// that is already closed does nothing.
+func (a *AttendanceRepository) CloseAttendance(intervalID string, leftAt time.Time, reason string) (bool, error) {
+	tx, err := a.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE attendance SET LeftAt = ?, LeaveReason = ? WHERE ID = ? AND LeftAt IS NULL",
+		leftAt.UTC(), reason, intervalID)
+	if err != nil {
+		return false, fmt.Errorf("failed to close attendance: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(`UPDATE meetings SET EndedAt = ?
+        WHERE ID = (SELECT MeetingID FROM attendance WHERE ID = ?) AND EndedAt IS NULL
+          AND NOT EXISTS (SELECT 1 FROM attendance o WHERE o.MeetingID = meetings.ID AND o.LeftAt IS NULL)`,
+		leftAt.UTC(), intervalID)
+	if err != nil {
+		return false, fmt.Errorf("failed to end meeting: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+const meetingColumns = "m.ID, m.RoomID, r.Name, r.HostID, m.StartedAt, m.EndedAt"
+
+func scanMeeting(row rowScanner, meeting *models.Meeting) error {
+	var endedAt sql.NullTime
+	if err := row.Scan(&meeting.ID, &meeting.RoomID, &meeting.RoomName, &meeting.HostID, &meeting.StartedAt, &endedAt); err != nil {
+		return err
+	}
+	if endedAt.Valid {
+		meeting.EndedAt = &endedAt.Time
+	}
+	return nil
+}
+
+// GetMeeting returns one of a room's meetings
+func (a *AttendanceRepository) GetMeeting(roomID, meetingID string) (*models.Meeting, error) {
+	var meeting models.Meeting
+	row := a.DB.QueryRow("SELECT "+meetingColumns+" FROM meetings m JOIN rooms r ON r.ID = m.RoomID WHERE m.ID = ? AND m.RoomID = ?", meetingID, roomID)
+	if err := scanMeeting(row, &meeting); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrMeetingNotFound
+		}
+		return nil, fmt.Errorf("failed to query meeting: %w", err)
+	}
+	return &meeting, nil
+}
+
+// ListRoomMeetings returns a room's meetings, newest first
+func (a *AttendanceRepository) ListRoomMeetings(roomID string) ([]models.Meeting, error) {
+	return a.meetings("SELECT "+meetingColumns+" FROM meetings m JOIN rooms r ON r.ID = m.RoomID WHERE m.RoomID = ? ORDER BY m.StartedAt DESC", roomID)
+}
+
+// ListUserMeetings returns the meetings a user attended, newest first
+func (a *AttendanceRepository) ListUserMeetings(userID string) ([]models.Meeting, error) {
+	return a.meetings(`SELECT `+meetingColumns+` FROM meetings m JOIN rooms r ON r.ID = m.RoomID
+        WHERE EXISTS (SELECT 1 FROM attendance a WHERE a.MeetingID = m.ID AND a.UserID = ?)
+        ORDER BY m.StartedAt DESC`, userID)
+}
+
+func (a *AttendanceRepository) meetings(query string, args ...interface{}) ([]models.Meeting, error) {
+	rows, err := a.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query meetings: %w", err)
+	}
+	defer rows.Close()
+
+	meetings := []models.Meeting{}
+	for rows.Next() {
+		var meeting models.Meeting
+		if err := scanMeeting(rows, &meeting); err != nil {
+			return nil, fmt.Errorf("failed to scan meeting: %w", err)
+		}
+		meetings = append(meetings, meeting)
+	}
+	return meetings, rows.Err()
+}
+
+const attendanceColumns = "a.ID, a.MeetingID, a.RoomID, a.UserID, COALESCE(u.Username, ''), COALESCE(u.Name, ''), a.JoinedAt, a.LeftAt, a.JoinReason, a.LeaveReason"
+
+// MeetingAttendance returns every interval in a meeting, oldest first
+func (a *AttendanceRepository) MeetingAttendance(meetingID string) ([]models.AttendanceInterval, error) {
+	return a.intervals("SELECT "+attendanceColumns+" FROM attendance a LEFT JOIN users u ON u.ID = a.UserID WHERE a.MeetingID = ? ORDER BY a.JoinedAt", meetingID)
+}
+
+// UserAttendance returns every interval a user spent in any meeting, oldest
+// first
+func (a *AttendanceRepository) UserAttendance(userID string) ([]models.AttendanceInterval, error) {
+	return a.intervals("SELECT "+attendanceColumns+" FROM attendance a LEFT JOIN users u ON u.ID = a.UserID WHERE a.UserID = ? ORDER BY a.JoinedAt", userID)
+}
+
+func (a *AttendanceRepository) intervals(query string, args ...interface{}) ([]models.AttendanceInterval, error) {
+	rows, err := a.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attendance: %w", err)
+	}
+	defer rows.Close()
+
+	intervals := []models.AttendanceInterval{}
+	for rows.Next() {
+		var interval models.AttendanceInterval
+		var leftAt sql.NullTime
+		if err := rows.Scan(&interval.ID, &interval.MeetingID, &interval.RoomID, &interval.UserID, &interval.Username, &interval.Name,
+			&interval.JoinedAt, &leftAt, &interval.JoinReason, &interval.LeaveReason); err != nil {
+			return nil, fmt.Errorf("failed to scan attendance: %w", err)
+		}
+		if leftAt.Valid {
+			interval.LeftAt = &leftAt.Time
+		}
+		intervals = append(intervals, interval)
+	}
+	return intervals, rows.Err()
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+// TestAttendance walks one room through a meeting: a meeting starts with the
+// first join, others join it, a reconnect closes the stale interval, and the
+// meeting ends when the last person leaves
+func TestAttendance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		attendance := NewAttendanceRepository(database)
+		createTestUser(t, database, "alice")
+		createTestUser(t, database, "bob")
+		createTestRoom(t, database, "standup", "id-alice", nil)
+
+		open := func(intervalID, userID, meetingID string, at time.Duration) func() (*models.AttendanceInterval, bool, error) {
+			return func() (*models.AttendanceInterval, bool, error) {
+				interval := &models.AttendanceInterval{ID: intervalID, RoomID: "standup", UserID: userID, JoinedAt: testTime.Add(at), JoinReason: models.AttendanceJoin}
+				err := attendance.OpenAttendance(interval, meetingID)
+				return interval, true, err
+			}
+		}
+		closeInterval := func(intervalID string, at time.Duration) func() (*models.AttendanceInterval, bool, error) {
+			return func() (*models.AttendanceInterval, bool, error) {
+				closed, err := attendance.CloseAttendance(intervalID, testTime.Add(at), models.AttendanceLeave)
+				return nil, closed, err
+			}
+		}
+
+		steps := []struct {
+			name           string
+			apply          func() (*models.AttendanceInterval, bool, error)
+			wantMeetingID  string // for opened intervals
+			wantJoinReason string
+			wantClosed     bool // for closed intervals
+			wantMeetings   map[string]bool
+		}{
+			{
+				name:           "first join starts a meeting",
+				apply:          open("a1", "id-alice", "m1", 0),
+				wantMeetingID:  "m1",
+				wantJoinReason: models.AttendanceJoin,
+				wantMeetings:   map[string]bool{"m1": false},
+			},
+			{
+				name:           "second join reuses it",
+				apply:          open("b1", "id-bob", "m2", time.Minute),
+				wantMeetingID:  "m1",
+				wantJoinReason: models.AttendanceJoin,
+				wantMeetings:   map[string]bool{"m1": false},
+			},
+			{
+				name:           "rejoining closes the stale interval",
+				apply:          open("b2", "id-bob", "m3", 2*time.Minute),
+				wantMeetingID:  "m1",
+				wantJoinReason: models.AttendanceReconnect,
+				wantMeetings:   map[string]bool{"m1": false},
+			},
+			{
+				name:         "leaving with others left keeps the meeting going",
+				apply:        closeInterval("a1", 3*time.Minute),
+				wantClosed:   true,
+				wantMeetings: map[string]bool{"m1": false},
+			},
+			{
+				name:         "closing twice does nothing",
+				apply:        closeInterval("a1", 4*time.Minute),
+				wantMeetings: map[string]bool{"m1": false},
+			},
+			{
+				name:         "last to leave ends the meeting",
+				apply:        closeInterval("b2", 5*time.Minute),
+				wantClosed:   true,
+				wantMeetings: map[string]bool{"m1": true},
+			},
+			{
+				name:           "joining again starts a new meeting",
+				apply:          open("a2", "id-alice", "m4", time.Hour),
+				wantMeetingID:  "m4",
+				wantJoinReason: models.AttendanceJoin,
+				wantMeetings:   map[string]bool{"m1": true, "m4": false},
+			},
+		}
+		for _, step := range steps {
+			t.Run(step.name, func(t *testing.T) {
+				interval, closed, err := step.apply()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if interval != nil {
+					if interval.MeetingID != step.wantMeetingID || interval.JoinReason != step.wantJoinReason {
+						t.Errorf("interval in meeting %q as %q, want %q as %q", interval.MeetingID, interval.JoinReason, step.wantMeetingID, step.wantJoinReason)
+					}
+				} else if closed != step.wantClosed {
+					t.Errorf("CloseAttendance() = %v, want %v", closed, step.wantClosed)
+				}
+
+				meetings, err := attendance.ListRoomMeetings("standup")
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := make(map[string]bool)
+				for _, meeting := range meetings {
+					got[meeting.ID] = meeting.EndedAt != nil
+				}
+				if len(got) != len(step.wantMeetings) {
+					t.Fatalf("meetings = %v, want %v", got, step.wantMeetings)
+				}
+				for id, ended := range step.wantMeetings {
+					if gotEnded, ok := got[id]; !ok || gotEnded != ended {
+						t.Errorf("meetings = %v, want %v", got, step.wantMeetings)
+					}
+				}
+			})
+		}
+
+		intervals, err := attendance.MeetingAttendance("m1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		leftAt := testTime.Add(2 * time.Minute)
+		if len(intervals) != 3 || intervals[1].ID != "b1" || intervals[1].LeaveReason != models.AttendanceReconnect || !sameTime(intervals[1].LeftAt, &leftAt) {
+			t.Errorf("MeetingAttendance() = %+v, want b1 closed as a reconnect at %v", intervals, leftAt)
+		} else if intervals[0].Username != "alice" {
+			t.Errorf("Username = %q, want alice", intervals[0].Username)
+		}
+	})
+}
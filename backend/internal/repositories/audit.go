@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+func NewAuditRepository(database *db.DB) *AuditRepository {
+	return &AuditRepository{
+		DB: database,
+	}
+}
+
+// AppendAuditEvent adds an event to the audit log and sets its ID. The table
+// refuses updates and deletes, so this is the only way in.
+func (a *AuditRepository) AppendAuditEvent(event *models.AuditEvent) error {
+	details, err := json.Marshal(event.Details)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+
+	err = a.DB.QueryRow(`INSERT INTO audit_events (Action, ActorID, TargetType, TargetID, RoomID, IP, Details, CreatedAt)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING ID`,
+		event.Action, event.ActorID, event.TargetType, event.TargetID, event.RoomID, event.IP, string(details), event.CreatedAt.UTC(),
+	).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEvents returns up to query.Limit events matching the query, newest
+// first
+func (a *AuditRepository) ListAuditEvents(query models.AuditQuery) ([]models.AuditEvent, error) {
+	var conditions []string
+	var args []interface{}
+	filter := func(condition string, arg interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+
+	if query.Action != "" {
+		filter("Action = ?", query.Action)
+	}
+	if query.ActorID != "" {
+		filter("ActorID = ?", query.ActorID)
+	}
+	if query.TargetID != "" {
+		filter("TargetID = ?", query.TargetID)
+	}
+	if query.RoomID != "" {
+		filter("RoomID = ?", query.RoomID)
+	}
+	if query.IP != "" {
+		filter("IP = ?", query.IP)
+	}
+	if !query.Since.IsZero() {
+		filter("CreatedAt >= ?", query.Since.UTC())
+	}
+	if !query.Until.IsZero() {
+		filter("CreatedAt < ?", query.Until.UTC())
+	}
+	if query.Before > 0 {
+		filter("ID < ?", query.Before)
+	}
+
+	statement := "SELECT ID, Action, ActorID, TargetType, TargetID, RoomID, IP, Details, CreatedAt FROM audit_events"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY ID DESC LIMIT ?"
+	args = append(args, query.Limit)
+
+	rows, err := a.DB.Query(statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var event models.AuditEvent
+		var details sql.NullString
+		if err := rows.Scan(&event.ID, &event.Action, &event.ActorID, &event.TargetType, &event.TargetID, &event.RoomID,
+			&event.IP, &details, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		if details.Valid && details.String != "" {
+			if err := json.Unmarshal([]byte(details.String), &event.Details); err != nil {
+				return nil, fmt.Errorf("failed to decode audit details: %w", err)
+			}
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+func TestAuditEvents(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		audit := NewAuditRepository(database)
+		events := []models.AuditEvent{
+			{Action: models.AuditLogin, ActorID: "id-alice", TargetType: "user", TargetID: "id-alice", IP: "192.0.2.1", CreatedAt: testTime},
+			{Action: models.AuditRoomCreated, ActorID: "id-alice", TargetType: "room", TargetID: "standup", RoomID: "standup", IP: "192.0.2.1", Details: map[string]string{"name": "Standup"}, CreatedAt: testTime.Add(time.Minute)},
+			{Action: models.AuditLoginFailed, TargetType: "user", TargetID: "bob", IP: "192.0.2.2", CreatedAt: testTime.Add(2 * time.Minute)},
+			{Action: models.AuditRoomEnded, ActorID: "id-alice", TargetType: "room", TargetID: "standup", RoomID: "standup", CreatedAt: testTime.Add(3 * time.Minute)},
+		}
+		var ids []int64
+		for i := range events {
+			if err := audit.AppendAuditEvent(&events[i]); err != nil {
+				t.Fatal(err)
+			}
+			if len(ids) > 0 && events[i].ID <= ids[len(ids)-1] {
+				t.Fatalf("IDs not increasing: %d after %v", events[i].ID, ids)
+			}
+			ids = append(ids, events[i].ID)
+		}
+
+		tests := []struct {
+			name  string
+			query models.AuditQuery
+			want  []int64
+		}{
+			{name: "everything, newest first", query: models.AuditQuery{Limit: 10}, want: []int64{ids[3], ids[2], ids[1], ids[0]}},
+			{name: "by action", query: models.AuditQuery{Action: models.AuditLogin, Limit: 10}, want: []int64{ids[0]}},
+			{name: "by actor", query: models.AuditQuery{ActorID: "id-alice", Limit: 10}, want: []int64{ids[3], ids[1], ids[0]}},
+			{name: "by room", query: models.AuditQuery{RoomID: "standup", Limit: 10}, want: []int64{ids[3], ids[1]}},
+			{name: "by IP", query: models.AuditQuery{IP: "192.0.2.2", Limit: 10}, want: []int64{ids[2]}},
+			{name: "time range", query: models.AuditQuery{Since: testTime.Add(time.Minute), Until: testTime.Add(3 * time.Minute), Limit: 10}, want: []int64{ids[2], ids[1]}},
+			{name: "first page", query: models.AuditQuery{Limit: 2}, want: []int64{ids[3], ids[2]}},
+			{name: "next page", query: models.AuditQuery{Before: ids[2], Limit: 2}, want: []int64{ids[1], ids[0]}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				found, err := audit.ListAuditEvents(tt.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := []int64{}
+				for _, event := range found {
+					got = append(got, event.ID)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("ListAuditEvents() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+
+		t.Run("details", func(t *testing.T) {
+			found, err := audit.ListAuditEvents(models.AuditQuery{Action: models.AuditRoomCreated, Limit: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(found) != 1 || found[0].Details["name"] != "Standup" || !found[0].CreatedAt.Equal(events[1].CreatedAt) {
+				t.Errorf("ListAuditEvents() = %+v, want %+v", found, events[1])
+			}
+		})
+	})
+}
+
+// TestAuditEventsAppendOnly checks both backends refuse to change the log
+func TestAuditEventsAppendOnly(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		event := models.AuditEvent{Action: models.AuditLogin, ActorID: "id-alice", TargetType: "user", TargetID: "id-alice", CreatedAt: testTime}
+		if err := NewAuditRepository(database).AppendAuditEvent(&event); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name      string
+			statement string
+		}{
+			{name: "update", statement: "UPDATE audit_events SET ActorID = 'someone' WHERE ID = ?"},
+			{name: "delete", statement: "DELETE FROM audit_events WHERE ID = ?"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := database.Exec(tt.statement, event.ID); err == nil {
+					t.Errorf("%s succeeded, want it refused", tt.name)
+				}
+			})
+		}
+	})
+}
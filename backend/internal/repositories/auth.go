@@ -4,16 +4,18 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/legendary-acp/chimecast/internal/db"
 	"github.com/legendary-acp/chimecast/internal/models"
 	"github.com/legendary-acp/chimecast/internal/utils"
-	"github.com/mattn/go-sqlite3"
 )
 
-func NewAuthRepository(db *sql.DB) *AuthRepository {
+func NewAuthRepository(database *db.DB) *AuthRepository {
 	return &AuthRepository{
-		DB: db,
+		DB: database,
 	}
 }
 
@@ -31,13 +33,9 @@ func (a *AuthRepository) RegisterUser(user models.User) error {
 	}()
 
 	// Attempt to insert user
-	_, err = tx.Exec("INSERT INTO users (Username, ID, Email, Name, HashedPassword, CreatedAt) VALUES (?, ?, ?, ?, ?, ?)", user.Username, user.ID, user.Email, user.Name, user.HashedPassword, user.CreatedAt)
+	err = insertUser(tx, user)
 	if err != nil {
-		if isUniqueViolation(err) {
-			log.Println("User already exists:", user)
-			return utils.ErrUserAlreadyExists
-		}
-		return fmt.Errorf("failed to register user: %w", err)
+		return err
 	}
 
 	// Commit transaction
@@ -45,7 +43,44 @@ func (a *AuthRepository) RegisterUser(user models.User) error {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	log.Println("User registered:", user)
+	slog.Info("User registered", "user", user)
+	return nil
+}
+
+// RegisterUserWithIdentity creates a user who signs in through an external
+// provider, together with the identity that links them
+func (a *AuthRepository) RegisterUserWithIdentity(user models.User, identity models.UserIdentity) error {
+	tx, err := a.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertUser(tx, user); err != nil {
+		return err
+	}
+	if err := insertIdentity(tx, identity); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	slog.Info("User registered", "user", user, "issuer", identity.Issuer)
+	return nil
+}
+
+func insertUser(tx execer, user models.User) error {
+	_, err := tx.Exec("INSERT INTO users (Username, ID, Email, Name, HashedPassword, CreatedAt, EmailVerified) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		user.Username, user.ID, user.Email, user.Name, user.HashedPassword, user.CreatedAt, user.EmailVerified)
+	if err != nil {
+		if db.IsUniqueViolation(err) {
+			slog.Info("User already exists", "user", user)
+			return utils.ErrUserAlreadyExists
+		}
+		return fmt.Errorf("failed to register user: %w", err)
+	}
 	return nil
 }
 
@@ -53,17 +88,17 @@ func (a *AuthRepository) Login(userName string) (*models.User, error) {
 	var user models.User
 
 	// Prepare and execute the SQL statement
-	stmt, err := a.DB.Prepare("SELECT Username, Email, Name, HashedPassword, CreatedAt FROM Users WHERE Username = ?")
+	stmt, err := a.DB.Prepare("SELECT " + userColumns + " FROM Users WHERE Username = ?")
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare query: %v", err)
 	}
 	defer stmt.Close()
 
 	// Execute the query
-	err = stmt.QueryRow(userName).Scan(&user.Username, &user.Email, &user.Name, &user.HashedPassword, &user.CreatedAt)
+	err = scanUser(stmt.QueryRow(userName), &user)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("user not found")
+			return nil, utils.ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to query user: %v", err)
 	}
@@ -71,9 +106,220 @@ func (a *AuthRepository) Login(userName string) (*models.User, error) {
 	return &user, nil
 }
 
-func isUniqueViolation(err error) bool {
-	if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.Code == sqlite3.ErrConstraint {
-		return true
+const userColumns = "ID, Username, Email, Name, HashedPassword, CreatedAt, EmailVerified, MFAEnabled, TOTPSecret, TOTPLastStep, AvatarName, PreferredLanguage, DeletedAt, IsAdmin, DisabledAt, SessionsRevokedAt"
+
+// scanUser reads a row selected with userColumns
+func scanUser(row rowScanner, user *models.User) error {
+	var deletedAt, disabledAt, sessionsRevokedAt sql.NullTime
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Name, &user.HashedPassword, &user.CreatedAt,
+		&user.EmailVerified, &user.MFAEnabled, &user.TOTPSecret, &user.TOTPLastStep, &user.AvatarName, &user.PreferredLanguage, &deletedAt, &user.IsAdmin, &disabledAt, &sessionsRevokedAt)
+	if err != nil {
+		return err
+	}
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	if disabledAt.Valid {
+		user.DisabledAt = &disabledAt.Time
+	}
+	if sessionsRevokedAt.Valid {
+		user.SessionsRevokedAt = &sessionsRevokedAt.Time
+	}
+	return nil
+}
+
+func (a *AuthRepository) GetUserByID(userID string) (*models.User, error) {
+	return a.getUser("ID", userID)
+}
+
+func (a *AuthRepository) GetUserByUsername(username string) (*models.User, error) {
+	return a.getUser("Username", username)
+}
+
+func (a *AuthRepository) GetUserByEmail(email string) (*models.User, error) {
+	return a.getUser("Email", email)
+}
+
+// getUser loads the user whose column matches value. column is never taken
+// from user input.
+func (a *AuthRepository) getUser(column, value string) (*models.User, error) {
+	var user models.User
+	err := scanUser(a.DB.QueryRow(fmt.Sprintf("SELECT %s FROM users WHERE %s = ?", userColumns, column), value), &user)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	return &user, nil
+}
+
+func (a *AuthRepository) SetEmailVerified(userID string) error {
+	_, err := a.DB.Exec("UPDATE users SET EmailVerified = TRUE WHERE ID = ?", userID)
+	if err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+	return nil
+}
+
+func (a *AuthRepository) UpdatePassword(userID, hashedPassword string) error {
+	result, err := a.DB.Exec("UPDATE users SET HashedPassword = ? WHERE ID = ?", hashedPassword, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return utils.ErrUserNotFound
+	}
+	return nil
+}
+
+// UpdateProfile saves the user's editable profile fields
+func (a *AuthRepository) UpdateProfile(user models.User) error {
+	result, err := a.DB.Exec("UPDATE users SET Name = ?, Email = ?, EmailVerified = ?, PreferredLanguage = ? WHERE ID = ?",
+		user.Name, user.Email, user.EmailVerified, user.PreferredLanguage, user.ID)
+	if err != nil {
+		if db.IsUniqueViolation(err) {
+			return utils.ErrUserAlreadyExists
+		}
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return utils.ErrUserNotFound
+	}
+	return nil
+}
+
+// SetAvatar records the user's avatar file, or clears it when name is empty
+func (a *AuthRepository) SetAvatar(userID, name string) error {
+	result, err := a.DB.Exec("UPDATE users SET AvatarName = ? WHERE ID = ?", name, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update avatar: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return utils.ErrUserNotFound
+	}
+	return nil
+}
+
+// SetAdmin grants or revokes a user's access to the admin API
+func (a *AuthRepository) SetAdmin(userID string, isAdmin bool) error {
+	result, err := a.DB.Exec("UPDATE users SET IsAdmin = ? WHERE ID = ?", isAdmin, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update admin role: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return utils.ErrUserNotFound
+	}
+	return nil
+}
+
+// SetDisabled disables an account from disabledAt, or re-enables it when
+// disabledAt is nil
+func (a *AuthRepository) SetDisabled(userID string, disabledAt *time.Time) error {
+	var value interface{}
+	if disabledAt != nil {
+		value = disabledAt.UTC()
+	}
+	result, err := a.DB.Exec("UPDATE users SET DisabledAt = ? WHERE ID = ? AND DeletedAt IS NULL", value, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update account status: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return utils.ErrUserNotFound
+	}
+	return nil
+}
+
+// RevokeSessions refuses every session of the user started before at
+func (a *AuthRepository) RevokeSessions(userID string, at time.Time) error {
+	result, err := a.DB.Exec("UPDATE users SET SessionsRevokedAt = ? WHERE ID = ?", at.UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return utils.ErrUserNotFound
+	}
+	return nil
+}
+
+// SearchUsers pages through accounts that haven't been erased, ordered by
+// username. The search matches part of the username, name or email,
+// ignoring case.
+func (a *AuthRepository) SearchUsers(query models.UserQuery) ([]models.User, error) {
+	where := []string{"DeletedAt IS NULL"}
+	var args []interface{}
+	if query.Search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(query.Search)) + "%"
+		where = append(where, `(LOWER(Username) LIKE ? ESCAPE '\' OR LOWER(Name) LIKE ? ESCAPE '\' OR LOWER(Email) LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern, pattern)
+	}
+	if query.After != "" {
+		where = append(where, "Username > ?")
+		args = append(args, query.After)
+	}
+	args = append(args, query.Limit)
+
+	rows, err := a.DB.Query("SELECT "+userColumns+" FROM users WHERE "+strings.Join(where, " AND ")+" ORDER BY Username LIMIT ?", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// escapeLike makes LIKE treat %, _ and \ in s literally, with \ as the
+// escape character
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// AnonymizeUser erases a user's personal data while keeping the row, so
+// rooms and other records that reference the ID stay valid. Credentials,
+// linked identities and the login history go with it.
+func (a *AuthRepository) AnonymizeUser(user models.User, now time.Time) error {
+	tx, err := a.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	placeholder := "deleted-" + user.ID
+	_, err = tx.Exec(`UPDATE users SET Username = ?, Email = ?, Name = ?, HashedPassword = '', EmailVerified = FALSE,
+        MFAEnabled = FALSE, TOTPSecret = '', TOTPLastStep = 0, AvatarName = '', PreferredLanguage = '', IsAdmin = FALSE, DeletedAt = ?
+        WHERE ID = ?`,
+		placeholder, placeholder+"@deleted.invalid", "Deleted user", now.UTC(), user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to anonymize user: %w", err)
+	}
+
+	cleanup := []struct{ query, arg string }{
+		{"DELETE FROM user_identities WHERE UserID = ?", user.ID},
+		{"DELETE FROM user_tokens WHERE UserID = ?", user.ID},
+		{"DELETE FROM mfa_recovery_codes WHERE UserID = ?", user.ID},
+		{"DELETE FROM api_tokens WHERE UserID = ?", user.ID},
+		{"DELETE FROM webhook_deliveries WHERE WebhookID IN (SELECT ID FROM webhooks WHERE UserID = ?)", user.ID},
+		{"DELETE FROM webhooks WHERE UserID = ?", user.ID},
+		{"DELETE FROM room_invitations WHERE UserID = ?", user.ID},
+		{"DELETE FROM login_attempts WHERE Username = ?", user.Username},
 	}
-	return false
+	for _, c := range cleanup {
+		if _, err := tx.Exec(c.query, c.arg); err != nil {
+			return fmt.Errorf("failed to erase user data: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	slog.Info("User anonymized", "user_id", user.ID)
+	return nil
 }
@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func TestRegisterUser(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		users := NewAuthRepository(database)
+		alice := createTestUser(t, database, "alice")
+
+		tests := []struct {
+			name string
+			user models.User
+			want error
+		}{
+			{name: "new user", user: models.User{ID: "id-bob", Username: "bob", Email: "bob@example.com", CreatedAt: testTime}},
+			{name: "taken username", user: models.User{ID: "id-other", Username: alice.Username, Email: "other@example.com", CreatedAt: testTime}, want: utils.ErrUserAlreadyExists},
+			{name: "taken email", user: models.User{ID: "id-other", Username: "other", Email: alice.Email, CreatedAt: testTime}, want: utils.ErrUserAlreadyExists},
+			{name: "taken ID", user: models.User{ID: alice.ID, Username: "other", Email: "other@example.com", CreatedAt: testTime}, want: utils.ErrUserAlreadyExists},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if err := users.RegisterUser(tt.user); !errors.Is(err, tt.want) {
+					t.Fatalf("RegisterUser() error = %v, want %v", err, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestGetUser(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		users := NewAuthRepository(database)
+		alice := createTestUser(t, database, "alice")
+
+		tests := []struct {
+			name   string
+			lookup func() (*models.User, error)
+			want   error
+		}{
+			{name: "by ID", lookup: func() (*models.User, error) { return users.GetUserByID(alice.ID) }},
+			{name: "by username", lookup: func() (*models.User, error) { return users.GetUserByUsername(alice.Username) }},
+			{name: "by email", lookup: func() (*models.User, error) { return users.GetUserByEmail(alice.Email) }},
+			{name: "login", lookup: func() (*models.User, error) { return users.Login(alice.Username) }},
+			{name: "unknown ID", lookup: func() (*models.User, error) { return users.GetUserByID("missing") }, want: utils.ErrUserNotFound},
+			{name: "unknown username", lookup: func() (*models.User, error) { return users.Login("missing") }, want: utils.ErrUserNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				user, err := tt.lookup()
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("error = %v, want %v", err, tt.want)
+				}
+				if tt.want != nil {
+					return
+				}
+				if user.ID != alice.ID || user.Username != alice.Username || user.Email != alice.Email || user.Name != alice.Name {
+					t.Errorf("user = %+v, want %+v", user, alice)
+				}
+				if !user.CreatedAt.Equal(alice.CreatedAt) {
+					t.Errorf("CreatedAt = %v, want %v", user.CreatedAt, alice.CreatedAt)
+				}
+			})
+		}
+	})
+}
+
+// TestUserFlags checks booleans and nullable times survive the round trip on
+// both backends
+func TestUserFlags(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		users := NewAuthRepository(database)
+		alice := createTestUser(t, database, "alice")
+		later := testTime.Add(time.Hour)
+
+		tests := []struct {
+			name   string
+			update func() error
+			check  func(user *models.User) bool
+		}{
+			{
+				name:   "email verified",
+				update: func() error { return users.SetEmailVerified(alice.ID) },
+				check:  func(user *models.User) bool { return user.EmailVerified },
+			},
+			{
+				name:   "admin granted",
+				update: func() error { return users.SetAdmin(alice.ID, true) },
+				check:  func(user *models.User) bool { return user.IsAdmin },
+			},
+			{
+				name:   "admin revoked",
+				update: func() error { return users.SetAdmin(alice.ID, false) },
+				check:  func(user *models.User) bool { return !user.IsAdmin },
+			},
+			{
+				name:   "disabled",
+				update: func() error { return users.SetDisabled(alice.ID, &later) },
+				check:  func(user *models.User) bool { return sameTime(user.DisabledAt, &later) },
+			},
+			{
+				name:   "re-enabled",
+				update: func() error { return users.SetDisabled(alice.ID, nil) },
+				check:  func(user *models.User) bool { return user.DisabledAt == nil },
+			},
+			{
+				name:   "sessions revoked",
+				update: func() error { return users.RevokeSessions(alice.ID, later) },
+				check:  func(user *models.User) bool { return sameTime(user.SessionsRevokedAt, &later) },
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if err := tt.update(); err != nil {
+					t.Fatal(err)
+				}
+				user, err := users.GetUserByID(alice.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !tt.check(user) {
+					t.Errorf("user after update = %+v", user)
+				}
+			})
+		}
+
+		if err := users.SetAdmin("missing", true); !errors.Is(err, utils.ErrUserNotFound) {
+			t.Errorf("SetAdmin(missing) error = %v, want %v", err, utils.ErrUserNotFound)
+		}
+	})
+}
+
+func TestSearchUsers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		users := NewAuthRepository(database)
+		for _, username := range []string{"alice", "bob", "carol", "dave_x", "davex"} {
+			createTestUser(t, database, username)
+		}
+
+		tests := []struct {
+			name  string
+			query models.UserQuery
+			want  []string
+		}{
+			{name: "everyone", query: models.UserQuery{Limit: 10}, want: []string{"alice", "bob", "carol", "dave_x", "davex"}},
+			{name: "first page", query: models.UserQuery{Limit: 2}, want: []string{"alice", "bob"}},
+			{name: "next page", query: models.UserQuery{After: "bob", Limit: 2}, want: []string{"carol", "dave_x"}},
+			{name: "ignores case", query: models.UserQuery{Search: "CAR", Limit: 10}, want: []string{"carol"}},
+			{name: "matches email", query: models.UserQuery{Search: "bob@example", Limit: 10}, want: []string{"bob"}},
+			{name: "underscore is literal", query: models.UserQuery{Search: "e_x", Limit: 10}, want: []string{"dave_x"}},
+			{name: "percent is literal", query: models.UserQuery{Search: "%", Limit: 10}, want: []string{}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				found, err := users.SearchUsers(tt.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := []string{}
+				for _, user := range found {
+					got = append(got, user.Username)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("SearchUsers() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+}
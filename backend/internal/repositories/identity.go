@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// GetUserByIdentity finds the user linked to an external identity
+func (a *AuthRepository) GetUserByIdentity(issuer, subject string) (*models.User, error) {
+	var userID string
+	err := a.DB.QueryRow("SELECT UserID FROM user_identities WHERE Issuer = ? AND Subject = ?", issuer, subject).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to query identity: %w", err)
+	}
+	return a.GetUserByID(userID)
+}
+
+// LinkIdentity lets an existing user sign in through an external provider
+func (a *AuthRepository) LinkIdentity(identity models.UserIdentity) error {
+	return insertIdentity(a.DB, identity)
+}
+
+func insertIdentity(tx execer, identity models.UserIdentity) error {
+	_, err := tx.Exec("INSERT INTO user_identities (Issuer, Subject, UserID, Email, CreatedAt) VALUES (?, ?, ?, ?, ?)",
+		identity.Issuer, identity.Subject, identity.UserID, identity.Email, identity.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	return nil
+}
+
+// ListIdentities returns the external identities linked to a user
+func (a *AuthRepository) ListIdentities(userID string) ([]models.UserIdentity, error) {
+	rows, err := a.DB.Query("SELECT Issuer, Subject, UserID, COALESCE(Email, ''), CreatedAt FROM user_identities WHERE UserID = ?", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query identities: %w", err)
+	}
+	defer rows.Close()
+
+	identities := []models.UserIdentity{}
+	for rows.Next() {
+		var identity models.UserIdentity
+		if err := rows.Scan(&identity.Issuer, &identity.Subject, &identity.UserID, &identity.Email, &identity.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
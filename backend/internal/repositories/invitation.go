@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// Invite lets a user into a private room. Inviting someone twice keeps the
+// first invitation.
+func (r *RoomRepository) Invite(invitation models.RoomInvitation) error {
+	_, err := r.DB.Exec("INSERT INTO room_invitations (RoomID, UserID, InvitedAt) VALUES (?, ?, ?)",
+		invitation.RoomID, invitation.UserID, invitation.InvitedAt.UTC())
+	if err != nil && !db.IsUniqueViolation(err) {
+		return fmt.Errorf("failed to invite user: %w", err)
+	}
+	return nil
+}
+
+// Uninvite withdraws a user's invitation to a room
+func (r *RoomRepository) Uninvite(roomID, userID string) error {
+	result, err := r.DB.Exec("DELETE FROM room_invitations WHERE RoomID = ? AND UserID = ?", roomID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to withdraw invitation: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return utils.ErrInvitationNotFound
+	}
+	return err
+}
+
+// IsInvited reports whether a user is invited to a room
+func (r *RoomRepository) IsInvited(roomID, userID string) (bool, error) {
+	var invited bool
+	err := r.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM room_invitations WHERE RoomID = ? AND UserID = ?)", roomID, userID).Scan(&invited)
+	if err != nil {
+		return false, fmt.Errorf("failed to check invitation: %w", err)
+	}
+	return invited, nil
+}
+
+// ListInvitations returns a room's invitees, most recently invited first
+func (r *RoomRepository) ListInvitations(roomID string) ([]models.RoomInvitation, error) {
+	return r.queryInvitations("i.RoomID = ?", roomID)
+}
+
+// UserInvitations returns the rooms a user is invited to, most recent first
+func (r *RoomRepository) UserInvitations(userID string) ([]models.RoomInvitation, error) {
+	return r.queryInvitations("i.UserID = ?", userID)
+}
+
+func (r *RoomRepository) queryInvitations(where, arg string) ([]models.RoomInvitation, error) {
+	rows, err := r.DB.Query(`SELECT i.RoomID, i.UserID, u.Username, u.Name, i.InvitedAt
+        FROM room_invitations i JOIN users u ON u.ID = i.UserID
+        WHERE `+where+`
+        ORDER BY i.InvitedAt DESC`, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []models.RoomInvitation{}
+	for rows.Next() {
+		var invitation models.RoomInvitation
+		if err := rows.Scan(&invitation.RoomID, &invitation.UserID, &invitation.Username, &invitation.Name, &invitation.InvitedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
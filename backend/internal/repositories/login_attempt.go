@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+func NewLoginAttemptRepository(database *db.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		DB: database,
+	}
+}
+
+func (l *LoginAttemptRepository) RecordLoginAttempt(attempt models.LoginAttempt) error {
+	_, err := l.DB.Exec("INSERT INTO login_attempts (Username, IP, Result, AttemptedAt) VALUES (?, ?, ?, ?)",
+		attempt.Username, attempt.IP, attempt.Result, attempt.AttemptedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+	return nil
+}
+
+// AttemptsFromIP returns when each password check from ip since the given
+// time happened, oldest first. Attempts refused by rate limiting don't count.
+func (l *LoginAttemptRepository) AttemptsFromIP(ip string, since time.Time) ([]time.Time, error) {
+	return l.attemptTimes(`SELECT AttemptedAt FROM login_attempts
+        WHERE IP = ? AND Result != ? AND AttemptedAt > ?
+        ORDER BY AttemptedAt`, ip, models.LoginResultBlocked, since.UTC())
+}
+
+// AttemptsForUsername is AttemptsFromIP keyed by the submitted username
+func (l *LoginAttemptRepository) AttemptsForUsername(username string, since time.Time) ([]time.Time, error) {
+	return l.attemptTimes(`SELECT AttemptedAt FROM login_attempts
+        WHERE Username = ? AND Result != ? AND AttemptedAt > ?
+        ORDER BY AttemptedAt`, username, models.LoginResultBlocked, since.UTC())
+}
+
+// FailuresSinceLastSuccess returns the failed attempts for username since its
+// last successful login or the given time, whichever is later, oldest first
+func (l *LoginAttemptRepository) FailuresSinceLastSuccess(username string, since time.Time) ([]time.Time, error) {
+	return l.attemptTimes(`SELECT AttemptedAt FROM login_attempts
+        WHERE Username = ? AND Result = ? AND AttemptedAt > ?
+          AND NOT EXISTS (SELECT 1 FROM login_attempts s
+            WHERE s.Username = ? AND s.Result = ? AND s.AttemptedAt >= login_attempts.AttemptedAt)
+        ORDER BY AttemptedAt`,
+		username, models.LoginResultFailure, since.UTC(), username, models.LoginResultSuccess)
+}
+
+func (l *LoginAttemptRepository) attemptTimes(query string, args ...interface{}) ([]time.Time, error) {
+	rows, err := l.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query login attempts: %w", err)
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var attemptedAt time.Time
+		if err := rows.Scan(&attemptedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan login attempt: %w", err)
+		}
+		times = append(times, attemptedAt)
+	}
+	return times, rows.Err()
+}
+
+// LoginHistory returns every recorded login attempt against a username,
+// newest first
+func (l *LoginAttemptRepository) LoginHistory(username string) ([]models.LoginAttempt, error) {
+	rows, err := l.DB.Query("SELECT Username, IP, Result, AttemptedAt FROM login_attempts WHERE Username = ? ORDER BY AttemptedAt DESC", username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query login attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []models.LoginAttempt{}
+	for rows.Next() {
+		var attempt models.LoginAttempt
+		if err := rows.Scan(&attempt.Username, &attempt.IP, &attempt.Result, &attempt.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan login attempt: %w", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
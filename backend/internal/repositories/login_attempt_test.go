@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+func TestLoginAttempts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		attempts := NewLoginAttemptRepository(database)
+		at := func(minutes int) time.Time { return testTime.Add(time.Duration(minutes) * time.Minute) }
+		for _, attempt := range []models.LoginAttempt{
+			{Username: "alice", IP: "192.0.2.1", Result: models.LoginResultFailure, AttemptedAt: at(0)},
+			{Username: "alice", IP: "192.0.2.1", Result: models.LoginResultSuccess, AttemptedAt: at(1)},
+			{Username: "alice", IP: "192.0.2.2", Result: models.LoginResultFailure, AttemptedAt: at(2)},
+			{Username: "alice", IP: "192.0.2.2", Result: models.LoginResultBlocked, AttemptedAt: at(3)},
+			{Username: "bob", IP: "192.0.2.1", Result: models.LoginResultFailure, AttemptedAt: at(4)},
+			{Username: "alice", IP: "192.0.2.1", Result: models.LoginResultFailure, AttemptedAt: at(5)},
+		} {
+			if err := attempts.RecordLoginAttempt(attempt); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tests := []struct {
+			name   string
+			lookup func() ([]time.Time, error)
+			want   []time.Time
+		}{
+			{
+				name:   "from an IP",
+				lookup: func() ([]time.Time, error) { return attempts.AttemptsFromIP("192.0.2.1", at(-1)) },
+				want:   []time.Time{at(0), at(1), at(4), at(5)},
+			},
+			{
+				name:   "from an IP, blocked attempts left out",
+				lookup: func() ([]time.Time, error) { return attempts.AttemptsFromIP("192.0.2.2", at(-1)) },
+				want:   []time.Time{at(2)},
+			},
+			{
+				name:   "for a username since a time",
+				lookup: func() ([]time.Time, error) { return attempts.AttemptsForUsername("alice", at(1)) },
+				want:   []time.Time{at(2), at(5)},
+			},
+			{
+				name:   "failures since the last success",
+				lookup: func() ([]time.Time, error) { return attempts.FailuresSinceLastSuccess("alice", at(-1)) },
+				want:   []time.Time{at(2), at(5)},
+			},
+			{
+				name:   "failures with no success",
+				lookup: func() ([]time.Time, error) { return attempts.FailuresSinceLastSuccess("bob", at(-1)) },
+				want:   []time.Time{at(4)},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := tt.lookup()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.EqualFunc(got, tt.want, time.Time.Equal) {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+
+		history, err := attempts.LoginHistory("alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 5 || !history[0].AttemptedAt.Equal(at(5)) || history[0].IP != "192.0.2.1" {
+			t.Errorf("LoginHistory() = %+v, want alice's five attempts, newest first", history)
+		}
+	})
+}
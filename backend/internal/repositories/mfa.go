@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// SetTOTPSecret stores a secret awaiting confirmation. MFA stays off until
+// EnableMFA.
+func (a *AuthRepository) SetTOTPSecret(userID, secret string) error {
+	_, err := a.DB.Exec("UPDATE users SET TOTPSecret = ?, TOTPLastStep = 0 WHERE ID = ? AND MFAEnabled = FALSE", secret, userID)
+	if err != nil {
+		return fmt.Errorf("failed to store totp secret: %w", err)
+	}
+	return nil
+}
+
+// EnableMFA turns on the second factor and replaces any recovery codes with
+// the given hashes
+func (a *AuthRepository) EnableMFA(userID string, codeHashes []string) error {
+	tx, err := a.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET MFAEnabled = TRUE WHERE ID = ?", userID); err != nil {
+		return fmt.Errorf("failed to enable mfa: %w", err)
+	}
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DisableMFA turns off the second factor and forgets the secret and codes
+func (a *AuthRepository) DisableMFA(userID string) error {
+	tx, err := a.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET MFAEnabled = FALSE, TOTPSecret = '', TOTPLastStep = 0 WHERE ID = ?", userID); err != nil {
+		return fmt.Errorf("failed to disable mfa: %w", err)
+	}
+	if err := replaceRecoveryCodes(tx, userID, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps a user's recovery codes for new ones
+func (a *AuthRepository) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	tx, err := a.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ConsumeRecoveryCode marks one of the user's unused codes as used
+func (a *AuthRepository) ConsumeRecoveryCode(userID, codeHash string) error {
+	result, err := a.DB.Exec("UPDATE mfa_recovery_codes SET UsedAt = ? WHERE UserID = ? AND CodeHash = ? AND UsedAt IS NULL",
+		time.Now().UTC(), userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("failed to consume recovery code: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows != 1 {
+		return utils.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// AdvanceTOTPStep records the time step of an accepted code. It fails if that
+// step or a later one was already used, so each code works only once.
+func (a *AuthRepository) AdvanceTOTPStep(userID string, step int64) error {
+	result, err := a.DB.Exec("UPDATE users SET TOTPLastStep = ? WHERE ID = ? AND TOTPLastStep < ?", step, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to record totp step: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows != 1 {
+		return utils.ErrInvalidMFACode
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx execer, userID string, codeHashes []string) error {
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE UserID = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec("INSERT INTO mfa_recovery_codes (CodeHash, UserID) VALUES (?, ?)", hash, userID); err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+	return nil
+}
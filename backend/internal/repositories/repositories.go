@@ -1,11 +1,44 @@
 package repositories
 
-import "database/sql"
+import (
+	"database/sql"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
 
 type AuthRepository struct {
-	DB *sql.DB
+	DB *db.DB
 }
 
 type RoomRepository struct {
-	DB *sql.DB
+	DB *db.DB
+}
+
+type LoginAttemptRepository struct {
+	DB *db.DB
+}
+
+type TokenRepository struct {
+	DB *db.DB
+}
+
+type APITokenRepository struct {
+	DB *db.DB
+}
+
+type AttendanceRepository struct {
+	DB *db.DB
+}
+
+type AuditRepository struct {
+	DB *db.DB
+}
+
+type WebhookRepository struct {
+	DB *db.DB
 }
@@ -4,91 +4,135 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
-	"sort"
+	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/legendary-acp/chimecast/internal/db"
 	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
 )
 
-func NewRoomRepository(db *sql.DB) *RoomRepository {
+func NewRoomRepository(database *db.DB) *RoomRepository {
 	return &RoomRepository{
-		DB: db,
+		DB: database,
 	}
 }
 
-func (r *RoomRepository) GetAllRooms() ([]models.Room, error) {
-	var rooms []models.Room
-	rows, err := r.DB.Query(`
-        SELECT ID, Name, HostID, CreatedAt, Status 
-        FROM rooms
-    `)
+const roomColumns = "ID, Name, HostID, CreatedAt, Status, ScreenSharePolicy, Visibility"
+
+func scanRoom(row rowScanner, room *models.Room) error {
+	return row.Scan(&room.ID, &room.Name, &room.HostID, &room.CreatedAt, &room.Status, &room.ScreenSharePolicy, &room.Visibility)
+}
+
+// ListRooms returns a page of rooms matching the query, active first, then
+// newest first
+func (r *RoomRepository) ListRooms(query models.RoomQuery) ([]models.Room, error) {
+	var conditions []string
+	var args []interface{}
+	filter := func(condition string, conditionArgs ...interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	if query.Status != 0 {
+		filter("Status = ?", query.Status)
+	}
+	if query.HostID != "" {
+		filter("HostID = ?", query.HostID)
+	}
+	if query.ViewerID != "" {
+		filter("(Visibility = ? OR HostID = ? OR (Visibility = ? AND ID IN (SELECT RoomID FROM room_invitations WHERE UserID = ?)))",
+			models.RoomVisibilityPublic, query.ViewerID, models.RoomVisibilityPrivate, query.ViewerID)
+	}
+	if !query.Since.IsZero() {
+		filter("CreatedAt >= ?", query.Since.UTC())
+	}
+	if !query.Until.IsZero() {
+		filter("CreatedAt < ?", query.Until.UTC())
+	}
+	if terms := strings.Fields(query.Search); len(terms) > 0 {
+		switch {
+		case r.DB.Driver == db.Postgres:
+			// Served by the trigram index on Name
+			for _, term := range terms {
+				filter(`Name ILIKE ? ESCAPE '\'`, "%"+escapeLike(term)+"%")
+			}
+		case r.DB.RoomSearch:
+			filter("ID IN (SELECT RoomID FROM rooms_fts WHERE rooms_fts MATCH ?)", ftsPrefixQuery(terms))
+		default:
+			for _, term := range terms {
+				filter(`LOWER(Name) LIKE ? ESCAPE '\'`, "%"+escapeLike(strings.ToLower(term))+"%")
+			}
+		}
+	}
+	if after := query.After; after != nil {
+		createdAt := after.CreatedAt.UTC()
+		filter("(Status > ? OR (Status = ? AND (CreatedAt < ? OR (CreatedAt = ? AND ID < ?))))",
+			after.Status, after.Status, createdAt, createdAt, after.ID)
+	}
+
+	statement := "SELECT " + roomColumns + " FROM rooms"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY Status, CreatedAt DESC, ID DESC LIMIT ?"
+	args = append(args, query.Limit)
+
+	rows, err := r.DB.Query(statement, args...)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("database error: %v", err)
 	}
 	defer rows.Close()
 
+	rooms := []models.Room{}
 	for rows.Next() {
 		var room models.Room
-		if err := rows.Scan(
-			&room.ID,
-			&room.Name,
-			&room.HostID,
-			&room.CreatedAt,
-			&room.Status,
-		); err != nil {
+		if err := scanRoom(rows, &room); err != nil {
 			return nil, err
 		}
 		rooms = append(rooms, room)
 	}
+	return rooms, rows.Err()
+}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+// ftsPrefixQuery matches names with a word starting with each of the terms.
+// Terms are quoted so FTS5 operators in them are taken literally.
+func ftsPrefixQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
 	}
-
-	// Sort active rooms first
-	sort.Slice(rooms, func(i, j int) bool {
-		return rooms[i].Status == models.RoomStatusActive && rooms[j].Status != models.RoomStatusActive
-	})
-
-	return rooms, nil
+	return strings.Join(quoted, " ")
 }
 
 func (r *RoomRepository) CreateRoom(room *models.Room) error {
 	_, err := r.DB.Exec(`
-        INSERT INTO rooms (ID, Name, HostID, CreatedAt, Status) 
-        VALUES (?, ?, ?, ?, ?)`,
+        INSERT INTO rooms (ID, Name, HostID, CreatedAt, Status, ScreenSharePolicy, Visibility) 
+        VALUES (?, ?, ?, ?, ?, ?, ?)`,
 		room.ID,
 		room.Name,
 		room.HostID,
-		room.CreatedAt,
+		room.CreatedAt.UTC(),
 		room.Status,
+		room.ScreenSharePolicy,
+		room.Visibility,
 	)
 	if err != nil {
-		log.Printf("Error creating room: %v", err)
+		slog.Error("Error creating room", "error", err)
 		return fmt.Errorf("failed to create room: %v", err)
 	}
 
-	log.Printf("Room created: %s (ID: %s)", room.Name, room.ID)
+	slog.Info("Room created", "room_id", room.ID, "name", room.Name)
 	return nil
 }
 
 func (r *RoomRepository) GetRoom(roomID string) (*models.Room, error) {
 	var room models.Room
-	err := r.DB.QueryRow(`
-       	SELECT ID, Name, HostID, CreatedAt, Status 
-        FROM rooms 
-        WHERE id = ?`,
-		roomID,
-	).Scan(
-		&room.ID,
-		&room.Name,
-		&room.HostID,
-		&room.CreatedAt,
-		&room.Status,
-	)
+	err := scanRoom(r.DB.QueryRow("SELECT "+roomColumns+" FROM rooms WHERE id = ?", roomID), &room)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("room not found")
+		return nil, utils.ErrRoomNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("database error: %v", err)
@@ -138,6 +182,42 @@ func (r *RoomRepository) UpdateRoomStatus(roomID string, status int) error {
 	return nil
 }
 
+func (r *RoomRepository) UpdateScreenSharePolicy(roomID string, policy string) error {
+	result, err := r.DB.Exec(`
+        UPDATE rooms 
+        SET ScreenSharePolicy = ? 
+        WHERE id = ?`,
+		policy,
+		roomID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update screen share policy: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking update result: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("room not found")
+	}
+
+	return nil
+}
+
+// UpdateVisibility changes who may see and join a room
+func (r *RoomRepository) UpdateVisibility(roomID string, visibility string) error {
+	result, err := r.DB.Exec("UPDATE rooms SET Visibility = ? WHERE ID = ?", visibility, roomID)
+	if err != nil {
+		return fmt.Errorf("failed to update room visibility: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return utils.ErrRoomNotFound
+	}
+	return err
+}
+
 func (r *RoomRepository) DeleteRoom(roomID string) error {
 	result, err := r.DB.Exec(`
         DELETE FROM rooms 
@@ -159,3 +239,113 @@ func (r *RoomRepository) DeleteRoom(roomID string) error {
 
 	return nil
 }
+
+// GetRoomsByHost returns every room the user has hosted, newest first
+func (r *RoomRepository) GetRoomsByHost(hostID string) ([]models.Room, error) {
+	rows, err := r.DB.Query(`
+        SELECT `+roomColumns+`
+        FROM rooms
+        WHERE HostID = ?
+        ORDER BY CreatedAt DESC`,
+		hostID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	rooms := []models.Room{}
+	for rows.Next() {
+		var room models.Room
+		if err := scanRoom(rows, &room); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
+
+func (r *RoomRepository) UpdateRoomHost(roomID string, hostID string) error {
+	result, err := r.DB.Exec(`
+        UPDATE rooms
+        SET HostID = ?
+        WHERE id = ?`,
+		hostID,
+		roomID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update room host: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking update result: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("room not found")
+	}
+
+	return nil
+}
+
+// InactiveRooms returns ended rooms created before the cutoff that haven't
+// hosted a meeting since, oldest first
+func (r *RoomRepository) InactiveRooms(before time.Time) ([]models.Room, error) {
+	rows, err := r.DB.Query(`
+        SELECT `+roomColumns+`
+        FROM rooms
+        WHERE Status = ? AND CreatedAt < ?
+          AND NOT EXISTS (
+              SELECT 1 FROM meetings m
+              WHERE m.RoomID = rooms.ID AND (m.StartedAt >= ? OR m.EndedAt IS NULL)
+          )
+        ORDER BY CreatedAt`,
+		models.RoomStatusInactive,
+		before.UTC(),
+		before.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	rooms := []models.Room{}
+	for rows.Next() {
+		var room models.Room
+		if err := scanRoom(rows, &room); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
+
+// PurgeRooms deletes rooms along with their meetings, attendance and webhooks
+func (r *RoomRepository) PurgeRooms(roomIDs []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, roomID := range roomIDs {
+		for _, statement := range []string{
+			`DELETE FROM webhook_deliveries WHERE WebhookID IN (SELECT ID FROM webhooks WHERE RoomID = ?)`,
+			`DELETE FROM webhooks WHERE RoomID = ?`,
+			`DELETE FROM room_invitations WHERE RoomID = ?`,
+			`DELETE FROM attendance WHERE RoomID = ?`,
+			`DELETE FROM meetings WHERE RoomID = ?`,
+			`DELETE FROM rooms WHERE ID = ?`,
+		} {
+			if _, err := tx.Exec(statement, roomID); err != nil {
+				return fmt.Errorf("failed to purge room: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package repositories
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func TestListRooms(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		rooms := NewRoomRepository(database)
+		createTestUser(t, database, "alice")
+		createTestUser(t, database, "bob")
+		createTestUser(t, database, "carol")
+
+		// Newest first within each status: daily, retro, standup, then the
+		// ended planning room
+		createTestRoom(t, database, "standup", "id-alice", func(room *models.Room) {
+			room.Name = "Team standup"
+		})
+		createTestRoom(t, database, "retro", "id-alice", func(room *models.Room) {
+			room.Name = "100% retro"
+			room.CreatedAt = testTime.Add(time.Hour)
+			room.Visibility = models.RoomVisibilityPrivate
+		})
+		createTestRoom(t, database, "daily", "id-bob", func(room *models.Room) {
+			room.Name = "Daily standup"
+			room.CreatedAt = testTime.Add(2 * time.Hour)
+		})
+		createTestRoom(t, database, "planning", "id-bob", func(room *models.Room) {
+			room.Name = "Planning"
+			room.CreatedAt = testTime.Add(3 * time.Hour)
+			room.Status = models.RoomStatusInactive
+		})
+		if err := rooms.Invite(models.RoomInvitation{RoomID: "retro", UserID: "id-bob", InvitedAt: testTime}); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name  string
+			query models.RoomQuery
+			want  []string
+		}{
+			{name: "everything", query: models.RoomQuery{Limit: 10}, want: []string{"daily", "retro", "standup", "planning"}},
+			{name: "active", query: models.RoomQuery{Status: models.RoomStatusActive, Limit: 10}, want: []string{"daily", "retro", "standup"}},
+			{name: "ended", query: models.RoomQuery{Status: models.RoomStatusInactive, Limit: 10}, want: []string{"planning"}},
+			{name: "by host", query: models.RoomQuery{HostID: "id-bob", Limit: 10}, want: []string{"daily", "planning"}},
+			{name: "since", query: models.RoomQuery{Since: testTime.Add(time.Hour), Limit: 10}, want: []string{"daily", "retro", "planning"}},
+			{name: "until", query: models.RoomQuery{Until: testTime.Add(time.Hour), Limit: 10}, want: []string{"standup"}},
+			{name: "search", query: models.RoomQuery{Search: "standup", Limit: 10}, want: []string{"daily", "standup"}},
+			{name: "search ignores case", query: models.RoomQuery{Search: "STAND", Limit: 10}, want: []string{"daily", "standup"}},
+			{name: "search needs every term", query: models.RoomQuery{Search: "team stand", Limit: 10}, want: []string{"standup"}},
+			{name: "search takes percent literally", query: models.RoomQuery{Search: "100%", Limit: 10}, want: []string{"retro"}},
+			{name: "private hidden from others", query: models.RoomQuery{ViewerID: "id-carol", Limit: 10}, want: []string{"daily", "standup", "planning"}},
+			{name: "private shown to host", query: models.RoomQuery{ViewerID: "id-alice", Limit: 10}, want: []string{"daily", "retro", "standup", "planning"}},
+			{name: "private shown to invitee", query: models.RoomQuery{ViewerID: "id-bob", Limit: 10}, want: []string{"daily", "retro", "standup", "planning"}},
+			{name: "limit", query: models.RoomQuery{Limit: 2}, want: []string{"daily", "retro"}},
+			{
+				name:  "after a cursor",
+				query: models.RoomQuery{After: &models.RoomCursor{Status: models.RoomStatusActive, CreatedAt: testTime.Add(time.Hour), ID: "retro"}, Limit: 10},
+				want:  []string{"standup", "planning"},
+			},
+			{
+				name:  "after the last active room",
+				query: models.RoomQuery{After: &models.RoomCursor{Status: models.RoomStatusActive, CreatedAt: testTime, ID: "standup"}, Limit: 10},
+				want:  []string{"planning"},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				found, err := rooms.ListRooms(tt.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := []string{}
+				for _, room := range found {
+					got = append(got, room.ID)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("ListRooms() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+// TestListRoomsTies pages through rooms created at the same instant, which
+// only the ID tells apart
+func TestListRoomsTies(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		rooms := NewRoomRepository(database)
+		createTestUser(t, database, "alice")
+		for _, id := range []string{"a", "b", "c", "d", "e"} {
+			createTestRoom(t, database, id, "id-alice", nil)
+		}
+
+		var got []string
+		query := models.RoomQuery{Limit: 2}
+		for {
+			page, err := rooms.ListRooms(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, room := range page {
+				got = append(got, room.ID)
+			}
+			if len(page) < query.Limit {
+				break
+			}
+			last := page[len(page)-1]
+			query.After = &models.RoomCursor{Status: last.Status, CreatedAt: last.CreatedAt, ID: last.ID}
+		}
+		if want := []string{"e", "d", "c", "b", "a"}; !slices.Equal(got, want) {
+			t.Errorf("pages = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestGetRoom(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		rooms := NewRoomRepository(database)
+		createTestUser(t, database, "alice")
+		want := createTestRoom(t, database, "standup", "id-alice", func(room *models.Room) {
+			room.ScreenSharePolicy = models.ScreenSharePolicyHostOnly
+			room.Visibility = models.RoomVisibilityPrivate
+		})
+
+		tests := []struct {
+			name    string
+			roomID  string
+			wantErr error
+		}{
+			{name: "existing", roomID: "standup"},
+			{name: "unknown", roomID: "missing", wantErr: utils.ErrRoomNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				room, err := rooms.GetRoom(tt.roomID)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetRoom() error = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr != nil {
+					return
+				}
+				if !room.CreatedAt.Equal(want.CreatedAt) {
+					t.Errorf("CreatedAt = %v, want %v", room.CreatedAt, want.CreatedAt)
+				}
+				room.CreatedAt = want.CreatedAt
+				if *room != want {
+					t.Errorf("GetRoom() = %+v, want %+v", *room, want)
+				}
+			})
+		}
+	})
+}
+
+func TestInvitations(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		rooms := NewRoomRepository(database)
+		createTestUser(t, database, "alice")
+		createTestUser(t, database, "bob")
+		createTestRoom(t, database, "retro", "id-alice", nil)
+
+		steps := []struct {
+			name        string
+			apply       func() error
+			wantErr     error
+			wantInvited bool
+		}{
+			{
+				name: "invite",
+				apply: func() error {
+					return rooms.Invite(models.RoomInvitation{RoomID: "retro", UserID: "id-bob", InvitedAt: testTime})
+				},
+				wantInvited: true,
+			},
+			{
+				name: "invite again",
+				apply: func() error {
+					return rooms.Invite(models.RoomInvitation{RoomID: "retro", UserID: "id-bob", InvitedAt: testTime.Add(time.Hour)})
+				},
+				wantInvited: true,
+			},
+			{
+				name:  "uninvite",
+				apply: func() error { return rooms.Uninvite("retro", "id-bob") },
+			},
+			{
+				name:    "uninvite again",
+				apply:   func() error { return rooms.Uninvite("retro", "id-bob") },
+				wantErr: utils.ErrInvitationNotFound,
+			},
+		}
+		for _, step := range steps {
+			t.Run(step.name, func(t *testing.T) {
+				if err := step.apply(); !errors.Is(err, step.wantErr) {
+					t.Fatalf("error = %v, want %v", err, step.wantErr)
+				}
+				invited, err := rooms.IsInvited("retro", "id-bob")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if invited != step.wantInvited {
+					t.Errorf("IsInvited() = %v, want %v", invited, step.wantInvited)
+				}
+				invitations, err := rooms.ListInvitations("retro")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if step.wantInvited && (len(invitations) != 1 || !invitations[0].InvitedAt.Equal(testTime) || invitations[0].Username != "bob") {
+					t.Errorf("ListInvitations() = %+v, want bob's first invitation", invitations)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func NewTokenRepository(database *db.DB) *TokenRepository {
+	return &TokenRepository{
+		DB: database,
+	}
+}
+
+// CreateToken stores a new token and withdraws any earlier unused ones the
+// user holds for the same purpose, so only the latest email works
+func (t *TokenRepository) CreateToken(token models.UserToken) error {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM user_tokens WHERE UserID = ? AND Purpose = ? AND UsedAt IS NULL", token.UserID, token.Purpose)
+	if err != nil {
+		return fmt.Errorf("failed to withdraw old tokens: %w", err)
+	}
+
+	_, err = tx.Exec("INSERT INTO user_tokens (TokenHash, UserID, Purpose, CreatedAt, ExpiresAt) VALUES (?, ?, ?, ?, ?)",
+		token.TokenHash, token.UserID, token.Purpose, token.CreatedAt.UTC(), token.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to create token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// TokenUser returns the user an unused, unexpired token belongs to without
+// redeeming it
+func (t *TokenRepository) TokenUser(tokenHash, purpose string, now time.Time) (string, error) {
+	var userID string
+	err := t.DB.QueryRow("SELECT UserID FROM user_tokens WHERE TokenHash = ? AND Purpose = ? AND UsedAt IS NULL AND ExpiresAt > ?",
+		tokenHash, purpose, now.UTC()).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", utils.ErrInvalidToken
+		}
+		return "", fmt.Errorf("failed to query token: %w", err)
+	}
+	return userID, nil
+}
+
+// ConsumeToken marks an unused, unexpired token as used and returns the user
+// it belongs to. Redeeming the same token twice fails.
+func (t *TokenRepository) ConsumeToken(tokenHash, purpose string, now time.Time) (string, error) {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRow("SELECT UserID FROM user_tokens WHERE TokenHash = ? AND Purpose = ? AND UsedAt IS NULL AND ExpiresAt > ?",
+		tokenHash, purpose, now.UTC()).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", utils.ErrInvalidToken
+		}
+		return "", fmt.Errorf("failed to query token: %w", err)
+	}
+
+	result, err := tx.Exec("UPDATE user_tokens SET UsedAt = ? WHERE TokenHash = ? AND UsedAt IS NULL", now.UTC(), tokenHash)
+	if err != nil {
+		return "", fmt.Errorf("failed to consume token: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows != 1 {
+		return "", utils.ErrInvalidToken
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return userID, nil
+}
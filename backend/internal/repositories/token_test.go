@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func TestConsumeToken(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		tokens := NewTokenRepository(database)
+		createTestUser(t, database, "alice")
+		expiresAt := testTime.Add(time.Hour)
+		for _, hash := range []string{"old", "current"} {
+			err := tokens.CreateToken(models.UserToken{TokenHash: hash, UserID: "id-alice", Purpose: models.TokenPurposeResetPassword, CreatedAt: testTime, ExpiresAt: expiresAt})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Run in order: the first redemption uses the token up
+		steps := []struct {
+			name    string
+			hash    string
+			purpose string
+			now     time.Time
+			want    error
+		}{
+			{name: "withdrawn by a newer token", hash: "old", purpose: models.TokenPurposeResetPassword, now: testTime, want: utils.ErrInvalidToken},
+			{name: "wrong purpose", hash: "current", purpose: models.TokenPurposeVerifyEmail, now: testTime, want: utils.ErrInvalidToken},
+			{name: "expired", hash: "current", purpose: models.TokenPurposeResetPassword, now: expiresAt, want: utils.ErrInvalidToken},
+			{name: "valid", hash: "current", purpose: models.TokenPurposeResetPassword, now: testTime},
+			{name: "already used", hash: "current", purpose: models.TokenPurposeResetPassword, now: testTime, want: utils.ErrInvalidToken},
+		}
+		for _, step := range steps {
+			t.Run(step.name, func(t *testing.T) {
+				userID, err := tokens.ConsumeToken(step.hash, step.purpose, step.now)
+				if !errors.Is(err, step.want) {
+					t.Fatalf("ConsumeToken() error = %v, want %v", err, step.want)
+				}
+				if step.want == nil && userID != "id-alice" {
+					t.Errorf("ConsumeToken() = %q, want id-alice", userID)
+				}
+			})
+		}
+	})
+}
+
+func TestAPITokens(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		apiTokens := NewAPITokenRepository(database)
+		createTestUser(t, database, "alice")
+		createTestUser(t, database, "bob")
+		expiresAt := testTime.Add(time.Hour)
+		for _, token := range []models.APIToken{
+			{ID: "t1", UserID: "id-alice", Name: "bot", TokenHash: "hash1", Scopes: []string{models.ScopeRoomsRead, models.ScopeRoomsWrite}, CreatedAt: testTime, ExpiresAt: expiresAt},
+			{ID: "t2", UserID: "id-alice", Name: "ci", TokenHash: "hash2", Scopes: []string{models.ScopeRoomsRead}, CreatedAt: testTime.Add(time.Minute), ExpiresAt: expiresAt},
+			{ID: "t3", UserID: "id-bob", Name: "bot", TokenHash: "hash3", Scopes: []string{models.ScopeRoomsRead}, CreatedAt: testTime, ExpiresAt: expiresAt},
+		} {
+			if err := apiTokens.CreateAPIToken(token); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		lookups := []struct {
+			name       string
+			hash       string
+			now        time.Time
+			wantID     string
+			wantScopes []string
+			wantErr    error
+		}{
+			{name: "valid", hash: "hash1", now: testTime, wantID: "t1", wantScopes: []string{models.ScopeRoomsRead, models.ScopeRoomsWrite}},
+			{name: "expired", hash: "hash1", now: expiresAt, wantErr: utils.ErrInvalidToken},
+			{name: "unknown", hash: "missing", now: testTime, wantErr: utils.ErrInvalidToken},
+		}
+		for _, tt := range lookups {
+			t.Run(tt.name, func(t *testing.T) {
+				token, err := apiTokens.GetAPITokenByHash(tt.hash, tt.now)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetAPITokenByHash() error = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr != nil {
+					return
+				}
+				if token.ID != tt.wantID || token.Username != "alice" || !slices.Equal(token.Scopes, tt.wantScopes) {
+					t.Errorf("GetAPITokenByHash() = %+v", token)
+				}
+			})
+		}
+
+		t.Run("touch", func(t *testing.T) {
+			used := testTime.Add(10 * time.Minute)
+			for _, now := range []time.Time{used, used.Add(time.Second)} {
+				if err := apiTokens.TouchAPIToken("t1", now, time.Minute); err != nil {
+					t.Fatal(err)
+				}
+			}
+			token, err := apiTokens.GetAPITokenByHash("hash1", testTime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameTime(token.LastUsedAt, &used) {
+				t.Errorf("LastUsedAt = %v, want %v", token.LastUsedAt, used)
+			}
+		})
+
+		t.Run("delete all of a user's tokens", func(t *testing.T) {
+			deleted, err := apiTokens.DeleteUserAPITokens("id-alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.Sort(deleted)
+			if want := []string{"t1", "t2"}; !slices.Equal(deleted, want) {
+				t.Errorf("DeleteUserAPITokens() = %v, want %v", deleted, want)
+			}
+			if left, err := apiTokens.ListAPITokens("id-bob"); err != nil || len(left) != 1 {
+				t.Errorf("bob's tokens = %v, %v; want one left", left, err)
+			}
+			if err := apiTokens.DeleteAPIToken("id-alice", "t1"); !errors.Is(err, utils.ErrAPITokenNotFound) {
+				t.Errorf("DeleteAPIToken() error = %v, want %v", err, utils.ErrAPITokenNotFound)
+			}
+		})
+	})
+}
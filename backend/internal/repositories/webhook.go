@@ -0,0 +1,226 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func NewWebhookRepository(database *db.DB) *WebhookRepository {
+	return &WebhookRepository{
+		DB: database,
+	}
+}
+
+const webhookColumns = "ID, UserID, RoomID, URL, Secret, Events, CreatedAt"
+
+func scanWebhook(row rowScanner, webhook *models.Webhook) error {
+	var events string
+	if err := row.Scan(&webhook.ID, &webhook.UserID, &webhook.RoomID, &webhook.URL, &webhook.Secret, &events, &webhook.CreatedAt); err != nil {
+		return err
+	}
+	webhook.Events = strings.Fields(events)
+	return nil
+}
+
+func (w *WebhookRepository) CreateWebhook(webhook models.Webhook) error {
+	_, err := w.DB.Exec("INSERT INTO webhooks (ID, UserID, RoomID, URL, Secret, Events, CreatedAt) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		webhook.ID, webhook.UserID, webhook.RoomID, webhook.URL, webhook.Secret, strings.Join(webhook.Events, " "), webhook.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return nil
+}
+
+// GetWebhook returns a webhook whoever owns it
+func (w *WebhookRepository) GetWebhook(webhookID string) (*models.Webhook, error) {
+	var webhook models.Webhook
+	if err := scanWebhook(w.DB.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE ID = ?", webhookID), &webhook); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("failed to query webhook: %w", err)
+	}
+	return &webhook, nil
+}
+
+// ListWebhooks returns a user's webhooks, newest first
+func (w *WebhookRepository) ListWebhooks(userID string) ([]models.Webhook, error) {
+	return w.webhooks("SELECT "+webhookColumns+" FROM webhooks WHERE UserID = ? ORDER BY CreatedAt DESC", userID)
+}
+
+// RoomWebhooks returns the webhooks that hear a room: those registered for
+// it, and those its current host registered for all their rooms
+func (w *WebhookRepository) RoomWebhooks(roomID string) ([]models.Webhook, error) {
+	return w.webhooks(`SELECT `+webhookColumns+` FROM webhooks
+        WHERE RoomID = ? OR (RoomID = '' AND UserID = (SELECT HostID FROM rooms WHERE ID = ?))`, roomID, roomID)
+}
+
+func (w *WebhookRepository) webhooks(query string, args ...interface{}) ([]models.Webhook, error) {
+	rows, err := w.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		var webhook models.Webhook
+		if err := scanWebhook(rows, &webhook); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook removes one of a user's webhooks along with its delivery log
+func (w *WebhookRepository) DeleteWebhook(userID, webhookID string) error {
+	tx, err := w.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM webhook_deliveries WHERE WebhookID IN (SELECT ID FROM webhooks WHERE ID = ? AND UserID = ?)", webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+	result, err := tx.Exec("DELETE FROM webhooks WHERE ID = ? AND UserID = ?", webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return utils.ErrWebhookNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+const deliveryColumns = "ID, WebhookID, EventID, Event, Payload, Status, Attempts, ResponseStatus, LastError, CreatedAt, NextAttemptAt, DeliveredAt"
+
+func scanDelivery(row rowScanner, delivery *models.WebhookDelivery) error {
+	var nextAttemptAt, deliveredAt sql.NullTime
+	err := row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.Event, &delivery.Payload, &delivery.Status,
+		&delivery.Attempts, &delivery.ResponseStatus, &delivery.LastError, &delivery.CreatedAt, &nextAttemptAt, &deliveredAt)
+	if err != nil {
+		return err
+	}
+	if nextAttemptAt.Valid {
+		delivery.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return nil
+}
+
+// CreateDeliveries queues deliveries of one event to several webhooks
+func (w *WebhookRepository) CreateDeliveries(deliveries []models.WebhookDelivery) error {
+	tx, err := w.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, d := range deliveries {
+		_, err := tx.Exec(`INSERT INTO webhook_deliveries (ID, WebhookID, EventID, Event, Payload, Status, CreatedAt, NextAttemptAt)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			d.ID, d.WebhookID, d.EventID, d.Event, d.Payload, d.Status, d.CreatedAt.UTC(), d.NextAttemptAt.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to queue webhook delivery: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetDelivery returns one of a webhook's deliveries
+func (w *WebhookRepository) GetDelivery(webhookID, deliveryID string) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	row := w.DB.QueryRow("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE ID = ? AND WebhookID = ?", deliveryID, webhookID)
+	if err := scanDelivery(row, &delivery); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrDeliveryNotFound
+		}
+		return nil, fmt.Errorf("failed to query webhook delivery: %w", err)
+	}
+	return &delivery, nil
+}
+
+// ListDeliveries returns a webhook's most recent deliveries, newest first
+func (w *WebhookRepository) ListDeliveries(webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	return w.deliveries("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE WebhookID = ? ORDER BY CreatedAt DESC LIMIT ?", webhookID, limit)
+}
+
+// DueDeliveries returns pending deliveries whose next attempt is due,
+// oldest first
+func (w *WebhookRepository) DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	return w.deliveries("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE Status = ? AND NextAttemptAt <= ? ORDER BY NextAttemptAt LIMIT ?",
+		models.DeliveryPending, now.UTC(), limit)
+}
+
+func (w *WebhookRepository) deliveries(query string, args ...interface{}) ([]models.WebhookDelivery, error) {
+	rows, err := w.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := scanDelivery(rows, &delivery); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// ClaimDelivery pushes a due delivery's next attempt out to leaseUntil so no
+// other instance picks it up while this one sends it. It reports false if
+// another instance got there first.
+func (w *WebhookRepository) ClaimDelivery(deliveryID string, now, leaseUntil time.Time) (bool, error) {
+	result, err := w.DB.Exec("UPDATE webhook_deliveries SET NextAttemptAt = ? WHERE ID = ? AND Status = ? AND NextAttemptAt <= ?",
+		leaseUntil.UTC(), deliveryID, models.DeliveryPending, now.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// RecordDeliveryAttempt stores the outcome of an attempt
+func (w *WebhookRepository) RecordDeliveryAttempt(delivery models.WebhookDelivery) error {
+	var nextAttemptAt, deliveredAt interface{}
+	if delivery.NextAttemptAt != nil {
+		nextAttemptAt = delivery.NextAttemptAt.UTC()
+	}
+	if delivery.DeliveredAt != nil {
+		deliveredAt = delivery.DeliveredAt.UTC()
+	}
+
+	_, err := w.DB.Exec(`UPDATE webhook_deliveries SET Status = ?, Attempts = ?, ResponseStatus = ?, LastError = ?, NextAttemptAt = ?, DeliveredAt = ?
+        WHERE ID = ?`,
+		delivery.Status, delivery.Attempts, delivery.ResponseStatus, delivery.LastError, nextAttemptAt, deliveredAt, delivery.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	return nil
+}
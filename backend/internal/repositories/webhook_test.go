@@ -0,0 +1,160 @@
+package repositories
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func TestRoomWebhooks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		webhooks := NewWebhookRepository(database)
+		createTestUser(t, database, "alice")
+		createTestUser(t, database, "bob")
+		createTestRoom(t, database, "standup", "id-alice", nil)
+		createTestRoom(t, database, "retro", "id-bob", nil)
+		for _, webhook := range []models.Webhook{
+			{ID: "all-of-alices", UserID: "id-alice", URL: "https://example.com/a", Secret: "s", Events: []string{models.EventRoomCreated, models.EventRoomEnded}, CreatedAt: testTime},
+			{ID: "standup-only", UserID: "id-bob", RoomID: "standup", URL: "https://example.com/b", Secret: "s", Events: []string{models.EventParticipantJoined}, CreatedAt: testTime},
+			{ID: "all-of-bobs", UserID: "id-bob", URL: "https://example.com/c", Secret: "s", Events: []string{models.EventRoomEnded}, CreatedAt: testTime.Add(time.Minute)},
+		} {
+			if err := webhooks.CreateWebhook(webhook); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tests := []struct {
+			name   string
+			roomID string
+			want   []string
+		}{
+			{name: "host's and the room's own", roomID: "standup", want: []string{"all-of-alices", "standup-only"}},
+			{name: "host's only", roomID: "retro", want: []string{"all-of-bobs"}},
+			{name: "unknown room", roomID: "missing", want: []string{}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				found, err := webhooks.RoomWebhooks(tt.roomID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := []string{}
+				for _, webhook := range found {
+					got = append(got, webhook.ID)
+				}
+				slices.Sort(got)
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("RoomWebhooks() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+
+		webhook, err := webhooks.GetWebhook("all-of-alices")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(webhook.Events, []string{models.EventRoomCreated, models.EventRoomEnded}) || webhook.RoomID != "" {
+			t.Errorf("GetWebhook() = %+v", webhook)
+		}
+		if err := webhooks.DeleteWebhook("id-bob", "all-of-alices"); !errors.Is(err, utils.ErrWebhookNotFound) {
+			t.Errorf("DeleteWebhook() by someone else error = %v, want %v", err, utils.ErrWebhookNotFound)
+		}
+	})
+}
+
+// TestWebhookDeliveries walks a delivery through the retry queue: due,
+// claimed by one instance, retried, then delivered
+func TestWebhookDeliveries(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, database *db.DB) {
+		webhooks := NewWebhookRepository(database)
+		createTestUser(t, database, "alice")
+		err := webhooks.CreateWebhook(models.Webhook{ID: "hook", UserID: "id-alice", URL: "https://example.com", Secret: "s", Events: []string{models.EventRoomEnded}, CreatedAt: testTime})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := webhooks.CreateDeliveries([]models.WebhookDelivery{
+			{ID: "d1", WebhookID: "hook", EventID: "e1", Event: models.EventRoomEnded, Payload: "{}", Status: models.DeliveryPending, CreatedAt: testTime, NextAttemptAt: &testTime},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		retryAt := testTime.Add(time.Minute)
+		deliveredAt := testTime.Add(2 * time.Minute)
+		steps := []struct {
+			name       string
+			now        time.Time
+			apply      func(now time.Time) (bool, error) // nil to only check what is due
+			wantResult bool
+			wantDue    bool // whether d1 is due at now after the step
+		}{
+			{
+				name:    "queued",
+				now:     testTime,
+				wantDue: true,
+			},
+			{
+				name:       "claimed",
+				now:        testTime,
+				apply:      func(now time.Time) (bool, error) { return webhooks.ClaimDelivery("d1", now, now.Add(time.Hour)) },
+				wantResult: true,
+			},
+			{
+				name:  "claimed again by another instance",
+				now:   testTime,
+				apply: func(now time.Time) (bool, error) { return webhooks.ClaimDelivery("d1", now, now.Add(time.Hour)) },
+			},
+			{
+				name: "attempt failed, retry due",
+				now:  retryAt,
+				apply: func(time.Time) (bool, error) {
+					return true, webhooks.RecordDeliveryAttempt(models.WebhookDelivery{ID: "d1", Status: models.DeliveryPending, Attempts: 1, ResponseStatus: 500, LastError: "server error", NextAttemptAt: &retryAt})
+				},
+				wantResult: true, wantDue: true,
+			},
+			{
+				name: "delivered",
+				now:  deliveredAt,
+				apply: func(time.Time) (bool, error) {
+					return true, webhooks.RecordDeliveryAttempt(models.WebhookDelivery{ID: "d1", Status: models.DeliverySucceeded, Attempts: 2, ResponseStatus: 204, DeliveredAt: &deliveredAt})
+				},
+				wantResult: true,
+			},
+		}
+		for _, step := range steps {
+			t.Run(step.name, func(t *testing.T) {
+				if step.apply != nil {
+					result, err := step.apply(step.now)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if result != step.wantResult {
+						t.Errorf("result = %v, want %v", result, step.wantResult)
+					}
+				}
+				due, err := webhooks.DueDeliveries(step.now, 10)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if gotDue := len(due) == 1 && due[0].ID == "d1"; gotDue != step.wantDue || len(due) > 1 {
+					t.Errorf("DueDeliveries() = %+v, want d1 due: %v", due, step.wantDue)
+				}
+			})
+		}
+
+		delivery, err := webhooks.GetDelivery("hook", "d1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delivery.Status != models.DeliverySucceeded || delivery.Attempts != 2 || delivery.NextAttemptAt != nil || !sameTime(delivery.DeliveredAt, &deliveredAt) {
+			t.Errorf("GetDelivery() = %+v", delivery)
+		}
+		if _, err := webhooks.GetDelivery("other", "d1"); !errors.Is(err, utils.ErrDeliveryNotFound) {
+			t.Errorf("GetDelivery() of another webhook error = %v, want %v", err, utils.ErrDeliveryNotFound)
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/mailer"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	verifyEmailTokenTTL   = 48 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+)
+
+// ResendVerificationEmail sends a fresh verification link. Unknown and already
+// verified addresses are silently ignored so the endpoint can't be used to
+// probe for accounts.
+func (a *AuthService) ResendVerificationEmail(email string) error {
+	user, err := a.AuthRepository.GetUserByEmail(email)
+	if errors.Is(err, utils.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+	return a.sendVerificationEmail(user)
+}
+
+// VerifyEmail redeems a verification token
+func (a *AuthService) VerifyEmail(token string) error {
+	userID, err := a.TokenRepository.ConsumeToken(utils.HashToken(token), models.TokenPurposeVerifyEmail, time.Now())
+	if err != nil {
+		return err
+	}
+	return a.AuthRepository.SetEmailVerified(userID)
+}
+
+// ForgotPassword emails a password reset link. Like ResendVerificationEmail it
+// succeeds whether or not the address belongs to anyone.
+func (a *AuthService) ForgotPassword(email string) error {
+	user, err := a.AuthRepository.GetUserByEmail(email)
+	if errors.Is(err, utils.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := a.issueToken(user.ID, models.TokenPurposeResetPassword, resetPasswordTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	a.sendEmail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your ChimeCast password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your ChimeCast account. "+
+			"If it was you, choose a new password here within the next hour:\n\n%s\n\n"+
+			"If it wasn't, you can ignore this email.\n",
+			user.Name, a.link("/reset-password", token)),
+	})
+	return nil
+}
+
+// ResetPassword redeems a reset token, sets the new password, signs the user
+// out everywhere and revokes their API tokens. Receiving the email also
+// proves the address.
+func (a *AuthService) ResetPassword(request *models.ResetPasswordRequest) error {
+	if request.Password == "" {
+		return errors.New("password cannot be empty")
+	}
+
+	userID, err := a.TokenRepository.ConsumeToken(utils.HashToken(request.Token), models.TokenPurposeResetPassword, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := a.AuthRepository.UpdatePassword(userID, string(hashedPassword)); err != nil {
+		return err
+	}
+	if err := a.AuthRepository.SetEmailVerified(userID); err != nil {
+		return err
+	}
+
+	a.SessionManager.DeleteUserSessions(userID)
+	if err := a.revokeUserAPITokens(userID); err != nil {
+		return err
+	}
+	slog.Info("Password reset", "user_id", userID)
+	return nil
+}
+
+// IsEmailVerified reports whether a user has confirmed their email address
+func (a *AuthService) IsEmailVerified(userID string) (bool, error) {
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerified, nil
+}
+
+func (a *AuthService) sendVerificationEmail(user *models.User) error {
+	token, err := a.issueToken(user.ID, models.TokenPurposeVerifyEmail, verifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	a.sendEmail(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email for ChimeCast",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening this link:\n\n%s\n\n"+
+			"The link expires in 48 hours.\n",
+			user.Name, a.link("/verify-email", token)),
+	})
+	return nil
+}
+
+// issueToken creates a token for the user and stores its hash
+func (a *AuthService) issueToken(userID, purpose string, ttl time.Duration) (string, error) {
+	token, err := utils.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = a.TokenRepository.CreateToken(models.UserToken{
+		TokenHash: utils.HashToken(token),
+		UserID:    userID,
+		Purpose:   purpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// link builds a URL in the web app that carries a token
+func (a *AuthService) link(path, token string) string {
+	return a.PublicURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendEmail delivers in the background so that slow mail servers don't hold
+// up requests, and so response times don't reveal whether an address exists
+func (a *AuthService) sendEmail(msg mailer.Message) {
+	go func() {
+		if err := a.Mailer.Send(context.Background(), msg); err != nil {
+			slog.Error("Error sending email", "subject", msg.Subject, "error", err)
+		}
+	}()
+}
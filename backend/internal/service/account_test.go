@@ -0,0 +1,139 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/session"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// TestEmailTokens redeems verification and reset tokens one step after
+// another, each step seeing what the earlier ones left behind
+func TestEmailTokens(t *testing.T) {
+	purposes := []struct {
+		purpose string
+		redeem  func(a *AuthService, token string) error
+	}{
+		{
+			purpose: models.TokenPurposeVerifyEmail,
+			redeem:  func(a *AuthService, token string) error { return a.VerifyEmail(token) },
+		},
+		{
+			purpose: models.TokenPurposeResetPassword,
+			redeem: func(a *AuthService, token string) error {
+				return a.ResetPassword(&models.ResetPasswordRequest{Token: token, Password: "new-password"})
+			},
+		},
+	}
+	for _, p := range purposes {
+		t.Run(p.purpose, func(t *testing.T) {
+			a := newTestAuthService(t, newTestDB(t), LoginLimits{})
+			user := registerTestUser(t, a, "alice")
+			issue := func(ttl time.Duration) string {
+				token, err := a.issueToken(user.ID, p.purpose, ttl)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return token
+			}
+
+			first := issue(time.Hour)
+			second := issue(time.Hour)
+			expired := issue(-time.Minute)
+			latest := issue(time.Hour)
+
+			steps := []struct {
+				name    string
+				token   string
+				wantErr error
+			}{
+				{name: "withdrawn by a newer token", token: first, wantErr: utils.ErrInvalidToken},
+				{name: "withdrawn by an expired one too", token: second, wantErr: utils.ErrInvalidToken},
+				{name: "expired", token: expired, wantErr: utils.ErrInvalidToken},
+				{name: "unknown", token: "not-a-token", wantErr: utils.ErrInvalidToken},
+				{name: "latest", token: latest},
+				{name: "latest again", token: latest, wantErr: utils.ErrInvalidToken},
+			}
+			for _, step := range steps {
+				t.Run(step.name, func(t *testing.T) {
+					if err := p.redeem(a, step.token); !errors.Is(err, step.wantErr) {
+						t.Fatalf("error = %v, want %v", err, step.wantErr)
+					}
+				})
+			}
+
+			// Either way the address is proven
+			if verified, err := a.IsEmailVerified(user.ID); err != nil || !verified {
+				t.Errorf("IsEmailVerified() = %v, %v, want true", verified, err)
+			}
+		})
+	}
+}
+
+// TestTokenPurposes checks that a token only works for what it was issued for
+func TestTokenPurposes(t *testing.T) {
+	a := newTestAuthService(t, newTestDB(t), LoginLimits{})
+	user := registerTestUser(t, a, "alice")
+	verify, err := a.issueToken(user.ID, models.TokenPurposeVerifyEmail, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = a.ResetPassword(&models.ResetPasswordRequest{Token: verify, Password: "new-password"})
+	if !errors.Is(err, utils.ErrInvalidToken) {
+		t.Fatalf("ResetPassword() with a verification token error = %v, want ErrInvalidToken", err)
+	}
+	if err := a.VerifyEmail(verify); err != nil {
+		t.Fatalf("VerifyEmail() error = %v, want the token still unused", err)
+	}
+}
+
+// TestResetPassword checks what a successful reset changes for the user, and
+// that it leaves everyone else alone
+func TestResetPassword(t *testing.T) {
+	a := newTestAuthService(t, newTestDB(t), LoginLimits{})
+	user := registerTestUser(t, a, "alice")
+	other := registerTestUser(t, a, "bob")
+	// Signing up left each of them a session
+	for _, u := range []*models.User{user, other} {
+		request := &models.CreateAPITokenRequest{Name: "bot", Scopes: []string{models.ScopeRoomsRead}}
+		if _, _, err := a.CreateAPIToken(u.ID, request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	token, err := a.issueToken(user.ID, models.TokenPurposeResetPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ResetPassword(&models.ResetPasswordRequest{Token: token, Password: "new-password"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user         *models.User
+		wantSessions int
+		wantTokens   int
+		password     string
+	}{
+		{user: user, password: "new-password"},
+		{user: other, wantSessions: 1, wantTokens: 1, password: "password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user.Username, func(t *testing.T) {
+			if sessions := a.ListSessions(tt.user.ID); len(sessions) != tt.wantSessions {
+				t.Errorf("%d sessions, want %d", len(sessions), tt.wantSessions)
+			}
+			if tokens, _ := a.ListAPITokens(tt.user.ID); len(tokens) != tt.wantTokens {
+				t.Errorf("%d API tokens, want %d", len(tokens), tt.wantTokens)
+			}
+			request := &models.LoginRequest{UserName: tt.user.Username, Password: tt.password}
+			if _, err := a.Login(request, session.Client{IP: "192.0.2.1"}); err != nil {
+				t.Errorf("Login() with %q error = %v", tt.password, err)
+			}
+		})
+	}
+}
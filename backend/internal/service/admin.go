@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+const (
+	defaultUserPageSize   = 50
+	maxUserPageSize       = 500
+	maxAnnouncementLength = 500 // characters; keep utils.ErrInvalidAnnouncement in step
+)
+
+// IsAdmin reports whether a user may use the admin API
+func (a *AuthService) IsAdmin(userID string) (bool, error) {
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return false, err
+	}
+	return user.IsAdmin && user.DeletedAt == nil && user.DisabledAt == nil, nil
+}
+
+// SetAdmin grants or revokes a user's admin role
+func (a *AuthService) SetAdmin(ctx context.Context, userID string, isAdmin bool) error {
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.IsAdmin == isAdmin {
+		return nil
+	}
+	if err := a.AuthRepository.SetAdmin(userID, isAdmin); err != nil {
+		return err
+	}
+
+	a.Audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditUserAdminRoleChanged,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+		Details:    map[string]string{"username": user.Username, "admin": strconv.FormatBool(isAdmin)},
+	})
+	slog.InfoContext(ctx, "Admin role changed", "user_id", userID, "admin", isAdmin)
+	return nil
+}
+
+// GrantAdmins gives the admin role to the named users, so a new instance can
+// have its first administrator. Names that don't exist yet are skipped.
+func (a *AuthService) GrantAdmins(ctx context.Context, usernames []string) error {
+	for _, username := range usernames {
+		user, err := a.AuthRepository.GetUserByUsername(username)
+		if errors.Is(err, utils.ErrUserNotFound) {
+			slog.WarnContext(ctx, "Admin user not found", "username", username)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := a.SetAdmin(ctx, user.ID, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewAdminService(authService *AuthService, roomService *RoomService) *AdminService {
+	return &AdminService{
+		AuthService: authService,
+		RoomService: roomService,
+	}
+}
+
+// ListUsers searches accounts that haven't been erased, a page at a time
+func (s *AdminService) ListUsers(query models.UserQuery) (*models.UserPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = defaultUserPageSize
+	}
+	query.Limit = min(query.Limit, maxUserPageSize)
+
+	// One extra row tells us whether there is another page
+	limit := query.Limit
+	query.Limit++
+	users, err := s.AuthService.AuthRepository.SearchUsers(query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.UserPage{Users: []models.AdminUser{}}
+	for _, user := range users[:min(len(users), limit)] {
+		page.Users = append(page.Users, adminUserOf(&user))
+	}
+	if len(users) > limit {
+		page.NextCursor = users[limit-1].Username
+	}
+	return page, nil
+}
+
+// GetUser returns one account
+func (s *AdminService) GetUser(userID string) (*models.AdminUser, error) {
+	user, err := s.AuthService.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, utils.ErrUserNotFound
+	}
+	adminUser := adminUserOf(user)
+	return &adminUser, nil
+}
+
+func adminUserOf(user *models.User) models.AdminUser {
+	return models.AdminUser{
+		ID:            user.ID,
+		Username:      user.Username,
+		Name:          user.Name,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		MFAEnabled:    user.MFAEnabled,
+		IsAdmin:       user.IsAdmin,
+		Disabled:      user.DisabledAt != nil,
+		DisabledAt:    user.DisabledAt,
+		CreatedAt:     user.CreatedAt,
+	}
+}
+
+// SetUserDisabled disables or re-enables an account. Disabling signs the
+// user out everywhere and closes their sockets, including those opened with
+// API tokens; until re-enabled they can't log in or use their tokens.
+func (s *AdminService) SetUserDisabled(ctx context.Context, userID string, disabled bool) error {
+	if actorID, _ := ctx.Value("userID").(string); disabled && actorID == userID {
+		return utils.ErrDisableSelf
+	}
+
+	user, err := s.AuthService.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.DeletedAt != nil {
+		return utils.ErrUserNotFound
+	}
+	if (user.DisabledAt != nil) == disabled {
+		return nil
+	}
+
+	var disabledAt *time.Time
+	action := models.AuditUserEnabled
+	if disabled {
+		now := time.Now()
+		disabledAt = &now
+		action = models.AuditUserDisabled
+	}
+	if err := s.AuthService.AuthRepository.SetDisabled(userID, disabledAt); err != nil {
+		return err
+	}
+
+	if disabled {
+		s.AuthService.SessionManager.DeleteUserSessions(userID)
+		if err := s.RoomService.DisconnectUser(userID, models.AttendanceDisabled); err != nil {
+			slog.ErrorContext(ctx, "Error disconnecting disabled user", "user_id", userID, "error", err)
+		}
+	}
+
+	s.AuthService.Audit.Record(ctx, models.AuditEvent{
+		Action:     action,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+		Details:    map[string]string{"username": user.Username},
+	})
+	slog.InfoContext(ctx, "Account status changed", "user_id", userID, "disabled", disabled)
+	return nil
+}
+
+// ListLiveRooms returns the rooms with someone in them on any node, busiest
+// first
+func (s *AdminService) ListLiveRooms() ([]models.LiveRoom, error) {
+	return s.RoomService.LiveRooms()
+}
+
+// EndRoom ends any active room, whoever hosts it
+func (s *AdminService) EndRoom(ctx context.Context, roomID string) error {
+	room, err := s.RoomService.RoomRepository.GetRoom(roomID)
+	if err != nil {
+		return err
+	}
+	if room.Status != models.RoomStatusActive {
+		return utils.ErrRoomEnded
+	}
+	return s.RoomService.EndRoom(ctx, roomID)
+}
+
+// Announce sends a message to every participant connected to any room,
+// including those still in a waiting room
+func (s *AdminService) Announce(ctx context.Context, message string) error {
+	message = strings.TrimSpace(message)
+	if message == "" || utf8.RuneCountInString(message) > maxAnnouncementLength {
+		return utils.ErrInvalidAnnouncement
+	}
+
+	if err := s.RoomService.Announce(message); err != nil {
+		return err
+	}
+
+	s.AuthService.Audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditAnnouncement,
+		TargetType: models.AuditTargetServer,
+		Details:    map[string]string{"message": message},
+	})
+	slog.InfoContext(ctx, "Announcement sent")
+	return nil
+}
+
+// LiveRooms counts who is present in each room across every node, busiest
+// first. Rooms missing from the database, e.g. purged while presence lingers,
+// are skipped.
+func (r *RoomService) LiveRooms() ([]models.LiveRoom, error) {
+	roomIDs, err := r.Bus.Rooms()
+	if err != nil {
+		return nil, err
+	}
+
+	rooms := make([]models.LiveRoom, 0, len(roomIDs))
+	for _, roomID := range roomIDs {
+		members, err := r.Bus.Members(roomID)
+		if err != nil {
+			return nil, err
+		}
+		participants, waiting := countMembers(members)
+		if participants+waiting == 0 {
+			continue
+		}
+
+		room, err := r.RoomRepository.GetRoom(roomID)
+		if errors.Is(err, utils.ErrRoomNotFound) {
+			slog.Warn("Skipping live room missing from the database", "room_id", roomID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, models.LiveRoom{
+			ID:           roomID,
+			Name:         room.Name,
+			HostID:       room.HostID,
+			Participants: participants,
+			WaitingCount: waiting,
+			CreatedAt:    room.CreatedAt,
+		})
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].Participants != rooms[j].Participants {
+			return rooms[i].Participants > rooms[j].Participants
+		}
+		return rooms[i].CreatedAt.Before(rooms[j].CreatedAt)
+	})
+	return rooms, nil
+}
+
+// Announce writes a server-wide message to every socket, on every node
+func (r *RoomService) Announce(message string) error {
+	data, err := json.Marshal(models.WebSocketMessage{
+		Type:    models.WSMessageTypeAnnouncement,
+		Payload: models.AnnouncementPayload{Message: message, SentAt: time.Now()},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	return r.Bus.Publish(bus.Event{
+		Kind:        bus.EventAnnounce,
+		MessageType: models.WSMessageTypeAnnouncement,
+		Message:     data,
+	})
+}
+
+// DisconnectUser closes every socket a user holds, in any room and on any
+// node, recording reason in their attendance
+func (r *RoomService) DisconnectUser(userID, reason string) error {
+	return r.Bus.Publish(bus.Event{
+		Kind:   bus.EventDisconnectUser,
+		Target: userID,
+		Reason: reason,
+	})
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+// TestLiveRooms builds the live room list from presence on the bus, so
+// participants on other nodes count too
+func TestLiveRooms(t *testing.T) {
+	type presence struct {
+		roomID, userID, status string
+	}
+	admitted, waiting := models.ParticipantStatusAdmitted, models.ParticipantStatusWaiting
+
+	tests := []struct {
+		name     string
+		rooms    []string // created in order, a minute apart
+		presence []presence
+		want     []models.LiveRoom // only ID and counts are compared
+	}{
+		{
+			name:  "nobody anywhere",
+			rooms: []string{"standup"},
+		},
+		{
+			name:     "busiest first",
+			rooms:    []string{"standup", "retro"},
+			presence: []presence{{"standup", "alice", admitted}, {"retro", "bob", admitted}, {"retro", "carol", admitted}, {"retro", "dave", waiting}},
+			want:     []models.LiveRoom{{ID: "retro", Participants: 2, WaitingCount: 1}, {ID: "standup", Participants: 1}},
+		},
+		{
+			name:     "ties go to the oldest room",
+			rooms:    []string{"standup", "retro"},
+			presence: []presence{{"retro", "bob", admitted}, {"standup", "alice", admitted}},
+			want:     []models.LiveRoom{{ID: "standup", Participants: 1}, {ID: "retro", Participants: 1}},
+		},
+		{
+			name:     "only waiting",
+			rooms:    []string{"standup"},
+			presence: []presence{{"standup", "alice", waiting}},
+			want:     []models.LiveRoom{{ID: "standup", WaitingCount: 1}},
+		},
+		{
+			name:     "room missing from the database is skipped",
+			rooms:    []string{"standup"},
+			presence: []presence{{"purged", "mallory", admitted}, {"standup", "alice", admitted}},
+			want:     []models.LiveRoom{{ID: "standup", Participants: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoomService(t)
+			createdAt := time.Now().Add(-time.Hour)
+			for i, roomID := range tt.rooms {
+				createTestRoom(t, r, roomID, "host", func(room *models.Room) {
+					room.CreatedAt = createdAt.Add(time.Duration(i) * time.Minute)
+				})
+			}
+			for _, p := range tt.presence {
+				setTestMember(t, r, p.roomID, bus.Member{UserID: p.userID, Status: p.status})
+			}
+
+			rooms, err := r.LiveRooms()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]models.LiveRoom, len(rooms))
+			for i, room := range rooms {
+				if room.Name != room.ID || room.HostID != "host" {
+					t.Errorf("room %s has name %q and host %q", room.ID, room.Name, room.HostID)
+				}
+				got[i] = models.LiveRoom{ID: room.ID, Participants: room.Participants, WaitingCount: room.WaitingCount}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("LiveRooms() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
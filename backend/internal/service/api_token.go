@@ -0,0 +1,145 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+const (
+	// apiTokenPrefix marks chimecast tokens so they are easy to spot in
+	// secret scanners and can't be mistaken for session IDs
+	apiTokenPrefix = "cc_"
+
+	defaultAPITokenLifetime = 30 * 24 * time.Hour
+	maxAPITokenLifetime     = 365 * 24 * time.Hour
+	maxAPITokenName         = 100
+
+	// apiTokenTouchInterval limits how often last-used times are written
+	apiTokenTouchInterval = time.Minute
+)
+
+// CreateAPIToken issues a personal API token. The returned token string is
+// the only time it is available; only its hash is stored.
+func (a *AuthService) CreateAPIToken(userID string, request *models.CreateAPITokenRequest) (string, *models.APIToken, error) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > maxAPITokenName {
+		return "", nil, fmt.Errorf("name must be between 1 and %d characters", maxAPITokenName)
+	}
+	scopes, err := normalizeScopes(request.Scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	lifetime := defaultAPITokenLifetime
+	if request.ExpiresInDays != 0 {
+		lifetime = time.Duration(request.ExpiresInDays) * 24 * time.Hour
+		if lifetime <= 0 || lifetime > maxAPITokenLifetime {
+			return "", nil, errors.New("expiresInDays must be between 1 and 365")
+		}
+	}
+
+	secret, err := utils.NewToken()
+	if err != nil {
+		return "", nil, err
+	}
+	raw := apiTokenPrefix + secret
+
+	now := time.Now()
+	token := models.APIToken{
+		ID:        utils.CreateNewUUID(),
+		UserID:    userID,
+		Name:      name,
+		TokenHash: utils.HashToken(raw),
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: now.Add(lifetime),
+	}
+	if err := a.APITokenRepository.CreateAPIToken(token); err != nil {
+		return "", nil, err
+	}
+	return raw, &token, nil
+}
+
+// ListAPITokens returns the user's tokens without their secrets
+func (a *AuthService) ListAPITokens(userID string) ([]models.APIToken, error) {
+	return a.APITokenRepository.ListAPITokens(userID)
+}
+
+// RevokeAPIToken deletes one of the user's tokens; it stops working at once
+// and any socket opened with it is closed
+func (a *AuthService) RevokeAPIToken(userID, tokenID string) error {
+	if err := a.APITokenRepository.DeleteAPIToken(userID, tokenID); err != nil {
+		return err
+	}
+	a.apiTokensRevoked([]string{tokenID})
+	return nil
+}
+
+// revokeUserAPITokens deletes all of the user's tokens and closes the sockets
+// they opened
+func (a *AuthService) revokeUserAPITokens(userID string) error {
+	tokenIDs, err := a.APITokenRepository.DeleteUserAPITokens(userID)
+	if err != nil {
+		return err
+	}
+	a.apiTokensRevoked(tokenIDs)
+	return nil
+}
+
+func (a *AuthService) apiTokensRevoked(tokenIDs []string) {
+	if len(tokenIDs) > 0 && a.OnAPITokensRevoked != nil {
+		a.OnAPITokensRevoked(tokenIDs)
+	}
+}
+
+// AuthenticateAPIToken resolves a bearer token to the token record and
+// records that it was used
+func (a *AuthService) AuthenticateAPIToken(raw string) (*models.APIToken, error) {
+	if !strings.HasPrefix(raw, apiTokenPrefix) {
+		return nil, utils.ErrInvalidToken
+	}
+
+	now := time.Now()
+	token, err := a.APITokenRepository.GetAPITokenByHash(utils.HashToken(raw), now)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.AuthRepository.GetUserByID(token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, utils.ErrAccountDisabled
+	}
+	// A failed write shouldn't lock a bot out
+	if err := a.APITokenRepository.TouchAPIToken(token.ID, now, apiTokenTouchInterval); err != nil {
+		slog.Error("Error recording API token use", "credential_id", token.ID, "error", err)
+	}
+	return token, nil
+}
+
+// normalizeScopes checks every requested scope is known and returns them in
+// canonical order without duplicates
+func normalizeScopes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	for _, scope := range requested {
+		if !slices.Contains(models.APIScopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	scopes := []string{}
+	for _, scope := range models.APIScopes {
+		if slices.Contains(requested, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/session"
+)
+
+func TestPasswordChangesRevokeAPITokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, a *AuthService, user *models.User) error
+	}{
+		{
+			name: "change password",
+			change: func(t *testing.T, a *AuthService, user *models.User) error {
+				_, err := a.ChangePassword(user.ID, &models.ChangePasswordRequest{CurrentPassword: "password", NewPassword: "new-password"}, session.Client{})
+				return err
+			},
+		},
+		{
+			name: "reset password",
+			change: func(t *testing.T, a *AuthService, user *models.User) error {
+				token, err := a.issueToken(user.ID, models.TokenPurposeResetPassword, time.Hour)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return a.ResetPassword(&models.ResetPasswordRequest{Token: token, Password: "new-password"})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuthService(t, newTestDB(t), LoginLimits{})
+			var revoked []string
+			a.OnAPITokensRevoked = func(tokenIDs []string) { revoked = append(revoked, tokenIDs...) }
+
+			user := registerTestUser(t, a, "alice")
+			other := registerTestUser(t, a, "bob")
+			request := &models.CreateAPITokenRequest{Name: "bot", Scopes: []string{models.ScopeRoomsWrite}}
+			_, token, err := a.CreateAPIToken(user.ID, request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := a.CreateAPIToken(other.ID, request); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.change(t, a, user); err != nil {
+				t.Fatalf("error = %v", err)
+			}
+
+			if tokens, _ := a.ListAPITokens(user.ID); len(tokens) != 0 {
+				t.Errorf("user still has %d API tokens", len(tokens))
+			}
+			if tokens, _ := a.ListAPITokens(other.ID); len(tokens) != 1 {
+				t.Errorf("other user has %d API tokens, want 1", len(tokens))
+			}
+			if len(revoked) != 1 || revoked[0] != token.ID {
+				t.Errorf("revoked = %v, want [%s]", revoked, token.ID)
+			}
+		})
+	}
+}
+
+func TestCloseAPITokens(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiTokenID string
+		wantClosed bool
+	}{
+		{name: "socket opened with the revoked token", apiTokenID: "revoked", wantClosed: true},
+		{name: "socket opened with another token", apiTokenID: "kept"},
+		{name: "socket opened with a session", apiTokenID: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoomService(t)
+			connected := make(chan struct{})
+			client := dialTestSocket(t, func(socket *Socket) {
+				r.mu.Lock()
+				r.Connections["room"] = map[string]*Connection{"alice": {Conn: socket, UserID: "alice", APITokenID: tt.apiTokenID}}
+				r.mu.Unlock()
+				close(connected)
+				// Hold the socket open until the client goes away
+				for {
+					if _, _, err := socket.ReadMessage(); err != nil {
+						return
+					}
+				}
+			})
+			<-connected
+
+			r.CloseAPITokens([]string{"revoked"})
+
+			client.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			_, _, err := client.ReadMessage()
+			closed := websocket.IsCloseError(err, websocket.ClosePolicyViolation)
+			if closed != tt.wantClosed {
+				t.Errorf("closed = %v (error %v), want %v", closed, err, tt.wantClosed)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"encoding/csv"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// startAttendance records that an admitted connection is now in the room.
+// The caller locks conn.attendance while admitting it, and startAttendance
+// unlocks it once the interval is open. Attendance is bookkeeping, so
+// failures are logged rather than keeping anyone out.
+func (r *RoomService) startAttendance(roomID string, conn *Connection, reason string) {
+	defer conn.attendance.Unlock()
+
+	interval := models.AttendanceInterval{
+		ID:         conn.ID,
+		RoomID:     roomID,
+		UserID:     conn.UserID,
+		JoinedAt:   time.Now(),
+		JoinReason: reason,
+	}
+	if err := r.AttendanceRepository.OpenAttendance(&interval, utils.CreateNewUUID()); err != nil {
+		slog.Error("Error recording attendance", "room_id", roomID, "user_id", conn.UserID, "error", err)
+		return
+	}
+	slog.Info("Participant joined meeting", "room_id", roomID, "user_id", conn.UserID, "meeting_id", interval.MeetingID, "reason", interval.JoinReason)
+
+	// A reconnect carries on the same stay, so subscribers hear of neither
+	// the old connection leaving nor the new one joining
+	if interval.JoinReason != models.AttendanceReconnect {
+		r.Webhooks.Publish(models.RoomEvent{
+			Type:   models.EventParticipantJoined,
+			RoomID: roomID,
+			Data:   map[string]string{"userId": conn.UserID, "meetingId": interval.MeetingID, "reason": interval.JoinReason},
+		})
+	}
+}
+
+// endAttendance records that an admitted connection has left the room
+func (r *RoomService) endAttendance(roomID string, conn *Connection, reason string) {
+	conn.attendance.Lock()
+	defer conn.attendance.Unlock()
+
+	closed, err := r.AttendanceRepository.CloseAttendance(conn.ID, time.Now(), reason)
+	if err != nil {
+		slog.Error("Error recording attendance", "user_id", conn.UserID, "error", err)
+		return
+	}
+	if closed {
+		r.Webhooks.Publish(models.RoomEvent{
+			Type:   models.EventParticipantLeft,
+			RoomID: roomID,
+			Data:   map[string]string{"userId": conn.UserID, "reason": reason},
+		})
+	}
+}
+
+// ListMyMeetings returns the meetings a user attended, newest first, with
+// how long they were in each
+func (r *RoomService) ListMyMeetings(userID string) ([]models.MeetingAttendance, error) {
+	meetings, err := r.AttendanceRepository.ListUserMeetings(userID)
+	if err != nil {
+		return nil, err
+	}
+	intervals, err := r.AttendanceRepository.UserAttendance(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byMeeting := make(map[string][]models.AttendanceInterval)
+	for _, interval := range intervals {
+		byMeeting[interval.MeetingID] = append(byMeeting[interval.MeetingID], interval)
+	}
+
+	now := time.Now()
+	result := make([]models.MeetingAttendance, 0, len(meetings))
+	for _, meeting := range meetings {
+		summary := summarize(byMeeting[meeting.ID], now)
+		result = append(result, models.MeetingAttendance{
+			Meeting:           withDuration(meeting, now),
+			JoinedAt:          summary.JoinedAt,
+			LeftAt:            summary.LeftAt,
+			TimeInRoomSeconds: summary.TimeInRoomSeconds,
+		})
+	}
+	return result, nil
+}
+
+// ListRoomMeetings returns a room's meetings, newest first. Only the host
+// may see them.
+func (r *RoomService) ListRoomMeetings(roomID, userID string) ([]models.Meeting, error) {
+	if err := r.requireHost(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	meetings, err := r.AttendanceRepository.ListRoomMeetings(roomID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range meetings {
+		meetings[i] = withDuration(meetings[i], now)
+	}
+	return meetings, nil
+}
+
+// GetAttendanceReport totals each participant's time in one of a room's
+// meetings. Only the host may see it.
+func (r *RoomService) GetAttendanceReport(roomID, meetingID, userID string) (*models.AttendanceReport, error) {
+	if err := r.requireHost(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	meeting, err := r.AttendanceRepository.GetMeeting(roomID, meetingID)
+	if err != nil {
+		return nil, err
+	}
+	intervals, err := r.AttendanceRepository.MeetingAttendance(meetingID)
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[string][]models.AttendanceInterval)
+	var order []string
+	for _, interval := range intervals {
+		if _, seen := byUser[interval.UserID]; !seen {
+			order = append(order, interval.UserID)
+		}
+		byUser[interval.UserID] = append(byUser[interval.UserID], interval)
+	}
+
+	now := time.Now()
+	report := &models.AttendanceReport{
+		Meeting:      withDuration(*meeting, now),
+		Participants: make([]models.ParticipantAttendance, 0, len(order)),
+	}
+	for _, id := range order {
+		report.Participants = append(report.Participants, summarize(byUser[id], now))
+	}
+	return report, nil
+}
+
+// requireHost checks that userID hosts the room
+func (r *RoomService) requireHost(roomID, userID string) error {
+	room, err := r.RoomRepository.GetRoom(roomID)
+	if err != nil {
+		return err
+	}
+	if room.HostID != userID {
+		return utils.ErrNotRoomHost
+	}
+	return nil
+}
+
+// summarize totals one participant's intervals, oldest first. Overlapping
+// intervals are only counted once, and open ones count up to now.
+func summarize(intervals []models.AttendanceInterval, now time.Time) models.ParticipantAttendance {
+	var summary models.ParticipantAttendance
+	if len(intervals) == 0 {
+		return summary
+	}
+	first := intervals[0]
+	summary.UserID = first.UserID
+	summary.Username = first.Username
+	summary.Name = first.Name
+	summary.JoinedAt = first.JoinedAt
+	summary.Sessions = intervals
+
+	sorted := append([]models.AttendanceInterval(nil), intervals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].JoinedAt.Before(sorted[j].JoinedAt) })
+
+	var total time.Duration
+	var coveredUntil time.Time
+	stillIn := false
+	for _, interval := range sorted {
+		end := now
+		if interval.LeftAt != nil {
+			end = *interval.LeftAt
+		} else {
+			stillIn = true
+		}
+		if summary.LeftAt == nil || end.After(*summary.LeftAt) {
+			summary.LeftAt = &end
+		}
+
+		start := interval.JoinedAt
+		if start.Before(coveredUntil) {
+			start = coveredUntil
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+			coveredUntil = end
+		}
+	}
+	if stillIn {
+		summary.LeftAt = nil
+	}
+	summary.TimeInRoomSeconds = int64(total.Seconds())
+	return summary
+}
+
+// withDuration fills in how long a meeting lasted, or has lasted so far
+func withDuration(meeting models.Meeting, now time.Time) models.Meeting {
+	end := now
+	if meeting.EndedAt != nil {
+		end = *meeting.EndedAt
+	}
+	meeting.DurationSeconds = int64(end.Sub(meeting.StartedAt).Seconds())
+	return meeting
+}
+
+// WriteAttendanceCSV writes a report with one row per participant
+func WriteAttendanceCSV(w io.Writer, report *models.AttendanceReport) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"user_id", "username", "name", "joined_at", "left_at", "time_in_room_seconds", "sessions"})
+	for _, p := range report.Participants {
+		leftAt := ""
+		if p.LeftAt != nil {
+			leftAt = p.LeftAt.UTC().Format(time.RFC3339)
+		}
+		out.Write([]string{
+			p.UserID,
+			csvSafe(p.Username),
+			csvSafe(p.Name),
+			p.JoinedAt.UTC().Format(time.RFC3339),
+			leftAt,
+			strconv.FormatInt(p.TimeInRoomSeconds, 10),
+			strconv.Itoa(len(p.Sessions)),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvSafe stops user-chosen text from being read as a formula when the file
+// is opened in a spreadsheet
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
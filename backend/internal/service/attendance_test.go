@@ -0,0 +1,186 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func TestSummarize(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	left := func(minutes int) *time.Time {
+		t := at(minutes)
+		return &t
+	}
+	interval := func(joined int, leftAt *time.Time) models.AttendanceInterval {
+		return models.AttendanceInterval{UserID: "alice", Username: "alice", JoinedAt: at(joined), LeftAt: leftAt}
+	}
+	now := at(60)
+
+	tests := []struct {
+		name       string
+		intervals  []models.AttendanceInterval
+		wantJoined time.Time
+		wantLeft   *time.Time
+		wantTotal  time.Duration
+	}{
+		{name: "none"},
+		{
+			name:       "one closed interval",
+			intervals:  []models.AttendanceInterval{interval(0, left(10))},
+			wantJoined: at(0), wantLeft: left(10), wantTotal: 10 * time.Minute,
+		},
+		{
+			name:       "gaps are not counted",
+			intervals:  []models.AttendanceInterval{interval(0, left(10)), interval(20, left(25))},
+			wantJoined: at(0), wantLeft: left(25), wantTotal: 15 * time.Minute,
+		},
+		{
+			name:       "overlaps are counted once",
+			intervals:  []models.AttendanceInterval{interval(0, left(10)), interval(5, left(15))},
+			wantJoined: at(0), wantLeft: left(15), wantTotal: 15 * time.Minute,
+		},
+		{
+			name:       "an interval inside another adds nothing",
+			intervals:  []models.AttendanceInterval{interval(0, left(30)), interval(10, left(20))},
+			wantJoined: at(0), wantLeft: left(30), wantTotal: 30 * time.Minute,
+		},
+		{
+			name:       "open interval counts up to now",
+			intervals:  []models.AttendanceInterval{interval(0, left(10)), interval(40, nil)},
+			wantJoined: at(0), wantTotal: 30 * time.Minute,
+		},
+		{
+			name:       "open interval overlapping a closed one",
+			intervals:  []models.AttendanceInterval{interval(30, nil), interval(50, left(55))},
+			wantJoined: at(30), wantTotal: 30 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarize(tt.intervals, now)
+			if !got.JoinedAt.Equal(tt.wantJoined) {
+				t.Errorf("JoinedAt = %v, want %v", got.JoinedAt, tt.wantJoined)
+			}
+			if (got.LeftAt == nil) != (tt.wantLeft == nil) || (got.LeftAt != nil && !got.LeftAt.Equal(*tt.wantLeft)) {
+				t.Errorf("LeftAt = %v, want %v", got.LeftAt, tt.wantLeft)
+			}
+			if want := int64(tt.wantTotal.Seconds()); got.TimeInRoomSeconds != want {
+				t.Errorf("TimeInRoomSeconds = %d, want %d", got.TimeInRoomSeconds, want)
+			}
+			if len(got.Sessions) != len(tt.intervals) {
+				t.Errorf("%d sessions, want %d", len(got.Sessions), len(tt.intervals))
+			}
+		})
+	}
+}
+
+// TestAttendanceReports checks that only the host sees a room's meetings and
+// who attended them
+func TestAttendanceReports(t *testing.T) {
+	r := newTestRoomService(t)
+	for _, username := range []string{"alice", "bob", "carol"} {
+		user := models.User{ID: "id-" + username, Username: username, Email: username + "@example.com", CreatedAt: time.Now()}
+		if err := r.AuthRepository.RegisterUser(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createTestRoom(t, r, "standup", "id-alice", nil)
+	createTestRoom(t, r, "retro", "id-carol", nil)
+
+	// alice and bob meet, bob drops out for a while, and alice is still there
+	joined := time.Now().Add(-time.Hour)
+	open := func(id, userID string, at time.Duration) string {
+		interval := &models.AttendanceInterval{ID: id, RoomID: "standup", UserID: userID, JoinedAt: joined.Add(at), JoinReason: models.AttendanceJoin}
+		if err := r.AttendanceRepository.OpenAttendance(interval, "m1"); err != nil {
+			t.Fatal(err)
+		}
+		return interval.MeetingID
+	}
+	meetingID := open("a1", "id-alice", 0)
+	open("b1", "id-bob", 10*time.Minute)
+	if _, err := r.AttendanceRepository.CloseAttendance("b1", joined.Add(20*time.Minute), models.AttendanceDisconnect); err != nil {
+		t.Fatal(err)
+	}
+	open("b2", "id-bob", 40*time.Minute)
+	if _, err := r.AttendanceRepository.CloseAttendance("b2", joined.Add(50*time.Minute), models.AttendanceLeave); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("meetings", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			roomID  string
+			userID  string
+			wantErr error
+		}{
+			{name: "host", roomID: "standup", userID: "id-alice"},
+			{name: "participant", roomID: "standup", userID: "id-bob", wantErr: utils.ErrNotRoomHost},
+			{name: "stranger", roomID: "standup", userID: "id-carol", wantErr: utils.ErrNotRoomHost},
+			{name: "unknown room", roomID: "missing", userID: "id-alice", wantErr: utils.ErrRoomNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				meetings, err := r.ListRoomMeetings(tt.roomID, tt.userID)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ListRoomMeetings() error = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr != nil {
+					return
+				}
+				if len(meetings) != 1 || meetings[0].ID != meetingID || meetings[0].EndedAt != nil {
+					t.Fatalf("ListRoomMeetings() = %+v, want meeting %s in progress", meetings, meetingID)
+				}
+				if meetings[0].DurationSeconds < int64(time.Hour.Seconds()) {
+					t.Errorf("DurationSeconds = %d, want it counted up to now", meetings[0].DurationSeconds)
+				}
+			})
+		}
+	})
+
+	t.Run("report", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			roomID    string
+			meetingID string
+			userID    string
+			wantErr   error
+		}{
+			{name: "host", roomID: "standup", meetingID: meetingID, userID: "id-alice"},
+			{name: "participant", roomID: "standup", meetingID: meetingID, userID: "id-bob", wantErr: utils.ErrNotRoomHost},
+			{name: "host of another room", roomID: "retro", meetingID: meetingID, userID: "id-carol", wantErr: utils.ErrMeetingNotFound},
+			{name: "unknown meeting", roomID: "standup", meetingID: "missing", userID: "id-alice", wantErr: utils.ErrMeetingNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				report, err := r.GetAttendanceReport(tt.roomID, tt.meetingID, tt.userID)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetAttendanceReport() error = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr != nil {
+					return
+				}
+
+				var usernames []string
+				for _, p := range report.Participants {
+					usernames = append(usernames, p.Username)
+				}
+				if !slices.Equal(usernames, []string{"alice", "bob"}) {
+					t.Fatalf("participants = %v, want alice then bob", usernames)
+				}
+				alice, bob := report.Participants[0], report.Participants[1]
+				if alice.LeftAt != nil || alice.TimeInRoomSeconds < int64(time.Hour.Seconds()) {
+					t.Errorf("alice = %+v, want still in the room for an hour", alice)
+				}
+				if bob.LeftAt == nil || bob.TimeInRoomSeconds != int64((20*time.Minute).Seconds()) || len(bob.Sessions) != 2 {
+					t.Errorf("bob = %+v, want two sessions totalling 20 minutes", bob)
+				}
+			})
+		}
+	})
+}
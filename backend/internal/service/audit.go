@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+func NewAuditService(auditRepository AuditRepository) *AuditService {
+	return &AuditService{
+		AuditRepository: auditRepository,
+	}
+}
+
+// Record appends an event to the audit log. The actor and IP default to the
+// signed-in user and client address carried by ctx. Auditing never fails the
+// action being audited, so errors are only logged.
+func (a *AuditService) Record(ctx context.Context, event models.AuditEvent) {
+	if a == nil {
+		return
+	}
+	if event.ActorID == "" {
+		event.ActorID, _ = ctx.Value("userID").(string)
+	}
+	if event.IP == "" {
+		event.IP, _ = ctx.Value("clientIP").(string)
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	if err := a.AuditRepository.AppendAuditEvent(&event); err != nil {
+		slog.ErrorContext(ctx, "Error recording audit event", "action", event.Action, "error", err)
+		return
+	}
+	a.writeSink(ctx, event)
+}
+
+// writeSink copies an event to the JSON-lines sink, for shipping to a SIEM
+func (a *AuditService) writeSink(ctx context.Context, event models.AuditEvent) {
+	if a.Sink == nil {
+		return
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error encoding audit event", "action", event.Action, "error", err)
+		return
+	}
+
+	a.sinkMu.Lock()
+	defer a.sinkMu.Unlock()
+	if _, err := a.Sink.Write(append(line, '\n')); err != nil {
+		slog.ErrorContext(ctx, "Error writing audit event to sink", "action", event.Action, "error", err)
+	}
+}
+
+// ListEvents returns one page of the audit log, newest first
+func (a *AuditService) ListEvents(query models.AuditQuery) (*models.AuditPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = defaultAuditPageSize
+	}
+	query.Limit = min(query.Limit, maxAuditPageSize)
+
+	// One extra row tells us whether there is another page
+	limit := query.Limit
+	query.Limit++
+	events, err := a.AuditRepository.ListAuditEvents(query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.AuditPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		page.NextCursor = strconv.FormatInt(page.Events[limit-1].ID, 10)
+	}
+	return page, nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/repositories"
+)
+
+func TestRecord(t *testing.T) {
+	var sink bytes.Buffer
+	a := NewAuditService(repositories.NewAuditRepository(newTestDB(t)))
+	a.Sink = &sink
+
+	ctx := context.WithValue(context.Background(), "userID", "id-alice")
+	ctx = context.WithValue(ctx, "clientIP", "192.0.2.1")
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		event     models.AuditEvent
+		wantActor string
+		wantIP    string
+	}{
+		{name: "actor and IP from the request", event: models.AuditEvent{Action: models.AuditRoomCreated, CreatedAt: at}, wantActor: "id-alice", wantIP: "192.0.2.1"},
+		{name: "given actor and IP", event: models.AuditEvent{Action: models.AuditLoginFailed, ActorID: "id-bob", IP: "192.0.2.2", CreatedAt: at}, wantActor: "id-bob", wantIP: "192.0.2.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink.Reset()
+			a.Record(ctx, tt.event)
+
+			page, err := a.ListEvents(models.AuditQuery{Action: tt.event.Action})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Events) != 1 || page.Events[0].ActorID != tt.wantActor || page.Events[0].IP != tt.wantIP {
+				t.Fatalf("stored %+v, want actor %s from %s", page.Events, tt.wantActor, tt.wantIP)
+			}
+
+			var line models.AuditEvent
+			if err := json.Unmarshal(sink.Bytes(), &line); err != nil {
+				t.Fatalf("sink line %q: %v", sink.String(), err)
+			}
+			if line.ID != page.Events[0].ID || line.ActorID != tt.wantActor {
+				t.Errorf("sink got %+v, want the stored event", line)
+			}
+		})
+	}
+
+	// Services without auditing carry a nil AuditService
+	var disabled *AuditService
+	disabled.Record(ctx, models.AuditEvent{Action: models.AuditLogin})
+}
+
+// TestListEventsPages walks the log a page at a time, following each page's
+// cursor the way the admin API does
+func TestListEventsPages(t *testing.T) {
+	a := NewAuditService(repositories.NewAuditRepository(newTestDB(t)))
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	const total = 7
+	for i := 0; i < total; i++ {
+		a.Record(context.Background(), models.AuditEvent{Action: models.AuditLogin, ActorID: "id-alice", CreatedAt: at.Add(time.Duration(i) * time.Minute)})
+	}
+
+	tests := []struct {
+		name      string
+		limit     int
+		wantPages []int
+	}{
+		{name: "exactly one page", limit: 7, wantPages: []int{7}},
+		{name: "last page shorter", limit: 3, wantPages: []int{3, 3, 1}},
+		{name: "one at a time", limit: 1, wantPages: []int{1, 1, 1, 1, 1, 1, 1}},
+		{name: "default size", wantPages: []int{7}},
+		{name: "oversized", limit: 10 * maxAuditPageSize, wantPages: []int{7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sizes []int
+			var ids []int64
+			query := models.AuditQuery{Limit: tt.limit}
+			for {
+				page, err := a.ListEvents(query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sizes = append(sizes, len(page.Events))
+				for _, event := range page.Events {
+					ids = append(ids, event.ID)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				if len(sizes) > total {
+					t.Fatalf("still paging after %v", sizes)
+				}
+				if query.Before, err = strconv.ParseInt(page.NextCursor, 10, 64); err != nil {
+					t.Fatalf("NextCursor = %q: %v", page.NextCursor, err)
+				}
+			}
+
+			if !slices.Equal(sizes, tt.wantPages) {
+				t.Errorf("page sizes = %v, want %v", sizes, tt.wantPages)
+			}
+			if len(ids) != total {
+				t.Fatalf("IDs = %v, want all %d", ids, total)
+			}
+			for i := 1; i < len(ids); i++ {
+				if ids[i] >= ids[i-1] {
+					t.Fatalf("IDs = %v, want newest first without repeats", ids)
+				}
+			}
+		})
+	}
+}
@@ -1,24 +1,34 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/legendary-acp/chimecast/internal/mailer"
+	"github.com/legendary-acp/chimecast/internal/metrics"
 	"github.com/legendary-acp/chimecast/internal/models"
-	"github.com/legendary-acp/chimecast/internal/repositories"
 	"github.com/legendary-acp/chimecast/internal/session"
 	"github.com/legendary-acp/chimecast/internal/utils"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func NewAuthService(authRepositories *repositories.AuthRepository, sessionManager *session.SessionManager) *AuthService {
+func NewAuthService(authRepositories AuthRepository, loginAttemptRepository LoginAttemptRepository, tokenRepository TokenRepository, apiTokenRepository APITokenRepository, sessionManager *session.SessionManager, mailer mailer.Mailer, loginLimits LoginLimits, publicURL string) *AuthService {
 	return &AuthService{
-		AuthRepository: authRepositories,
-		SessionManager: sessionManager,
+		AuthRepository:         authRepositories,
+		LoginAttemptRepository: loginAttemptRepository,
+		TokenRepository:        tokenRepository,
+		APITokenRepository:     apiTokenRepository,
+		SessionManager:         sessionManager,
+		Mailer:                 mailer,
+		LoginLimits:            loginLimits,
+		PublicURL:              strings.TrimSuffix(publicURL, "/"),
 	}
 }
 
-func (a *AuthService) RegisterUser(request *models.RegisterRequest) (*string, error) {
+func (a *AuthService) RegisterUser(request *models.RegisterRequest, client session.Client) (*string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
@@ -38,7 +48,13 @@ func (a *AuthService) RegisterUser(request *models.RegisterRequest) (*string, er
 	if err != nil {
 		return nil, err
 	}
-	sessionID, err := a.SessionManager.CreateSession(user.Username, user.ID)
+
+	// Registration stands even if the email can't be sent; the user can ask
+	// for another one
+	if err := a.sendVerificationEmail(&user); err != nil {
+		slog.Error("Error sending verification email", "user", user, "error", err)
+	}
+	sessionID, err := a.SessionManager.CreateSession(user.Username, user.ID, client)
 	if err != nil {
 		return nil, errors.New("could not create session")
 	}
@@ -46,24 +62,92 @@ func (a *AuthService) RegisterUser(request *models.RegisterRequest) (*string, er
 	return &sessionID, nil
 }
 
-func (a *AuthService) Login(request *models.LoginRequest) (string, error) {
+// LoginResult is the outcome of a successful password check. Either SessionID
+// is set, or MFAToken is and the login must be finished with CompleteMFALogin.
+type LoginResult struct {
+	SessionID string
+	MFAToken  string
+
+	// MFAEnrollmentRequired is set when MFA is mandatory and the user has yet
+	// to enrol; the session only reaches the enrolment endpoints until then
+	MFAEnrollmentRequired bool
+}
+
+// Login checks a user's password and starts a session, or asks for a second
+// factor when the user has one. Attempts are throttled per client IP and per
+// username before any password is hashed.
+func (a *AuthService) Login(request *models.LoginRequest, client session.Client) (*LoginResult, error) {
+	now := time.Now()
+	if err := a.throttleLogin(request.UserName, client.IP, now); err != nil {
+		return nil, err
+	}
+
 	user, err := a.AuthRepository.Login(request.UserName)
 	if err != nil {
-		return "", err
+		metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+		a.recordLoginAttempt(request.UserName, client.IP, models.LoginResultFailure, now)
+		return nil, err
 	}
 
 	// Compare the provided password with the hashed password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(request.Password)); err != nil {
-		return "", errors.New("invalid credentials")
+		metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+		a.recordLoginAttempt(request.UserName, client.IP, models.LoginResultFailure, now)
+		return nil, errors.New("invalid credentials")
 	}
 
+	if user.MFAEnabled {
+		token, err := a.issueToken(user.ID, models.TokenPurposeMFAPending, mfaPendingTokenTTL)
+		if err != nil {
+			return nil, err
+		}
+		a.recordLoginAttempt(request.UserName, client.IP, models.LoginResultMFAPending, now)
+		return &LoginResult{MFAToken: token}, nil
+	}
+
+	return a.startSession(user, client, now)
+}
+
+// throttleLogin refuses attempts over the login limits, recording them
+func (a *AuthService) throttleLogin(username, ip string, now time.Time) error {
+	err := a.checkLoginLimits(username, ip, now)
+	if errors.Is(err, utils.ErrTooManyLoginAttempts) {
+		metrics.Logins.WithLabelValues(metrics.LoginBlocked).Inc()
+		a.recordLoginAttempt(username, ip, models.LoginResultBlocked, now)
+	}
+	return err
+}
+
+// startSession finishes a login once every factor has been checked
+func (a *AuthService) startSession(user *models.User, client session.Client, now time.Time) (*LoginResult, error) {
+	if user.DisabledAt != nil {
+		metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+		a.recordLoginAttempt(user.Username, client.IP, models.LoginResultFailure, now)
+		return nil, utils.ErrAccountDisabled
+	}
+
+	metrics.Logins.WithLabelValues(metrics.LoginSuccess).Inc()
+	a.recordLoginAttempt(user.Username, client.IP, models.LoginResultSuccess, now)
+	a.Audit.Record(context.Background(), models.AuditEvent{
+		Action:     models.AuditLogin,
+		ActorID:    user.ID,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID,
+		IP:         client.IP,
+		Details:    map[string]string{"username": user.Username},
+		CreatedAt:  now,
+	})
+
 	// Generate session
-	sessionID, err := a.SessionManager.CreateSession(user.Username, user.ID)
+	sessionID, err := a.SessionManager.CreateSession(user.Username, user.ID, client)
 	if err != nil {
-		return "", errors.New("could not create session")
+		return nil, errors.New("could not create session")
 	}
 
-	return sessionID, nil
+	return &LoginResult{
+		SessionID:             sessionID,
+		MFAEnrollmentRequired: a.RequireMFA && !user.MFAEnabled,
+	}, nil
 }
 
 func (a *AuthService) Logout(sessionID string) error {
@@ -71,9 +155,11 @@ func (a *AuthService) Logout(sessionID string) error {
 	return nil
 }
 
-func (a *AuthService) ValidateAuth(sessionID string) error {
-	// Get and validate session
-	_, err := a.SessionManager.GetSession(sessionID)
-
-	return err
+// CSRFToken returns the token state-changing requests on a session must carry
+func (a *AuthService) CSRFToken(sessionID string) (string, error) {
+	session, err := a.SessionManager.GetSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+	return session.CSRFToken, nil
 }
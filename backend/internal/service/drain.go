@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+// Drain stops this node from accepting new joins and asks every socket it
+// holds to reconnect, which the load balancer will route to another node
+func (r *RoomService) Drain(reconnectAfter time.Duration) {
+	r.draining.Store(true)
+
+	msg := models.WebSocketMessage{
+		Type: models.WSMessageTypeServerRestarting,
+		Payload: models.ServerRestartingPayload{
+			Reason:           "server restarting",
+			ReconnectAfterMs: reconnectAfter.Milliseconds(),
+		},
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, connections := range []map[string]map[string]*Connection{r.Connections, r.WaitingRoom} {
+		for roomID, room := range connections {
+			for userID, conn := range room {
+				if err := writeMessage(conn.Conn, msg); err != nil {
+					slog.Warn("Error sending restart notice", "room_id", roomID, "user_id", userID, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// IsDraining reports whether Drain has been called
+func (r *RoomService) IsDraining() bool {
+	return r.draining.Load()
+}
+
+// WaitForDrain blocks until every socket on this node has disconnected or ctx
+// is done, whichever comes first
+func (r *RoomService) WaitForDrain(ctx context.Context) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		stats := r.Stats()
+		if stats.Admitted+stats.Waiting == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("Drain period over", "admitted", stats.Admitted, "waiting", stats.Waiting)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CloseAll closes every socket still held by this node
+func (r *RoomService) CloseAll() {
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting")
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, connections := range []map[string]map[string]*Connection{r.Connections, r.WaitingRoom} {
+		for _, room := range connections {
+			for _, conn := range room {
+				conn.Conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+				conn.Conn.Close()
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/db"
+	"github.com/legendary-acp/chimecast/internal/mailer"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/repositories"
+	"github.com/legendary-acp/chimecast/internal/session"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// newTestDB opens a fresh SQLite database that is removed after the test
+func newTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	database, err := db.Open(db.SQLite, filepath.Join(t.TempDir(), "chimecast.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// newTestRoomService builds a single-node RoomService on a fresh database
+func newTestRoomService(t *testing.T) *RoomService {
+	t.Helper()
+	return newTestRoomServiceOn(t, newTestDB(t))
+}
+
+// newTestRoomServiceOn builds a single-node RoomService on a given database
+func newTestRoomServiceOn(t *testing.T, database *db.DB) *RoomService {
+	t.Helper()
+	roomService, err := NewRoomService(
+		repositories.NewRoomRepository(database),
+		repositories.NewAuthRepository(database),
+		repositories.NewAttendanceRepository(database),
+		bus.NewMemoryBus(),
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("new room service: %v", err)
+	}
+	return roomService
+}
+
+// newTestAuthService builds an AuthService with the given login limits on a
+// given database. Email goes to the log.
+func newTestAuthService(t *testing.T, database *db.DB, limits LoginLimits) *AuthService {
+	t.Helper()
+	return NewAuthService(
+		repositories.NewAuthRepository(database),
+		repositories.NewLoginAttemptRepository(database),
+		repositories.NewTokenRepository(database),
+		repositories.NewAPITokenRepository(database),
+		session.NewSessionManager(time.Hour),
+		mailer.NewLogMailer(),
+		limits,
+		"http://localhost:5173",
+	)
+}
+
+// registerTestUser signs up a user whose password is "password"
+func registerTestUser(t *testing.T, a *AuthService, username string) *models.User {
+	t.Helper()
+	request := &models.RegisterRequest{UserName: username, Name: username, Email: username + "@example.com", Password: "password"}
+	if _, err := a.RegisterUser(request, session.Client{IP: "192.0.2.1"}); err != nil {
+		t.Fatalf("register %s: %v", username, err)
+	}
+	user, err := a.AuthRepository.GetUserByUsername(username)
+	if err != nil {
+		t.Fatalf("look up %s: %v", username, err)
+	}
+	return user
+}
+
+// createTestRoom stores an active room hosted by hostID
+func createTestRoom(t *testing.T, r *RoomService, roomID, hostID string, configure func(*models.Room)) *models.Room {
+	t.Helper()
+	room := &models.Room{
+		ID:                roomID,
+		Name:              roomID,
+		HostID:            hostID,
+		Status:            models.RoomStatusActive,
+		CreatedAt:         time.Now(),
+		ScreenSharePolicy: models.ScreenSharePolicyAnyone,
+		Visibility:        models.RoomVisibilityPublic,
+	}
+	if configure != nil {
+		configure(room)
+	}
+	if err := r.RoomRepository.CreateRoom(room); err != nil {
+		t.Fatalf("create room: %v", err)
+	}
+	return room
+}
+
+// setTestMember puts a participant's presence on the room's bus
+func setTestMember(t *testing.T, r *RoomService, roomID string, member bus.Member) {
+	t.Helper()
+	if member.JoinedAt.IsZero() {
+		member.JoinedAt = time.Now()
+	}
+	if err := r.Bus.SetMember(roomID, member); err != nil {
+		t.Fatalf("set member: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+// EndRoom closes a room: it is marked inactive so nobody can join again,
+// admitted participants are told it ended, and every socket in it is closed
+// wherever it lives
+func (r *RoomService) EndRoom(ctx context.Context, roomID string) error {
+	if err := r.RoomRepository.UpdateRoomStatus(roomID, models.RoomStatusInactive); err != nil {
+		return err
+	}
+
+	r.broadcastToRoom(roomID, models.WebSocketMessage{
+		Type:    models.WSMessageTypeRoomEnded,
+		Payload: map[string]string{"roomId": roomID},
+	}, "")
+
+	members, err := r.Bus.Members(roomID)
+	if err != nil {
+		return err
+	}
+	for userID, member := range members {
+		if err := r.Bus.Publish(bus.Event{
+			Kind:   bus.EventDisconnect,
+			RoomID: roomID,
+			Target: userID,
+			Reason: models.AttendanceEnded,
+		}); err != nil {
+			slog.Error("Error disconnecting participant", "room_id", roomID, "user_id", userID, "error", err)
+		}
+		if err := r.Bus.RemoveMember(roomID, userID, member.ConnectionID); err != nil {
+			slog.Error("Error removing presence", "room_id", roomID, "user_id", userID, "error", err)
+		}
+	}
+
+	r.Audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditRoomEnded,
+		TargetType: models.AuditTargetRoom,
+		TargetID:   roomID,
+		RoomID:     roomID,
+	})
+	r.Webhooks.Publish(models.RoomEvent{Type: models.EventRoomEnded, RoomID: roomID})
+	slog.Info("Room ended", "room_id", roomID)
+	return nil
+}
+
+// KickParticipant removes an admitted participant from a room. They are told
+// why before their socket is closed; they can ask to join again and wait
+// for the host like anyone else.
+func (r *RoomService) KickParticipant(ctx context.Context, roomID, participantID, hostID string) error {
+	if err := r.requireHost(roomID, hostID); err != nil {
+		return err
+	}
+	if participantID == hostID {
+		return errors.New("host cannot kick themselves")
+	}
+
+	members, err := r.Bus.Members(roomID)
+	if err != nil {
+		return err
+	}
+	member, exists := members[participantID]
+	if !exists || member.Status != models.ParticipantStatusAdmitted {
+		return errors.New("participant not found in room")
+	}
+
+	r.sendToUser(roomID, participantID, models.WebSocketMessage{
+		Type:    models.WSMessageTypeKicked,
+		Payload: map[string]string{"roomId": roomID},
+	})
+	r.broadcastToRoom(roomID, models.WebSocketMessage{
+		Type:    models.WSMessageTypeLeave,
+		Payload: map[string]string{"userId": participantID},
+	}, participantID)
+
+	if err := r.Bus.Publish(bus.Event{
+		Kind:   bus.EventDisconnect,
+		RoomID: roomID,
+		Target: participantID,
+		Reason: models.AttendanceKick,
+	}); err != nil {
+		return err
+	}
+	if err := r.Bus.RemoveMember(roomID, participantID, member.ConnectionID); err != nil {
+		return err
+	}
+
+	r.auditParticipant(ctx, models.AuditParticipantKicked, roomID, participantID)
+	slog.Info("Participant kicked", "room_id", roomID, "user_id", participantID)
+	return nil
+}
+
+// auditParticipant records a host's decision about a participant
+func (r *RoomService) auditParticipant(ctx context.Context, action, roomID, participantID string) {
+	r.Audit.Record(ctx, models.AuditEvent{
+		Action:     action,
+		TargetType: models.AuditTargetUser,
+		TargetID:   participantID,
+		RoomID:     roomID,
+	})
+}
+
+// TransferHost hands a room to another user and tells its participants
+func (r *RoomService) TransferHost(ctx context.Context, roomID, newHostID string) error {
+	room, err := r.RoomRepository.GetRoom(roomID)
+	if err != nil {
+		return err
+	}
+	if err := r.RoomRepository.UpdateRoomHost(roomID, newHostID); err != nil {
+		return err
+	}
+	r.Audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditRoomHostChanged,
+		TargetType: models.AuditTargetUser,
+		TargetID:   newHostID,
+		RoomID:     roomID,
+		Details:    map[string]string{"from": room.HostID},
+	})
+
+	r.broadcastToRoom(roomID, models.WebSocketMessage{
+		Type:    models.WSMessageTypeHostChanged,
+		Payload: map[string]string{"roomId": roomID, "hostId": newHostID},
+	}, "")
+
+	slog.Info("Room host changed", "room_id", roomID, "host_id", newHostID)
+	return nil
+}
+
+// ReleaseHostedRooms takes a user's active rooms off them: a room with other
+// admitted participants goes to the one who has been there longest, any
+// other room is ended
+func (r *RoomService) ReleaseHostedRooms(ctx context.Context, hostID string) error {
+	rooms, err := r.RoomRepository.GetRoomsByHost(hostID)
+	if err != nil {
+		return err
+	}
+
+	for _, room := range rooms {
+		if room.Status != models.RoomStatusActive {
+			continue
+		}
+		members, err := r.Bus.Members(room.ID)
+		if err != nil {
+			return err
+		}
+
+		var successor *bus.Member
+		for _, member := range members {
+			if member.UserID == hostID || member.Status != models.ParticipantStatusAdmitted {
+				continue
+			}
+			if successor == nil || member.JoinedAt.Before(successor.JoinedAt) {
+				successor = &member
+			}
+		}
+
+		if successor != nil {
+			err = r.TransferHost(ctx, room.ID, successor.UserID)
+		} else {
+			err = r.EndRoom(ctx, room.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
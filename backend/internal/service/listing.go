@@ -0,0 +1,96 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+const (
+	defaultRoomPageSize = 50
+	maxRoomPageSize     = 200
+)
+
+// ListRooms returns a page of the room list, active rooms first, then newest
+// first. Active rooms carry how many are in them and waiting to be admitted,
+// across every node.
+func (r *RoomService) ListRooms(query models.RoomQuery) (*models.RoomPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = defaultRoomPageSize
+	}
+	query.Limit = min(query.Limit, maxRoomPageSize)
+
+	// One extra row tells us whether there is another page
+	limit := query.Limit
+	query.Limit++
+	rooms, err := r.RoomRepository.ListRooms(query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.RoomPage{Rooms: []models.RoomListing{}}
+	for _, room := range rooms[:min(len(rooms), limit)] {
+		listing := models.RoomListing{Room: room}
+		if room.Status == models.RoomStatusActive {
+			members, err := r.Bus.Members(room.ID)
+			if err != nil {
+				return nil, err
+			}
+			listing.Participants, listing.WaitingCount = countMembers(members)
+		}
+		page.Rooms = append(page.Rooms, listing)
+	}
+	if len(rooms) > limit {
+		page.NextCursor = EncodeRoomCursor(rooms[limit-1])
+	}
+	return page, nil
+}
+
+// countMembers counts who is admitted to a room and who is waiting
+func countMembers(members map[string]bus.Member) (participants, waiting int) {
+	for _, member := range members {
+		if member.Status == models.ParticipantStatusAdmitted {
+			participants++
+		} else {
+			waiting++
+		}
+	}
+	return participants, waiting
+}
+
+// EncodeRoomCursor returns the opaque cursor for the page after room
+func EncodeRoomCursor(room models.Room) string {
+	cursor := fmt.Sprintf("%d.%d.%s", room.Status, room.CreatedAt.UnixNano(), room.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(cursor))
+}
+
+// ParseRoomCursor reads a cursor made by EncodeRoomCursor
+func ParseRoomCursor(cursor string) (*models.RoomCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, utils.ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(decoded), ".", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return nil, utils.ErrInvalidCursor
+	}
+	status, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, utils.ErrInvalidCursor
+	}
+	createdAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, utils.ErrInvalidCursor
+	}
+	return &models.RoomCursor{
+		Status:    status,
+		CreatedAt: time.Unix(0, createdAt),
+		ID:        parts[2],
+	}, nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func TestRoomCursor(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	tests := []struct {
+		name string
+		room models.Room
+	}{
+		{name: "active", room: models.Room{ID: "abc", Status: models.RoomStatusActive, CreatedAt: createdAt}},
+		{name: "ended", room: models.Room{ID: "abc", Status: models.RoomStatusInactive, CreatedAt: createdAt}},
+		{name: "ID with dots", room: models.Room{ID: "a.b.c", Status: models.RoomStatusActive, CreatedAt: createdAt}},
+		{name: "before 1970", room: models.Room{ID: "old", Status: models.RoomStatusActive, CreatedAt: time.Date(1969, 7, 20, 20, 17, 0, 0, time.UTC)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := ParseRoomCursor(EncodeRoomCursor(tt.room))
+			if err != nil {
+				t.Fatalf("ParseRoomCursor() error = %v", err)
+			}
+			if cursor.Status != tt.room.Status || cursor.ID != tt.room.ID || !cursor.CreatedAt.Equal(tt.room.CreatedAt) {
+				t.Errorf("ParseRoomCursor() = %+v, want the position of %+v", cursor, tt.room)
+			}
+		})
+	}
+}
+
+func TestParseRoomCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "empty", cursor: ""},
+		{name: "not base64", cursor: "!!!"},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte("1.0.abc"))},
+		{name: "too few parts", cursor: encode("1.0")},
+		{name: "empty ID", cursor: encode("1.0.")},
+		{name: "status not a number", cursor: encode("x.0.abc")},
+		{name: "time not a number", cursor: encode("1.x.abc")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRoomCursor(tt.cursor); !errors.Is(err, utils.ErrInvalidCursor) {
+				t.Errorf("ParseRoomCursor(%q) error = %v, want %v", tt.cursor, err, utils.ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+// TestListRoomsPages follows NextCursor through the room list and checks
+// every room comes up once, in order, whatever the page size
+func TestListRoomsPages(t *testing.T) {
+	r := newTestRoomService(t)
+	createdAt := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	// Two rooms share a creation time so only the ID orders them
+	rooms := []struct {
+		id     string
+		offset time.Duration
+		status int
+	}{
+		{"a", 0, models.RoomStatusActive},
+		{"b", time.Minute, models.RoomStatusActive},
+		{"c", time.Minute, models.RoomStatusActive},
+		{"d", 2 * time.Minute, models.RoomStatusInactive},
+		{"e", 3 * time.Minute, models.RoomStatusActive},
+	}
+	for _, room := range rooms {
+		createTestRoom(t, r, room.id, "host", func(m *models.Room) {
+			m.CreatedAt = createdAt.Add(room.offset)
+			m.Status = room.status
+		})
+	}
+	setTestMember(t, r, "b", bus.Member{UserID: "alice", Status: models.ParticipantStatusAdmitted})
+	setTestMember(t, r, "b", bus.Member{UserID: "bob", Status: models.ParticipantStatusWaiting})
+	want := []string{"e", "c", "b", "a", "d"}
+
+	tests := []struct {
+		name      string
+		limit     int
+		wantPages int
+	}{
+		{name: "one page", limit: 10, wantPages: 1},
+		{name: "exact fit", limit: 5, wantPages: 1},
+		{name: "pages of two", limit: 2, wantPages: 3},
+		{name: "pages of one", limit: 1, wantPages: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			query := models.RoomQuery{Limit: tt.limit}
+			pages := 0
+			for {
+				page, err := r.ListRooms(query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages++
+				if len(page.Rooms) > tt.limit {
+					t.Fatalf("page of %d rooms, limit %d", len(page.Rooms), tt.limit)
+				}
+				for _, listing := range page.Rooms {
+					got = append(got, listing.ID)
+					if listing.ID == "b" && (listing.Participants != 1 || listing.WaitingCount != 1) {
+						t.Errorf("room b has %d participants and %d waiting, want 1 and 1", listing.Participants, listing.WaitingCount)
+					}
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				if query.After, err = ParseRoomCursor(page.NextCursor); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("rooms = %v, want %v", got, want)
+			}
+			if pages != tt.wantPages {
+				t.Errorf("%d pages, want %d", pages, tt.wantPages)
+			}
+		})
+	}
+}
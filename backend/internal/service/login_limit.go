@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// lockoutMemory is how far back failures count towards a lockout when the
+// account has not logged in successfully since
+const lockoutMemory = 24 * time.Hour
+
+// LoginLimits bounds how often passwords may be tried. Zero limits disable the
+// corresponding check.
+type LoginLimits struct {
+	Window           time.Duration // sliding window for the per-IP and per-username limits
+	PerIP            int           // attempts allowed from one IP per window
+	PerUsername      int           // attempts allowed against one username per window
+	LockoutThreshold int           // consecutive failures before the account is locked
+	LockoutBase      time.Duration // first lockout; doubles with each further failure
+	LockoutMax       time.Duration // longest lockout
+}
+
+// LoginThrottledError is returned when a login is refused before the password
+// is checked. It matches utils.ErrTooManyLoginAttempts.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%v, retry after %v", utils.ErrTooManyLoginAttempts, e.RetryAfter)
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return utils.ErrTooManyLoginAttempts
+}
+
+// checkLoginLimits refuses an attempt that would exceed the sliding windows
+// or that targets a locked account, reporting the longest wait that applies
+func (a *AuthService) checkLoginLimits(username, ip string, now time.Time) error {
+	limits := a.LoginLimits
+	var retryAfter time.Duration
+
+	if limits.PerIP > 0 {
+		attempts, err := a.LoginAttemptRepository.AttemptsFromIP(ip, now.Add(-limits.Window))
+		if err != nil {
+			return err
+		}
+		retryAfter = max(retryAfter, windowRetryAfter(attempts, limits.PerIP, limits.Window, now))
+	}
+
+	if limits.PerUsername > 0 {
+		attempts, err := a.LoginAttemptRepository.AttemptsForUsername(username, now.Add(-limits.Window))
+		if err != nil {
+			return err
+		}
+		retryAfter = max(retryAfter, windowRetryAfter(attempts, limits.PerUsername, limits.Window, now))
+	}
+
+	if limits.LockoutThreshold > 0 {
+		failures, err := a.LoginAttemptRepository.FailuresSinceLastSuccess(username, now.Add(-lockoutMemory))
+		if err != nil {
+			return err
+		}
+		if len(failures) >= limits.LockoutThreshold {
+			lockedUntil := failures[len(failures)-1].Add(lockoutDuration(len(failures)-limits.LockoutThreshold, limits))
+			retryAfter = max(retryAfter, lockedUntil.Sub(now))
+		}
+	}
+
+	if retryAfter > 0 {
+		slog.Warn("Login throttled", "username", username, "ip", ip, "retry_after", retryAfter)
+		return &LoginThrottledError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// windowRetryAfter returns how long until fewer than limit of the given
+// attempts, oldest first, fall inside the window
+func windowRetryAfter(attempts []time.Time, limit int, window time.Duration, now time.Time) time.Duration {
+	if len(attempts) < limit {
+		return 0
+	}
+	return attempts[len(attempts)-limit].Add(window).Sub(now)
+}
+
+// lockoutDuration doubles the base lockout for every failure past the
+// threshold, up to the configured maximum
+func lockoutDuration(extraFailures int, limits LoginLimits) time.Duration {
+	lockout := limits.LockoutBase
+	for i := 0; i < extraFailures && lockout < limits.LockoutMax; i++ {
+		lockout *= 2
+	}
+	return min(lockout, limits.LockoutMax)
+}
+
+// recordLoginAttempt keeps the attempt for the limits above and later review.
+// A failure to record is logged rather than failing the login.
+func (a *AuthService) recordLoginAttempt(username, ip, result string, at time.Time) {
+	err := a.LoginAttemptRepository.RecordLoginAttempt(models.LoginAttempt{
+		Username:    username,
+		IP:          ip,
+		Result:      result,
+		AttemptedAt: at,
+	})
+	if err != nil {
+		slog.Error("Error recording login attempt", "username", username, "error", err)
+	}
+
+	if result == models.LoginResultFailure || result == models.LoginResultBlocked {
+		a.Audit.Record(context.Background(), models.AuditEvent{
+			Action:     models.AuditLoginFailed,
+			TargetType: models.AuditTargetUsername,
+			TargetID:   username,
+			IP:         ip,
+			Details:    map[string]string{"result": result},
+			CreatedAt:  at,
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/session"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+func TestWindowRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+
+	tests := []struct {
+		name     string
+		attempts []time.Time
+		limit    int
+		want     time.Duration
+	}{
+		{name: "no attempts", limit: 3},
+		{name: "under the limit", attempts: []time.Time{ago(time.Minute), ago(30 * time.Second)}, limit: 3},
+		{name: "at the limit waits for the oldest to expire", attempts: []time.Time{ago(10 * time.Minute), ago(5 * time.Minute), ago(time.Minute)}, limit: 3, want: 5 * time.Minute},
+		{name: "over the limit waits for enough to expire", attempts: []time.Time{ago(14 * time.Minute), ago(12 * time.Minute), ago(2 * time.Minute), ago(time.Minute)}, limit: 3, want: 3 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := windowRetryAfter(tt.attempts, tt.limit, 15*time.Minute, now); got != tt.want {
+				t.Errorf("windowRetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockoutDuration(t *testing.T) {
+	limits := LoginLimits{LockoutBase: 30 * time.Second, LockoutMax: 5 * time.Minute}
+
+	tests := []struct {
+		extraFailures int
+		want          time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 5 * time.Minute},
+		{40, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := lockoutDuration(tt.extraFailures, limits); got != tt.want {
+			t.Errorf("lockoutDuration(%d) = %v, want %v", tt.extraFailures, got, tt.want)
+		}
+	}
+}
+
+func TestCheckLoginLimits(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	limits := LoginLimits{
+		Window:           15 * time.Minute,
+		PerIP:            5,
+		PerUsername:      4,
+		LockoutThreshold: 3,
+		LockoutBase:      time.Minute,
+		LockoutMax:       10 * time.Minute,
+	}
+
+	type attempt struct {
+		username, ip, result string
+		ago                  time.Duration
+	}
+	failure := func(username, ip string, ago time.Duration) attempt {
+		return attempt{username, ip, models.LoginResultFailure, ago}
+	}
+	lockoutOnly := LoginLimits{LockoutThreshold: 3, LockoutBase: time.Minute, LockoutMax: 10 * time.Minute}
+
+	tests := []struct {
+		name      string
+		limits    LoginLimits
+		history   []attempt
+		username  string
+		ip        string
+		wantRetry time.Duration // zero when the attempt is allowed
+	}{
+		{name: "first attempt", username: "alice", ip: "a"},
+		{
+			name:     "failures below the lockout threshold",
+			history:  []attempt{failure("alice", "a", 2*time.Minute), failure("alice", "a", time.Minute)},
+			username: "alice", ip: "a",
+		},
+		{
+			name:     "locked at the threshold",
+			history:  []attempt{failure("alice", "a", 3*time.Minute), failure("alice", "b", 2*time.Minute), failure("alice", "c", 30*time.Second)},
+			username: "alice", ip: "d",
+			wantRetry: 30 * time.Second,
+		},
+		{
+			name:   "each further failure doubles the lockout",
+			limits: lockoutOnly,
+			history: []attempt{
+				failure("alice", "a", 10*time.Minute), failure("alice", "b", 9*time.Minute),
+				failure("alice", "c", 8*time.Minute), failure("alice", "d", 30*time.Second),
+			},
+			username: "alice", ip: "e",
+			wantRetry: 90 * time.Second,
+		},
+		{
+			name:     "lockout expires",
+			history:  []attempt{failure("alice", "a", 4*time.Minute), failure("alice", "b", 3*time.Minute), failure("alice", "c", 2*time.Minute)},
+			username: "alice", ip: "d",
+		},
+		{
+			name:   "a success resets the failure count",
+			limits: lockoutOnly,
+			history: []attempt{
+				failure("alice", "a", 3*time.Minute), failure("alice", "a", 2*time.Minute),
+				{"alice", "a", models.LoginResultSuccess, 90 * time.Second}, failure("alice", "a", time.Minute),
+			},
+			username: "alice", ip: "a",
+		},
+		{
+			name:     "other users' failures don't lock an account",
+			history:  []attempt{failure("bob", "x", 3*time.Minute), failure("carol", "y", 2*time.Minute), failure("dave", "z", time.Minute)},
+			username: "alice", ip: "a",
+		},
+		{
+			name: "per-IP limit across usernames",
+			history: []attempt{
+				failure("u1", "a", 14*time.Minute), failure("u2", "a", 10*time.Minute), failure("u3", "a", 5*time.Minute),
+				failure("u4", "a", 2*time.Minute), failure("u5", "a", time.Minute),
+			},
+			username: "alice", ip: "a",
+			wantRetry: time.Minute,
+		},
+		{
+			name:   "per-username limit across IPs",
+			limits: LoginLimits{Window: 15 * time.Minute, PerUsername: 4},
+			history: []attempt{
+				failure("alice", "a", 13*time.Minute), failure("alice", "b", 12*time.Minute),
+				failure("alice", "c", 11*time.Minute), failure("alice", "d", 10*time.Minute),
+			},
+			username: "alice", ip: "e",
+			wantRetry: 2 * time.Minute,
+		},
+		{
+			name:   "zero limits disable every check",
+			limits: LoginLimits{Window: 15 * time.Minute},
+			history: []attempt{
+				failure("alice", "a", 4*time.Minute), failure("alice", "a", 3*time.Minute),
+				failure("alice", "a", 2*time.Minute), failure("alice", "a", time.Minute),
+				failure("alice", "a", 30*time.Second), failure("alice", "a", 10*time.Second),
+			},
+			username: "alice", ip: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testLimits := limits
+			if tt.limits != (LoginLimits{}) {
+				testLimits = tt.limits
+			}
+			a := newTestAuthService(t, newTestDB(t), testLimits)
+			for _, h := range tt.history {
+				err := a.LoginAttemptRepository.RecordLoginAttempt(models.LoginAttempt{
+					Username: h.username, IP: h.ip, Result: h.result, AttemptedAt: now.Add(-h.ago),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := a.checkLoginLimits(tt.username, tt.ip, now)
+			if tt.wantRetry == 0 {
+				if err != nil {
+					t.Fatalf("checkLoginLimits() error = %v, want allowed", err)
+				}
+				return
+			}
+			var throttled *LoginThrottledError
+			if !errors.As(err, &throttled) || !errors.Is(err, utils.ErrTooManyLoginAttempts) {
+				t.Fatalf("checkLoginLimits() error = %v, want LoginThrottledError", err)
+			}
+			if throttled.RetryAfter != tt.wantRetry {
+				t.Errorf("RetryAfter = %v, want %v", throttled.RetryAfter, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestLoginLocksAccount(t *testing.T) {
+	a := newTestAuthService(t, newTestDB(t), LoginLimits{
+		Window:           15 * time.Minute,
+		LockoutThreshold: 3,
+		LockoutBase:      time.Minute,
+		LockoutMax:       time.Hour,
+	})
+	registerTestUser(t, a, "alice")
+	client := session.Client{IP: "192.0.2.10"}
+
+	for i := 0; i < 3; i++ {
+		_, err := a.Login(&models.LoginRequest{UserName: "alice", Password: "wrong"}, client)
+		if err == nil || errors.Is(err, utils.ErrTooManyLoginAttempts) {
+			t.Fatalf("attempt %d: error = %v, want invalid credentials", i+1, err)
+		}
+	}
+
+	// Even the right password is refused while locked, without being checked
+	_, err := a.Login(&models.LoginRequest{UserName: "alice", Password: "password"}, client)
+	if !errors.Is(err, utils.ErrTooManyLoginAttempts) {
+		t.Fatalf("login while locked: error = %v, want ErrTooManyLoginAttempts", err)
+	}
+
+	history, err := a.LoginAttemptRepository.LoginHistory("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := map[string]int{}
+	for _, attempt := range history {
+		results[attempt.Result]++
+	}
+	if results[models.LoginResultFailure] != 3 || results[models.LoginResultBlocked] != 1 {
+		t.Errorf("login history results = %v, want 3 failures and 1 blocked", results)
+	}
+}
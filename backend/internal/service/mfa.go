@@ -0,0 +1,222 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/metrics"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/session"
+	"github.com/legendary-acp/chimecast/internal/totp"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+const (
+	mfaIssuer          = "ChimeCast"
+	mfaPendingTokenTTL = 5 * time.Minute
+	recoveryCodeCount  = 10
+)
+
+// MFAEnrollment is what an authenticator app needs to start producing codes
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"` // render as a QR code
+}
+
+// CompleteMFALogin finishes a login that Login answered with an MFA token.
+// Wrong codes count as failed logins, so they are throttled and lead to
+// lockout like wrong passwords. The token survives wrong codes until it
+// expires.
+func (a *AuthService) CompleteMFALogin(request *models.MFALoginRequest, client session.Client) (*LoginResult, error) {
+	now := time.Now()
+	tokenHash := utils.HashToken(request.MFAToken)
+	userID, err := a.TokenRepository.TokenUser(tokenHash, models.TokenPurposeMFAPending, now)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.throttleLogin(user.Username, client.IP, now); err != nil {
+		return nil, err
+	}
+
+	if err := a.checkSecondFactor(user, request.Code, now); err != nil {
+		if errors.Is(err, utils.ErrInvalidMFACode) {
+			metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+			a.recordLoginAttempt(user.Username, client.IP, models.LoginResultFailure, now)
+		}
+		return nil, err
+	}
+
+	if _, err := a.TokenRepository.ConsumeToken(tokenHash, models.TokenPurposeMFAPending, now); err != nil {
+		return nil, err
+	}
+	return a.startSession(user, client, now)
+}
+
+// BeginMFAEnrollment creates a new authenticator secret for the user. It only
+// takes effect once confirmed with a code.
+func (a *AuthService) BeginMFAEnrollment(userID string) (*MFAEnrollment, error) {
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.AuthRepository.SetTOTPSecret(userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(secret, mfaIssuer, user.Username),
+	}, nil
+}
+
+// ConfirmMFAEnrollment turns MFA on once the user proves their authenticator
+// works, and returns recovery codes to be shown exactly once
+func (a *AuthService) ConfirmMFAEnrollment(userID, code string) ([]string, error) {
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("two-factor enrolment has not been started")
+	}
+
+	if err := a.checkTOTP(user, code, time.Now()); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.AuthRepository.EnableMFA(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Two-factor authentication enabled", "user_id", userID)
+	return codes, nil
+}
+
+// DisableMFA turns MFA off after checking a current code. Not allowed while
+// MFA is mandatory.
+func (a *AuthService) DisableMFA(userID, code string) error {
+	if a.RequireMFA {
+		return errors.New("two-factor authentication is required on this server")
+	}
+
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.MFAEnabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+	if err := a.checkSecondFactor(user, code, time.Now()); err != nil {
+		return err
+	}
+
+	if err := a.AuthRepository.DisableMFA(userID); err != nil {
+		return err
+	}
+	slog.Info("Two-factor authentication disabled", "user_id", userID)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking a
+// current code
+func (a *AuthService) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.MFAEnabled {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+	if err := a.checkSecondFactor(user, code, time.Now()); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.AuthRepository.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// IsMFAEnrolled reports whether a user has a confirmed second factor
+func (a *AuthService) IsMFAEnrolled(userID string) (bool, error) {
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return false, err
+	}
+	return user.MFAEnabled, nil
+}
+
+// checkSecondFactor accepts either an authenticator code or an unused
+// recovery code
+func (a *AuthService) checkSecondFactor(user *models.User, code string, now time.Time) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return a.checkTOTP(user, code, now)
+	}
+	return a.AuthRepository.ConsumeRecoveryCode(user.ID, utils.HashToken(normalizeRecoveryCode(code)))
+}
+
+// checkTOTP validates an authenticator code and burns its time step
+func (a *AuthService) checkTOTP(user *models.User, code string, now time.Time) error {
+	step, ok := totp.Validate(user.TOTPSecret, code, now)
+	if !ok {
+		return utils.ErrInvalidMFACode
+	}
+	return a.AuthRepository.AdvanceTOTPStep(user.ID, step)
+}
+
+// newRecoveryCodes returns fresh codes, formatted like "abcd-efgh", and the
+// hashes to store for them
+func newRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))
+		codes[i] = raw[:4] + "-" + raw[4:]
+		hashes[i] = utils.HashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes in typed codes
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+}
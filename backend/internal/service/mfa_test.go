@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/session"
+	"github.com/legendary-acp/chimecast/internal/totp"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// enrolTestUser registers a user with MFA enabled, returning the user, their
+// secret and recovery codes. The current step is used up by the enrolment.
+func enrolTestUser(t *testing.T, a *AuthService, username string) (*models.User, string, []string) {
+	t.Helper()
+	user := registerTestUser(t, a, username)
+	enrollment, err := a.BeginMFAEnrollment(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stay clear of a step boundary so the confirmation burns this step
+	if untilNext := totp.Period - time.Duration(time.Now().UnixNano())%totp.Period; untilNext < time.Second {
+		time.Sleep(untilNext)
+	}
+	code, err := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recoveryCodes, err := a.ConfirmMFAEnrollment(user.ID, code)
+	if err != nil {
+		t.Fatalf("ConfirmMFAEnrollment() error = %v", err)
+	}
+	return user, enrollment.Secret, recoveryCodes
+}
+
+func TestCheckSecondFactor(t *testing.T) {
+	a := newTestAuthService(t, newTestDB(t), LoginLimits{})
+	user, secret, recoveryCodes := enrolTestUser(t, a, "alice")
+	now := time.Now() // in the step the enrolment used
+	code := func(offset int64) string {
+		c, err := totp.Code(secret, totp.Step(now)+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	spaced := strings.ToUpper(strings.ReplaceAll(recoveryCodes[1], "-", " "))
+
+	// Steps run in order against the same user, so burned codes stay burned
+	steps := []struct {
+		name    string
+		code    string
+		wantErr bool
+	}{
+		{name: "the enrolment's step can't be replayed", code: code(0), wantErr: true},
+		{name: "the next step is accepted", code: code(1)},
+		{name: "that step can't be replayed", code: code(1), wantErr: true},
+		{name: "earlier steps are refused once a later one is used", code: code(-1), wantErr: true},
+		{name: "recovery code", code: recoveryCodes[0]},
+		{name: "recovery codes work once", code: recoveryCodes[0], wantErr: true},
+		{name: "recovery codes ignore case, spaces and dashes", code: spaced},
+		{name: "unknown recovery code", code: "aaaa-bbbb", wantErr: true},
+		{name: "garbage", code: "12", wantErr: true},
+	}
+
+	for _, step := range steps {
+		fresh, err := a.AuthRepository.GetUserByID(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = a.checkSecondFactor(fresh, step.code, now)
+		if (err != nil) != step.wantErr {
+			t.Errorf("%s: checkSecondFactor() error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+	}
+}
+
+func TestTwoStepLogin(t *testing.T) {
+	a := newTestAuthService(t, newTestDB(t), LoginLimits{
+		Window:           15 * time.Minute,
+		LockoutThreshold: 3,
+		LockoutBase:      time.Minute,
+		LockoutMax:       time.Hour,
+	})
+	_, _, recoveryCodes := enrolTestUser(t, a, "alice")
+	client := session.Client{IP: "192.0.2.20"}
+
+	result, err := a.Login(&models.LoginRequest{UserName: "alice", Password: "password"}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SessionID != "" || result.MFAToken == "" {
+		t.Fatalf("Login() = %+v, want only an MFA token", result)
+	}
+
+	// Wrong codes count towards the lockout, and the token survives them
+	for i := 0; i < 2; i++ {
+		_, err := a.CompleteMFALogin(&models.MFALoginRequest{MFAToken: result.MFAToken, Code: "000000"}, client)
+		if !errors.Is(err, utils.ErrInvalidMFACode) {
+			t.Fatalf("wrong code %d: error = %v, want ErrInvalidMFACode", i+1, err)
+		}
+	}
+
+	done, err := a.CompleteMFALogin(&models.MFALoginRequest{MFAToken: result.MFAToken, Code: recoveryCodes[0]}, client)
+	if err != nil {
+		t.Fatalf("CompleteMFALogin() error = %v", err)
+	}
+	if done.SessionID == "" {
+		t.Fatal("CompleteMFALogin() started no session")
+	}
+
+	// The token is single use
+	if _, err := a.CompleteMFALogin(&models.MFALoginRequest{MFAToken: result.MFAToken, Code: recoveryCodes[1]}, client); err == nil {
+		t.Error("MFA token was accepted twice")
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func NewPrivacyService(authService *AuthService, roomService *RoomService, webhookService *WebhookService) *PrivacyService {
+	return &PrivacyService{
+		AuthService: authService,
+		RoomService: roomService,
+		Webhooks:    webhookService,
+	}
+}
+
+// Export gathers everything stored about a user into a ZIP of JSON files,
+// plus their avatar. Chat messages are relayed between peers and never
+// stored, so there are none to include.
+func (p *PrivacyService) Export(userID string) ([]byte, error) {
+	auth := p.AuthService
+	user, err := auth.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rooms, err := p.RoomService.RoomRepository.GetRoomsByHost(userID)
+	if err != nil {
+		return nil, err
+	}
+	invitations, err := p.RoomService.RoomRepository.UserInvitations(userID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := auth.AuthRepository.ListIdentities(userID)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := auth.APITokenRepository.ListAPITokens(userID)
+	if err != nil {
+		return nil, err
+	}
+	logins, err := auth.LoginAttemptRepository.LoginHistory(user.Username)
+	if err != nil {
+		return nil, err
+	}
+	attendance, err := p.RoomService.AttendanceRepository.UserAttendance(userID)
+	if err != nil {
+		return nil, err
+	}
+	webhooks, err := p.Webhooks.ListWebhooks(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", profileOf(user)},
+		{"rooms_hosted.json", rooms},
+		{"room_invitations.json", invitations},
+		{"linked_identities.json", identities},
+		{"api_tokens.json", tokens},
+		{"sessions.json", auth.SessionManager.UserSessions(userID)},
+		{"login_history.json", logins},
+		{"attendance.json", attendance},
+		{"webhooks.json", webhooks},
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	now := time.Now()
+	for _, file := range files {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+	}
+
+	if user.AvatarName != "" {
+		if err := addAvatar(archive, auth, user.AvatarName, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func addAvatar(archive *zip.Writer, auth *AuthService, name string, modified time.Time) error {
+	f, _, err := auth.Avatars.Open(name)
+	if err != nil {
+		// A missing file shouldn't block the rest of the export
+		slog.Warn("Error opening avatar for export", "name", name, "error", err)
+		return nil
+	}
+	defer f.Close()
+
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: "avatar.png", Method: zip.Store, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// DeleteAccount erases a user. The account is anonymized rather than
+// removed so rooms keep a valid host reference; their live rooms are handed
+// on or ended, and every session is revoked.
+func (p *PrivacyService) DeleteAccount(ctx context.Context, userID string, request *models.DeleteAccountRequest) error {
+	auth := p.AuthService
+	user, err := auth.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.HashedPassword != "" {
+		if bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(request.Password)) != nil {
+			return utils.ErrIncorrectPassword
+		}
+	} else if request.Confirm != user.Username {
+		return utils.ErrConfirmationMismatch
+	}
+
+	if err := auth.AuthRepository.AnonymizeUser(*user, time.Now()); err != nil {
+		return err
+	}
+	auth.deleteAvatar(user.AvatarName)
+
+	// The account is already gone; a room that can't be released is left for
+	// the host-less cleanup rather than failing the erasure
+	if err := p.RoomService.ReleaseHostedRooms(ctx, userID); err != nil {
+		slog.Error("Error releasing rooms of deleted user", "user_id", userID, "error", err)
+	}
+	auth.SessionManager.DeleteUserSessions(userID)
+	return nil
+}
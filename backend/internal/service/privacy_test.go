@@ -0,0 +1,336 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/repositories"
+	"github.com/legendary-acp/chimecast/internal/session"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// privacyFixture is a user with something stored in every place an export
+// reads from or an erasure clears
+type privacyFixture struct {
+	p        *PrivacyService
+	user     *models.User
+	other    *models.User
+	cookie   string
+	apiToken string
+	secrets  map[string]string // what each secret is, by its value
+}
+
+func newPrivacyFixture(t *testing.T) *privacyFixture {
+	t.Helper()
+	database := newTestDB(t)
+	auth := newTestAuthService(t, database, LoginLimits{})
+	rooms := newTestRoomServiceOn(t, database)
+	webhooks := NewWebhookService(repositories.NewWebhookRepository(database), repositories.NewRoomRepository(database), WebhookSettings{})
+	f := &privacyFixture{
+		p:     NewPrivacyService(auth, rooms, webhooks),
+		user:  registerTestUser(t, auth, "alice"),
+		other: registerTestUser(t, auth, "bob"),
+	}
+
+	cookie, err := auth.SessionManager.CreateSession(f.user.Username, f.user.ID, session.Client{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := auth.SessionManager.GetSession(cookie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiToken, _, err := auth.CreateAPIToken(f.user.ID, &models.CreateAPITokenRequest{Name: "deploy bot", Scopes: []string{models.ScopeRoomsRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := auth.APITokenRepository.GetAPITokenByHash(utils.HashToken(apiToken), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetToken, err := auth.issueToken(f.user.ID, models.TokenPurposeResetPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.AuthRepository.SetTOTPSecret(f.user.ID, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.AuthRepository.EnableMFA(f.user.ID, []string{utils.HashToken("recovery-code")}); err != nil {
+		t.Fatal(err)
+	}
+	identity := models.UserIdentity{Issuer: "https://idp.example.com", Subject: "alice-subject", UserID: f.user.ID, Email: "alice@example.com", CreatedAt: time.Now()}
+	if err := auth.AuthRepository.LinkIdentity(identity); err != nil {
+		t.Fatal(err)
+	}
+	webhook := models.Webhook{ID: "hook", UserID: f.user.ID, URL: "https://hooks.example.com/alice", Secret: "whsec-alice",
+		Events: models.WebhookEvents, CreatedAt: time.Now()}
+	if err := webhooks.WebhookRepository.CreateWebhook(webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	// alice hosts a busy and an empty room, and is invited to bob's
+	createTestRoom(t, rooms, "standup", f.user.ID, nil)
+	createTestRoom(t, rooms, "lonely", f.user.ID, nil)
+	createTestRoom(t, rooms, "retro", f.other.ID, func(room *models.Room) { room.Visibility = models.RoomVisibilityPrivate })
+	if _, err := rooms.Invite(context.Background(), "retro", f.other.ID, f.user.Username); err != nil {
+		t.Fatal(err)
+	}
+	setTestMember(t, rooms, "standup", bus.Member{UserID: f.user.ID, Status: models.ParticipantStatusAdmitted})
+	setTestMember(t, rooms, "standup", bus.Member{UserID: f.other.ID, Status: models.ParticipantStatusAdmitted})
+
+	f.cookie = cookie
+	f.apiToken = apiToken
+	f.secrets = map[string]string{
+		f.user.HashedPassword:            "password hash",
+		"JBSWY3DPEHPK3PXP":               "TOTP secret",
+		utils.HashToken("recovery-code"): "recovery code hash",
+		"whsec-alice":                    "webhook secret",
+		apiToken:                         "API token",
+		stored.TokenHash:                 "API token hash",
+		resetToken:                       "reset token",
+		utils.HashToken(resetToken):      "reset token hash",
+		cookie:                           "session cookie",
+		current.CSRFToken:                "CSRF token",
+	}
+	return f
+}
+
+func TestExport(t *testing.T) {
+	f := newPrivacyFixture(t)
+	data, err := f.p.Export(f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[file.Name] = string(content)
+	}
+
+	t.Run("sections", func(t *testing.T) {
+		tests := []struct {
+			file string
+			want string
+		}{
+			{file: "profile.json", want: `"username": "alice"`},
+			{file: "rooms_hosted.json", want: `"standup"`},
+			{file: "room_invitations.json", want: `"retro"`},
+			{file: "linked_identities.json", want: "alice-subject"},
+			{file: "api_tokens.json", want: "deploy bot"},
+			{file: "sessions.json", want: "192.0.2.1"},
+			{file: "login_history.json", want: "["},
+			{file: "attendance.json", want: "["},
+			{file: "webhooks.json", want: "https://hooks.example.com/alice"},
+		}
+		var names []string
+		for _, tt := range tests {
+			names = append(names, tt.file)
+			if content, ok := files[tt.file]; !ok {
+				t.Errorf("%s missing", tt.file)
+			} else if !strings.Contains(content, tt.want) {
+				t.Errorf("%s = %s, want it to contain %s", tt.file, content, tt.want)
+			}
+		}
+		for name := range files {
+			if !slices.Contains(names, name) {
+				t.Errorf("unexpected file %s", name)
+			}
+		}
+	})
+
+	t.Run("no secrets", func(t *testing.T) {
+		for secret, what := range f.secrets {
+			for name, content := range files {
+				if strings.Contains(content, secret) {
+					t.Errorf("%s contains the %s", name, what)
+				}
+			}
+		}
+	})
+}
+
+func TestDeleteAccountConfirmation(t *testing.T) {
+	tests := []struct {
+		name     string
+		password bool
+		request  models.DeleteAccountRequest
+		wantErr  error
+	}{
+		{name: "wrong password", password: true, request: models.DeleteAccountRequest{Password: "guess"}, wantErr: utils.ErrIncorrectPassword},
+		{name: "username instead of the password", password: true, request: models.DeleteAccountRequest{Confirm: "alice"}, wantErr: utils.ErrIncorrectPassword},
+		{name: "password", password: true, request: models.DeleteAccountRequest{Password: "password"}},
+		{name: "single sign-on user with the wrong username", request: models.DeleteAccountRequest{Confirm: "bob"}, wantErr: utils.ErrConfirmationMismatch},
+		{name: "single sign-on user with their username", request: models.DeleteAccountRequest{Confirm: "alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDB(t)
+			auth := newTestAuthService(t, database, LoginLimits{})
+			p := NewPrivacyService(auth, newTestRoomServiceOn(t, database), nil)
+			user := registerTestUser(t, auth, "alice")
+			if !tt.password {
+				if err := auth.AuthRepository.UpdatePassword(user.ID, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := p.DeleteAccount(context.Background(), user.ID, &tt.request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteAccount() error = %v, want %v", err, tt.wantErr)
+			}
+			got, err := auth.AuthRepository.GetUserByID(user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted := got.DeletedAt != nil; deleted != (tt.wantErr == nil) {
+				t.Errorf("deleted = %v, want %v", deleted, tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	f := newPrivacyFixture(t)
+	auth, rooms := f.p.AuthService, f.p.RoomService
+	if err := f.p.DeleteAccount(context.Background(), f.user.ID, &models.DeleteAccountRequest{Password: "password"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		check func() error
+	}{
+		{
+			name: "anonymized",
+			check: func() error {
+				user, err := auth.AuthRepository.GetUserByID(f.user.ID)
+				if err != nil {
+					return err
+				}
+				if user.Username != "deleted-"+f.user.ID || user.Email == f.user.Email || user.Name != "Deleted user" ||
+					user.HashedPassword != "" || user.TOTPSecret != "" || user.MFAEnabled || user.DeletedAt == nil {
+					return errors.New("personal data left on the account")
+				}
+				return nil
+			},
+		},
+		{
+			name: "username is free again",
+			check: func() error {
+				if _, err := auth.AuthRepository.GetUserByUsername("alice"); !errors.Is(err, utils.ErrUserNotFound) {
+					return errors.New("alice still resolves")
+				}
+				return nil
+			},
+		},
+		{
+			name: "identities unlinked",
+			check: func() error {
+				if identities, _ := auth.AuthRepository.ListIdentities(f.user.ID); len(identities) != 0 {
+					return errors.New("identities left")
+				}
+				if _, err := auth.AuthRepository.GetUserByIdentity("https://idp.example.com", "alice-subject"); err == nil {
+					return errors.New("the identity still signs in")
+				}
+				return nil
+			},
+		},
+		{
+			name: "API tokens revoked",
+			check: func() error {
+				if _, err := auth.AuthenticateAPIToken(f.apiToken); err == nil {
+					return errors.New("the API token still works")
+				}
+				return nil
+			},
+		},
+		{
+			name: "webhooks removed",
+			check: func() error {
+				if webhooks, _ := f.p.Webhooks.ListWebhooks(f.user.ID); len(webhooks) != 0 {
+					return errors.New("webhooks left")
+				}
+				return nil
+			},
+		},
+		{
+			name: "invitations withdrawn",
+			check: func() error {
+				if invitations, _ := rooms.RoomRepository.UserInvitations(f.user.ID); len(invitations) != 0 {
+					return errors.New("invitations left")
+				}
+				return nil
+			},
+		},
+		{
+			name: "sessions ended",
+			check: func() error {
+				if _, err := auth.SessionManager.GetSession(f.cookie); err == nil {
+					return errors.New("the session still works")
+				}
+				return nil
+			},
+		},
+		{
+			name: "busy room handed on",
+			check: func() error {
+				room, err := rooms.RoomRepository.GetRoom("standup")
+				if err != nil {
+					return err
+				}
+				if room.HostID != f.other.ID || room.Status != models.RoomStatusActive {
+					return errors.New("standup was not handed to bob")
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty room ended",
+			check: func() error {
+				room, err := rooms.RoomRepository.GetRoom("lonely")
+				if err != nil {
+					return err
+				}
+				if room.Status == models.RoomStatusActive {
+					return errors.New("lonely is still active")
+				}
+				return nil
+			},
+		},
+		{
+			name: "others untouched",
+			check: func() error {
+				_, err := auth.AuthRepository.GetUserByUsername("bob")
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.check(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/legendary-acp/chimecast/internal/avatar"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/session"
+	"github.com/legendary-acp/chimecast/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const maxDisplayName = 100
+
+// languagePattern accepts BCP 47 tags such as "en", "pt-BR" or "zh-Hant-TW"
+var languagePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// GetProfile returns the user's own profile
+func (a *AuthService) GetProfile(userID string) (*models.Profile, error) {
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return profileOf(user), nil
+}
+
+// UpdateProfile changes the fields present in the request. A new email
+// address has to be verified again.
+func (a *AuthService) UpdateProfile(userID string, request *models.UpdateProfileRequest) (*models.Profile, error) {
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" || len(name) > maxDisplayName {
+			return nil, fmt.Errorf("name must be between 1 and %d characters", maxDisplayName)
+		}
+		user.Name = name
+	}
+	if request.PreferredLanguage != nil {
+		language := strings.TrimSpace(*request.PreferredLanguage)
+		if language != "" && (len(language) > 35 || !languagePattern.MatchString(language)) {
+			return nil, errors.New(`preferredLanguage must be a language tag such as "en" or "pt-BR"`)
+		}
+		user.PreferredLanguage = language
+	}
+	emailChanged := false
+	if request.Email != nil {
+		email := strings.TrimSpace(*request.Email)
+		if _, err := mail.ParseAddress(email); err != nil {
+			return nil, errors.New("email is not a valid address")
+		}
+		if !strings.EqualFold(email, user.Email) {
+			user.Email = email
+			user.EmailVerified = false
+			emailChanged = true
+		}
+	}
+
+	if err := a.AuthRepository.UpdateProfile(*user); err != nil {
+		return nil, err
+	}
+	if emailChanged {
+		if err := a.sendVerificationEmail(user); err != nil {
+			slog.Error("Error sending verification email", "user", user, "error", err)
+		}
+	}
+	return profileOf(user), nil
+}
+
+// ChangePassword sets a new password after checking the current one. Every
+// session and API token of the user is revoked, and a fresh session is
+// started for the client that made the change; its ID is returned.
+func (a *AuthService) ChangePassword(userID string, request *models.ChangePasswordRequest, client session.Client) (string, error) {
+	if request.NewPassword == "" {
+		return "", errors.New("password cannot be empty")
+	}
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return "", err
+	}
+	// Accounts created through single sign-on have no password to check;
+	// they set one with the reset flow, which proves control of the email
+	if user.HashedPassword == "" ||
+		bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(request.CurrentPassword)) != nil {
+		return "", utils.ErrIncorrectPassword
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	if err := a.AuthRepository.UpdatePassword(userID, string(hashedPassword)); err != nil {
+		return "", err
+	}
+
+	// Whoever knew the old password may have minted tokens with it
+	a.SessionManager.DeleteUserSessions(userID)
+	if err := a.revokeUserAPITokens(userID); err != nil {
+		return "", err
+	}
+	sessionID, err := a.SessionManager.CreateSession(user.Username, user.ID, client)
+	if err != nil {
+		return "", errors.New("could not create session")
+	}
+	return sessionID, nil
+}
+
+// SetAvatar resizes an uploaded picture and makes it the user's avatar
+func (a *AuthService) SetAvatar(userID string, upload io.Reader) (*models.Profile, error) {
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := avatar.Process(upload)
+	if err != nil {
+		return nil, err
+	}
+	name, err := avatar.NewName(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Avatars.Save(name, data); err != nil {
+		return nil, fmt.Errorf("failed to store avatar: %w", err)
+	}
+	if err := a.AuthRepository.SetAvatar(userID, name); err != nil {
+		a.Avatars.Delete(name)
+		return nil, err
+	}
+
+	a.deleteAvatar(user.AvatarName)
+	user.AvatarName = name
+	return profileOf(user), nil
+}
+
+// RemoveAvatar clears the user's avatar
+func (a *AuthService) RemoveAvatar(userID string) error {
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := a.AuthRepository.SetAvatar(userID, ""); err != nil {
+		return err
+	}
+	a.deleteAvatar(user.AvatarName)
+	return nil
+}
+
+// deleteAvatar removes a replaced avatar file. Failure only leaves an
+// orphaned file behind, so it is logged rather than returned.
+func (a *AuthService) deleteAvatar(name string) {
+	if name == "" {
+		return
+	}
+	if err := a.Avatars.Delete(name); err != nil {
+		slog.Warn("Error deleting old avatar", "name", name, "error", err)
+	}
+}
+
+func profileOf(user *models.User) *models.Profile {
+	return &models.Profile{
+		ID:                user.ID,
+		Username:          user.Username,
+		Name:              user.Name,
+		Email:             user.Email,
+		EmailVerified:     user.EmailVerified,
+		MFAEnabled:        user.MFAEnabled,
+		HasPassword:       user.HashedPassword != "",
+		PreferredLanguage: user.PreferredLanguage,
+		AvatarURL:         avatar.URL(user.AvatarName),
+		CreatedAt:         user.CreatedAt,
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/legendary-acp/chimecast/internal/metrics"
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+// SupportedProtocolVersions lists every signaling version this server speaks,
+// newest first
+var SupportedProtocolVersions = []int{models.ProtocolVersion1}
+
+// Subprotocols returns the WebSocket subprotocol names to offer on upgrade
+func Subprotocols() []string {
+	names := make([]string, 0, len(SupportedProtocolVersions))
+	for _, version := range SupportedProtocolVersions {
+		names = append(names, models.SubprotocolNamePrefix+strconv.Itoa(version))
+	}
+	return names
+}
+
+// signalingRegistry maps every inbound message type to the payload it carries.
+// Types missing from here are rejected with an unknown_type error.
+var signalingRegistry = map[string]func() models.SignalPayload{
+	models.WSMessageTypeOffer:            func() models.SignalPayload { return &models.SessionDescriptionPayload{} },
+	models.WSMessageTypeAnswer:           func() models.SignalPayload { return &models.SessionDescriptionPayload{} },
+	models.WSMessageTypeIceCandidate:     func() models.SignalPayload { return &models.IceCandidatePayload{} },
+	models.WSMessageTypeScreenShareStart: func() models.SignalPayload { return &models.EmptyPayload{} },
+	models.WSMessageTypeScreenShareStop:  func() models.SignalPayload { return &models.ScreenSharePayload{} },
+	models.WSMessageTypeLeave:            func() models.SignalPayload { return &models.EmptyPayload{} },
+}
+
+// SignalingError is a failure that is reported back to the client as an
+// error frame rather than tearing down the connection
+type SignalingError struct {
+	Code    string
+	Message string
+}
+
+func (e *SignalingError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func signalingError(code, format string, args ...interface{}) *SignalingError {
+	return &SignalingError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// NegotiateProtocol settles on a signaling version for a freshly upgraded
+// socket and greets the client with it. Clients that don't ask for a
+// subprotocol get the current version; clients that only ask for versions we
+// don't speak get an error frame and are disconnected.
+func NegotiateProtocol(conn *Socket, requested []string) (int, error) {
+	version := models.CurrentProtocol
+	if chosen := conn.Subprotocol(); chosen != "" {
+		version, _ = strconv.Atoi(strings.TrimPrefix(chosen, models.SubprotocolNamePrefix))
+	} else if len(requested) > 0 {
+		err := signalingError(models.ErrCodeUnsupportedVersion, "none of %v are supported, use one of %v", requested, Subprotocols())
+		sendError(conn, "", err)
+		conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseProtocolError, models.ErrCodeUnsupportedVersion))
+		return 0, err
+	}
+
+	return version, writeMessage(conn, models.WebSocketMessage{
+		Type: models.WSMessageTypeWelcome,
+		Payload: models.WelcomePayload{
+			ProtocolVersion:   version,
+			SupportedVersions: SupportedProtocolVersions,
+		},
+	})
+}
+
+// decodeMessage parses a raw frame and validates its payload against the
+// registry
+func decodeMessage(data []byte) (*models.InboundMessage, models.SignalPayload, *SignalingError) {
+	var msg models.InboundMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, nil, signalingError(models.ErrCodeMalformedMessage, "message is not valid JSON: %v", err)
+	}
+	if msg.Type == "" {
+		return &msg, nil, signalingError(models.ErrCodeMalformedMessage, "message type is required")
+	}
+
+	newPayload, known := signalingRegistry[msg.Type]
+	if !known {
+		return &msg, nil, signalingError(models.ErrCodeUnknownType, "unknown message type %q", msg.Type)
+	}
+
+	payload := newPayload()
+	if len(msg.Payload) > 0 && !bytes.Equal(msg.Payload, []byte("null")) {
+		decoder := json.NewDecoder(bytes.NewReader(msg.Payload))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(payload); err != nil {
+			return &msg, nil, signalingError(models.ErrCodeInvalidPayload, "invalid %s payload: %v", msg.Type, err)
+		}
+	}
+	if err := payload.Validate(); err != nil {
+		return &msg, nil, signalingError(models.ErrCodeInvalidPayload, "invalid %s payload: %v", msg.Type, err)
+	}
+
+	return &msg, payload, nil
+}
+
+// sendError replies to a client with a standard error frame
+func sendError(conn *Socket, id string, err *SignalingError) error {
+	return writeMessage(conn, models.WebSocketMessage{
+		Type: models.WSMessageTypeError,
+		ID:   id,
+		Payload: models.ErrorPayload{
+			Code:    err.Code,
+			Message: err.Message,
+		},
+	})
+}
+
+// sendAck confirms a message that carried a correlation ID
+func sendAck(conn *Socket, id string) error {
+	if id == "" {
+		return nil
+	}
+	return writeMessage(conn, models.WebSocketMessage{
+		Type: models.WSMessageTypeAck,
+		ID:   id,
+	})
+}
+
+// writeMessage sends a frame to one socket and counts it
+func writeMessage(conn *Socket, msg models.WebSocketMessage) error {
+	metrics.WebSocketMessages.WithLabelValues(metrics.DirectionOut, msg.Type).Inc()
+	return conn.WriteJSON(msg)
+}
+
+// countInbound records a received frame, bucketing anything that failed to
+// decode so arbitrary client input can't create new label values
+func countInbound(msg *models.InboundMessage, sigErr *SignalingError) {
+	msgType := "invalid"
+	if sigErr == nil || sigErr.Code == models.ErrCodeInvalidPayload {
+		msgType = msg.Type
+	}
+	metrics.WebSocketMessages.WithLabelValues(metrics.DirectionIn, msgType).Inc()
+}
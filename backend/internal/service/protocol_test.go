@@ -0,0 +1,135 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+func TestDecodeMessage(t *testing.T) {
+	zero := 0
+	video := "video"
+
+	tests := []struct {
+		name        string
+		frame       string
+		wantCode    string // empty when the frame is valid
+		wantPayload models.SignalPayload
+	}{
+		{
+			name:        "offer",
+			frame:       `{"type":"offer","id":"1","payload":{"type":"offer","sdp":"v=0"}}`,
+			wantPayload: &models.SessionDescriptionPayload{Type: "offer", SDP: "v=0"},
+		},
+		{
+			name:        "targeted answer",
+			frame:       `{"type":"answer","payload":{"target":"u2","type":"answer","sdp":"v=0"}}`,
+			wantPayload: &models.SessionDescriptionPayload{Target: "u2", Type: "answer", SDP: "v=0"},
+		},
+		{
+			name:        "ice candidate",
+			frame:       `{"type":"ice-candidate","payload":{"candidate":"candidate:1","sdpMid":"video","sdpMLineIndex":0}}`,
+			wantPayload: &models.IceCandidatePayload{Candidate: "candidate:1", SDPMid: &video, SDPMLineIndex: &zero},
+		},
+		{
+			name:        "end of candidates",
+			frame:       `{"type":"ice-candidate","payload":{"candidate":"","sdpMLineIndex":0}}`,
+			wantPayload: &models.IceCandidatePayload{SDPMLineIndex: &zero},
+		},
+		{
+			name:        "screen share start without a payload",
+			frame:       `{"type":"screenshare-start"}`,
+			wantPayload: &models.EmptyPayload{},
+		},
+		{
+			name:        "screen share stop with a null payload",
+			frame:       `{"type":"screenshare-stop","payload":null}`,
+			wantPayload: &models.ScreenSharePayload{},
+		},
+		{
+			name:        "leave",
+			frame:       `{"type":"leave","payload":{}}`,
+			wantPayload: &models.EmptyPayload{},
+		},
+		{name: "not JSON", frame: `offer`, wantCode: models.ErrCodeMalformedMessage},
+		{name: "missing type", frame: `{"payload":{}}`, wantCode: models.ErrCodeMalformedMessage},
+		{name: "unknown type", frame: `{"type":"dance"}`, wantCode: models.ErrCodeUnknownType},
+		{name: "server-only type", frame: `{"type":"welcome"}`, wantCode: models.ErrCodeUnknownType},
+		{name: "legacy offer shape", frame: `{"type":"offer","offer":{"type":"offer","sdp":"v=0"}}`, wantCode: models.ErrCodeInvalidPayload},
+		{name: "offer without sdp", frame: `{"type":"offer","payload":{"type":"offer"}}`, wantCode: models.ErrCodeInvalidPayload},
+		{name: "offer labelled as answer", frame: `{"type":"offer","payload":{"type":"pranswer","sdp":"v=0"}}`, wantCode: models.ErrCodeInvalidPayload},
+		{name: "unknown payload field", frame: `{"type":"offer","payload":{"type":"offer","sdp":"v=0","extra":1}}`, wantCode: models.ErrCodeInvalidPayload},
+		{name: "payload of the wrong shape", frame: `{"type":"leave","payload":[1]}`, wantCode: models.ErrCodeInvalidPayload},
+		{name: "candidate without a media line", frame: `{"type":"ice-candidate","payload":{"candidate":"candidate:1"}}`, wantCode: models.ErrCodeInvalidPayload},
+		{name: "negative media line", frame: `{"type":"ice-candidate","payload":{"candidate":"c","sdpMLineIndex":-1}}`, wantCode: models.ErrCodeInvalidPayload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, payload, sigErr := decodeMessage([]byte(tt.frame))
+			if tt.wantCode != "" {
+				if sigErr == nil || sigErr.Code != tt.wantCode {
+					t.Fatalf("decodeMessage() error = %v, want code %s", sigErr, tt.wantCode)
+				}
+				return
+			}
+			if sigErr != nil {
+				t.Fatalf("decodeMessage() error = %v", sigErr)
+			}
+			if !reflect.DeepEqual(payload, tt.wantPayload) {
+				t.Errorf("payload = %#v, want %#v", payload, tt.wantPayload)
+			}
+		})
+	}
+}
+
+func TestNegotiateProtocol(t *testing.T) {
+	tests := []struct {
+		name        string
+		requested   []string
+		wantVersion int
+		wantError   bool
+	}{
+		{name: "no subprotocol gets the current version", wantVersion: models.CurrentProtocol},
+		{name: "v1 is accepted", requested: []string{"chimecast.v1"}, wantVersion: models.ProtocolVersion1},
+		{name: "supported version among others", requested: []string{"chimecast.v9", "chimecast.v1"}, wantVersion: models.ProtocolVersion1},
+		{name: "unsupported versions are refused", requested: []string{"chimecast.v9"}, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			type result struct {
+				version int
+				err     error
+			}
+			results := make(chan result, 1)
+
+			upgrader := websocket.Upgrader{Subprotocols: Subprotocols()}
+			client := dialTestSocketWith(t, upgrader, tt.requested, func(socket *Socket, requested []string) {
+				version, err := NegotiateProtocol(socket, requested)
+				results <- result{version, err}
+			})
+
+			var msg models.WebSocketMessage
+			if err := client.ReadJSON(&msg); err != nil {
+				t.Fatalf("read greeting: %v", err)
+			}
+			got := <-results
+
+			if tt.wantError {
+				if got.err == nil || msg.Type != models.WSMessageTypeError {
+					t.Fatalf("got version %d and a %q frame, want an unsupported_version error", got.version, msg.Type)
+				}
+				return
+			}
+			if got.err != nil {
+				t.Fatalf("NegotiateProtocol() error = %v", got.err)
+			}
+			if got.version != tt.wantVersion || msg.Type != models.WSMessageTypeWelcome {
+				t.Errorf("got version %d and a %q frame, want version %d and a welcome", got.version, msg.Type, tt.wantVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/ratelimit"
+)
+
+// DefaultMessageLimit is the MessageLimits key for message types without a
+// limit of their own. Those types, and frames that fail to decode, share one
+// bucket.
+const DefaultMessageLimit = "*"
+
+// messageLimiter throttles the messages one connection sends, by type
+type messageLimiter struct {
+	limits  map[string]ratelimit.Rate
+	buckets map[string]*ratelimit.Bucket
+}
+
+func (r *RoomService) newMessageLimiter() *messageLimiter {
+	return &messageLimiter{
+		limits:  r.MessageLimits,
+		buckets: make(map[string]*ratelimit.Bucket),
+	}
+}
+
+// allow spends a token for a message of the given type
+func (m *messageLimiter) allow(msgType string) bool {
+	key := msgType
+	if _, known := signalingRegistry[msgType]; !known {
+		key = DefaultMessageLimit
+	}
+	rate, limited := m.limits[key]
+	if !limited {
+		key = DefaultMessageLimit
+		rate = m.limits[DefaultMessageLimit]
+	}
+
+	now := time.Now()
+	bucket, exists := m.buckets[key]
+	if !exists {
+		bucket = ratelimit.NewBucket(rate, now)
+		m.buckets[key] = bucket
+	}
+	allowed, _ := bucket.Take(rate, now)
+	return allowed
+}
+
+// inboundType is the type a frame is throttled under; frames that didn't
+// decode far enough to have one fall under the default limit
+func inboundType(msg *models.InboundMessage) string {
+	if msg == nil || msg.Type == "" {
+		return DefaultMessageLimit
+	}
+	return msg.Type
+}
+
+// disconnectRateLimited tells a client it sent too much and closes the socket
+func disconnectRateLimited(ctx context.Context, conn *Socket, msg *models.InboundMessage) {
+	slog.WarnContext(ctx, "Disconnecting client over message rate limit", "type", inboundType(msg))
+	sendError(conn, messageID(msg), signalingError(models.ErrCodeRateLimited, "too many %s messages", inboundType(msg)))
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.ClosePolicyViolation, models.ErrCodeRateLimited),
+		time.Now().Add(time.Second))
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+// The services reach storage only through these interfaces. The
+// repositories package implements them over SQLite or PostgreSQL, picked
+// with CHIMECAST_DB_DRIVER.
+
+// AuthRepository stores users, their credentials and linked identities
+type AuthRepository interface {
+	RegisterUser(user models.User) error
+	RegisterUserWithIdentity(user models.User, identity models.UserIdentity) error
+	Login(userName string) (*models.User, error)
+	GetUserByID(userID string) (*models.User, error)
+	GetUserByUsername(username string) (*models.User, error)
+	GetUserByEmail(email string) (*models.User, error)
+	SetEmailVerified(userID string) error
+	UpdatePassword(userID, hashedPassword string) error
+	UpdateProfile(user models.User) error
+	SetAvatar(userID, name string) error
+	AnonymizeUser(user models.User, now time.Time) error
+	SetAdmin(userID string, isAdmin bool) error
+	SetDisabled(userID string, disabledAt *time.Time) error
+	RevokeSessions(userID string, at time.Time) error
+	SearchUsers(query models.UserQuery) ([]models.User, error)
+
+	GetUserByIdentity(issuer, subject string) (*models.User, error)
+	LinkIdentity(identity models.UserIdentity) error
+	ListIdentities(userID string) ([]models.UserIdentity, error)
+
+	SetTOTPSecret(userID, secret string) error
+	EnableMFA(userID string, codeHashes []string) error
+	DisableMFA(userID string) error
+	ReplaceRecoveryCodes(userID string, codeHashes []string) error
+	ConsumeRecoveryCode(userID, codeHash string) error
+	AdvanceTOTPStep(userID string, step int64) error
+}
+
+// RoomRepository stores rooms; who is in them lives in RoomService
+type RoomRepository interface {
+	ListRooms(query models.RoomQuery) ([]models.Room, error)
+	CreateRoom(room *models.Room) error
+	GetRoom(roomID string) (*models.Room, error)
+	DoesRoomExist(ID string) (*bool, error)
+	UpdateRoomStatus(roomID string, status int) error
+	UpdateScreenSharePolicy(roomID string, policy string) error
+	UpdateVisibility(roomID string, visibility string) error
+	UpdateRoomHost(roomID string, hostID string) error
+	DeleteRoom(roomID string) error
+	GetRoomsByHost(hostID string) ([]models.Room, error)
+	Invite(invitation models.RoomInvitation) error
+	Uninvite(roomID, userID string) error
+	IsInvited(roomID, userID string) (bool, error)
+	ListInvitations(roomID string) ([]models.RoomInvitation, error)
+	UserInvitations(userID string) ([]models.RoomInvitation, error)
+}
+
+// LoginAttemptRepository records login attempts for rate limiting and
+// lockouts
+type LoginAttemptRepository interface {
+	RecordLoginAttempt(attempt models.LoginAttempt) error
+	AttemptsFromIP(ip string, since time.Time) ([]time.Time, error)
+	AttemptsForUsername(username string, since time.Time) ([]time.Time, error)
+	FailuresSinceLastSuccess(username string, since time.Time) ([]time.Time, error)
+	LoginHistory(username string) ([]models.LoginAttempt, error)
+}
+
+// TokenRepository stores single-use tokens sent by email
+type TokenRepository interface {
+	CreateToken(token models.UserToken) error
+	TokenUser(tokenHash, purpose string, now time.Time) (string, error)
+	ConsumeToken(tokenHash, purpose string, now time.Time) (string, error)
+}
+
+// APITokenRepository stores personal API tokens
+type APITokenRepository interface {
+	CreateAPIToken(token models.APIToken) error
+	ListAPITokens(userID string) ([]models.APIToken, error)
+	GetAPITokenByHash(tokenHash string, now time.Time) (*models.APIToken, error)
+	TouchAPIToken(tokenID string, now time.Time, interval time.Duration) error
+	DeleteAPIToken(userID, tokenID string) error
+	DeleteUserAPITokens(userID string) ([]string, error)
+}
+
+// AuditRepository stores the audit log; events can be added but never
+// changed
+type AuditRepository interface {
+	AppendAuditEvent(event *models.AuditEvent) error
+	ListAuditEvents(query models.AuditQuery) ([]models.AuditEvent, error)
+}
+
+// AttendanceRepository stores meetings and who was in them when
+type AttendanceRepository interface {
+	OpenAttendance(interval *models.AttendanceInterval, newMeetingID string) error
+	CloseAttendance(intervalID string, leftAt time.Time, reason string) (bool, error)
+	GetMeeting(roomID, meetingID string) (*models.Meeting, error)
+	ListRoomMeetings(roomID string) ([]models.Meeting, error)
+	ListUserMeetings(userID string) ([]models.Meeting, error)
+	MeetingAttendance(meetingID string) ([]models.AttendanceInterval, error)
+	UserAttendance(userID string) ([]models.AttendanceInterval, error)
+}
+
+// WebhookRepository stores webhooks and the log of deliveries to them
+type WebhookRepository interface {
+	CreateWebhook(webhook models.Webhook) error
+	GetWebhook(webhookID string) (*models.Webhook, error)
+	ListWebhooks(userID string) ([]models.Webhook, error)
+	RoomWebhooks(roomID string) ([]models.Webhook, error)
+	DeleteWebhook(userID, webhookID string) error
+	CreateDeliveries(deliveries []models.WebhookDelivery) error
+	GetDelivery(webhookID, deliveryID string) (*models.WebhookDelivery, error)
+	ListDeliveries(webhookID string, limit int) ([]models.WebhookDelivery, error)
+	DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error)
+	ClaimDelivery(deliveryID string, now, leaseUntil time.Time) (bool, error)
+	RecordDeliveryAttempt(delivery models.WebhookDelivery) error
+}
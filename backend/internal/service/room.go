@@ -1,49 +1,88 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/legendary-acp/chimecast/internal/avatar"
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/metrics"
 	"github.com/legendary-acp/chimecast/internal/models"
-	"github.com/legendary-acp/chimecast/internal/repositories"
+	"github.com/legendary-acp/chimecast/internal/ratelimit"
 	"github.com/legendary-acp/chimecast/internal/utils"
 )
 
 type Connection struct {
-	Conn     *websocket.Conn
-	UserID   string
-	Username string
-	JoinedAt time.Time
-	Status   string // "waiting" or "admitted"
+	Conn            *Socket
+	UserID          string
+	Username        string
+	Name            string // display name, for other participants
+	AvatarURL       string
+	JoinedAt        time.Time
+	Status          string // "waiting" or "admitted"
+	ProtocolVersion int    // signaling protocol negotiated on connect
+	SessionID       string // public ID of the login session that opened it; empty for API tokens
+	APITokenID      string // ID of the API token that opened it; empty for login sessions
+	ID              string // identifies the connection's attendance interval
+	leaveReason     string // why the server closed it, for attendance; guarded by RoomService.mu
+
+	// attendance is held from the moment the connection is admitted until
+	// its interval is recorded, so the database writes can run outside
+	// RoomService.mu without the close overtaking the open
+	attendance sync.Mutex
 }
 
-func NewRoomService(roomRepository *repositories.RoomRepository) *RoomService {
-	return &RoomService{
-		RoomRepository: roomRepository,
-		Connections:    make(map[string]map[string]*Connection),
-		WaitingRoom:    make(map[string]map[string]*Connection),
+func NewRoomService(roomRepository RoomRepository, authRepository AuthRepository, attendanceRepository AttendanceRepository, roomBus bus.Bus, messageLimits map[string]ratelimit.Rate) (*RoomService, error) {
+	roomService := &RoomService{
+		RoomRepository:       roomRepository,
+		AuthRepository:       authRepository,
+		AttendanceRepository: attendanceRepository,
+		Bus:                  roomBus,
+		MessageLimits:        messageLimits,
+		Connections:          make(map[string]map[string]*Connection),
+		WaitingRoom:          make(map[string]map[string]*Connection),
+	}
+
+	// Every node hears every room event and acts on the sockets it holds
+	if err := roomBus.Subscribe(roomService.deliver); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to room events: %w", err)
 	}
-}
 
-// GetAllRooms returns all available rooms
-func (r *RoomService) GetAllRooms() ([]models.Room, error) {
-	return r.RoomRepository.GetAllRooms()
+	return roomService, nil
 }
 
-func (r *RoomService) CreateRoom(request *models.CreateRoomRequest, hostID string) (*string, error) {
+func (r *RoomService) CreateRoom(ctx context.Context, request *models.CreateRoomRequest, hostID string) (*string, error) {
 	if request.Name == "" {
 		return nil, errors.New("name can't be empty")
 	}
 
+	if request.ScreenSharePolicy == "" {
+		request.ScreenSharePolicy = models.ScreenSharePolicyAnyone
+	}
+	if !models.IsValidScreenSharePolicy(request.ScreenSharePolicy) {
+		return nil, utils.ErrInvalidScreenSharePolicy
+	}
+	if request.Visibility == "" {
+		request.Visibility = models.RoomVisibilityPublic
+	}
+	if !models.IsValidRoomVisibility(request.Visibility) {
+		return nil, utils.ErrInvalidVisibility
+	}
+
 	room := models.Room{
-		ID:        utils.CreateNewUUID(),
-		Name:      request.Name,
-		HostID:    hostID,
-		Status:    models.RoomStatusActive,
-		CreatedAt: time.Now(),
+		ID:                utils.CreateNewUUID(),
+		Name:              request.Name,
+		HostID:            hostID,
+		Status:            models.RoomStatusActive,
+		CreatedAt:         time.Now(),
+		ScreenSharePolicy: request.ScreenSharePolicy,
+		Visibility:        request.Visibility,
 	}
 
 	if err := r.RoomRepository.CreateRoom(&room); err != nil {
@@ -55,19 +94,34 @@ func (r *RoomService) CreateRoom(request *models.CreateRoomRequest, hostID strin
 	r.WaitingRoom[room.ID] = make(map[string]*Connection)
 	r.mu.Unlock()
 
+	r.Audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditRoomCreated,
+		TargetType: models.AuditTargetRoom,
+		TargetID:   room.ID,
+		RoomID:     room.ID,
+		Details:    map[string]string{"name": room.Name, "screenSharePolicy": room.ScreenSharePolicy, "visibility": room.Visibility},
+	})
+	r.Webhooks.Publish(models.RoomEvent{
+		Type:      models.EventRoomCreated,
+		RoomID:    room.ID,
+		CreatedAt: room.CreatedAt,
+		Data:      map[string]string{"name": room.Name, "hostId": hostID},
+	})
 	return &room.ID, nil
 }
 
 func (r *RoomService) JoinRoom(roomID, userID string) (string, error) {
-	exists, err := r.RoomRepository.DoesRoomExist(roomID)
-	if err != nil || !*exists {
-		return "", fmt.Errorf("room does not exist")
+	if r.IsDraining() {
+		return "", utils.ErrServerDraining
 	}
 
-	room, err := r.RoomRepository.GetRoom(roomID)
+	room, err := r.requireVisible(roomID, userID)
 	if err != nil {
 		return "", err
 	}
+	if room.Status != models.RoomStatusActive {
+		return "", utils.ErrRoomEnded
+	}
 
 	// Host is automatically admitted
 	if room.HostID == userID {
@@ -77,74 +131,126 @@ func (r *RoomService) JoinRoom(roomID, userID string) (string, error) {
 	return models.ParticipantStatusWaiting, nil
 }
 
-func (r *RoomService) HandleWebSocket(roomID, userID string, conn *websocket.Conn) error {
+func (r *RoomService) HandleWebSocket(ctx context.Context, roomID, userID string, protocolVersion int, conn *Socket) error {
+	connection := &Connection{
+		Conn:            conn,
+		UserID:          userID,
+		JoinedAt:        time.Now(),
+		Status:          models.ParticipantStatusAdmitted,
+		ProtocolVersion: protocolVersion,
+		SessionID:       sessionIDFrom(ctx),
+		APITokenID:      apiTokenIDFrom(ctx),
+		ID:              utils.CreateNewUUID(),
+	}
+	r.describe(connection)
+
 	r.mu.Lock()
 	if r.Connections[roomID] == nil {
 		r.Connections[roomID] = make(map[string]*Connection)
 	}
-
-	connection := &Connection{
-		Conn:     conn,
-		UserID:   userID,
-		JoinedAt: time.Now(),
-		Status:   models.ParticipantStatusAdmitted,
-	}
 	r.Connections[roomID][userID] = connection
+	connection.attendance.Lock()
 	r.mu.Unlock()
+	r.startAttendance(roomID, connection, models.AttendanceJoin)
+
+	defer func() {
+		r.removeConnection(roomID, userID, conn, models.AttendanceDisconnect)
+	}()
+
+	if err := r.Bus.SetMember(roomID, memberFor(connection)); err != nil {
+		return err
+	}
+
+	// Bring the new peer up to date with the room
+	if room, err := r.RoomRepository.GetRoom(roomID); err == nil {
+		if err := writeMessage(conn, models.WebSocketMessage{
+			Type:    models.WSMessageTypeRoomState,
+			Payload: r.roomState(room),
+		}); err != nil {
+			return err
+		}
+	}
 
 	// Notify others about new peer
 	r.broadcastToRoom(roomID, models.WebSocketMessage{
-		Type: models.WSMessageTypeJoin,
-		Payload: map[string]string{
-			"userId": userID,
-			"status": models.ParticipantStatusAdmitted,
-		},
+		Type:    models.WSMessageTypeJoin,
+		Payload: participantOf(memberFor(connection)),
 	}, userID)
 
-	defer func() {
-		r.removeConnection(roomID, userID)
-	}()
-
-	return r.handleMessages(roomID, userID, conn)
+	return r.handleMessages(ctx, roomID, userID, conn, r.newMessageLimiter())
 }
 
-func (r *RoomService) HandleWaitingRoom(roomID, userID string, conn *websocket.Conn) error {
+func (r *RoomService) HandleWaitingRoom(ctx context.Context, roomID, userID string, protocolVersion int, conn *Socket) error {
+	connection := &Connection{
+		Conn:            conn,
+		UserID:          userID,
+		JoinedAt:        time.Now(),
+		Status:          models.ParticipantStatusWaiting,
+		ProtocolVersion: protocolVersion,
+		SessionID:       sessionIDFrom(ctx),
+		APITokenID:      apiTokenIDFrom(ctx),
+		ID:              utils.CreateNewUUID(),
+	}
+	r.describe(connection)
+
 	r.mu.Lock()
 	if r.WaitingRoom[roomID] == nil {
 		r.WaitingRoom[roomID] = make(map[string]*Connection)
 	}
-
-	connection := &Connection{
-		Conn:     conn,
-		UserID:   userID,
-		JoinedAt: time.Now(),
-		Status:   models.ParticipantStatusWaiting,
-	}
 	r.WaitingRoom[roomID][userID] = connection
+	// Taken under the lock, as an admission may change the status
+	member := memberFor(connection)
 	r.mu.Unlock()
+	limiter := r.newMessageLimiter()
+
+	// Whichever applies is a no-op: still waiting, or admitted since
+	defer func() {
+		r.removeFromWaitingRoom(roomID, userID, conn)
+		r.removeConnection(roomID, userID, conn, models.AttendanceDisconnect)
+	}()
+
+	if err := r.Bus.SetMember(roomID, member); err != nil {
+		return err
+	}
 
 	// Notify host about waiting participant
 	r.notifyHost(roomID, models.WebSocketMessage{
-		Type: "waiting-participant",
-		Payload: map[string]string{
-			"userId": userID,
-		},
+		Type:    "waiting-participant",
+		Payload: participantOf(member),
 	})
 
-	defer func() {
-		r.removeFromWaitingRoom(roomID, userID)
-	}()
-
-	// Wait for admission decision
+	// Wait for admission decision. Waiting participants may only leave.
 	for {
-		var msg models.WebSocketMessage
-		if err := conn.ReadJSON(&msg); err != nil {
+		frameType, data, err := conn.ReadMessage()
+		if err != nil {
 			return err
 		}
+
+		// Once admitted, this socket carries the participant's signaling
+		if r.isAdmitted(connection) {
+			if done := r.handleFrame(ctx, roomID, userID, conn, limiter, frameType, data); done {
+				return nil
+			}
+			return r.handleMessages(ctx, roomID, userID, conn, limiter)
+		}
+
+		msg, _, sigErr := decodeMessage(data)
+		countInbound(msg, sigErr)
+		if sigErr == nil && msg.Type == models.WSMessageTypeLeave {
+			return nil
+		}
+		if !limiter.allow(inboundType(msg)) {
+			disconnectRateLimited(ctx, conn, msg)
+			return nil
+		}
+		if sigErr == nil {
+			sigErr = signalingError(models.ErrCodeNotAdmitted, "%s is not allowed before being admitted", msg.Type)
+		}
+		sendError(conn, messageID(msg), sigErr)
 	}
 }
 
-func (r *RoomService) AdmitParticipant(roomID, participantID, hostID string) error {
+func (r *RoomService) AdmitParticipant(ctx context.Context, roomID, participantID, hostID string) error {
 	room, err := r.RoomRepository.GetRoom(roomID)
 	if err != nil {
 		return err
@@ -154,28 +260,24 @@ func (r *RoomService) AdmitParticipant(roomID, participantID, hostID string) err
 		return errors.New("only host can admit participants")
 	}
 
-	r.mu.Lock()
-	participant, exists := r.WaitingRoom[roomID][participantID]
-	if !exists {
-		r.mu.Unlock()
-		return errors.New("participant not found in waiting room")
+	if err := r.requireWaiting(roomID, participantID); err != nil {
+		return err
 	}
 
-	// Move from waiting room to admitted participants
-	delete(r.WaitingRoom[roomID], participantID)
-	r.Connections[roomID][participantID] = participant
-	participant.Status = models.ParticipantStatusAdmitted
-	r.mu.Unlock()
-
-	// Notify participant about admission
-	msg := models.WebSocketMessage{
-		Type:    models.WSMessageTypeAdmitted,
-		Payload: map[string]string{"status": "admitted"},
+	// The node holding the participant's socket moves it out of the waiting room
+	if err := r.Bus.Publish(bus.Event{
+		Kind:   bus.EventAdmit,
+		RoomID: roomID,
+		Target: participantID,
+	}); err != nil {
+		return err
 	}
-	return participant.Conn.WriteJSON(msg)
+
+	r.auditParticipant(ctx, models.AuditParticipantAdmitted, roomID, participantID)
+	return nil
 }
 
-func (r *RoomService) DenyParticipant(roomID, participantID, hostID string) error {
+func (r *RoomService) DenyParticipant(ctx context.Context, roomID, participantID, hostID string) error {
 	room, err := r.RoomRepository.GetRoom(roomID)
 	if err != nil {
 		return err
@@ -185,150 +287,206 @@ func (r *RoomService) DenyParticipant(roomID, participantID, hostID string) erro
 		return errors.New("only host can deny participants")
 	}
 
-	r.mu.Lock()
-	participant, exists := r.WaitingRoom[roomID][participantID]
-	if !exists {
-		r.mu.Unlock()
-		return errors.New("participant not found in waiting room")
+	if err := r.requireWaiting(roomID, participantID); err != nil {
+		return err
 	}
 
-	// Remove from waiting room
-	delete(r.WaitingRoom[roomID], participantID)
-	r.mu.Unlock()
+	if err := r.Bus.Publish(bus.Event{
+		Kind:   bus.EventDeny,
+		RoomID: roomID,
+		Target: participantID,
+	}); err != nil {
+		return err
+	}
+
+	r.auditParticipant(ctx, models.AuditParticipantDenied, roomID, participantID)
+	return nil
+}
+
+// requireWaiting checks that a participant is in the room's waiting room on
+// any node
+func (r *RoomService) requireWaiting(roomID, participantID string) error {
+	members, err := r.Bus.Members(roomID)
+	if err != nil {
+		return err
+	}
 
-	// Notify participant about denial
-	msg := models.WebSocketMessage{
-		Type:    models.WSMessageTypeDenied,
-		Payload: map[string]string{"status": "denied"},
+	if member, exists := members[participantID]; !exists || member.Status != models.ParticipantStatusWaiting {
+		return errors.New("participant not found in waiting room")
 	}
-	return participant.Conn.WriteJSON(msg)
+	return nil
 }
 
 // Additional helper methods...
 func (r *RoomService) GetRoomStatus(roomID, userID string) (*models.RoomStatus, error) {
-	room, err := r.RoomRepository.GetRoom(roomID)
+	room, err := r.requireVisible(roomID, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	members, err := r.Bus.Members(roomID)
+	if err != nil {
+		return nil, err
+	}
+	participants, waiting := countMembers(members)
 
 	status := &models.RoomStatus{
-		RoomID:       room.ID,
-		Name:         room.Name,
-		HostID:       room.HostID,
-		IsActive:     room.Status == models.RoomStatusActive,
-		Participants: len(r.Connections[roomID]),
-		WaitingCount: len(r.WaitingRoom[roomID]),
-		CreatedAt:    room.CreatedAt,
+		RoomID:            room.ID,
+		Name:              room.Name,
+		HostID:            room.HostID,
+		IsActive:          room.Status == models.RoomStatusActive,
+		CreatedAt:         room.CreatedAt,
+		ScreenSharePolicy: room.ScreenSharePolicy,
+		Visibility:        room.Visibility,
+		ScreenSharers:     screenSharers(members),
+		Participants:      participants,
+		WaitingCount:      waiting,
 	}
 
 	return status, nil
 }
 
 func (r *RoomService) GetParticipants(roomID, userID string) (*models.Participants, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	if _, err := r.requireVisible(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	members, err := r.Bus.Members(roomID)
+	if err != nil {
+		return nil, err
+	}
 
 	result := &models.Participants{
 		Admitted: make([]models.Participant, 0),
 		Waiting:  make([]models.Participant, 0),
 	}
 
-	// Get admitted participants
-	for _, conn := range r.Connections[roomID] {
-		result.Admitted = append(result.Admitted, models.Participant{
-			UserID:   conn.UserID,
-			Username: conn.Username,
-			JoinedAt: conn.JoinedAt,
-			Status:   models.ParticipantStatusAdmitted,
-		})
-	}
-
-	// Get waiting participants
-	for _, conn := range r.WaitingRoom[roomID] {
-		result.Waiting = append(result.Waiting, models.Participant{
-			UserID:   conn.UserID,
-			Username: conn.Username,
-			JoinedAt: conn.JoinedAt,
-			Status:   models.ParticipantStatusWaiting,
-		})
+	for _, member := range members {
+		participant := participantOf(member)
+		if member.Status == models.ParticipantStatusAdmitted {
+			result.Admitted = append(result.Admitted, participant)
+		} else {
+			result.Waiting = append(result.Waiting, participant)
+		}
 	}
 
 	return result, nil
 }
 
-// broadcastToRoom sends a message to all connections in a room except the sender
-func (r *RoomService) broadcastToRoom(roomID string, message interface{}, senderID string) error {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	// Check if room exists
-	connections, exists := r.Connections[roomID]
-	if !exists {
-		return fmt.Errorf("room %s not found", roomID)
+// broadcastToRoom sends a message to all connections in a room except the
+// sender, on every node
+func (r *RoomService) broadcastToRoom(roomID string, message models.WebSocketMessage, senderID string) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
 	}
 
-	// Send to all connections except sender
-	for userID, conn := range connections {
-		if userID != senderID {
-			err := conn.Conn.WriteJSON(message)
-			if err != nil {
-				log.Printf("Error sending message to user %s in room %s: %v", userID, roomID, err)
-				continue // Continue broadcasting to others even if one fails
-			}
-		}
+	return r.Bus.Publish(bus.Event{
+		Kind:        bus.EventBroadcast,
+		RoomID:      roomID,
+		Exclude:     senderID,
+		MessageType: message.Type,
+		Message:     data,
+	})
+}
+
+// sendToUser delivers a message to one admitted participant, on whichever
+// node holds their socket
+func (r *RoomService) sendToUser(roomID, userID string, message models.WebSocketMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
 	}
 
-	return nil
+	return r.Bus.Publish(bus.Event{
+		Kind:        bus.EventBroadcast,
+		RoomID:      roomID,
+		Target:      userID,
+		MessageType: message.Type,
+		Message:     data,
+	})
 }
 
-// removeConnection removes a WebSocket connection from a room
-func (r *RoomService) removeConnection(roomID string, userID string) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// removeConnection removes a WebSocket connection from a room and ends its
+// attendance, for reason unless the server gave one when closing it. It is a
+// no-op if the user has since reconnected on a different socket.
+func (r *RoomService) removeConnection(roomID string, userID string, conn *Socket, reason string) {
+	var removed *Connection
 
+	r.mu.Lock()
 	// Check if room exists in connections map
 	if connections, exists := r.Connections[roomID]; exists {
 		// Remove the specific user's connection
-		if _, ok := connections[userID]; ok {
-			connections[userID].Conn.Close()
+		if current, ok := connections[userID]; ok && current.Conn == conn {
+			current.Conn.Close()
 			delete(connections, userID)
-			log.Printf("Connection removed from room %s. Total connections: %d", roomID, len(connections))
+			removed = current
+			if current.leaveReason != "" {
+				reason = current.leaveReason
+			}
+			slog.Info("Connection removed from room", "room_id", roomID, "user_id", userID, "connections", len(connections), "reason", reason)
 		}
 
 		// Clean up room if empty
 		if len(connections) == 0 {
 			delete(r.Connections, roomID)
-			log.Printf("Room %s removed as it's empty", roomID)
+			slog.Info("Room removed as it's empty", "room_id", roomID)
 		}
 	}
+	r.mu.Unlock()
+
+	if removed == nil {
+		return
+	}
+	r.endAttendance(roomID, removed, reason)
+
+	members, err := r.Bus.Members(roomID)
+	if err != nil {
+		slog.Error("Error reading presence", "room_id", roomID, "error", err)
+	}
+	if err := r.Bus.RemoveMember(roomID, userID, removed.ID); err != nil {
+		slog.Error("Error removing presence", "room_id", roomID, "user_id", userID, "error", err)
+	}
+
+	// A share can't outlive its sharer
+	if member := members[userID]; member.ConnectionID == removed.ID && member.ScreenSharing {
+		r.broadcastToRoom(roomID, models.WebSocketMessage{
+			Type: models.WSMessageTypeScreenShareStop,
+			Payload: map[string]string{
+				"userId":    userID,
+				"stoppedBy": userID,
+			},
+		}, userID)
+	}
 }
 
 // GetRoomParticipants returns a list of user IDs in a room
 func (r *RoomService) GetRoomParticipants(roomID string) []string {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	members, err := r.Bus.Members(roomID)
+	if err != nil {
+		slog.Error("Error reading presence", "room_id", roomID, "error", err)
+		return nil
+	}
 
 	var participants []string
-	for _, conn := range r.Connections[roomID] {
-		participants = append(participants, conn.UserID)
+	for userID, member := range members {
+		if member.Status == models.ParticipantStatusAdmitted {
+			participants = append(participants, userID)
+		}
 	}
 	return participants
 }
 
 // IsUserInRoom checks if a user is currently in a room
 func (r *RoomService) IsUserInRoom(roomID, userID string) bool {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	for _, conn := range r.Connections[roomID] {
-		if conn.UserID == userID {
-			return true
-		}
+	members, err := r.Bus.Members(roomID)
+	if err != nil {
+		slog.Error("Error reading presence", "room_id", roomID, "error", err)
+		return false
 	}
-	return false
+
+	member, exists := members[userID]
+	return exists && member.Status == models.ParticipantStatusAdmitted
 }
 
 // IsUserAdmitted checks if a user is admitted to the room
@@ -343,68 +501,75 @@ func (r *RoomService) IsUserAdmitted(roomID, userID string) (bool, error) {
 		return true, nil
 	}
 
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	// Check if user is in admitted connections
-	_, isAdmitted := r.Connections[roomID][userID]
-	return isAdmitted, nil
+	// Check if user was admitted on any node
+	members, err := r.Bus.Members(roomID)
+	if err != nil {
+		return false, err
+	}
+	member, exists := members[userID]
+	return exists && member.Status == models.ParticipantStatusAdmitted, nil
 }
 
 // LeaveRoom handles a user leaving the room
 func (r *RoomService) LeaveRoom(roomID, userID string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	members, err := r.Bus.Members(roomID)
+	if err != nil {
+		return err
+	}
 
-	// Check if user is in admitted connections
-	if conn, exists := r.Connections[roomID][userID]; exists {
-		// Notify others about the user leaving
+	member, exists := members[userID]
+	if !exists {
+		return errors.New("user not found in room")
+	}
+
+	// Notify others about the user leaving
+	if member.Status == models.ParticipantStatusAdmitted {
 		r.broadcastToRoom(roomID, models.WebSocketMessage{
 			Type: models.WSMessageTypeLeave,
 			Payload: map[string]string{
 				"userId": userID,
 			},
 		}, userID)
-
-		// Close connection and remove from admitted list
-		conn.Conn.Close()
-		delete(r.Connections[roomID], userID)
-
-		// If room is empty, clean up
-		if len(r.Connections[roomID]) == 0 {
-			delete(r.Connections, roomID)
-			delete(r.WaitingRoom, roomID)
-		}
-
-		return nil
 	}
 
-	// Check if user is in waiting room
-	if conn, exists := r.WaitingRoom[roomID][userID]; exists {
-		conn.Conn.Close()
-		delete(r.WaitingRoom[roomID], userID)
-		return nil
+	// Close the socket wherever it lives; its read loop cleans up after it
+	if err := r.Bus.Publish(bus.Event{
+		Kind:   bus.EventDisconnect,
+		RoomID: roomID,
+		Target: userID,
+		Reason: models.AttendanceLeave,
+	}); err != nil {
+		return err
 	}
 
-	return errors.New("user not found in room")
+	return r.Bus.RemoveMember(roomID, userID, member.ConnectionID)
 }
 
-// removeFromWaitingRoom removes a user from the waiting room
-func (r *RoomService) removeFromWaitingRoom(roomID string, userID string) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// removeFromWaitingRoom removes a user from the waiting room. It is a no-op
+// if the user has since been admitted or reconnected.
+func (r *RoomService) removeFromWaitingRoom(roomID string, userID string, conn *Socket) {
+	var removed *Connection
 
+	r.mu.Lock()
 	if waitingRoom, exists := r.WaitingRoom[roomID]; exists {
-		if conn, ok := waitingRoom[userID]; ok {
-			conn.Conn.Close()
+		if current, ok := waitingRoom[userID]; ok && current.Conn == conn {
+			current.Conn.Close()
 			delete(waitingRoom, userID)
-			log.Printf("User %s removed from waiting room %s", userID, roomID)
+			removed = current
+			slog.Info("User removed from waiting room", "room_id", roomID, "user_id", userID)
 		}
 
 		// Clean up waiting room if empty
 		if len(waitingRoom) == 0 {
 			delete(r.WaitingRoom, roomID)
-			log.Printf("Waiting room for room %s removed as it's empty", roomID)
+			slog.Info("Waiting room removed as it's empty", "room_id", roomID)
+		}
+	}
+	r.mu.Unlock()
+
+	if removed != nil {
+		if err := r.Bus.RemoveMember(roomID, userID, removed.ID); err != nil {
+			slog.Error("Error removing presence", "room_id", roomID, "user_id", userID, "error", err)
 		}
 	}
 }
@@ -416,52 +581,338 @@ func (r *RoomService) notifyHost(roomID string, message models.WebSocketMessage)
 		return err
 	}
 
+	members, err := r.Bus.Members(roomID)
+	if err != nil {
+		return err
+	}
+
+	// Find host's connection
+	if host, ok := members[room.HostID]; !ok || host.Status != models.ParticipantStatusAdmitted {
+		return errors.New("host not connected")
+	}
+
+	return r.sendToUser(roomID, room.HostID, message)
+}
+
+// deliver applies a room event to the sockets held by this node
+func (r *RoomService) deliver(event bus.Event) {
+	switch event.Kind {
+	case bus.EventBroadcast:
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		for userID, conn := range r.Connections[event.RoomID] {
+			if userID == event.Exclude || (event.Target != "" && userID != event.Target) {
+				continue
+			}
+			metrics.WebSocketMessages.WithLabelValues(metrics.DirectionOut, event.MessageType).Inc()
+			if err := conn.Conn.WriteMessage(websocket.TextMessage, event.Message); err != nil {
+				metrics.BroadcastWriteFailures.Inc()
+				slog.Warn("Error sending message", "room_id", event.RoomID, "user_id", userID, "type", event.MessageType, "error", err)
+				continue // Continue broadcasting to others even if one fails
+			}
+		}
+
+	case bus.EventAdmit:
+		r.mu.Lock()
+		participant, exists := r.WaitingRoom[event.RoomID][event.Target]
+		if !exists {
+			r.mu.Unlock()
+			return
+		}
+
+		// Move from waiting room to admitted participants
+		delete(r.WaitingRoom[event.RoomID], event.Target)
+		if r.Connections[event.RoomID] == nil {
+			r.Connections[event.RoomID] = make(map[string]*Connection)
+		}
+		r.Connections[event.RoomID][event.Target] = participant
+		participant.Status = models.ParticipantStatusAdmitted
+		participant.attendance.Lock()
+		r.mu.Unlock()
+		r.startAttendance(event.RoomID, participant, models.AttendanceAdmit)
+
+		if err := r.Bus.SetMember(event.RoomID, memberFor(participant)); err != nil {
+			slog.Error("Error updating presence", "room_id", event.RoomID, "user_id", event.Target, "error", err)
+		}
+
+		// Notify participant about admission
+		writeMessage(participant.Conn, models.WebSocketMessage{
+			Type:    models.WSMessageTypeAdmitted,
+			Payload: map[string]string{"status": "admitted"},
+		})
+
+		if room, err := r.RoomRepository.GetRoom(event.RoomID); err == nil {
+			writeMessage(participant.Conn, models.WebSocketMessage{
+				Type:    models.WSMessageTypeRoomState,
+				Payload: r.roomState(room),
+			})
+		}
+
+		// Notify others about new peer
+		r.broadcastToRoom(event.RoomID, models.WebSocketMessage{
+			Type:    models.WSMessageTypeJoin,
+			Payload: participantOf(memberFor(participant)),
+		}, event.Target)
+
+	case bus.EventDeny:
+		r.mu.Lock()
+		participant, exists := r.WaitingRoom[event.RoomID][event.Target]
+		if !exists {
+			r.mu.Unlock()
+			return
+		}
+
+		// Remove from waiting room
+		delete(r.WaitingRoom[event.RoomID], event.Target)
+		r.mu.Unlock()
+
+		if err := r.Bus.RemoveMember(event.RoomID, event.Target, participant.ID); err != nil {
+			slog.Error("Error removing presence", "room_id", event.RoomID, "user_id", event.Target, "error", err)
+		}
+
+		// Notify participant about denial
+		writeMessage(participant.Conn, models.WebSocketMessage{
+			Type:    models.WSMessageTypeDenied,
+			Payload: map[string]string{"status": "denied"},
+		})
+
+	case bus.EventAnnounce:
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		for _, connections := range []map[string]map[string]*Connection{r.Connections, r.WaitingRoom} {
+			for roomID, room := range connections {
+				for userID, conn := range room {
+					metrics.WebSocketMessages.WithLabelValues(metrics.DirectionOut, event.MessageType).Inc()
+					if err := conn.Conn.WriteMessage(websocket.TextMessage, event.Message); err != nil {
+						slog.Warn("Error sending announcement", "room_id", roomID, "user_id", userID, "error", err)
+					}
+				}
+			}
+		}
+
+	case bus.EventDisconnectUser:
+		var closing []*Connection
+		r.mu.Lock()
+		for _, connections := range []map[string]map[string]*Connection{r.Connections, r.WaitingRoom} {
+			for _, room := range connections {
+				if conn, ok := room[event.Target]; ok {
+					conn.leaveReason = event.Reason
+					closing = append(closing, conn)
+				}
+			}
+		}
+		r.mu.Unlock()
+
+		for _, conn := range closing {
+			conn.Conn.Close()
+		}
+
+	case bus.EventCloseAPITokens:
+		r.closeAPITokenSockets(event.Tokens)
+
+	case bus.EventDisconnect:
+		r.mu.Lock()
+		conn, exists := r.Connections[event.RoomID][event.Target]
+		if !exists {
+			conn, exists = r.WaitingRoom[event.RoomID][event.Target]
+		}
+		if exists && event.Reason != "" {
+			conn.leaveReason = event.Reason
+		}
+		r.mu.Unlock()
+
+		if exists {
+			conn.Conn.Close()
+		}
+	}
+}
+
+// Stats counts the rooms and participants held by this node
+func (r *RoomService) Stats() metrics.RoomStats {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	// Find host's connection
-	if connections, exists := r.Connections[roomID]; exists {
-		if hostConn, ok := connections[room.HostID]; ok {
-			return hostConn.Conn.WriteJSON(message)
+	var stats metrics.RoomStats
+	for _, connections := range r.Connections {
+		if len(connections) > 0 {
+			stats.ActiveRooms++
 		}
+		stats.Admitted += len(connections)
+	}
+	for _, waiting := range r.WaitingRoom {
+		stats.Waiting += len(waiting)
+	}
+	return stats
+}
+
+// memberFor describes a local connection for the presence store
+func memberFor(conn *Connection) bus.Member {
+	return bus.Member{
+		UserID:       conn.UserID,
+		ConnectionID: conn.ID,
+		Username:     conn.Username,
+		Name:         conn.Name,
+		AvatarURL:    conn.AvatarURL,
+		Status:       conn.Status,
+		JoinedAt:     conn.JoinedAt,
 	}
+}
 
-	return errors.New("host not connected")
+func participantOf(member bus.Member) models.Participant {
+	return models.Participant{
+		UserID:    member.UserID,
+		Username:  member.Username,
+		Name:      member.Name,
+		AvatarURL: member.AvatarURL,
+		JoinedAt:  member.JoinedAt,
+		Status:    member.Status,
+	}
+}
+
+// describe fills in how a connection's user appears to others. A failed
+// lookup leaves the participant shown by ID only. It reads the database, so
+// it must not be called with r.mu held.
+func (r *RoomService) describe(conn *Connection) {
+	user, err := r.AuthRepository.GetUserByID(conn.UserID)
+	if err != nil {
+		slog.Warn("Error loading participant profile", "user_id", conn.UserID, "error", err)
+		return
+	}
+	conn.Username = user.Username
+	conn.Name = user.Name
+	conn.AvatarURL = avatar.URL(user.AvatarName)
 }
 
 // handleMessages handles incoming WebSocket messages
-func (r *RoomService) handleMessages(roomID string, userID string, conn *websocket.Conn) error {
+func (r *RoomService) handleMessages(ctx context.Context, roomID string, userID string, conn *Socket, limiter *messageLimiter) error {
 	for {
-		var msg models.WebSocketMessage
-		err := conn.ReadJSON(&msg)
+		frameType, data, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("error reading message: %v", err)
+				slog.WarnContext(ctx, "Error reading message", "error", err)
 			}
 			return err
 		}
 
-		switch msg.Type {
-		case models.WSMessageTypeOffer,
-			models.WSMessageTypeAnswer,
-			models.WSMessageTypeIceCandidate:
-			// Forward WebRTC signaling messages to other participants
-			if err := r.broadcastToRoom(roomID, msg, userID); err != nil {
-				log.Printf("error broadcasting message: %v", err)
+		if done := r.handleFrame(ctx, roomID, userID, conn, limiter, frameType, data); done {
+			return nil
+		}
+	}
+}
+
+// handleFrame processes one frame from an admitted participant and reports
+// whether they left the room
+func (r *RoomService) handleFrame(ctx context.Context, roomID, userID string, conn *Socket, limiter *messageLimiter, frameType int, data []byte) bool {
+	if frameType != websocket.TextMessage {
+		sigErr := signalingError(models.ErrCodeMalformedMessage, "only text frames are accepted")
+		countInbound(nil, sigErr)
+		if !limiter.allow(DefaultMessageLimit) {
+			disconnectRateLimited(ctx, conn, nil)
+			return true
+		}
+		sendError(conn, "", sigErr)
+		return false
+	}
+
+	msg, payload, sigErr := decodeMessage(data)
+	countInbound(msg, sigErr)
+	leaving := sigErr == nil && msg.Type == models.WSMessageTypeLeave
+	if !leaving && !limiter.allow(inboundType(msg)) {
+		disconnectRateLimited(ctx, conn, msg)
+		return true
+	}
+	if sigErr != nil {
+		sendError(conn, messageID(msg), sigErr)
+		return false
+	}
+
+	if msg.Type == models.WSMessageTypeLeave {
+		r.removeConnection(roomID, userID, conn, models.AttendanceLeave)
+		r.broadcastToRoom(roomID, models.WebSocketMessage{
+			Type: models.WSMessageTypeLeave,
+			Payload: map[string]string{
+				"userId": userID,
+			},
+		}, userID)
+		return true
+	}
+
+	if sigErr := r.dispatchMessage(ctx, roomID, userID, msg, payload); sigErr != nil {
+		sendError(conn, msg.ID, sigErr)
+		return false
+	}
+	sendAck(conn, msg.ID)
+	return false
+}
+
+// isAdmitted reports whether a waiting connection has since been admitted
+func (r *RoomService) isAdmitted(conn *Connection) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return conn.Status == models.ParticipantStatusAdmitted
+}
+
+// dispatchMessage acts on a validated message from an admitted participant
+func (r *RoomService) dispatchMessage(ctx context.Context, roomID, userID string, msg *models.InboundMessage, payload models.SignalPayload) *SignalingError {
+	switch p := payload.(type) {
+	case *models.SessionDescriptionPayload:
+		// Forward WebRTC signaling messages to other participants
+		return r.relaySignal(ctx, roomID, userID, msg.Type, p.Target, p)
+
+	case *models.IceCandidatePayload:
+		return r.relaySignal(ctx, roomID, userID, msg.Type, p.Target, p)
+
+	case *models.ScreenSharePayload:
+		// Hosts may name another participant; everyone else stops their own share
+		targetID := p.UserID
+		if targetID == "" {
+			targetID = userID
+		}
+		if err := r.StopScreenShare(roomID, targetID, userID); err != nil {
+			return signalingError(models.ErrCodeScreenShareDenied, "%v", err)
+		}
+
+	default:
+		if msg.Type == models.WSMessageTypeScreenShareStart {
+			if err := r.StartScreenShare(roomID, userID); err != nil {
+				return signalingError(models.ErrCodeScreenShareDenied, "%v", err)
 			}
+		}
+	}
+	return nil
+}
 
-		case models.WSMessageTypeLeave:
-			r.removeConnection(roomID, userID)
-			r.broadcastToRoom(roomID, models.WebSocketMessage{
-				Type: models.WSMessageTypeLeave,
-				Payload: map[string]string{
-					"userId": userID,
-				},
-			}, userID)
-			return nil
+// relaySignal forwards a signaling payload to one peer, or to everyone else
+// in the room when no target is given
+func (r *RoomService) relaySignal(ctx context.Context, roomID, senderID, msgType, targetID string, payload models.SignalPayload) *SignalingError {
+	message := models.WebSocketMessage{
+		Type:    msgType,
+		From:    senderID,
+		Payload: payload,
+	}
 
-		default:
-			log.Printf("unknown message type: %s", msg.Type)
+	if targetID == "" {
+		if err := r.broadcastToRoom(roomID, message, senderID); err != nil {
+			slog.ErrorContext(ctx, "Error broadcasting message", "type", msgType, "error", err)
 		}
+		return nil
+	}
+
+	if !r.IsUserInRoom(roomID, targetID) {
+		return signalingError(models.ErrCodeUnknownTarget, "user %s is not in the room", targetID)
+	}
+	if err := r.sendToUser(roomID, targetID, message); err != nil {
+		slog.ErrorContext(ctx, "Error sending message", "target", targetID, "type", msgType, "error", err)
+	}
+	return nil
+}
+
+// messageID returns the correlation ID of a possibly undecodable message
+func messageID(msg *models.InboundMessage) string {
+	if msg == nil {
+		return ""
 	}
+	return msg.ID
 }
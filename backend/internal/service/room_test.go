@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// lockProbe counts calls and how many of them ran while the room service's
+// lock was held
+type lockProbe struct {
+	r      *RoomService
+	calls  atomic.Int32
+	locked atomic.Int32
+}
+
+func (p *lockProbe) check() {
+	p.calls.Add(1)
+	if !p.r.mu.TryLock() {
+		p.locked.Add(1)
+		return
+	}
+	p.r.mu.Unlock()
+}
+
+// lockCheckingAuthRepository probes profile lookups
+type lockCheckingAuthRepository struct {
+	AuthRepository
+	probe *lockProbe
+}
+
+func (l *lockCheckingAuthRepository) GetUserByID(userID string) (*models.User, error) {
+	l.probe.check()
+	return l.AuthRepository.GetUserByID(userID)
+}
+
+// lockCheckingAttendanceRepository probes attendance writes and counts the
+// intervals that were actually closed
+type lockCheckingAttendanceRepository struct {
+	AttendanceRepository
+	probe  *lockProbe
+	closed atomic.Int32
+}
+
+func (l *lockCheckingAttendanceRepository) OpenAttendance(interval *models.AttendanceInterval, newMeetingID string) error {
+	l.probe.check()
+	return l.AttendanceRepository.OpenAttendance(interval, newMeetingID)
+}
+
+func (l *lockCheckingAttendanceRepository) CloseAttendance(intervalID string, leftAt time.Time, reason string) (bool, error) {
+	l.probe.check()
+	closed, err := l.AttendanceRepository.CloseAttendance(intervalID, leftAt, reason)
+	if closed {
+		l.closed.Add(1)
+	}
+	return closed, err
+}
+
+// joinHandler is HandleWebSocket or HandleWaitingRoom
+type joinHandler func(ctx context.Context, roomID, userID string, protocolVersion int, conn *Socket) error
+
+func TestJoinDescribesOutsideLock(t *testing.T) {
+	tests := []struct {
+		name   string
+		handle func(r *RoomService) joinHandler
+	}{
+		{name: "admitted", handle: func(r *RoomService) joinHandler { return r.HandleWebSocket }},
+		{name: "waiting", handle: func(r *RoomService) joinHandler { return r.HandleWaitingRoom }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDB(t)
+			r := newTestRoomServiceOn(t, database)
+			user := registerTestUser(t, newTestAuthService(t, database, LoginLimits{}), "alice")
+			createTestRoom(t, r, "room", user.ID, nil)
+			probe := &lockProbe{r: r}
+			r.AuthRepository = &lockCheckingAuthRepository{AuthRepository: r.AuthRepository, probe: probe}
+
+			done := make(chan struct{})
+			client := dialTestSocket(t, func(socket *Socket) {
+				defer close(done)
+				tt.handle(r)(context.Background(), "room", user.ID, models.CurrentProtocol, socket)
+			})
+			// The participant joins before the first read notices the hang-up
+			client.Close()
+			<-done
+
+			if probe.calls.Load() == 0 {
+				t.Fatal("profile was never looked up")
+			}
+			if n := probe.locked.Load(); n != 0 {
+				t.Errorf("%d profile lookups ran with the lock held", n)
+			}
+		})
+	}
+}
+
+func TestAttendanceWrittenOutsideLock(t *testing.T) {
+	tests := []struct {
+		name  string
+		admit bool // join through the waiting room and get admitted
+	}{
+		{name: "joined directly"},
+		{name: "admitted from the waiting room", admit: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoomService(t)
+			createTestRoom(t, r, "room", "host", nil)
+			probe := &lockProbe{r: r}
+			attendance := &lockCheckingAttendanceRepository{AttendanceRepository: r.AttendanceRepository, probe: probe}
+			r.AttendanceRepository = attendance
+
+			handle := r.HandleWebSocket
+			if tt.admit {
+				handle = r.HandleWaitingRoom
+			}
+			done := make(chan struct{})
+			client := dialTestSocket(t, func(socket *Socket) {
+				defer close(done)
+				handle(context.Background(), "room", "alice", models.CurrentProtocol, socket)
+			})
+
+			if tt.admit {
+				waitFor(t, func() bool {
+					r.mu.RLock()
+					defer r.mu.RUnlock()
+					return r.WaitingRoom["room"]["alice"] != nil
+				})
+				if err := r.Bus.Publish(bus.Event{Kind: bus.EventAdmit, RoomID: "room", Target: "alice"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			waitFor(t, func() bool { return probe.calls.Load() == 1 })
+			client.Close()
+			<-done
+
+			if n := probe.calls.Load(); n != 2 {
+				t.Errorf("%d attendance writes, want an open and a close", n)
+			}
+			if n := probe.locked.Load(); n != 0 {
+				t.Errorf("%d attendance writes ran with the lock held", n)
+			}
+			if n := attendance.closed.Load(); n != 1 {
+				t.Errorf("%d intervals closed, want 1", n)
+			}
+		})
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a second
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCanAccessRoom(t *testing.T) {
+	tests := []struct {
+		name      string
+		userID    string
+		configure func(room *models.Room)
+		invited   bool
+		want      error
+	}{
+		{name: "public", userID: "guest"},
+		{name: "ended", userID: "guest", configure: func(room *models.Room) { room.Status = models.RoomStatusInactive }, want: utils.ErrRoomEnded},
+		{name: "ended, as host", userID: "host", configure: func(room *models.Room) { room.Status = models.RoomStatusInactive }, want: utils.ErrRoomEnded},
+		{name: "unlisted", userID: "guest", configure: func(room *models.Room) { room.Visibility = models.RoomVisibilityUnlisted }},
+		{name: "private, as host", userID: "host", configure: func(room *models.Room) { room.Visibility = models.RoomVisibilityPrivate }},
+		{name: "private, invited", userID: "guest", invited: true, configure: func(room *models.Room) { room.Visibility = models.RoomVisibilityPrivate }},
+		{name: "private, not invited", userID: "guest", configure: func(room *models.Room) { room.Visibility = models.RoomVisibilityPrivate }, want: utils.ErrRoomNotFound},
+		{
+			// Whether a private room has ended is none of an outsider's business
+			name:   "private and ended, not invited",
+			userID: "guest",
+			configure: func(room *models.Room) {
+				room.Visibility = models.RoomVisibilityPrivate
+				room.Status = models.RoomStatusInactive
+			},
+			want: utils.ErrRoomNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoomService(t)
+			createTestRoom(t, r, "room", "host", tt.configure)
+			if tt.invited {
+				if err := r.RoomRepository.Invite(models.RoomInvitation{RoomID: "room", UserID: tt.userID, InvitedAt: time.Now()}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := r.CanAccessRoom("room", tt.userID); !errors.Is(err, tt.want) {
+				t.Errorf("CanAccessRoom() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if err := newTestRoomService(t).CanAccessRoom("missing", "guest"); !errors.Is(err, utils.ErrRoomNotFound) {
+		t.Errorf("CanAccessRoom(missing) error = %v, want %v", err, utils.ErrRoomNotFound)
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// errNotInRoom refuses screen shares from participants who aren't admitted
+var errNotInRoom = errors.New("user not found in room")
+
+// StartScreenShare marks a participant as sharing their screen if the room's
+// policy allows it and tells everyone in the room, including the sharer. The
+// check and the update are one atomic step on the bus, so two nodes can't
+// both let someone in under the single-sharer policy.
+func (r *RoomService) StartScreenShare(roomID, userID string) error {
+	room, err := r.RoomRepository.GetRoom(roomID)
+	if err != nil {
+		return err
+	}
+
+	err = r.Bus.UpdateMember(roomID, userID, func(member bus.Member, members map[string]bus.Member) (bus.Member, error) {
+		if member.Status != models.ParticipantStatusAdmitted {
+			return member, errNotInRoom
+		}
+
+		switch room.ScreenSharePolicy {
+		case models.ScreenSharePolicyHostOnly:
+			if room.HostID != userID {
+				return member, errors.New("only host can share their screen")
+			}
+		case models.ScreenSharePolicySingle:
+			for otherID, other := range members {
+				if otherID != userID && other.ScreenSharing {
+					return member, errors.New("another participant is already sharing their screen")
+				}
+			}
+		}
+
+		member.ScreenSharing = true
+		return member, nil
+	})
+	if errors.Is(err, bus.ErrMemberNotFound) {
+		return errNotInRoom
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.broadcastToRoom(roomID, models.WebSocketMessage{
+		Type: models.WSMessageTypeScreenShareStart,
+		Payload: map[string]string{
+			"userId": userID,
+		},
+	}, "")
+}
+
+// StopScreenShare ends a participant's screen share. Participants may stop
+// their own share; only the host may stop someone else's.
+func (r *RoomService) StopScreenShare(roomID, participantID, requesterID string) error {
+	if participantID != requesterID {
+		room, err := r.RoomRepository.GetRoom(roomID)
+		if err != nil {
+			return err
+		}
+		if room.HostID != requesterID {
+			return errors.New("only host can stop another participant's screen share")
+		}
+	}
+
+	errNotSharing := errors.New("participant is not sharing their screen")
+	err := r.Bus.UpdateMember(roomID, participantID, func(member bus.Member, _ map[string]bus.Member) (bus.Member, error) {
+		if !member.ScreenSharing {
+			return member, errNotSharing
+		}
+		member.ScreenSharing = false
+		return member, nil
+	})
+	if errors.Is(err, bus.ErrMemberNotFound) {
+		return errNotSharing
+	}
+	if err != nil {
+		return err
+	}
+
+	// The sharer's client stops its track when it sees its own ID here
+	return r.broadcastToRoom(roomID, models.WebSocketMessage{
+		Type: models.WSMessageTypeScreenShareStop,
+		Payload: map[string]string{
+			"userId":    participantID,
+			"stoppedBy": requesterID,
+		},
+	}, "")
+}
+
+// UpdateScreenSharePolicy changes who may share in a room. Shares already in
+// progress are left running; the new policy applies to the next start.
+func (r *RoomService) UpdateScreenSharePolicy(ctx context.Context, roomID, policy, hostID string) error {
+	if !models.IsValidScreenSharePolicy(policy) {
+		return utils.ErrInvalidScreenSharePolicy
+	}
+
+	room, err := r.RoomRepository.GetRoom(roomID)
+	if err != nil {
+		return err
+	}
+
+	if room.HostID != hostID {
+		return errors.New("only host can change the screen share policy")
+	}
+
+	if err := r.RoomRepository.UpdateScreenSharePolicy(roomID, policy); err != nil {
+		return err
+	}
+	r.Audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditRoomSettingsChanged,
+		TargetType: models.AuditTargetRoom,
+		TargetID:   roomID,
+		RoomID:     roomID,
+		Details:    map[string]string{"setting": "screenSharePolicy", "from": room.ScreenSharePolicy, "to": policy},
+	})
+	room.ScreenSharePolicy = policy
+
+	r.broadcastToRoom(roomID, models.WebSocketMessage{
+		Type:    models.WSMessageTypeRoomState,
+		Payload: r.roomState(room),
+	}, "")
+	return nil
+}
+
+// roomState builds the snapshot sent to late joiners
+func (r *RoomService) roomState(room *models.Room) models.RoomState {
+	state := models.RoomState{
+		RoomID:            room.ID,
+		HostID:            room.HostID,
+		Participants:      make([]string, 0),
+		ScreenSharePolicy: room.ScreenSharePolicy,
+		ScreenSharers:     make([]string, 0),
+	}
+
+	members, err := r.Bus.Members(room.ID)
+	if err != nil {
+		slog.Error("Error reading presence", "room_id", room.ID, "error", err)
+		return state
+	}
+
+	for userID, member := range members {
+		if member.Status == models.ParticipantStatusAdmitted {
+			state.Participants = append(state.Participants, userID)
+		}
+	}
+	state.ScreenSharers = screenSharers(members)
+	return state
+}
+
+// screenSharers returns the IDs of everyone currently sharing in a room
+func screenSharers(members map[string]bus.Member) []string {
+	sharers := make([]string, 0)
+	for userID, member := range members {
+		if member.ScreenSharing {
+			sharers = append(sharers, userID)
+		}
+	}
+	return sharers
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+func TestStartScreenSharePolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       string
+		sharer       string // who asks to share
+		guestStatus  string // the guest's presence, if any
+		guestSharing bool   // whether the guest is already sharing
+		hostSharing  bool   // whether the host is already sharing
+		wantErr      bool
+	}{
+		{name: "anyone lets a guest share", policy: models.ScreenSharePolicyAnyone, sharer: "guest", guestStatus: models.ParticipantStatusAdmitted},
+		{name: "anyone allows several sharers", policy: models.ScreenSharePolicyAnyone, sharer: "guest", guestStatus: models.ParticipantStatusAdmitted, hostSharing: true},
+		{name: "host-only lets the host share", policy: models.ScreenSharePolicyHostOnly, sharer: "host"},
+		{name: "host-only refuses a guest", policy: models.ScreenSharePolicyHostOnly, sharer: "guest", guestStatus: models.ParticipantStatusAdmitted, wantErr: true},
+		{name: "single lets the first sharer in", policy: models.ScreenSharePolicySingle, sharer: "guest", guestStatus: models.ParticipantStatusAdmitted},
+		{name: "single refuses a second sharer", policy: models.ScreenSharePolicySingle, sharer: "guest", guestStatus: models.ParticipantStatusAdmitted, hostSharing: true, wantErr: true},
+		{name: "single lets a sharer start again", policy: models.ScreenSharePolicySingle, sharer: "guest", guestStatus: models.ParticipantStatusAdmitted, guestSharing: true},
+		{name: "waiting participants can't share", policy: models.ScreenSharePolicyAnyone, sharer: "guest", guestStatus: models.ParticipantStatusWaiting, wantErr: true},
+		{name: "absent participants can't share", policy: models.ScreenSharePolicyAnyone, sharer: "guest", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoomService(t)
+			createTestRoom(t, r, "room", "host", func(room *models.Room) {
+				room.ScreenSharePolicy = tt.policy
+			})
+			setTestMember(t, r, "room", bus.Member{UserID: "host", Status: models.ParticipantStatusAdmitted, ScreenSharing: tt.hostSharing})
+			if tt.guestStatus != "" {
+				setTestMember(t, r, "room", bus.Member{UserID: "guest", Status: tt.guestStatus, ScreenSharing: tt.guestSharing})
+			}
+
+			err := r.StartScreenShare("room", tt.sharer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StartScreenShare() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			members, err := r.Bus.Members("room")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.wantErr && !members[tt.sharer].ScreenSharing {
+				t.Errorf("%s is not marked as sharing", tt.sharer)
+			}
+			if tt.wantErr && tt.guestStatus != "" && members["guest"].ScreenSharing != tt.guestSharing {
+				t.Errorf("refused share changed the guest's presence")
+			}
+		})
+	}
+}
+
+func TestStopScreenShare(t *testing.T) {
+	tests := []struct {
+		name      string
+		requester string
+		target    string
+		sharing   bool
+		wantErr   bool
+	}{
+		{name: "sharer stops their own share", requester: "guest", target: "guest", sharing: true},
+		{name: "host stops someone's share", requester: "host", target: "guest", sharing: true},
+		{name: "guest can't stop someone else's share", requester: "other", target: "guest", sharing: true, wantErr: true},
+		{name: "stopping a share that isn't running fails", requester: "guest", target: "guest", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoomService(t)
+			createTestRoom(t, r, "room", "host", nil)
+			setTestMember(t, r, "room", bus.Member{UserID: "guest", Status: models.ParticipantStatusAdmitted, ScreenSharing: tt.sharing})
+
+			err := r.StopScreenShare("room", tt.target, tt.requester)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StopScreenShare() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			members, err := r.Bus.Members("room")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.wantErr && members[tt.target].ScreenSharing {
+				t.Errorf("%s is still marked as sharing", tt.target)
+			}
+		})
+	}
+}
+
+func TestUpdateScreenSharePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		hostID  string
+		wantErr bool
+	}{
+		{name: "host changes the policy", policy: models.ScreenSharePolicySingle, hostID: "host"},
+		{name: "unknown policies are refused", policy: "everyone", hostID: "host", wantErr: true},
+		{name: "only the host may change it", policy: models.ScreenSharePolicyHostOnly, hostID: "guest", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoomService(t)
+			createTestRoom(t, r, "room", "host", nil)
+
+			err := r.UpdateScreenSharePolicy(context.Background(), "room", tt.policy, tt.hostID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateScreenSharePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			room, err := r.RoomRepository.GetRoom("room")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := models.ScreenSharePolicyAnyone
+			if !tt.wantErr {
+				want = tt.policy
+			}
+			if room.ScreenSharePolicy != want {
+				t.Errorf("policy = %q, want %q", room.ScreenSharePolicy, want)
+			}
+		})
+	}
+}
+
+func TestStartScreenShareSingleIsExclusive(t *testing.T) {
+	const guests = 20
+
+	r := newTestRoomService(t)
+	createTestRoom(t, r, "room", "host", func(room *models.Room) {
+		room.ScreenSharePolicy = models.ScreenSharePolicySingle
+	})
+	for i := 0; i < guests; i++ {
+		setTestMember(t, r, "room", bus.Member{UserID: fmt.Sprintf("guest%d", i), Status: models.ParticipantStatusAdmitted})
+	}
+
+	var wg sync.WaitGroup
+	var granted atomic.Int32
+	for i := 0; i < guests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if r.StartScreenShare("room", fmt.Sprintf("guest%d", i)) == nil {
+				granted.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	members, err := r.Bus.Members("room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := granted.Load(); got != 1 || len(screenSharers(members)) != 1 {
+		t.Errorf("%d shares granted, %d sharing, want exactly one", got, len(screenSharers(members)))
+	}
+}
+
+func TestScreenShareDoesNotRestoreDepartedMembers(t *testing.T) {
+	r := newTestRoomService(t)
+	createTestRoom(t, r, "room", "host", nil)
+	setTestMember(t, r, "room", bus.Member{UserID: "guest", Status: models.ParticipantStatusAdmitted, ScreenSharing: true})
+	if err := r.Bus.RemoveMember("room", "guest", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.StartScreenShare("room", "guest"); err == nil {
+		t.Error("StartScreenShare() succeeded for a participant who left")
+	}
+	if err := r.StopScreenShare("room", "guest", "host"); err == nil {
+		t.Error("StopScreenShare() succeeded for a participant who left")
+	}
+
+	members, err := r.Bus.Members("room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := members["guest"]; exists {
+		t.Error("departed participant is present again")
+	}
+}
@@ -1,21 +1,81 @@
 package service
 
 import (
+	"io"
+	"net/http"
 	"sync"
+	"sync/atomic"
 
-	"github.com/legendary-acp/chimecast/internal/repositories"
+	"github.com/legendary-acp/chimecast/internal/avatar"
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/mailer"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/ratelimit"
 	"github.com/legendary-acp/chimecast/internal/session"
 )
 
 type AuthService struct {
-	AuthRepository *repositories.AuthRepository
-	SessionManager *session.SessionManager
+	AuthRepository         AuthRepository
+	LoginAttemptRepository LoginAttemptRepository
+	TokenRepository        TokenRepository
+	APITokenRepository     APITokenRepository
+	SessionManager         *session.SessionManager
+	Mailer                 mailer.Mailer
+	LoginLimits            LoginLimits
+	PublicURL              string        // where the web app is served, for links in emails
+	RequireVerifiedEmail   bool          // only users with a confirmed email address may host rooms
+	RequireMFA             bool          // every user must enrol a second factor before using rooms
+	OIDC                   *OIDCProvider // single sign-on provider, nil when disabled
+	Avatars                avatar.Store  // where profile pictures are kept
+	Audit                  *AuditService // records logins and failed logins
+
+	// OnAPITokensRevoked is told the IDs of revoked API tokens, so sockets
+	// opened with them can be closed; nil for none
+	OnAPITokensRevoked func(tokenIDs []string)
 }
 
 // RoomService handles room operations and WebRTC signaling
 type RoomService struct {
-	RoomRepository *repositories.RoomRepository
-	mu             sync.RWMutex
-	Connections    map[string]map[string]*Connection // roomID -> userID -> Connection
-	WaitingRoom    map[string]map[string]*Connection // roomID -> userID -> Connection
+	RoomRepository       RoomRepository
+	AuthRepository       AuthRepository            // participant names and avatars
+	AttendanceRepository AttendanceRepository      // who was in each meeting, and when
+	Audit                *AuditService             // records moderation and settings changes
+	Webhooks             *WebhookService           // tells subscribers about room and participant events
+	Bus                  bus.Bus                   // cross-node fan-out and presence
+	MessageLimits        map[string]ratelimit.Rate // inbound WebSocket messages per connection, by type
+	mu                   sync.RWMutex
+	Connections          map[string]map[string]*Connection // roomID -> userID -> Connection held by this node
+	WaitingRoom          map[string]map[string]*Connection // roomID -> userID -> Connection held by this node
+	draining             atomic.Bool                       // set on shutdown; no new joins are accepted
+}
+
+// AuditService keeps the append-only audit log
+type AuditService struct {
+	AuditRepository AuditRepository
+	Sink            io.Writer  // also receives every event as a JSON line; nil for none
+	sinkMu          sync.Mutex // keeps concurrent lines whole
+}
+
+// WebhookService delivers room events to the endpoints users registered,
+// signed and retried with backoff
+type WebhookService struct {
+	WebhookRepository WebhookRepository
+	RoomRepository    RoomRepository // who hosts a room, for room-scoped webhooks
+	Client            *http.Client
+	Settings          WebhookSettings
+	events            chan models.RoomEvent // published, waiting to be queued for delivery
+	sending           chan struct{}         // one slot per delivery in flight
+}
+
+// AdminService carries out server-wide moderation across accounts and rooms
+type AdminService struct {
+	AuthService *AuthService
+	RoomService *RoomService
+}
+
+// PrivacyService exports and erases a user's data across accounts and rooms
+type PrivacyService struct {
+	AuthService *AuthService
+	RoomService *RoomService
+	Webhooks    *WebhookService
 }
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/legendary-acp/chimecast/internal/bus"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/session"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// ListSessions returns the user's live sessions, most recently used first
+func (a *AuthService) ListSessions(userID string) []session.Session {
+	return a.SessionManager.UserSessions(userID)
+}
+
+// RevokeSession signs out one of the user's sessions. Any WebSocket it
+// opened is closed through the session manager's revoke hook.
+func (a *AuthService) RevokeSession(userID, sessionID string) error {
+	return a.SessionManager.RevokeSession(userID, sessionID)
+}
+
+// RevokeOtherSessions signs out every session of the user except the current
+// one and returns how many were ended
+func (a *AuthService) RevokeOtherSessions(userID, currentSessionID string) int {
+	return a.SessionManager.RevokeOtherSessions(userID, currentSessionID)
+}
+
+// CheckSession refuses sessions of erased or disabled accounts, and those
+// started before the user's sessions were last revoked from the command
+// line. Sessions live in memory, so this is how changes made straight in
+// the database reach a running server.
+func (a *AuthService) CheckSession(userID string, createdAt time.Time) error {
+	user, err := a.AuthRepository.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.DeletedAt != nil || user.DisabledAt != nil {
+		return utils.ErrAccountDisabled
+	}
+	if user.SessionsRevokedAt != nil && createdAt.Before(*user.SessionsRevokedAt) {
+		return utils.ErrSessionRevoked
+	}
+	return nil
+}
+
+// CloseSessions disconnects every WebSocket on this node that was opened by
+// one of the given sessions. It is registered as the session manager's
+// revoke hook.
+func (r *RoomService) CloseSessions(sessionIDs []string) {
+	revoked := make(map[string]bool, len(sessionIDs))
+	for _, id := range sessionIDs {
+		revoked[id] = true
+	}
+	r.closeSockets("session revoked", func(conn *Connection) bool {
+		return conn.SessionID != "" && revoked[conn.SessionID]
+	})
+}
+
+// CloseAPITokens disconnects every WebSocket in the cluster that was opened
+// with one of the given API tokens. Unlike sessions, tokens aren't tied to a
+// node, so the sockets may be anywhere.
+func (r *RoomService) CloseAPITokens(tokenIDs []string) {
+	if len(tokenIDs) == 0 {
+		return
+	}
+	if err := r.Bus.Publish(bus.Event{Kind: bus.EventCloseAPITokens, Tokens: tokenIDs}); err != nil {
+		slog.Error("Error closing sockets of revoked API tokens", "error", err)
+	}
+}
+
+// closeAPITokenSockets closes this node's sockets opened with the tokens
+func (r *RoomService) closeAPITokenSockets(tokenIDs []string) {
+	revoked := make(map[string]bool, len(tokenIDs))
+	for _, id := range tokenIDs {
+		revoked[id] = true
+	}
+	r.closeSockets("token revoked", func(conn *Connection) bool {
+		return conn.APITokenID != "" && revoked[conn.APITokenID]
+	})
+}
+
+// closeSockets closes the matching sockets on this node, admitted or waiting,
+// with a policy violation carrying reason
+func (r *RoomService) closeSockets(reason string, match func(conn *Connection) bool) {
+	closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, connections := range []map[string]map[string]*Connection{r.Connections, r.WaitingRoom} {
+		for _, room := range connections {
+			for _, conn := range room {
+				if match(conn) {
+					conn.Conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+					conn.Conn.Close()
+				}
+			}
+		}
+	}
+}
+
+// sessionIDFrom returns the public session ID AuthMiddleware put in the
+// request context
+func sessionIDFrom(ctx context.Context) string {
+	sessionID, _ := ctx.Value("sessionID").(string)
+	return sessionID
+}
+
+// apiTokenIDFrom returns the ID of the API token AuthMiddleware put in the
+// request context, if the request used one
+func apiTokenIDFrom(ctx context.Context) string {
+	if token, ok := ctx.Value("apiToken").(*models.APIToken); ok {
+		return token.ID
+	}
+	return ""
+}
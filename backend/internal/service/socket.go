@@ -0,0 +1,33 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Socket is a WebSocket connection written to from several goroutines: the
+// one reading the client's frames, which replies with acks and errors, and
+// those delivering room events or closing sessions. gorilla/websocket allows
+// one writer at a time, so data frames are serialized here; WriteControl and
+// Close are already safe to call concurrently.
+type Socket struct {
+	*websocket.Conn
+	writeMu sync.Mutex
+}
+
+func NewSocket(conn *websocket.Conn) *Socket {
+	return &Socket{Conn: conn}
+}
+
+func (s *Socket) WriteMessage(messageType int, data []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.Conn.WriteMessage(messageType, data)
+}
+
+func (s *Socket) WriteJSON(v interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.Conn.WriteJSON(v)
+}
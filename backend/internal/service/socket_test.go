@@ -0,0 +1,88 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/legendary-acp/chimecast/internal/models"
+)
+
+// dialTestSocket connects a client to a server-side Socket handed to serve
+func dialTestSocket(t *testing.T, serve func(*Socket)) *websocket.Conn {
+	t.Helper()
+	return dialTestSocketWith(t, websocket.Upgrader{}, nil, func(socket *Socket, _ []string) {
+		serve(socket)
+	})
+}
+
+// dialTestSocketWith is dialTestSocket with a given upgrader and requested
+// subprotocols, which serve also receives
+func dialTestSocketWith(t *testing.T, upgrader websocket.Upgrader, subprotocols []string, serve func(*Socket, []string)) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		socket := NewSocket(conn)
+		defer socket.Close()
+		serve(socket, websocket.Subprotocols(r))
+	}))
+	t.Cleanup(server.Close)
+
+	dialer := websocket.Dialer{Subprotocols: subprotocols}
+	client, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestSocketConcurrentWrites(t *testing.T) {
+	const writers, perWriter = 8, 50
+	// Frames large enough that writes from different goroutines overlap
+	id := strings.Repeat("x", 64<<10)
+	raw := []byte(`{"type":"ack","id":"` + id + `"}`)
+
+	done := make(chan struct{})
+	client := dialTestSocket(t, func(socket *Socket) {
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < perWriter; j++ {
+					var err error
+					if i%2 == 0 {
+						err = writeMessage(socket, models.WebSocketMessage{Type: models.WSMessageTypeAck, ID: id})
+					} else {
+						err = socket.WriteMessage(websocket.TextMessage, raw)
+					}
+					if err != nil {
+						t.Errorf("write: %v", err)
+						return
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		<-done
+	})
+	defer close(done)
+
+	for n := 0; n < writers*perWriter; n++ {
+		var msg models.WebSocketMessage
+		if err := client.ReadJSON(&msg); err != nil {
+			t.Fatalf("frame %d: %v", n, err)
+		}
+		if msg.Type != models.WSMessageTypeAck || msg.ID != id {
+			t.Fatalf("frame %d was garbled", n)
+		}
+	}
+}
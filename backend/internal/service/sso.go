@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/session"
+	"github.com/legendary-acp/chimecast/internal/utils"
+	"golang.org/x/oauth2"
+)
+
+// OIDCConfig describes the OpenID Connect provider users may sign in with
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // this server's callback, registered with the provider
+	Scopes       []string
+
+	// LinkByEmail lets a first SSO login take over an existing local account
+	// when both sides have verified the same email address
+	LinkByEmail bool
+}
+
+// OIDCProvider discovers the provider's endpoints and keys on first use, so a
+// provider that is briefly down doesn't stop the server from starting
+type OIDCProvider struct {
+	config OIDCConfig
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func NewOIDCProvider(config OIDCConfig) *OIDCProvider {
+	return &OIDCProvider{config: config}
+}
+
+// OIDCAuthState is kept by the browser between the redirect to the provider
+// and the callback
+type OIDCAuthState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code verifier
+	ReturnTo string `json:"returnTo"`
+}
+
+// oidcClaims are the ID token claims used to find or provision a user
+type oidcClaims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Nonce             string `json:"nonce"`
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider == nil {
+		// Keys are refetched in the background for the life of the provider,
+		// so it must not be tied to the request's context
+		provider, err := oidc.NewProvider(context.Background(), p.config.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover oidc provider: %w", err)
+		}
+		p.provider = provider
+	}
+	return p.provider, nil
+}
+
+func (p *OIDCProvider) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.config.Scopes,
+	}
+}
+
+// SSOEnabled reports whether an OpenID Connect provider is configured
+func (a *AuthService) SSOEnabled() bool {
+	return a.OIDC != nil
+}
+
+// BeginOIDCLogin returns the provider URL to send the browser to, and the
+// state the browser must bring back to the callback
+func (a *AuthService) BeginOIDCLogin(ctx context.Context, returnTo string) (string, *OIDCAuthState, error) {
+	provider, err := a.OIDC.discover(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	state, err := utils.NewToken()
+	if err != nil {
+		return "", nil, err
+	}
+	nonce, err := utils.NewToken()
+	if err != nil {
+		return "", nil, err
+	}
+	authState := &OIDCAuthState{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+		ReturnTo: returnTo,
+	}
+
+	authURL := a.OIDC.oauth2Config(provider).AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(authState.Verifier))
+	return authURL, authState, nil
+}
+
+// CompleteOIDCLogin exchanges the authorization code, validates the ID token
+// and signs in the user it names, provisioning them on first login
+func (a *AuthService) CompleteOIDCLogin(ctx context.Context, authState *OIDCAuthState, code string, client session.Client) (*LoginResult, error) {
+	provider, err := a.OIDC.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := a.OIDC.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(authState.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	// Checks signature against the provider's JWKS, issuer, audience and expiry
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: a.OIDC.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("invalid id token claims: %w", err)
+	}
+	if claims.Nonce != authState.Nonce {
+		return nil, errors.New("id token nonce does not match")
+	}
+
+	user, err := a.userForIdentity(idToken.Issuer, claims)
+	if err != nil {
+		return nil, err
+	}
+	return a.startSession(user, client, time.Now())
+}
+
+// userForIdentity finds the user linked to the identity, links an existing
+// user with the same verified email, or provisions a new user
+func (a *AuthService) userForIdentity(issuer string, claims oidcClaims) (*models.User, error) {
+	user, err := a.AuthRepository.GetUserByIdentity(issuer, claims.Subject)
+	if !errors.Is(err, utils.ErrUserNotFound) {
+		return user, err
+	}
+
+	identity := models.UserIdentity{
+		Issuer:    issuer,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: time.Now(),
+	}
+
+	if claims.Email != "" {
+		existing, err := a.AuthRepository.GetUserByEmail(claims.Email)
+		switch {
+		case errors.Is(err, utils.ErrUserNotFound):
+		case err != nil:
+			return nil, err
+		// Both sides must vouch for the address, or whoever registered it
+		// locally first could capture the SSO user's logins
+		case a.OIDC.config.LinkByEmail && claims.EmailVerified && existing.EmailVerified:
+			identity.UserID = existing.ID
+			if err := a.AuthRepository.LinkIdentity(identity); err != nil {
+				return nil, err
+			}
+			slog.Info("Linked external identity", "user", existing, "issuer", issuer)
+			return existing, nil
+		default:
+			return nil, utils.ErrUserAlreadyExists
+		}
+	}
+
+	username, err := a.availableUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+	name := claims.Name
+	if name == "" {
+		name = username
+	}
+
+	// No password: these users sign in through the provider, or set one via
+	// the reset flow
+	newUser := models.User{
+		ID:            utils.CreateNewUUID(),
+		Username:      username,
+		Name:          name,
+		Email:         claims.Email,
+		CreatedAt:     time.Now(),
+		EmailVerified: claims.EmailVerified,
+	}
+	identity.UserID = newUser.ID
+	if err := a.AuthRepository.RegisterUserWithIdentity(newUser, identity); err != nil {
+		return nil, err
+	}
+	return &newUser, nil
+}
+
+// availableUsername picks a free username based on what the provider knows
+// about the user
+func (a *AuthService) availableUsername(claims oidcClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = strings.Map(func(r rune) rune {
+		if r == '.' || r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, strings.ToLower(base))
+	if base == "" {
+		base = "user"
+	}
+
+	for i := 1; i <= 100; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = base + strconv.Itoa(i)
+		}
+		_, err := a.AuthRepository.GetUserByUsername(candidate)
+		if errors.Is(err, utils.ErrUserNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", errors.New("no free username available")
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/legendary-acp/chimecast/internal/session"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+const testClientID = "chimecast"
+
+// testOIDCProvider is a minimal OpenID Connect provider: discovery, JWKS and a
+// token endpoint that enforces PKCE. Codes are issued directly by the test
+// rather than through a browser at the authorization endpoint.
+type testOIDCProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]testAuthorization
+}
+
+type testAuthorization struct {
+	challenge string
+	claims    map[string]any
+}
+
+func newTestOIDCProvider(t *testing.T) *testOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testOIDCProvider{key: key, codes: make(map[string]testAuthorization)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: string(jose.RS256), Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("POST /token", p.token)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *testOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.mu.Lock()
+	authorization, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != authorization.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := p.sign(authorization.claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (p *testOIDCProvider) sign(claims map[string]any) (string, error) {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: p.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"))
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+	return signed.CompactSerialize()
+}
+
+// authorize stands in for the user approving the login at the provider: it
+// reads the challenge off the authorization URL and returns a code that will
+// yield an ID token with the given claims
+func (p *testOIDCProvider) authorize(t *testing.T, authURL string, claims map[string]any) string {
+	t.Helper()
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := utils.NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	p.codes[code] = testAuthorization{challenge: parsed.Query().Get("code_challenge"), claims: claims}
+	p.mu.Unlock()
+	return code
+}
+
+// claims returns valid ID token claims for subject, bound to nonce
+func (p *testOIDCProvider) claims(subject, nonce string) map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":            p.URL,
+		"aud":            testClientID,
+		"sub":            subject,
+		"nonce":          nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"email":          subject + "@sso.example.com",
+		"email_verified": true,
+		"name":           "SSO " + subject,
+	}
+}
+
+func newTestSSOService(t *testing.T, provider *testOIDCProvider) *AuthService {
+	t.Helper()
+	a := newTestAuthService(t, newTestDB(t), LoginLimits{})
+	a.OIDC = NewOIDCProvider(OIDCConfig{
+		Issuer:      provider.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:8080/api/auth/v1/oidc/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+	})
+	return a
+}
+
+func TestBeginOIDCLogin(t *testing.T) {
+	provider := newTestOIDCProvider(t)
+	a := newTestSSOService(t, provider)
+
+	authURL, authState, err := a.BeginOIDCLogin(context.Background(), "/rooms")
+	if err != nil {
+		t.Fatalf("BeginOIDCLogin() error = %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(authState.Verifier))
+
+	query := parsed.Query()
+	tests := []struct {
+		param string
+		want  string
+	}{
+		{param: "state", want: authState.State},
+		{param: "nonce", want: authState.Nonce},
+		{param: "code_challenge", want: base64.RawURLEncoding.EncodeToString(sum[:])},
+		{param: "code_challenge_method", want: "S256"},
+		{param: "client_id", want: testClientID},
+		{param: "response_type", want: "code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.param, func(t *testing.T) {
+			if got := query.Get(tt.param); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.param, got, tt.want)
+			}
+		})
+	}
+	if got := parsed.Scheme + "://" + parsed.Host + parsed.Path; got != provider.URL+"/authorize" {
+		t.Errorf("auth URL = %q, want the provider's authorization endpoint", got)
+	}
+	if authState.ReturnTo != "/rooms" {
+		t.Errorf("ReturnTo = %q, want %q", authState.ReturnTo, "/rooms")
+	}
+}
+
+func TestCompleteOIDCLogin(t *testing.T) {
+	provider := newTestOIDCProvider(t)
+
+	tests := []struct {
+		name string
+		// adjust edits the claims or the state the browser brings back
+		adjust  func(claims map[string]any, authState *OIDCAuthState)
+		wantErr bool
+	}{
+		{name: "valid token signs in", adjust: func(map[string]any, *OIDCAuthState) {}},
+		{
+			name:    "wrong audience",
+			adjust:  func(claims map[string]any, _ *OIDCAuthState) { claims["aud"] = "someone-else" },
+			wantErr: true,
+		},
+		{
+			name:    "wrong issuer",
+			adjust:  func(claims map[string]any, _ *OIDCAuthState) { claims["iss"] = "https://evil.example.com" },
+			wantErr: true,
+		},
+		{
+			name:    "nonce from another login",
+			adjust:  func(claims map[string]any, _ *OIDCAuthState) { claims["nonce"] = "replayed" },
+			wantErr: true,
+		},
+		{
+			name: "expired token",
+			adjust: func(claims map[string]any, _ *OIDCAuthState) {
+				claims["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			},
+			wantErr: true,
+		},
+		{
+			name:    "PKCE verifier that doesn't match the challenge",
+			adjust:  func(_ map[string]any, authState *OIDCAuthState) { authState.Verifier = "not-the-verifier" },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestSSOService(t, provider)
+			authURL, authState, err := a.BeginOIDCLogin(context.Background(), "/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims := provider.claims("alice", authState.Nonce)
+			tt.adjust(claims, authState)
+			code := provider.authorize(t, authURL, claims)
+
+			result, err := a.CompleteOIDCLogin(context.Background(), authState, code, session.Client{IP: "192.0.2.1"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompleteOIDCLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && result.SessionID == "" {
+				t.Error("CompleteOIDCLogin() returned no session")
+			}
+		})
+	}
+}
+
+func TestCompleteOIDCLoginProvisioning(t *testing.T) {
+	provider := newTestOIDCProvider(t)
+	a := newTestSSOService(t, provider)
+	registerTestUser(t, a, "bob") // holds bob@example.com, not linked
+
+	login := func(claims func(nonce string) map[string]any) (string, error) {
+		t.Helper()
+		authURL, authState, err := a.BeginOIDCLogin(context.Background(), "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := provider.authorize(t, authURL, claims(authState.Nonce))
+		result, err := a.CompleteOIDCLogin(context.Background(), authState, code, session.Client{IP: "192.0.2.1"})
+		if err != nil {
+			return "", err
+		}
+		user, err := a.SessionManager.GetSession(result.SessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.UserID, nil
+	}
+
+	// Steps run in order against the same database
+	var firstUserID string
+	steps := []struct {
+		name    string
+		claims  func(nonce string) map[string]any
+		check   func(t *testing.T, userID string)
+		wantErr error
+	}{
+		{
+			name:   "first login provisions a user",
+			claims: func(nonce string) map[string]any { return provider.claims("alice", nonce) },
+			check: func(t *testing.T, userID string) {
+				user, err := a.AuthRepository.GetUserByID(userID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if user.Username != "alice" || user.Email != "alice@sso.example.com" || user.Name != "SSO alice" {
+					t.Errorf("provisioned user = %+v", user)
+				}
+				firstUserID = userID
+			},
+		},
+		{
+			name: "same issuer and subject signs in the same user, whatever the email",
+			claims: func(nonce string) map[string]any {
+				claims := provider.claims("alice", nonce)
+				claims["email"] = "renamed@sso.example.com"
+				return claims
+			},
+			check: func(t *testing.T, userID string) {
+				if userID != firstUserID {
+					t.Errorf("user = %s, want %s", userID, firstUserID)
+				}
+			},
+		},
+		{
+			name: "new subject gets its own user and a free username",
+			claims: func(nonce string) map[string]any {
+				claims := provider.claims("alice-2", nonce)
+				claims["preferred_username"] = "alice"
+				return claims
+			},
+			check: func(t *testing.T, userID string) {
+				user, err := a.AuthRepository.GetUserByID(userID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if userID == firstUserID || user.Username != "alice2" {
+					t.Errorf("user = %s (%s), want a new user named alice2", userID, user.Username)
+				}
+			},
+		},
+		{
+			name: "email held by a local account is refused without linking",
+			claims: func(nonce string) map[string]any {
+				claims := provider.claims("bob", nonce)
+				claims["email"] = "bob@example.com"
+				return claims
+			},
+			wantErr: utils.ErrUserAlreadyExists,
+		},
+	}
+	for _, step := range steps {
+		userID, err := login(step.claims)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: CompleteOIDCLogin() error = %v, want %v", step.name, err, step.wantErr)
+		}
+		if step.check != nil {
+			t.Run(step.name, func(t *testing.T) { step.check(t, userID) })
+		}
+	}
+}
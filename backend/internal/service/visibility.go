@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// requireVisible returns a room if the user may reach it by its ID: its host
+// always, anyone for public and unlisted rooms, and invitees for private
+// ones. Other private rooms are reported as not found, so their IDs can't be
+// probed.
+func (r *RoomService) requireVisible(roomID, userID string) (*models.Room, error) {
+	room, err := r.RoomRepository.GetRoom(roomID)
+	if err != nil {
+		return nil, err
+	}
+	if room.Visibility != models.RoomVisibilityPrivate || room.HostID == userID {
+		return room, nil
+	}
+
+	invited, err := r.RoomRepository.IsInvited(roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !invited {
+		return nil, utils.ErrRoomNotFound
+	}
+	return room, nil
+}
+
+// CanAccessRoom reports utils.ErrRoomNotFound unless the user may see and
+// join the room, and utils.ErrRoomEnded once it has ended
+func (r *RoomService) CanAccessRoom(roomID, userID string) error {
+	room, err := r.requireVisible(roomID, userID)
+	if err != nil {
+		return err
+	}
+	if room.Status != models.RoomStatusActive {
+		return utils.ErrRoomEnded
+	}
+	return nil
+}
+
+// requireVisibleHost is requireHost for rooms the user may not be able to
+// see, which are reported as not found rather than as someone else's
+func (r *RoomService) requireVisibleHost(roomID, userID string) (*models.Room, error) {
+	room, err := r.requireVisible(roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if room.HostID != userID {
+		return nil, utils.ErrNotRoomHost
+	}
+	return room, nil
+}
+
+// UpdateVisibility changes who may see and join a room. Participants already
+// in it stay.
+func (r *RoomService) UpdateVisibility(ctx context.Context, roomID, visibility, hostID string) error {
+	if !models.IsValidRoomVisibility(visibility) {
+		return utils.ErrInvalidVisibility
+	}
+	room, err := r.requireVisibleHost(roomID, hostID)
+	if err != nil {
+		return err
+	}
+	if room.Visibility == visibility {
+		return nil
+	}
+
+	if err := r.RoomRepository.UpdateVisibility(roomID, visibility); err != nil {
+		return err
+	}
+	r.Audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditRoomSettingsChanged,
+		TargetType: models.AuditTargetRoom,
+		TargetID:   roomID,
+		RoomID:     roomID,
+		Details:    map[string]string{"setting": "visibility", "from": room.Visibility, "to": visibility},
+	})
+	slog.InfoContext(ctx, "Room visibility changed", "room_id", roomID, "visibility", visibility)
+	return nil
+}
+
+// ListInvitations returns who is invited to a room. Host only.
+func (r *RoomService) ListInvitations(roomID, hostID string) ([]models.RoomInvitation, error) {
+	if _, err := r.requireVisibleHost(roomID, hostID); err != nil {
+		return nil, err
+	}
+	return r.RoomRepository.ListInvitations(roomID)
+}
+
+// Invite lets a user, named by username, see and join a private room. Hosts
+// may invite people to rooms of any visibility; invitations only matter once
+// the room is private.
+func (r *RoomService) Invite(ctx context.Context, roomID, hostID, username string) (*models.RoomInvitation, error) {
+	if _, err := r.requireVisibleHost(roomID, hostID); err != nil {
+		return nil, err
+	}
+	user, err := r.AuthRepository.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, utils.ErrUserNotFound
+	}
+
+	invitation := models.RoomInvitation{
+		RoomID:    roomID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Name:      user.Name,
+		InvitedAt: time.Now(),
+	}
+	if err := r.RoomRepository.Invite(invitation); err != nil {
+		return nil, err
+	}
+	r.Audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditParticipantInvited,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID,
+		RoomID:     roomID,
+	})
+	return &invitation, nil
+}
+
+// Uninvite withdraws an invitation. Someone already in the room stays until
+// they leave or are kicked.
+func (r *RoomService) Uninvite(ctx context.Context, roomID, hostID, userID string) error {
+	if _, err := r.requireVisibleHost(roomID, hostID); err != nil {
+		return err
+	}
+	if err := r.RoomRepository.Uninvite(roomID, userID); err != nil {
+		return err
+	}
+	r.Audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditParticipantUninvited,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+		RoomID:     roomID,
+	})
+	return nil
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+// TestVisibilityRules checks who may reach a room by its ID and who sees it
+// listed, for every visibility
+func TestVisibilityRules(t *testing.T) {
+	tests := []struct {
+		visibility string
+		viewer     string // "host", "invitee" or "other"
+		wantReach  error
+		wantListed bool
+	}{
+		{visibility: models.RoomVisibilityPublic, viewer: "host", wantListed: true},
+		{visibility: models.RoomVisibilityPublic, viewer: "invitee", wantListed: true},
+		{visibility: models.RoomVisibilityPublic, viewer: "other", wantListed: true},
+		{visibility: models.RoomVisibilityUnlisted, viewer: "host", wantListed: true},
+		{visibility: models.RoomVisibilityUnlisted, viewer: "invitee"},
+		{visibility: models.RoomVisibilityUnlisted, viewer: "other"},
+		{visibility: models.RoomVisibilityPrivate, viewer: "host", wantListed: true},
+		{visibility: models.RoomVisibilityPrivate, viewer: "invitee", wantListed: true},
+		{visibility: models.RoomVisibilityPrivate, viewer: "other", wantReach: utils.ErrRoomNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.visibility+" as "+tt.viewer, func(t *testing.T) {
+			r := newTestRoomService(t)
+			createTestRoom(t, r, "room", "host", func(room *models.Room) { room.Visibility = tt.visibility })
+			if err := r.RoomRepository.Invite(models.RoomInvitation{RoomID: "room", UserID: "invitee", InvitedAt: time.Now()}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := r.JoinRoom("room", tt.viewer); !errors.Is(err, tt.wantReach) {
+				t.Errorf("JoinRoom() error = %v, want %v", err, tt.wantReach)
+			}
+			if _, err := r.GetRoomStatus("room", tt.viewer); !errors.Is(err, tt.wantReach) {
+				t.Errorf("GetRoomStatus() error = %v, want %v", err, tt.wantReach)
+			}
+
+			page, err := r.ListRooms(models.RoomQuery{ViewerID: tt.viewer})
+			if err != nil {
+				t.Fatal(err)
+			}
+			listed := slices.ContainsFunc(page.Rooms, func(listing models.RoomListing) bool { return listing.ID == "room" })
+			if listed != tt.wantListed {
+				t.Errorf("listed = %v, want %v", listed, tt.wantListed)
+			}
+		})
+	}
+}
+
+func TestUpdateVisibility(t *testing.T) {
+	tests := []struct {
+		name       string
+		from       string
+		to         string
+		userID     string
+		want       error
+		wantStored string
+	}{
+		{name: "host makes it private", from: models.RoomVisibilityPublic, to: models.RoomVisibilityPrivate, userID: "host", wantStored: models.RoomVisibilityPrivate},
+		{name: "host makes it unlisted", from: models.RoomVisibilityPrivate, to: models.RoomVisibilityUnlisted, userID: "host", wantStored: models.RoomVisibilityUnlisted},
+		{name: "unchanged", from: models.RoomVisibilityPublic, to: models.RoomVisibilityPublic, userID: "host", wantStored: models.RoomVisibilityPublic},
+		{name: "unknown visibility", from: models.RoomVisibilityPublic, to: "secret", userID: "host", want: utils.ErrInvalidVisibility, wantStored: models.RoomVisibilityPublic},
+		{name: "not the host", from: models.RoomVisibilityPublic, to: models.RoomVisibilityPrivate, userID: "other", want: utils.ErrNotRoomHost, wantStored: models.RoomVisibilityPublic},
+		{name: "private room of someone else", from: models.RoomVisibilityPrivate, to: models.RoomVisibilityPublic, userID: "other", want: utils.ErrRoomNotFound, wantStored: models.RoomVisibilityPrivate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoomService(t)
+			createTestRoom(t, r, "room", "host", func(room *models.Room) { room.Visibility = tt.from })
+
+			if err := r.UpdateVisibility(context.Background(), "room", tt.to, tt.userID); !errors.Is(err, tt.want) {
+				t.Fatalf("UpdateVisibility() error = %v, want %v", err, tt.want)
+			}
+			room, err := r.RoomRepository.GetRoom("room")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if room.Visibility != tt.wantStored {
+				t.Errorf("visibility = %q, want %q", room.Visibility, tt.wantStored)
+			}
+		})
+	}
+}
+
+// TestInvitations walks a private room through inviting and uninviting a
+// user, checking after each step whether they can reach it
+func TestInvitations(t *testing.T) {
+	database := newTestDB(t)
+	a := newTestAuthService(t, database, LoginLimits{})
+	r := newTestRoomServiceOn(t, database)
+	host := registerTestUser(t, a, "host")
+	guest := registerTestUser(t, a, "guest")
+	createTestRoom(t, r, "room", host.ID, func(room *models.Room) { room.Visibility = models.RoomVisibilityPrivate })
+	ctx := context.Background()
+
+	steps := []struct {
+		name      string
+		apply     func() error
+		want      error
+		wantReach error
+	}{
+		{
+			name:      "before any invitation",
+			apply:     func() error { return nil },
+			wantReach: utils.ErrRoomNotFound,
+		},
+		{
+			name:      "someone else can't invite",
+			apply:     func() error { _, err := r.Invite(ctx, "room", guest.ID, "guest"); return err },
+			want:      utils.ErrRoomNotFound,
+			wantReach: utils.ErrRoomNotFound,
+		},
+		{
+			name:      "unknown username",
+			apply:     func() error { _, err := r.Invite(ctx, "room", host.ID, "nobody"); return err },
+			want:      utils.ErrUserNotFound,
+			wantReach: utils.ErrRoomNotFound,
+		},
+		{
+			name:  "host invites",
+			apply: func() error { _, err := r.Invite(ctx, "room", host.ID, "guest"); return err },
+		},
+		{
+			name:  "inviting twice is fine",
+			apply: func() error { _, err := r.Invite(ctx, "room", host.ID, "guest"); return err },
+		},
+		{
+			name:  "invitee can't uninvite themselves",
+			apply: func() error { return r.Uninvite(ctx, "room", guest.ID, guest.ID) },
+			want:  utils.ErrNotRoomHost,
+		},
+		{
+			name:      "host uninvites",
+			apply:     func() error { return r.Uninvite(ctx, "room", host.ID, guest.ID) },
+			wantReach: utils.ErrRoomNotFound,
+		},
+		{
+			name:      "uninviting twice",
+			apply:     func() error { return r.Uninvite(ctx, "room", host.ID, guest.ID) },
+			want:      utils.ErrInvitationNotFound,
+			wantReach: utils.ErrRoomNotFound,
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if err := step.apply(); !errors.Is(err, step.want) {
+				t.Fatalf("error = %v, want %v", err, step.want)
+			}
+			if err := r.CanAccessRoom("room", guest.ID); !errors.Is(err, step.wantReach) {
+				t.Errorf("CanAccessRoom() error = %v, want %v", err, step.wantReach)
+			}
+		})
+	}
+}
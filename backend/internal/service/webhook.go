@@ -0,0 +1,435 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/utils"
+)
+
+const (
+	maxWebhooksPerUser     = 20
+	maxWebhookURL          = 2048
+	webhookQueueSize       = 1024            // events waiting to be fanned out before new ones are dropped
+	webhookPollInterval    = 5 * time.Second // how often due retries are looked for
+	webhookBatchSize       = 50              // due deliveries picked up per poll
+	webhookConcurrency     = 8               // deliveries in flight at once
+	webhookDeliveryHistory = 100             // deliveries listed per webhook
+	maxWebhookRetryDelay   = 6 * time.Hour
+	webhookUserAgent       = "ChimeCast-Webhook/1"
+)
+
+// WebhookSettings control how deliveries are sent and retried
+type WebhookSettings struct {
+	MaxAttempts  int           // attempts before a delivery is marked failed
+	RetryBase    time.Duration // wait before the first retry; doubles with each further one
+	Timeout      time.Duration // per attempt, including connecting
+	AllowPrivate bool          // permit loopback and private addresses, for local receivers
+}
+
+var errPrivateAddress = errors.New("webhook address is not public")
+
+func NewWebhookService(webhookRepository WebhookRepository, roomRepository RoomRepository, settings WebhookSettings) *WebhookService {
+	return &WebhookService{
+		WebhookRepository: webhookRepository,
+		RoomRepository:    roomRepository,
+		Client:            newWebhookClient(settings),
+		Settings:          settings,
+		events:            make(chan models.RoomEvent, webhookQueueSize),
+		sending:           make(chan struct{}, webhookConcurrency),
+	}
+}
+
+// newWebhookClient returns a client that does not follow redirects and,
+// unless settings allow it, refuses to connect to anything but public
+// addresses, so webhooks can't be pointed at the server's own network
+func newWebhookClient(settings WebhookSettings) *http.Client {
+	dialer := &net.Dialer{Timeout: settings.Timeout}
+	if !settings.AllowPrivate {
+		// Checked on the resolved address, so DNS can't be used to sneak past
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: settings.Timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: settings.Timeout,
+			MaxIdleConnsPerHost: 2,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() && !ip.IsMulticast() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast()
+}
+
+// SignWebhook returns the X-ChimeCast-Signature header for a body sent at
+// timestamp: the timestamp and a hex HMAC-SHA256 of "<timestamp>.<body>"
+// keyed with the webhook's secret
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// CreateWebhook registers an endpoint for every room the user hosts, or for
+// one of them. The signing secret is returned only here.
+func (w *WebhookService) CreateWebhook(userID string, request *models.CreateWebhookRequest) (string, *models.Webhook, error) {
+	target, err := url.Parse(strings.TrimSpace(request.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" || len(target.String()) > maxWebhookURL {
+		return "", nil, errors.New("url must be an absolute http or https URL")
+	}
+
+	var events []string
+	for _, event := range request.Events {
+		if !slices.Contains(models.WebhookEvents, event) {
+			return "", nil, fmt.Errorf("unknown event %q; must be one of %s", event, strings.Join(models.WebhookEvents, ", "))
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		return "", nil, errors.New("at least one event is required")
+	}
+
+	if request.RoomID != "" {
+		room, err := w.RoomRepository.GetRoom(request.RoomID)
+		if err != nil {
+			return "", nil, err
+		}
+		if room.HostID != userID {
+			return "", nil, utils.ErrNotRoomHost
+		}
+	}
+
+	existing, err := w.WebhookRepository.ListWebhooks(userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(existing) >= maxWebhooksPerUser {
+		return "", nil, fmt.Errorf("at most %d webhooks are allowed", maxWebhooksPerUser)
+	}
+
+	secret, err := utils.NewToken()
+	if err != nil {
+		return "", nil, err
+	}
+	webhook := models.Webhook{
+		ID:        utils.CreateNewUUID(),
+		UserID:    userID,
+		RoomID:    request.RoomID,
+		URL:       target.String(),
+		Secret:    secret,
+		Events:    events,
+		CreatedAt: time.Now(),
+	}
+	if err := w.WebhookRepository.CreateWebhook(webhook); err != nil {
+		return "", nil, err
+	}
+	return secret, &webhook, nil
+}
+
+// ListWebhooks returns the user's webhooks without their secrets
+func (w *WebhookService) ListWebhooks(userID string) ([]models.Webhook, error) {
+	return w.WebhookRepository.ListWebhooks(userID)
+}
+
+// DeleteWebhook removes one of the user's webhooks and its delivery log
+func (w *WebhookService) DeleteWebhook(userID, webhookID string) error {
+	return w.WebhookRepository.DeleteWebhook(userID, webhookID)
+}
+
+// ListDeliveries returns the most recent deliveries to one of the user's
+// webhooks, newest first
+func (w *WebhookService) ListDeliveries(userID, webhookID string) ([]models.WebhookDelivery, error) {
+	if _, err := w.ownWebhook(userID, webhookID); err != nil {
+		return nil, err
+	}
+	return w.WebhookRepository.ListDeliveries(webhookID, webhookDeliveryHistory)
+}
+
+// Redeliver queues an earlier delivery to be sent again as a new delivery
+// with the same event ID and payload, so receivers can de-duplicate it
+func (w *WebhookService) Redeliver(userID, webhookID, deliveryID string) (*models.WebhookDelivery, error) {
+	if _, err := w.ownWebhook(userID, webhookID); err != nil {
+		return nil, err
+	}
+	original, err := w.WebhookRepository.GetDelivery(webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	delivery := models.WebhookDelivery{
+		ID:            utils.CreateNewUUID(),
+		WebhookID:     webhookID,
+		EventID:       original.EventID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        models.DeliveryPending,
+		CreatedAt:     now,
+		NextAttemptAt: &now,
+	}
+	if err := w.WebhookRepository.CreateDeliveries([]models.WebhookDelivery{delivery}); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ownWebhook returns a webhook if it belongs to the user; anyone else's
+// is reported as not found
+func (w *WebhookService) ownWebhook(userID, webhookID string) (*models.Webhook, error) {
+	webhook, err := w.WebhookRepository.GetWebhook(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.UserID != userID {
+		return nil, utils.ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// Publish hands an event to Run for delivery. It never blocks the room
+// operation that raised it: if the queue is full the event is dropped and
+// logged.
+func (w *WebhookService) Publish(event models.RoomEvent) {
+	if w == nil {
+		return
+	}
+	if event.ID == "" {
+		event.ID = utils.CreateNewUUID()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	select {
+	case w.events <- event:
+	default:
+		slog.Warn("Webhook queue full, dropping event", "event", event.Type, "room_id", event.RoomID)
+	}
+}
+
+// Run queues deliveries for published events and sends those that are
+// due, until ctx is cancelled. Deliveries live in the database, so retries
+// survive a restart and any instance may send them; events still waiting
+// when ctx is cancelled are queued before Run returns.
+func (w *WebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.Flush()
+			return
+		case event := <-w.events:
+			w.queueDeliveries(event)
+			w.sendDue(ctx)
+		case <-ticker.C:
+			w.sendDue(ctx)
+		}
+	}
+}
+
+// Flush queues deliveries for every event published so far without sending
+// them; they go out when some node's Run next finds them due
+func (w *WebhookService) Flush() {
+	for {
+		select {
+		case event := <-w.events:
+			w.queueDeliveries(event)
+		default:
+			return
+		}
+	}
+}
+
+// queueDeliveries records a pending delivery of the event to each webhook
+// listening to its room
+func (w *WebhookService) queueDeliveries(event models.RoomEvent) {
+	webhooks, err := w.WebhookRepository.RoomWebhooks(event.RoomID)
+	if err != nil {
+		slog.Error("Error finding webhooks", "event", event.Type, "room_id", event.RoomID, "error", err)
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Error encoding webhook event", "event", event.Type, "error", err)
+		return
+	}
+
+	now := time.Now()
+	var deliveries []models.WebhookDelivery
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(event.Type) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:            utils.CreateNewUUID(),
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			Event:         event.Type,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			CreatedAt:     now,
+			NextAttemptAt: &now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+	if err := w.WebhookRepository.CreateDeliveries(deliveries); err != nil {
+		slog.Error("Error queueing webhook deliveries", "event", event.Type, "room_id", event.RoomID, "error", err)
+	}
+}
+
+// sendDue claims the deliveries whose next attempt is due and sends them in
+// the background, a few at a time
+func (w *WebhookService) sendDue(ctx context.Context) {
+	now := time.Now()
+	due, err := w.WebhookRepository.DueDeliveries(now, webhookBatchSize)
+	if err != nil {
+		slog.Error("Error finding due webhook deliveries", "error", err)
+		return
+	}
+
+	for _, delivery := range due {
+		// The claim holds the delivery for longer than an attempt can take;
+		// if this instance dies mid-attempt it becomes due again after that
+		claimed, err := w.WebhookRepository.ClaimDelivery(delivery.ID, now, now.Add(2*w.Settings.Timeout))
+		if err != nil {
+			slog.Error("Error claiming webhook delivery", "delivery_id", delivery.ID, "error", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		select {
+		case w.sending <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		go func(delivery models.WebhookDelivery) {
+			defer func() { <-w.sending }()
+			w.attempt(ctx, delivery)
+		}(delivery)
+	}
+}
+
+// attempt sends a delivery once and records the outcome, scheduling a retry
+// with exponential backoff until the attempts run out
+func (w *WebhookService) attempt(ctx context.Context, delivery models.WebhookDelivery) {
+	webhook, err := w.WebhookRepository.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		if !errors.Is(err, utils.ErrWebhookNotFound) {
+			slog.Error("Error loading webhook", "webhook_id", delivery.WebhookID, "error", err)
+		}
+		return
+	}
+
+	status, err := w.send(ctx, webhook, delivery)
+	if ctx.Err() != nil {
+		// Shutting down; the claim lapses and the attempt is made again
+		return
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	delivery.LastError = ""
+	delivery.NextAttemptAt = nil
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliverySucceeded
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= w.Settings.MaxAttempts:
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		next := now.Add(w.retryDelay(delivery.Attempts))
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = &next
+	}
+
+	if err := w.WebhookRepository.RecordDeliveryAttempt(delivery); err != nil {
+		slog.Error("Error recording webhook delivery", "delivery_id", delivery.ID, "error", err)
+		return
+	}
+	slog.Info("Webhook delivery attempted", "webhook_id", webhook.ID, "delivery_id", delivery.ID, "event", delivery.Event,
+		"attempt", delivery.Attempts, "status", delivery.Status, "response_status", status, "error", delivery.LastError)
+}
+
+// send POSTs a delivery's payload, signed with the webhook's secret. Anything
+// but a 2xx answer is an error.
+func (w *WebhookService) send(ctx context.Context, webhook *models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", webhookUserAgent)
+	request.Header.Set("X-ChimeCast-Event", delivery.Event)
+	request.Header.Set("X-ChimeCast-Delivery", delivery.ID)
+	request.Header.Set("X-ChimeCast-Signature", SignWebhook(webhook.Secret, time.Now(), body))
+
+	response, err := w.Client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	// Drained so the connection can be reused; the body itself is ignored
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("endpoint answered %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+// retryDelay is how long to wait after the given number of failed attempts:
+// RetryBase, doubling each time, up to maxWebhookRetryDelay
+func (w *WebhookService) retryDelay(attempts int) time.Duration {
+	delay := w.Settings.RetryBase
+	for i := 1; i < attempts && delay < maxWebhookRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxWebhookRetryDelay)
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/legendary-acp/chimecast/internal/models"
+	"github.com/legendary-acp/chimecast/internal/repositories"
+)
+
+const testWebhookSecret = "secret"
+
+// verifyWebhookSignature checks an X-ChimeCast-Signature header the way the
+// README tells receivers to
+func verifyWebhookSignature(secret, header string, body []byte) bool {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil || signature == "" {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"event":"room.created"}`)
+	timestamp := time.Unix(1714564800, 0)
+	header := SignWebhook(testWebhookSecret, timestamp, body)
+	if !strings.HasPrefix(header, "t=1714564800,v1=") {
+		t.Fatalf("SignWebhook() = %q, want t=1714564800,v1=...", header)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		want   bool
+	}{
+		{name: "valid", secret: testWebhookSecret, header: header, body: body, want: true},
+		{name: "wrong secret", secret: "other", header: header, body: body},
+		{name: "tampered body", secret: testWebhookSecret, header: header, body: []byte(`{"event":"room.ended"}`)},
+		{name: "tampered timestamp", secret: testWebhookSecret, header: strings.Replace(header, "t=1714564800", "t=1714564801", 1), body: body},
+		{name: "missing signature", secret: testWebhookSecret, header: "t=1714564800", body: body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyWebhookSignature(tt.secret, tt.header, tt.body); got != tt.want {
+				t.Errorf("verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	w := &WebhookService{Settings: WebhookSettings{RetryBase: 30 * time.Second}}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 3, want: 2 * time.Minute},
+		{attempts: 10, want: 256 * time.Minute},
+		{attempts: 11, want: maxWebhookRetryDelay},
+		{attempts: 100, want: maxWebhookRetryDelay},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempts), func(t *testing.T) {
+			if got := w.retryDelay(tt.attempts); got != tt.want {
+				t.Errorf("retryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "93.184.216.34", want: true},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{ip: "127.0.0.1"},
+		{ip: "::1"},
+		{ip: "10.0.0.1"},
+		{ip: "172.16.0.1"},
+		{ip: "192.168.1.1"},
+		{ip: "169.254.169.254"},
+		{ip: "fd00::1"},
+		{ip: "fe80::1"},
+		{ip: "0.0.0.0"},
+		{ip: "224.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("isPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+// testReceiver is a local webhook endpoint that answers with the given
+// statuses in turn, then 200, and keeps what it was sent
+type testReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedWebhook
+}
+
+type receivedWebhook struct {
+	header      http.Header
+	body        []byte
+	signatureOK bool
+}
+
+func newTestReceiver(t *testing.T, statuses ...int) *testReceiver {
+	t.Helper()
+	receiver := &testReceiver{statuses: statuses}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		status := http.StatusOK
+		if len(receiver.statuses) > 0 {
+			status, receiver.statuses = receiver.statuses[0], receiver.statuses[1:]
+		}
+		receiver.requests = append(receiver.requests, receivedWebhook{
+			header:      r.Header.Clone(),
+			body:        body,
+			signatureOK: verifyWebhookSignature(testWebhookSecret, r.Header.Get("X-ChimeCast-Signature"), body),
+		})
+		rw.WriteHeader(status)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func (r *testReceiver) received() []receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedWebhook(nil), r.requests...)
+}
+
+// newTestWebhookService builds a WebhookService on a database holding alice,
+// her room "standup" and a webhook to url for every event in her rooms
+func newTestWebhookService(t *testing.T, url string, settings WebhookSettings) *WebhookService {
+	t.Helper()
+	database := newTestDB(t)
+	err := repositories.NewAuthRepository(database).RegisterUser(models.User{ID: "id-alice", Username: "alice", Email: "alice@example.com", CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	room := models.Room{ID: "standup", Name: "Standup", HostID: "id-alice", CreatedAt: time.Now(), Status: models.RoomStatusActive,
+		ScreenSharePolicy: models.ScreenSharePolicyAnyone, Visibility: models.RoomVisibilityPublic}
+	if err := repositories.NewRoomRepository(database).CreateRoom(&room); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWebhookService(repositories.NewWebhookRepository(database), repositories.NewRoomRepository(database), settings)
+	webhook := models.Webhook{ID: "hook", UserID: "id-alice", URL: url, Secret: testWebhookSecret, Events: models.WebhookEvents, CreatedAt: time.Now()}
+	if err := w.WebhookRepository.CreateWebhook(webhook); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// queueTestDelivery queues a room.ended delivery to the test webhook
+func queueTestDelivery(t *testing.T, w *WebhookService) models.WebhookDelivery {
+	t.Helper()
+	w.queueDeliveries(models.RoomEvent{ID: "event", Type: models.EventRoomEnded, RoomID: "standup", CreatedAt: time.Now()})
+	deliveries, err := w.WebhookRepository.ListDeliveries("hook", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("queued %d deliveries, want 1", len(deliveries))
+	}
+	return deliveries[0]
+}
+
+// TestWebhookRetries sends a delivery until it succeeds or runs out of
+// attempts, checking each retry is scheduled with exponential backoff
+func TestWebhookRetries(t *testing.T) {
+	const retryBase = time.Minute
+	tests := []struct {
+		name         string
+		statuses     []int // answered before the receiver starts saying 200
+		maxAttempts  int
+		wantStatus   string
+		wantAttempts int
+		wantLast     int // response status of the last attempt
+	}{
+		{name: "first attempt succeeds", maxAttempts: 3, wantStatus: models.DeliverySucceeded, wantAttempts: 1, wantLast: http.StatusOK},
+		{name: "succeeds on a retry", statuses: []int{500, 503}, maxAttempts: 3, wantStatus: models.DeliverySucceeded, wantAttempts: 3, wantLast: http.StatusOK},
+		{name: "redirects are failures", statuses: []int{302}, maxAttempts: 1, wantStatus: models.DeliveryFailed, wantAttempts: 1, wantLast: http.StatusFound},
+		{name: "gives up after max attempts", statuses: []int{500, 500, 500, 500}, maxAttempts: 3, wantStatus: models.DeliveryFailed, wantAttempts: 3, wantLast: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newTestReceiver(t, tt.statuses...)
+			w := newTestWebhookService(t, receiver.URL, WebhookSettings{MaxAttempts: tt.maxAttempts, RetryBase: retryBase, Timeout: 5 * time.Second, AllowPrivate: true})
+			delivery := queueTestDelivery(t, w)
+
+			for delivery.Status == models.DeliveryPending {
+				before := time.Now()
+				w.attempt(context.Background(), delivery)
+				updated, err := w.WebhookRepository.GetDelivery("hook", delivery.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if updated.Attempts != delivery.Attempts+1 {
+					t.Fatalf("attempts = %d after attempt %d", updated.Attempts, delivery.Attempts+1)
+				}
+				delivery = *updated
+
+				if delivery.Status != models.DeliveryPending {
+					if delivery.NextAttemptAt != nil {
+						t.Errorf("NextAttemptAt = %v once %s, want none", delivery.NextAttemptAt, delivery.Status)
+					}
+					break
+				}
+				// Each retry waits twice as long as the one before
+				wait := retryBase << (delivery.Attempts - 1)
+				if delivery.NextAttemptAt == nil || delivery.NextAttemptAt.Before(before.Add(wait)) || delivery.NextAttemptAt.After(time.Now().Add(wait)) {
+					t.Errorf("after attempt %d NextAttemptAt = %v, want %v from now", delivery.Attempts, delivery.NextAttemptAt, wait)
+				}
+			}
+
+			if delivery.Status != tt.wantStatus || delivery.Attempts != tt.wantAttempts || delivery.ResponseStatus != tt.wantLast {
+				t.Errorf("delivery %s after %d attempts answered %d, want %s after %d answered %d",
+					delivery.Status, delivery.Attempts, delivery.ResponseStatus, tt.wantStatus, tt.wantAttempts, tt.wantLast)
+			}
+			if tt.wantStatus == models.DeliverySucceeded && delivery.DeliveredAt == nil {
+				t.Error("DeliveredAt not set")
+			}
+			if tt.wantStatus == models.DeliveryFailed && delivery.LastError == "" {
+				t.Error("LastError not set")
+			}
+			if got := len(receiver.received()); got != tt.wantAttempts {
+				t.Errorf("receiver got %d requests, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+// TestWebhookPrivateAddresses checks webhooks can't reach loopback
+// addresses unless AllowPrivate is set
+func TestWebhookPrivateAddresses(t *testing.T) {
+	tests := []struct {
+		name         string
+		allowPrivate bool
+		wantStatus   string
+	}{
+		{name: "refused", wantStatus: models.DeliveryFailed},
+		{name: "allowed", allowPrivate: true, wantStatus: models.DeliverySucceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newTestReceiver(t)
+			w := newTestWebhookService(t, receiver.URL, WebhookSettings{MaxAttempts: 1, RetryBase: time.Minute, Timeout: 5 * time.Second, AllowPrivate: tt.allowPrivate})
+			delivery := queueTestDelivery(t, w)
+
+			w.attempt(context.Background(), delivery)
+			updated, err := w.WebhookRepository.GetDelivery("hook", delivery.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated.Status != tt.wantStatus {
+				t.Fatalf("status = %s (%s), want %s", updated.Status, updated.LastError, tt.wantStatus)
+			}
+			if tt.allowPrivate {
+				return
+			}
+			if !strings.Contains(updated.LastError, errPrivateAddress.Error()) {
+				t.Errorf("LastError = %q, want it to mention %q", updated.LastError, errPrivateAddress)
+			}
+			if got := len(receiver.received()); got != 0 {
+				t.Errorf("receiver got %d requests, want none", got)
+			}
+		})
+	}
+}
+
+// TestWebhookRun publishes an event and checks the receiver gets it, signed,
+// through the queue Run works from
+func TestWebhookRun(t *testing.T) {
+	receiver := newTestReceiver(t)
+	w := newTestWebhookService(t, receiver.URL, WebhookSettings{MaxAttempts: 3, RetryBase: time.Minute, Timeout: 5 * time.Second, AllowPrivate: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	w.Publish(models.RoomEvent{Type: models.EventParticipantJoined, RoomID: "standup", Data: map[string]string{"userId": "id-bob"}})
+	waitFor(t, func() bool { return len(receiver.received()) == 1 })
+
+	request := receiver.received()[0]
+	if !request.signatureOK {
+		t.Errorf("signature %q does not verify", request.header.Get("X-ChimeCast-Signature"))
+	}
+	var event models.RoomEvent
+	if err := json.Unmarshal(request.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != models.EventParticipantJoined || event.RoomID != "standup" || event.ID == "" || event.Data["userId"] != "id-bob" {
+		t.Errorf("event = %+v", event)
+	}
+	headers := map[string]string{
+		"Content-Type":      "application/json",
+		"User-Agent":        webhookUserAgent,
+		"X-ChimeCast-Event": models.EventParticipantJoined,
+	}
+	for name, want := range headers {
+		if got := request.header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if request.header.Get("X-ChimeCast-Delivery") == "" {
+		t.Error("X-ChimeCast-Delivery missing")
+	}
+}
@@ -1,59 +1,216 @@
 package session
 
 import (
+	"context"
 	"errors"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var ErrSessionNotFound = errors.New("session not found")
+
+// Client describes the device a session was started from
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
 type Session struct {
-	UserName  string
-	UserID    string // Added UserID field
-	ExpiresAt time.Time
+	ID         string    `json:"id"` // Public handle for listing and revoking; the cookie value stays secret
+	UserName   string    `json:"-"`
+	UserID     string    `json:"-"` // Added UserID field
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	CSRFToken  string    `json:"-"` // Must accompany state-changing requests made with the cookie
 }
 
 type SessionManager struct {
 	sessions map[string]*Session
+	ttl      time.Duration
 	mu       sync.RWMutex
+	onRevoke func(sessionIDs []string) // told the public IDs of ended sessions
 }
 
-func NewSessionManager() *SessionManager {
+func NewSessionManager(ttl time.Duration) *SessionManager {
 	return &SessionManager{
 		sessions: make(map[string]*Session),
+		ttl:      ttl,
 	}
 }
 
-// CreateSession now takes both userName and userID
-func (sm *SessionManager) CreateSession(userName string, userID string) (string, error) {
+// TTL is how long a session lasts after it is created
+func (sm *SessionManager) TTL() time.Duration {
+	return sm.ttl
+}
+
+// OnRevoke registers a callback run with the public IDs of sessions that
+// were logged out or revoked, so anything they opened can be shut down
+func (sm *SessionManager) OnRevoke(fn func(sessionIDs []string)) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.onRevoke = fn
+}
+
+// CreateSession starts a session for a user on the given client
+func (sm *SessionManager) CreateSession(userName string, userID string, client Client) (string, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	now := time.Now()
 	sessionID := uuid.NewString()
 	sm.sessions[sessionID] = &Session{
-		UserName:  userName,
-		UserID:    userID,
-		ExpiresAt: time.Now().Add(24 * time.Hour),
+		ID:         uuid.NewString(),
+		UserName:   userName,
+		UserID:     userID,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(sm.ttl),
+		CSRFToken:  uuid.NewString(),
 	}
 	return sessionID, nil
 }
 
-// GetSession remains the same
+// GetSession returns a copy of a live session and marks it as seen
 func (sm *SessionManager) GetSession(sessionID string) (*Session, error) {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
 	session, exists := sm.sessions[sessionID]
-	if !exists || time.Now().After(session.ExpiresAt) {
+	now := time.Now()
+	if !exists {
+		return nil, errors.New("invalid or expired session")
+	}
+	if now.After(session.ExpiresAt) {
+		delete(sm.sessions, sessionID)
 		return nil, errors.New("invalid or expired session")
 	}
-	return session, nil
+	session.LastSeenAt = now
+	copied := *session
+	return &copied, nil
 }
 
 // DeleteSession remains the same
 func (sm *SessionManager) DeleteSession(sessionID string) {
+	sm.revoke(func(key string, session *Session) bool {
+		return key == sessionID
+	})
+}
+
+// ActiveSessions counts sessions that have not yet expired
+func (sm *SessionManager) ActiveSessions() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	now := time.Now()
+	count := 0
+	for _, session := range sm.sessions {
+		if now.Before(session.ExpiresAt) {
+			count++
+		}
+	}
+	return count
+}
+
+// UserSessions lists a user's live sessions, most recently used first
+func (sm *SessionManager) UserSessions(userID string) []Session {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	now := time.Now()
+	sessions := []Session{}
+	for _, session := range sm.sessions {
+		if session.UserID == userID && now.Before(session.ExpiresAt) {
+			sessions = append(sessions, *session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions
+}
+
+// RevokeSession ends one of a user's sessions by its public ID
+func (sm *SessionManager) RevokeSession(userID, id string) error {
+	revoked := sm.revoke(func(key string, session *Session) bool {
+		return session.UserID == userID && session.ID == id
+	})
+	if revoked == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions ends every session of a user except the one with the
+// given public ID, returning how many were ended
+func (sm *SessionManager) RevokeOtherSessions(userID, keepID string) int {
+	return sm.revoke(func(key string, session *Session) bool {
+		return session.UserID == userID && session.ID != keepID
+	})
+}
+
+// DeleteUserSessions ends every session belonging to a user
+func (sm *SessionManager) DeleteUserSessions(userID string) {
+	sm.revoke(func(key string, session *Session) bool {
+		return session.UserID == userID
+	})
+}
+
+// DeleteExpired forgets sessions that expired before now and returns how many
+// there were. They aren't revoked: sockets they opened stay up.
+func (sm *SessionManager) DeleteExpired(now time.Time) int {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	delete(sm.sessions, sessionID)
+
+	count := 0
+	for key, session := range sm.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(sm.sessions, key)
+			count++
+		}
+	}
+	return count
+}
+
+// Sweep deletes expired sessions every interval until ctx is cancelled, so
+// sessions that are never used again don't stay in memory
+func (sm *SessionManager) Sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sm.DeleteExpired(now)
+		}
+	}
+}
+
+// revoke deletes the matching sessions and tells the OnRevoke callback,
+// outside the lock
+func (sm *SessionManager) revoke(match func(key string, session *Session) bool) int {
+	sm.mu.Lock()
+	var ids []string
+	for key, session := range sm.sessions {
+		if match(key, session) {
+			ids = append(ids, session.ID)
+			delete(sm.sessions, key)
+		}
+	}
+	onRevoke := sm.onRevoke
+	sm.mu.Unlock()
+
+	if len(ids) > 0 && onRevoke != nil {
+		onRevoke(ids)
+	}
+	return len(ids)
 }
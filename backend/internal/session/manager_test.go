@@ -0,0 +1,124 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// expire moves a session's expiry relative to now
+func expire(t *testing.T, sm *SessionManager, sessionID string, in time.Duration) {
+	t.Helper()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.sessions[sessionID].ExpiresAt = time.Now().Add(in)
+}
+
+func newSessions(t *testing.T, sm *SessionManager, expiries ...time.Duration) []string {
+	t.Helper()
+	ids := make([]string, len(expiries))
+	for i, in := range expiries {
+		id, err := sm.CreateSession("alice", "alice-id", Client{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expire(t, sm, id, in)
+		ids[i] = id
+	}
+	return ids
+}
+
+func TestGetSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiresIn  time.Duration
+		wantErr    bool
+		wantStored bool
+	}{
+		{name: "live session", expiresIn: time.Hour, wantStored: true},
+		{name: "expired session is deleted", expiresIn: -time.Second, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewSessionManager(time.Hour)
+			id := newSessions(t, sm, tt.expiresIn)[0]
+
+			if _, err := sm.GetSession(id); (err != nil) != tt.wantErr {
+				t.Fatalf("GetSession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			sm.mu.RLock()
+			_, stored := sm.sessions[id]
+			sm.mu.RUnlock()
+			if stored != tt.wantStored {
+				t.Errorf("stored = %v, want %v", stored, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	tests := []struct {
+		name        string
+		expiries    []time.Duration
+		wantDeleted int
+	}{
+		{name: "none", expiries: nil},
+		{name: "all live", expiries: []time.Duration{time.Minute, time.Hour}},
+		{name: "mixed", expiries: []time.Duration{-time.Minute, time.Hour, -time.Second}, wantDeleted: 2},
+		{name: "all expired", expiries: []time.Duration{-time.Hour, -time.Second}, wantDeleted: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewSessionManager(time.Hour)
+			revoked := 0
+			sm.OnRevoke(func(ids []string) { revoked += len(ids) })
+			newSessions(t, sm, tt.expiries...)
+
+			if got := sm.DeleteExpired(time.Now()); got != tt.wantDeleted {
+				t.Errorf("DeleteExpired() = %d, want %d", got, tt.wantDeleted)
+			}
+			sm.mu.RLock()
+			remaining := len(sm.sessions)
+			sm.mu.RUnlock()
+			if want := len(tt.expiries) - tt.wantDeleted; remaining != want {
+				t.Errorf("%d sessions remain, want %d", remaining, want)
+			}
+			if revoked != 0 {
+				t.Errorf("expiry revoked %d sessions, want none", revoked)
+			}
+		})
+	}
+}
+
+func TestSweep(t *testing.T) {
+	sm := NewSessionManager(time.Hour)
+	ids := newSessions(t, sm, -time.Second, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sm.Sweep(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		sm.mu.RLock()
+		_, expired := sm.sessions[ids[0]]
+		_, live := sm.sessions[ids[1]]
+		sm.mu.RUnlock()
+		if !expired {
+			if !live {
+				t.Error("sweep deleted a live session")
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sweep didn't delete the expired session")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
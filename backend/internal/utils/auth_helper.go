@@ -1,7 +1,12 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"net/mail"
 
 	"github.com/legendary-acp/chimecast/internal/models"
 )
@@ -13,6 +18,9 @@ func ValidateUserRegistrationRequest(request *models.RegisterRequest) error {
 	if request.Email == "" {
 		return errors.New("email cannot be empty")
 	}
+	if _, err := mail.ParseAddress(request.Email); err != nil {
+		return errors.New("email is not a valid address")
+	}
 	if request.Password == "" {
 		return errors.New("password cannot be empty")
 	}
@@ -21,3 +29,18 @@ func ValidateUserRegistrationRequest(request *models.RegisterRequest) error {
 	}
 	return nil
 }
+
+// NewToken returns a random, URL-safe secret with 256 bits of entropy
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken is how tokens are stored, so a leaked table can't be replayed
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
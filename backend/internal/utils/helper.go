@@ -3,13 +3,37 @@ package utils
 import (
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 
 	"github.com/google/uuid"
 )
 
 var ErrUserAlreadyExists = errors.New("user already exists")
+var ErrInvalidScreenSharePolicy = errors.New("invalid screen share policy")
+var ErrServerDraining = errors.New("server is shutting down, please reconnect")
+var ErrTooManyLoginAttempts = errors.New("too many login attempts")
+var ErrInvalidToken = errors.New("invalid or expired token")
+var ErrUserNotFound = errors.New("user not found")
+var ErrInvalidMFACode = errors.New("invalid two-factor code")
+var ErrAPITokenNotFound = errors.New("API token not found")
+var ErrInvalidImage = errors.New("invalid image")
+var ErrIncorrectPassword = errors.New("current password is incorrect")
+var ErrRoomEnded = errors.New("room has ended")
+var ErrRoomNotFound = errors.New("room not found")
+var ErrConfirmationMismatch = errors.New("confirmation does not match your username")
+var ErrMeetingNotFound = errors.New("meeting not found")
+var ErrNotRoomHost = errors.New("only the host can do that")
+var ErrWebhookNotFound = errors.New("webhook not found")
+var ErrDeliveryNotFound = errors.New("webhook delivery not found")
+var ErrAccountDisabled = errors.New("account is disabled")
+var ErrSessionRevoked = errors.New("session has been revoked")
+var ErrDisableSelf = errors.New("you can't disable your own account")
+var ErrInvalidAnnouncement = errors.New("announcement must be between 1 and 500 characters")
+var ErrInvalidCursor = errors.New("invalid cursor")
+var ErrInvalidVisibility = errors.New("visibility must be private, unlisted or public")
+var ErrInvitationNotFound = errors.New("invitation not found")
 
 type ErrorResponse struct {
 	Error string `json:"error"`
@@ -44,7 +68,7 @@ func SendJSONError(w http.ResponseWriter, statusCode int, errMsg string) {
 	// Marshal the error response struct to JSON
 	jsonData, err := json.Marshal(errResp)
 	if err != nil {
-		log.Printf("Failed to marshal error response to JSON: %s", err.Error())
+		slog.Error("Failed to marshal error response to JSON", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
@@ -59,6 +83,15 @@ func SendJSONError(w http.ResponseWriter, statusCode int, errMsg string) {
 	}
 }
 
+// ClientIP returns the address a request came from, without the port
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func CreateNewUUID() string {
 	return uuid.NewString()
 }
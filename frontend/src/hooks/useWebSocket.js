@@ -1,5 +1,8 @@
 import { useEffect, useRef, useState } from "react";
 
+// Signaling protocol spoken with the server; messages are {type, payload}
+const SIGNALING_PROTOCOL = "chimecast.v1";
+
 const configuration = {
   iceServers: [
     { urls: "stun:stun.l.google.com:19302" }, // STUN server
@@ -18,7 +21,7 @@ const useWebRTC = (isVideoOn, isMicOn, roomID) => {
     const signalingServerUrl = `${
       import.meta.env.VITE_WS_URI
     }/api/room/v1/${roomID}/ws`;
-    socket.current = new WebSocket(signalingServerUrl);
+    socket.current = new WebSocket(signalingServerUrl, SIGNALING_PROTOCOL);
 
     socket.current.onopen = () => {
       console.log("Connected to signaling server");
@@ -64,7 +67,7 @@ const useWebRTC = (isVideoOn, isMicOn, roomID) => {
           socket.current.send(
             JSON.stringify({
               type: "ice-candidate",
-              candidate: event.candidate,
+              payload: event.candidate.toJSON(),
             })
           );
         }
@@ -80,7 +83,12 @@ const useWebRTC = (isVideoOn, isMicOn, roomID) => {
       // Create offer, set local description, and send it via signaling
       const offer = await pc.createOffer();
       await pc.setLocalDescription(offer);
-      socket.current.send(JSON.stringify({ type: "offer", offer }));
+      socket.current.send(
+        JSON.stringify({
+          type: "offer",
+          payload: { type: offer.type, sdp: offer.sdp },
+        })
+      );
     } catch (error) {
       console.log("Error while initiating call", error);
     }
@@ -89,7 +97,7 @@ const useWebRTC = (isVideoOn, isMicOn, roomID) => {
   const handleSignalingData = (data) => {
     switch (data.type) {
       case "offer": {
-        const offer = new RTCSessionDescription(data.offer);
+        const offer = new RTCSessionDescription(data.payload);
         if (peerConnection.signalingState === "stable") {
           // Only set remote description if stable
           peerConnection
@@ -101,11 +109,9 @@ const useWebRTC = (isVideoOn, isMicOn, roomID) => {
               return peerConnection.setLocalDescription(answer);
             })
             .then(() => {
+              const { type, sdp } = peerConnection.localDescription;
               socket.current.send(
-                JSON.stringify({
-                  type: "answer",
-                  answer: peerConnection.localDescription,
-                })
+                JSON.stringify({ type: "answer", payload: { type, sdp } })
               );
             })
             .catch((error) => {
@@ -119,7 +125,7 @@ const useWebRTC = (isVideoOn, isMicOn, roomID) => {
         break;
       }
       case "answer": {
-        const answer = new RTCSessionDescription(data.answer);
+        const answer = new RTCSessionDescription(data.payload);
         if (peerConnection.signalingState === "have-local-offer") {
           // Check state before setting answer
           peerConnection.setRemoteDescription(answer).catch((error) => {
@@ -133,7 +139,7 @@ const useWebRTC = (isVideoOn, isMicOn, roomID) => {
         break;
       }
       case "ice-candidate": {
-        const candidate = new RTCIceCandidate(data.candidate);
+        const candidate = new RTCIceCandidate(data.payload);
         if (peerConnection) {
           peerConnection.addIceCandidate(candidate).catch((error) => {
             console.error("Error adding received ice candidate:", error);
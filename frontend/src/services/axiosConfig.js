@@ -8,9 +8,18 @@ const axiosInstance = axios.create({
   },
 });
 
+// The backend hands out a CSRF token with the session (on login, register and
+// validate); state-changing requests must echo it back
+const CSRF_TOKEN_KEY = 'csrfToken';
+const SAFE_METHODS = ['get', 'head', 'options'];
+
 // Log requests for debugging purposes
 axiosInstance.interceptors.request.use(
   (config) => {
+    const csrfToken = sessionStorage.getItem(CSRF_TOKEN_KEY);
+    if (csrfToken && !SAFE_METHODS.includes((config.method || 'get').toLowerCase())) {
+      config.headers['X-CSRF-Token'] = csrfToken;
+    }
     console.log('Request:', {
       url: config.url,
       method: config.method,
@@ -23,7 +32,12 @@ axiosInstance.interceptors.request.use(
 
 // Handle response errors with improved logging
 axiosInstance.interceptors.response.use(
-  (response) => response,
+  (response) => {
+    if (response.data?.csrfToken) {
+      sessionStorage.setItem(CSRF_TOKEN_KEY, response.data.csrfToken);
+    }
+    return response;
+  },
   (error) => {
     if (error.code === 'ERR_NETWORK') {
       console.error('Network Error: Ensure the backend is running and CORS is configured correctly.');
@@ -3,14 +3,14 @@ import axios from './axiosConfig';
 // Set up the base URL for Axios
 const API_URL = import.meta.env.VITE_BACKEND_URI + '/api/room/v1';
 
-// Get all rooms
-export const fetchRooms = async () => {
+// Get the first page of rooms, active ones first
+export const fetchRooms = async (params = {}) => {
   try {
-    const response = await axios.get(`${API_URL}/`);
-    if (response.data == null) {
+    const response = await axios.get(`${API_URL}/`, { params });
+    if (response.data?.rooms == null) {
       return []
     }
-    return response.data;
+    return response.data.rooms;
   } catch (error) {
     throw error.response?.data || error.message;
   }